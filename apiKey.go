@@ -0,0 +1,81 @@
+package togglr
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/togglr-io/togglr/uid"
+)
+
+// the number of random bytes in the secret of an APIKey, and how many characters of it are kept as its Prefix
+const (
+	apiKeySecretSize = 32
+	apiKeyPrefixSize = 8
+)
+
+// ValidAPIKeyKind returns true if the kind is one of the enumerated APIKeyKinds
+func ValidAPIKeyKind(kind APIKeyKind) bool {
+	switch kind {
+	case APIKeyKindClient, APIKeyKindServer, APIKeyKindManagement:
+		return true
+	}
+
+	return false
+}
+
+// NewAPIKey generates a secret for an APIKey, returning the key with its ID, Prefix and Hash filled in along with
+// the secret. The secret is prefixed with the kind of the key so that leaked keys are easy to recognize
+func NewAPIKey(key APIKey) (APIKey, string, error) {
+	if !ValidAPIKeyKind(key.Kind) {
+		return key, "", fmt.Errorf("unknown api key kind %q", key.Kind)
+	}
+
+	random := make([]byte, apiKeySecretSize)
+	if _, err := rand.Read(random); err != nil {
+		return key, "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+
+	secret := fmt.Sprintf("togglr_%s_%s", key.Kind, base64.RawURLEncoding.EncodeToString(random))
+	key.ID = uid.New()
+	key.Prefix = secret[:len("togglr_")+len(key.Kind)+1+apiKeyPrefixSize]
+	key.Hash = HashAPIKey(secret)
+	key.RevokedAt = nil
+
+	return key, secret, nil
+}
+
+// HashAPIKey hashes the secret of an APIKey. Secrets are long and random, so unlike passwords a fast hash is enough
+// to keep them safe, and it lets keys be looked up by their hash
+func HashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// AuthenticateAPIKey finds the APIKey a secret belongs to. ErrInvalidAPIKey is returned if there isn't one or it has
+// been revoked
+func AuthenticateAPIKey(ctx context.Context, ks APIKeyService, secret string) (APIKey, error) {
+	if !strings.HasPrefix(secret, "togglr_") {
+		return APIKey{}, ErrInvalidAPIKey
+	}
+
+	key, err := ks.FetchAPIKeyByHash(ctx, HashAPIKey(secret))
+	if errors.Is(err, ErrNotFound) {
+		return key, ErrInvalidAPIKey
+	}
+
+	if err != nil {
+		return key, err
+	}
+
+	if key.Revoked() {
+		return key, ErrInvalidAPIKey
+	}
+
+	return key, nil
+}
@@ -0,0 +1,79 @@
+package togglr_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/mock"
+	"github.com/togglr-io/togglr/uid"
+)
+
+func Test_NewAPIKey(t *testing.T) {
+	// RUN
+	key, secret, err := togglr.NewAPIKey(togglr.APIKey{AccountID: uid.New(), Name: "web", Kind: togglr.APIKeyKindClient})
+	if err != nil {
+		t.Fatalf("failed to create api key: %s", err)
+	}
+
+	if !strings.HasPrefix(secret, "togglr_client_") || !strings.HasPrefix(secret, key.Prefix) {
+		t.Fatalf("expected secret to start with the kind and the key's prefix, got %s and %s", secret, key.Prefix)
+	}
+
+	if key.Hash != togglr.HashAPIKey(secret) || strings.Contains(key.Hash, secret) {
+		t.Fatalf("expected only the hash of the secret to be kept")
+	}
+
+	if _, _, err := togglr.NewAPIKey(togglr.APIKey{Kind: "admin"}); err == nil {
+		t.Fatalf("expected unknown kinds of api key to fail")
+	}
+}
+
+func Test_AuthenticateAPIKey(t *testing.T) {
+	// SETUP
+	ctx := context.TODO()
+	key, secret, err := togglr.NewAPIKey(togglr.APIKey{AccountID: uid.New(), Name: "backend", Kind: togglr.APIKeyKindServer})
+	if err != nil {
+		t.Fatalf("failed to create api key: %s", err)
+	}
+
+	ks := mock.NewAPIKeyService(nil)
+	ks.FetchAPIKeyByHashFn = func(ctx context.Context, hash string) (togglr.APIKey, error) {
+		if hash != key.Hash {
+			return togglr.APIKey{}, togglr.ErrNotFound
+		}
+		return key, nil
+	}
+
+	// RUN
+	authed, err := togglr.AuthenticateAPIKey(ctx, ks, secret)
+	if err != nil {
+		t.Fatalf("failed to authenticate api key: %s", err)
+	}
+
+	if !authed.ID.Equals(key.ID) {
+		t.Fatalf("expected to authenticate as %s, got %s", key.ID, authed.ID)
+	}
+
+	if _, err := togglr.AuthenticateAPIKey(ctx, ks, secret+"x"); !errors.Is(err, togglr.ErrInvalidAPIKey) {
+		t.Fatalf("expected unknown secret to be invalid, got %v", err)
+	}
+
+	calls := ks.FetchAPIKeyByHashCalled
+	if _, err := togglr.AuthenticateAPIKey(ctx, ks, "not-a-key"); !errors.Is(err, togglr.ErrInvalidAPIKey) {
+		t.Fatalf("expected malformed secret to be invalid, got %v", err)
+	}
+
+	if ks.FetchAPIKeyByHashCalled != calls {
+		t.Fatalf("expected malformed secret not to be looked up")
+	}
+
+	revokedAt := time.Now()
+	key.RevokedAt = &revokedAt
+	if _, err := togglr.AuthenticateAPIKey(ctx, ks, secret); !errors.Is(err, togglr.ErrInvalidAPIKey) {
+		t.Fatalf("expected revoked key to be invalid, got %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package togglr
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// A batchRecorder is the part shared by the EvaluationRecorders that aggregate evaluations in memory and
+// periodically flush them somewhere. Evaluations are queued without blocking, folded into the pending batch by merge
+// and written out by flush, which is expected to start a new batch. merge and flush are only ever called from Run, so
+// the batch they share doesn't need to be locked
+type batchRecorder struct {
+	evals    chan Evaluation
+	interval time.Duration
+	merge    func(eval Evaluation)
+	flush    func(ctx context.Context)
+
+	log *zap.Logger
+}
+
+func newBatchRecorder(size int, interval time.Duration, merge func(Evaluation), flush func(context.Context), logger *zap.Logger) batchRecorder {
+	return batchRecorder{
+		evals:    make(chan Evaluation, size),
+		interval: interval,
+		merge:    merge,
+		flush:    flush,
+		log:      logger,
+	}
+}
+
+// RecordEvaluation queues an evaluation to be merged into the next batch. If the buffer is full the evaluation is
+// dropped rather than slowing down the caller
+func (r *batchRecorder) RecordEvaluation(eval Evaluation) {
+	select {
+	case r.evals <- eval:
+	default:
+	}
+}
+
+// Run merges queued evaluations and flushes the batch every interval until the context is cancelled, at which point
+// anything remaining is flushed one last time
+func (r *batchRecorder) Run(ctx context.Context) {
+	defer r.log.Sync()
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case eval := <-r.evals:
+			r.merge(eval)
+		case <-ticker.C:
+			r.flush(ctx)
+		case <-ctx.Done():
+			// drain whatever is already queued before the final flush
+			r.drain()
+			r.flush(context.Background())
+			return
+		}
+	}
+}
+
+func (r *batchRecorder) drain() {
+	for {
+		select {
+		case eval := <-r.evals:
+			r.merge(eval)
+		default:
+			return
+		}
+	}
+}
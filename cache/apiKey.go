@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/uid"
+)
+
+type cachedKey struct {
+	key      togglr.APIKey
+	loadedAt time.Time
+}
+
+// An APIKeyService implements the togglr.APIKeyService interface by wrapping another APIKeyService and keeping the
+// keys looked up by hash in memory, since every authenticated request looks one up. Keys revoked through the
+// APIKeyService are dropped right away, keys revoked some other way, e.g. by another server, keep working until
+// they're older than the TTL. Keys that aren't found are never cached
+type APIKeyService struct {
+	ks  togglr.APIKeyService
+	ttl time.Duration
+
+	mu     sync.RWMutex
+	byHash map[string]cachedKey
+}
+
+// NewAPIKeyService returns a new APIKeyService that caches keys from the given APIKeyService for up to ttl
+func NewAPIKeyService(ks togglr.APIKeyService, ttl time.Duration) *APIKeyService {
+	return &APIKeyService{
+		ks:     ks,
+		ttl:    ttl,
+		byHash: make(map[string]cachedKey),
+	}
+}
+
+func (s *APIKeyService) CreateAPIKey(ctx context.Context, key togglr.APIKey) (uid.UID, error) {
+	return s.ks.CreateAPIKey(ctx, key)
+}
+
+func (s *APIKeyService) FetchAPIKey(ctx context.Context, id uid.UID) (togglr.APIKey, error) {
+	return s.ks.FetchAPIKey(ctx, id)
+}
+
+// FetchAPIKeyByHash returns the cached key with the given hash, loading it if it isn't cached or is too old
+func (s *APIKeyService) FetchAPIKeyByHash(ctx context.Context, hash string) (togglr.APIKey, error) {
+	s.mu.RLock()
+	cached, ok := s.byHash[hash]
+	s.mu.RUnlock()
+	if ok && time.Since(cached.loadedAt) < s.ttl {
+		return cached.key, nil
+	}
+
+	key, err := s.ks.FetchAPIKeyByHash(ctx, hash)
+	if err != nil {
+		return key, err
+	}
+
+	s.mu.Lock()
+	s.byHash[hash] = cachedKey{key: key, loadedAt: time.Now()}
+	s.mu.Unlock()
+
+	return key, nil
+}
+
+func (s *APIKeyService) ListAPIKeys(ctx context.Context, accountID uid.UID) ([]togglr.APIKey, error) {
+	return s.ks.ListAPIKeys(ctx, accountID)
+}
+
+// RevokeAPIKey revokes a key and drops it from the cache
+func (s *APIKeyService) RevokeAPIKey(ctx context.Context, id uid.UID) error {
+	if err := s.ks.RevokeAPIKey(ctx, id); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for hash, cached := range s.byHash {
+		if cached.key.ID.Equals(id) {
+			delete(s.byHash, hash)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,258 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/uid"
+	"golang.org/x/sync/singleflight"
+)
+
+// how long a snapshot load may take. Loads are shared between every request waiting on them, so they aren't tied
+// to the context of any one request
+const loadTimeout = 30 * time.Second
+
+// A snapshot is an immutable copy of every resolvable Toggle in an account at the time it was loaded
+type snapshot struct {
+	toggles  []togglr.Toggle
+	loadedAt time.Time
+}
+
+// A ToggleService implements the togglr.ToggleService interface by wrapping another ToggleService and keeping
+// a snapshot of each account's toggles in memory. Snapshots are used to answer the unfiltered listings made when
+// resolving toggles, every other request is passed through. Writes made through the ToggleService invalidate the
+// snapshot of the account they affect, and snapshots older than the TTL are reloaded in case the toggles were
+// changed some other way. Toggles served from a snapshot share their rules, tags and other reference fields with
+// it, so callers must treat them as read-only
+type ToggleService struct {
+	ts  togglr.ToggleService
+	ttl time.Duration
+
+	mu          sync.RWMutex
+	snapshots   map[uid.UID]snapshot
+	generations map[uid.UID]uint64
+	epoch       uint64
+	accounts    map[uid.UID]uid.UID
+
+	group singleflight.Group
+}
+
+// NewToggleService returns a new ToggleService that caches snapshots from the given ToggleService for up to ttl
+func NewToggleService(ts togglr.ToggleService, ttl time.Duration) *ToggleService {
+	return &ToggleService{
+		ts:          ts,
+		ttl:         ttl,
+		snapshots:   make(map[uid.UID]snapshot),
+		generations: make(map[uid.UID]uint64),
+		accounts:    make(map[uid.UID]uid.UID),
+	}
+}
+
+// cacheable returns true if the request lists every resolvable toggle in an account, which is exactly what a
+// snapshot holds
+func cacheable(req togglr.ListTogglesReq) bool {
+	return !req.AccountID.IsNull() && req == togglr.ListTogglesReq{AccountID: req.AccountID}
+}
+
+// Invalidate discards the snapshot of an account so that the next listing reloads it
+func (s *ToggleService) Invalidate(accountID uid.UID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if snap, ok := s.snapshots[accountID]; ok {
+		for _, toggle := range snap.toggles {
+			delete(s.accounts, toggle.ID)
+		}
+
+		delete(s.snapshots, accountID)
+	}
+
+	// bumping the generation prevents loads that started before the invalidation from being stored
+	s.generations[accountID]++
+}
+
+// InvalidateAll discards every snapshot
+func (s *ToggleService) InvalidateAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// bumping the epoch prevents loads for any account that started before the invalidation from being stored
+	s.epoch++
+	s.snapshots = make(map[uid.UID]snapshot)
+	s.accounts = make(map[uid.UID]uid.UID)
+}
+
+// HandleChange invalidates the snapshot affected by a ChangeEvent so that writes made by other servers are picked
+// up without waiting for the TTL
+func (s *ToggleService) HandleChange(event togglr.ChangeEvent) {
+	if event.Resync {
+		s.InvalidateAll()
+		return
+	}
+
+	if !event.AccountID.IsNull() {
+		s.Invalidate(event.AccountID)
+	}
+}
+
+// accountFor finds the account a Toggle belongs to, falling back to the wrapped ToggleService when the Toggle isn't
+// part of any cached snapshot
+func (s *ToggleService) accountFor(ctx context.Context, id uid.UID) (uid.UID, error) {
+	s.mu.RLock()
+	accountID, ok := s.accounts[id]
+	s.mu.RUnlock()
+	if ok {
+		return accountID, nil
+	}
+
+	toggle, err := s.ts.FetchToggle(ctx, id)
+	if err != nil {
+		return accountID, err
+	}
+
+	return toggle.AccountID, nil
+}
+
+// snapshot returns the current snapshot for an account, loading it if it's missing or expired. Concurrent loads
+// for the same account are collapsed into a single call to the wrapped ToggleService, which runs with its own
+// timeout so that one caller giving up doesn't fail the load for everyone else waiting on it
+func (s *ToggleService) snapshot(ctx context.Context, accountID uid.UID) ([]togglr.Toggle, error) {
+	s.mu.RLock()
+	snap, ok := s.snapshots[accountID]
+	generation := s.generations[accountID]
+	epoch := s.epoch
+	s.mu.RUnlock()
+
+	if ok && time.Now().Sub(snap.loadedAt) < s.ttl {
+		return snap.toggles, nil
+	}
+
+	key := fmt.Sprintf("%s:%d:%d", accountID, epoch, generation)
+	ch := s.group.DoChan(key, func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), loadTimeout)
+		defer cancel()
+
+		toggles, err := s.ts.ListToggles(ctx, togglr.ListTogglesReq{AccountID: accountID})
+		if err != nil {
+			return nil, err
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.epoch == epoch && s.generations[accountID] == generation {
+			s.snapshots[accountID] = snapshot{toggles: toggles, loadedAt: time.Now()}
+			for _, toggle := range toggles {
+				s.accounts[toggle.ID] = accountID
+			}
+		}
+
+		return toggles, nil
+	})
+
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+
+		return res.Val.([]togglr.Toggle), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *ToggleService) CreateToggle(ctx context.Context, toggle togglr.Toggle) (uid.UID, error) {
+	id, err := s.ts.CreateToggle(ctx, toggle)
+	s.Invalidate(toggle.AccountID)
+	return id, err
+}
+
+// write performs a write against a single Toggle and invalidates the snapshot of the account it belongs to
+func (s *ToggleService) write(ctx context.Context, id uid.UID, fn func() error) error {
+	accountID, err := s.accountFor(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = fn()
+	s.Invalidate(accountID)
+	return err
+}
+
+func (s *ToggleService) UpdateToggle(ctx context.Context, req togglr.UpdateToggleReq) error {
+	return s.write(ctx, req.ID, func() error {
+		return s.ts.UpdateToggle(ctx, req)
+	})
+}
+
+func (s *ToggleService) FetchToggle(ctx context.Context, id uid.UID) (togglr.Toggle, error) {
+	return s.ts.FetchToggle(ctx, id)
+}
+
+// FetchToggleByKey answers from the account's snapshot when the Toggle is in it. Otherwise, including when the
+// Toggle is archived, the lookup is passed through
+func (s *ToggleService) FetchToggleByKey(ctx context.Context, accountID uid.UID, key string) (togglr.Toggle, error) {
+	s.mu.RLock()
+	snap, ok := s.snapshots[accountID]
+	s.mu.RUnlock()
+
+	if ok && time.Since(snap.loadedAt) < s.ttl {
+		for _, toggle := range snap.toggles {
+			if toggle.Key == key {
+				return toggle, nil
+			}
+		}
+	}
+
+	return s.ts.FetchToggleByKey(ctx, accountID, key)
+}
+
+// ListToggles answers unfiltered listings from the account's snapshot. The returned slice is a copy, so callers
+// are free to reorder or append to it, but the Toggles in it are shared with the snapshot and must not be modified
+func (s *ToggleService) ListToggles(ctx context.Context, req togglr.ListTogglesReq) ([]togglr.Toggle, error) {
+	if !cacheable(req) {
+		return s.ts.ListToggles(ctx, req)
+	}
+
+	toggles, err := s.snapshot(ctx, req.AccountID)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(make([]togglr.Toggle, 0, len(toggles)), toggles...), nil
+}
+
+func (s *ToggleService) ListDependents(ctx context.Context, accountID uid.UID, key string) ([]togglr.Toggle, error) {
+	return s.ts.ListDependents(ctx, accountID, key)
+}
+
+func (s *ToggleService) DeleteToggle(ctx context.Context, id uid.UID) error {
+	return s.write(ctx, id, func() error {
+		return s.ts.DeleteToggle(ctx, id)
+	})
+}
+
+func (s *ToggleService) ArchiveToggle(ctx context.Context, id uid.UID) error {
+	return s.write(ctx, id, func() error {
+		return s.ts.ArchiveToggle(ctx, id)
+	})
+}
+
+func (s *ToggleService) RestoreToggle(ctx context.Context, id uid.UID) error {
+	return s.write(ctx, id, func() error {
+		return s.ts.RestoreToggle(ctx, id)
+	})
+}
+
+func (s *ToggleService) ListToggleRevisions(ctx context.Context, toggleID uid.UID) ([]togglr.ToggleRevision, error) {
+	return s.ts.ListToggleRevisions(ctx, toggleID)
+}
+
+func (s *ToggleService) RollbackToggle(ctx context.Context, toggleID uid.UID, revision int) error {
+	return s.write(ctx, toggleID, func() error {
+		return s.ts.RollbackToggle(ctx, toggleID, revision)
+	})
+}
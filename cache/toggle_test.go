@@ -0,0 +1,214 @@
+package cache_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/cache"
+	"github.com/togglr-io/togglr/mock"
+	"github.com/togglr-io/togglr/uid"
+)
+
+func Test_ToggleService(t *testing.T) {
+	// SETUP
+	ctx := context.TODO()
+	accountID := uid.New()
+	toggle := togglr.Toggle{ID: uid.New(), AccountID: accountID, Key: "cached-feature"}
+
+	var loads int32
+	mockTS := mock.NewToggleService(nil)
+	mockTS.ListTogglesFn = func(ctx context.Context, req togglr.ListTogglesReq) ([]togglr.Toggle, error) {
+		atomic.AddInt32(&loads, 1)
+		return []togglr.Toggle{toggle}, nil
+	}
+	ts := cache.NewToggleService(mockTS, time.Hour)
+
+	// RUN
+	for i := 0; i < 3; i++ {
+		toggles, err := ts.ListToggles(ctx, togglr.ListTogglesReq{AccountID: accountID})
+		if err != nil {
+			t.Fatalf("failed to list toggles: %s", err)
+		}
+
+		if len(toggles) != 1 || toggles[0].Key != toggle.Key {
+			t.Fatalf("expected cached toggle to be listed, got %v", toggles)
+		}
+	}
+
+	if loads != 1 {
+		t.Fatalf("expected toggles to be loaded 1 time, not %d", loads)
+	}
+
+	// filtered listings aren't served from the snapshot
+	if _, err := ts.ListToggles(ctx, togglr.ListTogglesReq{AccountID: accountID, Tag: "beta"}); err != nil {
+		t.Fatalf("failed to list toggles: %s", err)
+	}
+
+	if loads != 2 {
+		t.Fatalf("expected filtered listing to bypass the cache")
+	}
+
+	// writes invalidate the snapshot of the toggle's account
+	if err := ts.UpdateToggle(ctx, togglr.UpdateToggleReq{ID: toggle.ID}); err != nil {
+		t.Fatalf("failed to update toggle: %s", err)
+	}
+
+	if _, err := ts.ListToggles(ctx, togglr.ListTogglesReq{AccountID: accountID}); err != nil {
+		t.Fatalf("failed to list toggles: %s", err)
+	}
+
+	if loads != 3 {
+		t.Fatalf("expected update to invalidate the snapshot")
+	}
+
+	if mockTS.FetchToggleCalled != 0 {
+		t.Fatalf("expected the toggle's account to be found in the snapshot")
+	}
+}
+
+func Test_ToggleServiceTTL(t *testing.T) {
+	// SETUP
+	ctx := context.TODO()
+	mockTS := mock.NewToggleService(nil)
+	ts := cache.NewToggleService(mockTS, 0)
+
+	// RUN
+	for i := 0; i < 2; i++ {
+		if _, err := ts.ListToggles(ctx, togglr.ListTogglesReq{AccountID: uid.New()}); err != nil {
+			t.Fatalf("failed to list toggles: %s", err)
+		}
+	}
+
+	if mockTS.ListTogglesCalled != 2 {
+		t.Fatalf("expected expired snapshots to be reloaded")
+	}
+}
+
+func Test_ToggleServiceSingleflight(t *testing.T) {
+	// SETUP
+	ctx := context.TODO()
+	accountID := uid.New()
+	release := make(chan struct{})
+
+	var loads int32
+	mockTS := mock.NewToggleService(nil)
+	mockTS.ListTogglesFn = func(ctx context.Context, req togglr.ListTogglesReq) ([]togglr.Toggle, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		return []togglr.Toggle{}, nil
+	}
+	ts := cache.NewToggleService(mockTS, time.Hour)
+
+	// RUN
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := ts.ListToggles(ctx, togglr.ListTogglesReq{AccountID: accountID}); err != nil {
+				t.Errorf("failed to list toggles: %s", err)
+			}
+		}()
+	}
+
+	// give every listing a chance to join the load before releasing it
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if loads != 1 {
+		t.Fatalf("expected concurrent listings to share 1 load, not %d", loads)
+	}
+}
+
+func Test_ToggleServiceSingleflightCancel(t *testing.T) {
+	// SETUP
+	accountID := uid.New()
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	mockTS := mock.NewToggleService(nil)
+	mockTS.ListTogglesFn = func(ctx context.Context, req togglr.ListTogglesReq) ([]togglr.Toggle, error) {
+		close(started)
+		select {
+		case <-release:
+			return []togglr.Toggle{{ID: uid.New(), AccountID: accountID, Key: "loaded"}}, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	ts := cache.NewToggleService(mockTS, time.Hour)
+
+	// RUN
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := ts.ListToggles(ctx, togglr.ListTogglesReq{AccountID: accountID})
+		first <- err
+	}()
+
+	<-started
+	second := make(chan []togglr.Toggle, 1)
+	go func() {
+		toggles, err := ts.ListToggles(context.Background(), togglr.ListTogglesReq{AccountID: accountID})
+		if err != nil {
+			t.Errorf("failed to list toggles: %s", err)
+		}
+		second <- toggles
+	}()
+
+	// the first caller giving up shouldn't cancel the load the second caller is waiting on
+	cancel()
+	if err := <-first; err != context.Canceled {
+		t.Fatalf("expected the canceled listing to fail with context.Canceled, got %v", err)
+	}
+
+	close(release)
+	if toggles := <-second; len(toggles) != 1 {
+		t.Fatalf("expected the load to finish for the remaining caller, got %v", toggles)
+	}
+}
+
+func Test_ToggleServiceHandleChange(t *testing.T) {
+	// SETUP
+	ctx := context.TODO()
+	accountID := uid.New()
+
+	var loads int32
+	mockTS := mock.NewToggleService(nil)
+	mockTS.ListTogglesFn = func(ctx context.Context, req togglr.ListTogglesReq) ([]togglr.Toggle, error) {
+		atomic.AddInt32(&loads, 1)
+		return []togglr.Toggle{}, nil
+	}
+	ts := cache.NewToggleService(mockTS, time.Hour)
+
+	list := func() {
+		if _, err := ts.ListToggles(ctx, togglr.ListTogglesReq{AccountID: accountID}); err != nil {
+			t.Fatalf("failed to list toggles: %s", err)
+		}
+	}
+
+	// RUN
+	list()
+	ts.HandleChange(togglr.ChangeEvent{Table: "toggles", Op: togglr.ChangeOpUpdate, ID: uid.New(), AccountID: uid.New()})
+	list()
+	if loads != 1 {
+		t.Fatalf("expected changes to other accounts to keep the snapshot")
+	}
+
+	ts.HandleChange(togglr.ChangeEvent{Table: "toggles", Op: togglr.ChangeOpUpdate, ID: uid.New(), AccountID: accountID})
+	list()
+	if loads != 2 {
+		t.Fatalf("expected a change to the account to invalidate the snapshot")
+	}
+
+	ts.HandleChange(togglr.ChangeEvent{Resync: true})
+	list()
+	if loads != 3 {
+		t.Fatalf("expected a resync to invalidate the snapshot")
+	}
+}
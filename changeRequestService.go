@@ -0,0 +1,129 @@
+package togglr
+
+import (
+	"context"
+
+	"github.com/togglr-io/togglr/uid"
+	"go.uber.org/zap"
+)
+
+type approvedChangeKey struct{}
+
+// withApprovedChange marks a context as belonging to the application of an approved ChangeRequest, which allows
+// edits to Toggles that require approval
+func withApprovedChange(ctx context.Context) context.Context {
+	return context.WithValue(ctx, approvedChangeKey{}, true)
+}
+
+func isApprovedChange(ctx context.Context) bool {
+	approved, _ := ctx.Value(approvedChangeKey{}).(bool)
+	return approved
+}
+
+// A DefaultChangeRequestService provides a default implementation of the ChangeRequestService interface that wraps
+// another ChangeRequestService and enforces the approval workflow
+type DefaultChangeRequestService struct {
+	cs ChangeRequestService
+	ts ToggleService
+
+	log *zap.Logger
+}
+
+// NewChangeRequestService returns a new DefaultChangeRequestService. Approved changes are applied through the
+// given ToggleService
+func NewChangeRequestService(cs ChangeRequestService, ts ToggleService, logger *zap.Logger) DefaultChangeRequestService {
+	return DefaultChangeRequestService{
+		cs:  cs,
+		ts:  ts,
+		log: logger,
+	}
+}
+
+// CreateChangeRequest submits a new pending ChangeRequest for the Toggle it targets
+func (s DefaultChangeRequestService) CreateChangeRequest(ctx context.Context, cr ChangeRequest) (uid.UID, error) {
+	toggle, err := s.ts.FetchToggle(ctx, cr.ToggleID)
+	if err != nil {
+		return uid.UID{}, err
+	}
+
+	cr.ID = uid.New()
+	cr.AccountID = toggle.AccountID
+	cr.ReviewerID = uid.UID{}
+	cr.Status = ChangeRequestStatusPending
+	cr.Change.ID = toggle.ID
+	cr.Change.AccountID = toggle.AccountID
+	// pin the change to the version it was proposed against so that it can't silently overwrite edits that
+	// were applied in the meantime
+	if cr.Change.Version == 0 {
+		cr.Change.Version = toggle.Version
+	}
+
+	return s.cs.CreateChangeRequest(ctx, cr)
+}
+
+// UpdateChangeRequest moves a ChangeRequest through the approval workflow. Pending requests can be approved or
+// rejected by anyone other than their author and approved requests can be applied. Each move only succeeds if the
+// ChangeRequest hasn't moved on in the meantime, so that concurrent reviews can't both succeed
+func (s DefaultChangeRequestService) UpdateChangeRequest(ctx context.Context, req UpdateChangeRequestReq) error {
+	if req.Status == nil {
+		return s.cs.UpdateChangeRequest(ctx, req)
+	}
+
+	cr, err := s.cs.FetchChangeRequest(ctx, req.ID)
+	if err != nil {
+		return err
+	}
+
+	switch *req.Status {
+	case ChangeRequestStatusApproved, ChangeRequestStatusRejected:
+		if cr.Status != ChangeRequestStatusPending {
+			return ErrInvalidTransition
+		}
+
+		if req.ReviewerID.IsNull() {
+			return ErrReviewerRequired
+		}
+
+		if req.ReviewerID.Equals(cr.AuthorID) {
+			return ErrSelfApproval
+		}
+
+		req.FromStatus = ChangeRequestStatusPending
+		return s.cs.UpdateChangeRequest(ctx, req)
+	case ChangeRequestStatusApplied:
+		if cr.Status != ChangeRequestStatusApproved {
+			return ErrInvalidTransition
+		}
+
+		// the reviewer was recorded on approval. The request is claimed before the change is made so that it's
+		// only ever applied once
+		req.ReviewerID = uid.UID{}
+		req.FromStatus = ChangeRequestStatusApproved
+		if err := s.cs.UpdateChangeRequest(ctx, req); err != nil {
+			return err
+		}
+
+		if err := s.ts.UpdateToggle(withApprovedChange(ctx), cr.Change); err != nil {
+			approved := ChangeRequestStatusApproved
+			release := UpdateChangeRequestReq{ID: cr.ID, Status: &approved, FromStatus: ChangeRequestStatusApplied}
+			if relErr := s.cs.UpdateChangeRequest(ctx, release); relErr != nil {
+				s.log.Error("failed to release change request", zap.String("changeRequestID", cr.ID.String()), zap.Error(relErr))
+			}
+
+			return err
+		}
+
+		s.log.Info("applied change request", zap.String("changeRequestID", cr.ID.String()), zap.String("toggleID", cr.ToggleID.String()))
+		return nil
+	default:
+		return ErrInvalidTransition
+	}
+}
+
+func (s DefaultChangeRequestService) FetchChangeRequest(ctx context.Context, id uid.UID) (ChangeRequest, error) {
+	return s.cs.FetchChangeRequest(ctx, id)
+}
+
+func (s DefaultChangeRequestService) ListChangeRequests(ctx context.Context, req ListChangeRequestsReq) ([]ChangeRequest, error) {
+	return s.cs.ListChangeRequests(ctx, req)
+}
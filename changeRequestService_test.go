@@ -0,0 +1,107 @@
+package togglr_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/mock"
+	"github.com/togglr-io/togglr/uid"
+	"go.uber.org/zap"
+)
+
+func Test_DefaultChangeRequestService(t *testing.T) {
+	// SETUP
+	ctx := context.TODO()
+	authorID := uid.New()
+	reviewerID := uid.New()
+	toggle := togglr.Toggle{
+		ID:               uid.New(),
+		AccountID:        uid.New(),
+		Key:              "payments-provider",
+		RequiresApproval: true,
+		Version:          3,
+	}
+
+	mockTS := mock.NewToggleService(nil)
+	mockTS.FetchToggleFn = func(ctx context.Context, id uid.UID) (togglr.Toggle, error) {
+		return toggle, nil
+	}
+	ts := togglr.NewToggleService(mockTS, mock.NewMetadataService(nil), zap.NewNop())
+
+	var stored togglr.ChangeRequest
+	mockCS := mock.NewChangeRequestService(nil)
+	mockCS.CreateChangeRequestFn = func(ctx context.Context, cr togglr.ChangeRequest) (uid.UID, error) {
+		stored = cr
+		return cr.ID, nil
+	}
+	mockCS.FetchChangeRequestFn = func(ctx context.Context, id uid.UID) (togglr.ChangeRequest, error) {
+		return stored, nil
+	}
+	mockCS.UpdateChangeRequestFn = func(ctx context.Context, req togglr.UpdateChangeRequestReq) error {
+		if req.FromStatus != "" && req.FromStatus != stored.Status {
+			return togglr.ErrInvalidTransition
+		}
+		stored.Status = *req.Status
+		return nil
+	}
+	cs := togglr.NewChangeRequestService(mockCS, ts, zap.NewNop())
+
+	description := "switch providers"
+	approved := togglr.ChangeRequestStatusApproved
+	applied := togglr.ChangeRequestStatusApplied
+
+	// RUN
+	err := ts.UpdateToggle(ctx, togglr.UpdateToggleReq{ID: toggle.ID, Description: &description})
+	if !errors.Is(err, togglr.ErrApprovalRequired) {
+		t.Fatalf("expected direct update to be refused, got %v", err)
+	}
+
+	if _, err := cs.CreateChangeRequest(ctx, togglr.ChangeRequest{
+		ToggleID: toggle.ID,
+		AuthorID: authorID,
+		Change:   togglr.UpdateToggleReq{Description: &description},
+	}); err != nil {
+		t.Fatalf("failed to create change request: %s", err)
+	}
+
+	if stored.Status != togglr.ChangeRequestStatusPending {
+		t.Fatalf("expected change request to be pending, not %s", stored.Status)
+	}
+
+	if stored.Change.Version != toggle.Version {
+		t.Fatalf("expected change to be pinned to version %d, not %d", toggle.Version, stored.Change.Version)
+	}
+
+	err = cs.UpdateChangeRequest(ctx, togglr.UpdateChangeRequestReq{ID: stored.ID, Status: &applied, ReviewerID: reviewerID})
+	if !errors.Is(err, togglr.ErrInvalidTransition) {
+		t.Fatalf("expected applying a pending change request to fail, got %v", err)
+	}
+
+	err = cs.UpdateChangeRequest(ctx, togglr.UpdateChangeRequestReq{ID: stored.ID, Status: &approved, ReviewerID: authorID})
+	if !errors.Is(err, togglr.ErrSelfApproval) {
+		t.Fatalf("expected self approval to fail, got %v", err)
+	}
+
+	if err := cs.UpdateChangeRequest(ctx, togglr.UpdateChangeRequestReq{ID: stored.ID, Status: &approved, ReviewerID: reviewerID}); err != nil {
+		t.Fatalf("failed to approve change request: %s", err)
+	}
+
+	if err := cs.UpdateChangeRequest(ctx, togglr.UpdateChangeRequestReq{ID: stored.ID, Status: &applied}); err != nil {
+		t.Fatalf("failed to apply change request: %s", err)
+	}
+
+	err = cs.UpdateChangeRequest(ctx, togglr.UpdateChangeRequestReq{ID: stored.ID, Status: &applied})
+	if !errors.Is(err, togglr.ErrInvalidTransition) {
+		t.Fatalf("expected applying a change request twice to fail, got %v", err)
+	}
+
+	if mockTS.UpdateToggleCalled != 1 {
+		t.Fatalf("expected ToggleService.UpdateToggle to be called 1 time, not %d", mockTS.UpdateToggleCalled)
+	}
+
+	if stored.Status != togglr.ChangeRequestStatusApplied {
+		t.Fatalf("expected change request to be applied, not %s", stored.Status)
+	}
+}
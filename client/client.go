@@ -0,0 +1,547 @@
+// Package client evaluates an account's toggles locally. Definitions are downloaded from a togglr server and kept
+// up to date in the background, so evaluating a toggle never waits on the network. If the server can't be reached
+// the last definitions downloaded keep being used. Definitions can also be bootstrapped from a file or an embedded
+// snapshot, and persisted to disk, so that a client starting while the server is down still has toggles to evaluate.
+// Toggles that aren't in any definitions return their defaults
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/rules"
+	"github.com/togglr-io/togglr/uid"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultPollInterval  = 30 * time.Second
+	defaultTimeout       = 10 * time.Second
+	defaultEventInterval = 10 * time.Second
+	// the longest a stream waits before reconnecting
+	maxStreamBackoff = time.Minute
+)
+
+// A Config captures everything needed to create a Client
+type Config struct {
+	// BaseURL is the address of the togglr server, e.g. https://togglr.example.com
+	BaseURL   string
+	AccountID uid.UID
+	// APIKey authenticates requests to the server. Downloading definitions takes a server or management key
+	APIKey string
+	// PollInterval is how often definitions are downloaded. When streaming, polling is only a fallback in case
+	// a change notification is missed. Defaults to 30 seconds
+	PollInterval time.Duration
+	// Stream listens for changes to the account's toggles so that they're downloaded as soon as they happen
+	Stream bool
+	// HTTPClient is used to talk to the server. Streams use a copy without a timeout. Defaults to a client with a
+	// 10 second timeout
+	HTTPClient *http.Client
+	Logger     *zap.Logger
+
+	// BootstrapFile is a JSON or YAML file of definitions, e.g. one written by the export command
+	BootstrapFile string
+	// Bootstrap is a snapshot of definitions in JSON or YAML, e.g. embedded into the binary with go:embed
+	Bootstrap []byte
+	// PersistFile is where definitions are written after every successful download. It's stored as YAML if it has
+	// a .yaml or .yml extension and JSON otherwise
+	PersistFile string
+
+	// SendEvents reports every evaluation back to the server, so that it shows up in the toggle's evaluation
+	// counts. Events are sent in batches while Run is running
+	SendEvents bool
+	// EventInterval is how often batches of events are sent. Defaults to 10 seconds
+	EventInterval time.Duration
+}
+
+// A Source is where the definitions a Client is using came from
+type Source string
+
+// Enumeration of possible Sources
+const (
+	SourceNone      = Source("none")
+	SourceBootstrap = Source("bootstrap")
+	SourcePersisted = Source("persisted")
+	SourceServer    = Source("server")
+)
+
+// A Status describes the definitions a Client is using
+type Status struct {
+	Source  Source
+	Version int64
+	// LoadedAt is when the definitions in use were loaded
+	LoadedAt time.Time
+	// LastRefresh is when definitions were last downloaded, or zero if they never have been
+	LastRefresh time.Time
+	// LastError is the error from the last attempt to download or load definitions, if it failed
+	LastError error
+}
+
+// A Client evaluates toggles locally against definitions kept up to date by Run. It's safe for concurrent use
+type Client struct {
+	cfg    Config
+	log    *zap.Logger
+	http   *http.Client
+	stream *http.Client
+
+	mu       sync.RWMutex
+	snapshot togglr.Snapshot
+	etag     string
+	status   Status
+	// changed is signalled by the stream whenever the definitions should be downloaded again
+	changed chan struct{}
+
+	subsMu sync.Mutex
+	subs   map[chan struct{}]struct{}
+
+	// events waiting to be sent to the server
+	events chan togglr.EvaluationEvent
+}
+
+// New creates a new Client. No definitions are downloaded until Run or Refresh is called. Until then the newest
+// definitions out of the persisted and bootstrapped ones are used, if there are any
+func New(cfg Config) *Client {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultPollInterval
+	}
+
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: defaultTimeout}
+	}
+
+	if cfg.Logger == nil {
+		cfg.Logger = zap.NewNop()
+	}
+
+	if cfg.EventInterval <= 0 {
+		cfg.EventInterval = defaultEventInterval
+	}
+
+	stream := *cfg.HTTPClient
+	stream.Timeout = 0
+
+	c := &Client{
+		cfg:      cfg,
+		log:      cfg.Logger.With(zap.String("accountID", cfg.AccountID.String())),
+		http:     cfg.HTTPClient,
+		stream:   &stream,
+		snapshot: togglr.NewSnapshot(nil),
+		status:   Status{Source: SourceNone},
+		changed:  make(chan struct{}, 1),
+		subs:     make(map[chan struct{}]struct{}),
+	}
+	c.loadLocal()
+
+	if cfg.SendEvents {
+		c.events = make(chan togglr.EvaluationEvent, eventBufferSize)
+	}
+
+	return c
+}
+
+// loadLocal loads the newest of the persisted and bootstrapped definitions. Persisted definitions win ties, since
+// they're known to have come from the server
+func (c *Client) loadLocal() {
+	var (
+		best    togglr.Definitions
+		source  = SourceNone
+		lastErr error
+	)
+
+	consider := func(defs togglr.Definitions, from Source, err error) {
+		if err != nil {
+			c.log.Warn("failed to load definitions", zap.String("source", string(from)), zap.Error(err))
+			lastErr = err
+			return
+		}
+
+		if source == SourceNone || defs.Version > best.Version {
+			best, source = defs, from
+		}
+	}
+
+	if c.cfg.PersistFile != "" {
+		defs, err := ReadDefinitions(c.cfg.PersistFile)
+		// nothing has been persisted yet the first time a client starts
+		if !os.IsNotExist(err) {
+			consider(defs, SourcePersisted, err)
+		}
+	}
+
+	if c.cfg.BootstrapFile != "" {
+		defs, err := ReadDefinitions(c.cfg.BootstrapFile)
+		consider(defs, SourceBootstrap, err)
+	}
+
+	if len(c.cfg.Bootstrap) > 0 {
+		defs, err := ParseDefinitions(c.cfg.Bootstrap)
+		consider(defs, SourceBootstrap, err)
+	}
+
+	c.status.LastError = lastErr
+	if source == SourceNone {
+		return
+	}
+
+	c.snapshot = togglr.NewSnapshot(best.Toggles)
+	c.status.Source = source
+	c.status.Version = best.Version
+	c.status.LoadedAt = time.Now()
+	c.log.Info("loaded local definitions", zap.String("source", string(source)), zap.Int64("version", best.Version))
+}
+
+// Status reports where the definitions in use came from and how the last refresh went
+func (c *Client) Status() Status {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.status
+}
+
+// Subscribe registers for changes to the Status. The returned channel receives a value whenever definitions are
+// loaded or a refresh finishes, and the returned function must be called once the subscriber is done. Notifications
+// that arrive while a subscriber is still busy are coalesced
+func (c *Client) Subscribe() (<-chan struct{}, func()) {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+
+	changes := make(chan struct{}, 1)
+	c.subs[changes] = struct{}{}
+
+	return changes, func() {
+		c.subsMu.Lock()
+		defer c.subsMu.Unlock()
+		delete(c.subs, changes)
+	}
+}
+
+// notify signals every subscriber
+func (c *Client) notify() {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+
+	for changes := range c.subs {
+		select {
+		case changes <- struct{}{}:
+		default:
+			// a notification is already pending, so the subscriber will pick up this change as well
+		}
+	}
+}
+
+// fail records a failed refresh in the Status
+func (c *Client) fail(err error) error {
+	c.mu.Lock()
+	c.status.LastError = err
+	c.mu.Unlock()
+
+	c.notify()
+	return err
+}
+
+// Run keeps the definitions up to date until the context is cancelled. Failed downloads are logged and retried on
+// the next poll or change, so Run only returns once the context is done
+func (c *Client) Run(ctx context.Context) {
+	defer c.log.Sync()
+	if c.cfg.Stream {
+		go c.watch(ctx)
+	}
+
+	if c.cfg.SendEvents {
+		go c.sendEvents(ctx)
+	}
+
+	ticker := time.NewTicker(c.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.Refresh(ctx); err != nil && ctx.Err() == nil {
+			c.log.Warn("failed to refresh definitions", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-c.changed:
+		}
+	}
+}
+
+// newRequest creates a request to a path on the server, authenticated with the APIKey if there is one
+func (c *Client) newRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(c.cfg.BaseURL, "/")+path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.cfg.APIKey)
+	}
+
+	return req, nil
+}
+
+// Refresh downloads the definitions once, replacing the ones in use if they've changed. It can be used to wait for
+// the first download before serving traffic. Successful downloads are written to the PersistFile, if there is one
+func (c *Client) Refresh(ctx context.Context) error {
+	req, err := c.newRequest(ctx, http.MethodGet, "/definitions/"+c.cfg.AccountID.String(), nil)
+	if err != nil {
+		return c.fail(err)
+	}
+
+	c.mu.RLock()
+	etag := c.etag
+	c.mu.RUnlock()
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return c.fail(err)
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusNotModified:
+		c.mu.Lock()
+		c.status.LastRefresh = time.Now()
+		c.status.LastError = nil
+		c.mu.Unlock()
+
+		c.notify()
+		return nil
+	case http.StatusOK:
+	default:
+		return c.fail(fmt.Errorf("unexpected status downloading definitions: %s", res.Status))
+	}
+
+	var defs togglr.Definitions
+	if err := json.NewDecoder(res.Body).Decode(&defs); err != nil {
+		return c.fail(fmt.Errorf("failed to decode definitions: %w", err))
+	}
+
+	now := time.Now()
+	c.mu.Lock()
+	c.snapshot = togglr.NewSnapshot(defs.Toggles)
+	c.etag = res.Header.Get("ETag")
+	c.status = Status{
+		Source:      SourceServer,
+		Version:     defs.Version,
+		LoadedAt:    now,
+		LastRefresh: now,
+	}
+	c.mu.Unlock()
+	c.notify()
+
+	c.log.Debug("refreshed definitions", zap.Int64("version", defs.Version), zap.Int("toggles", len(defs.Toggles)))
+	if c.cfg.PersistFile != "" {
+		// the download itself succeeded, so failing to persist it is only logged
+		if err := WriteDefinitions(c.cfg.PersistFile, defs); err != nil {
+			c.log.Warn("failed to persist definitions", zap.Error(err))
+		}
+	}
+
+	return nil
+}
+
+// watch listens to the server's stream of config versions, reconnecting with a backoff whenever it drops
+func (c *Client) watch(ctx context.Context) {
+	backoff := time.Second
+	for {
+		connected, err := c.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if connected {
+			backoff = time.Second
+		}
+		c.log.Debug("definitions stream disconnected", zap.Error(err), zap.Duration("backoff", backoff))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; backoff > maxStreamBackoff {
+			backoff = maxStreamBackoff
+		}
+	}
+}
+
+// listen reads a single stream connection until it drops. Every version event is treated as a change, including
+// the first one, which covers anything missed while disconnected. The returned bool is true if the stream was
+// connected at all
+func (c *Client) listen(ctx context.Context) (bool, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, "/definitions/"+c.cfg.AccountID.String()+"/stream", nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	res, err := c.stream.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("unexpected status opening stream: %s", res.Status)
+	}
+
+	scanner := bufio.NewScanner(res.Body)
+	for scanner.Scan() {
+		if scanner.Text() != "event: version" {
+			continue
+		}
+
+		// changes are coalesced, a pending refresh will pick up the latest definitions anyway
+		select {
+		case c.changed <- struct{}{}:
+		default:
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return true, err
+	}
+
+	return true, errors.New("stream closed by server")
+}
+
+// current returns the snapshot in use
+func (c *Client) current() togglr.Snapshot {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.snapshot
+}
+
+// A Result is the outcome of evaluating a single toggle
+type Result struct {
+	// Found is false if the toggle isn't in the definitions, or couldn't be evaluated
+	Found bool
+	Value bool
+	// Payload is only set when the toggle resolved to true
+	Payload togglr.Payload
+	// Targeted is true when the toggle has rules or prerequisites, so its value depends on the metadata
+	Targeted bool
+	Reason   togglr.Reason
+	// Variant is the key of the Variant assigned, for toggles with Variants that were split between them
+	Variant string
+}
+
+// Evaluate resolves a toggle. Evaluation never panics, a toggle that can't be evaluated isn't Found
+func (c *Client) Evaluate(key string, md rules.Metadata) (result Result) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.log.Error("recovered from panic evaluating toggle", zap.String("key", key), zap.Any("panic", r))
+			result = Result{}
+		}
+	}()
+
+	snapshot := c.current()
+	toggle, found := snapshot.Lookup(key)
+	if !found {
+		return Result{}
+	}
+
+	explanation := snapshot.Explain(key, md)
+	result = Result{
+		Found:    true,
+		Value:    explanation.Value,
+		Targeted: len(toggle.Rules) > 0 || len(toggle.Prerequisites) > 0,
+		Reason:   explanation.Reason,
+	}
+
+	if result.Value {
+		result.Payload = toggle.Payload
+	}
+
+	if explanation.Variant != nil {
+		result.Variant = explanation.Variant.Key
+		if result.Value && len(explanation.Variant.Payload) > 0 {
+			result.Payload = explanation.Variant.Payload
+		}
+	}
+
+	c.record(key, md, result)
+	return result
+}
+
+// Bool returns whether a toggle resolves to true, or def if the toggle doesn't exist
+func (c *Client) Bool(key string, md rules.Metadata, def bool) bool {
+	result := c.Evaluate(key, md)
+	if !result.Found {
+		return def
+	}
+
+	return result.Value
+}
+
+// decode unmarshals the Payload of a toggle that resolves to true, returning false if there's no such payload or it
+// doesn't fit into v
+func (c *Client) decode(key string, md rules.Metadata, v interface{}) bool {
+	result := c.Evaluate(key, md)
+	if !result.Value || len(result.Payload) == 0 {
+		return false
+	}
+
+	return json.Unmarshal(result.Payload, v) == nil
+}
+
+// String returns the Payload of a toggle as a string. def is returned if the toggle doesn't exist, resolves to false
+// or its Payload isn't a string
+func (c *Client) String(key string, md rules.Metadata, def string) string {
+	var val string
+	if !c.decode(key, md, &val) {
+		return def
+	}
+
+	return val
+}
+
+// Int returns the Payload of a toggle as an int. def is returned if the toggle doesn't exist, resolves to false or
+// its Payload isn't an integer
+func (c *Client) Int(key string, md rules.Metadata, def int) int {
+	var val int
+	if !c.decode(key, md, &val) {
+		return def
+	}
+
+	return val
+}
+
+// JSON returns the raw Payload of a toggle. def is returned if the toggle doesn't exist, resolves to false or has
+// no Payload
+func (c *Client) JSON(key string, md rules.Metadata, def json.RawMessage) json.RawMessage {
+	result := c.Evaluate(key, md)
+	if !result.Value || len(result.Payload) == 0 {
+		return def
+	}
+
+	// copied so that callers can't modify the definitions in use
+	return append(json.RawMessage{}, result.Payload...)
+}
+
+// All resolves every toggle. Toggles resolved this way aren't reported as events
+func (c *Client) All(md rules.Metadata) togglr.ResolvedToggles {
+	defer func() {
+		if r := recover(); r != nil {
+			c.log.Error("recovered from panic resolving toggles", zap.Any("panic", r))
+		}
+	}()
+
+	return c.current().Resolve(md)
+}
@@ -0,0 +1,228 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/client"
+	"github.com/togglr-io/togglr/http"
+	"github.com/togglr-io/togglr/mock"
+	"github.com/togglr-io/togglr/rules"
+	"github.com/togglr-io/togglr/uid"
+	"go.uber.org/zap"
+)
+
+// a fakeServer serves definitions from a list of toggles that can be changed while it's running
+type fakeServer struct {
+	mu      sync.Mutex
+	version int64
+	toggles []togglr.Toggle
+}
+
+func (f *fakeServer) set(toggles ...togglr.Toggle) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.version++
+	f.toggles = toggles
+}
+
+func (f *fakeServer) routes(hub *http.Hub) *httptest.Server {
+	ts := mock.NewToggleService(nil)
+	ts.ListTogglesFn = func(ctx context.Context, req togglr.ListTogglesReq) ([]togglr.Toggle, error) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		return f.toggles, nil
+	}
+
+	cvs := mock.NewConfigVersionService(nil)
+	cvs.FetchConfigVersionFn = func(ctx context.Context, accountID uid.UID) (int64, error) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		return f.version, nil
+	}
+
+	return httptest.NewServer(http.BuildRoutes(http.Config{
+		Logger: zap.NewNop(),
+		Hub:    hub,
+		Services: http.Services{
+			ToggleService:        ts,
+			ConfigVersionService: cvs,
+		},
+	}))
+}
+
+func adminToggle(key string, payload string) togglr.Toggle {
+	return togglr.Toggle{
+		ID:      uid.New(),
+		Key:     key,
+		Payload: togglr.Payload(payload),
+		Rules: rules.Rules{
+			{
+				Op: rules.BinOpAnd,
+				Expr: rules.Expression{
+					Type:   rules.ExprTypeBinary,
+					Binary: rules.NewBinary(rules.NewIdent("userType"), rules.NewString("admin"), rules.BinOpEq),
+				},
+			},
+		},
+	}
+}
+
+func Test_Client(t *testing.T) {
+	// SETUP
+	ctx := context.TODO()
+	fake := &fakeServer{}
+	fake.set(
+		adminToggle("bool", ""),
+		adminToggle("string", `"hello"`),
+		adminToggle("int", "42"),
+		adminToggle("json", `{"a":1}`),
+	)
+	s := fake.routes(nil)
+	c := client.New(client.Config{BaseURL: s.URL, AccountID: uid.New()})
+	admin := rules.Metadata{"userType": rules.NewString("admin")}
+	user := rules.Metadata{"userType": rules.NewString("user")}
+
+	// RUN
+	if !c.Bool("bool", admin, true) || c.String("string", admin, "default") != "default" {
+		t.Fatalf("expected defaults before definitions are downloaded")
+	}
+
+	if err := c.Refresh(ctx); err != nil {
+		t.Fatalf("failed to refresh: %s", err)
+	}
+
+	if !c.Bool("bool", admin, false) || c.Bool("bool", user, true) || !c.Bool("missing", admin, true) {
+		t.Fatalf("unexpected bool values")
+	}
+
+	if c.String("string", admin, "default") != "hello" || c.String("string", user, "default") != "default" {
+		t.Fatalf("unexpected string values")
+	}
+
+	if c.Int("int", admin, 0) != 42 || c.Int("string", admin, 7) != 7 {
+		t.Fatalf("unexpected int values")
+	}
+
+	if string(c.JSON("json", admin, nil)) != `{"a":1}` || c.JSON("bool", admin, json.RawMessage("null")) == nil {
+		t.Fatalf("unexpected json values")
+	}
+
+	// the last definitions downloaded keep being used once the server goes away
+	s.Close()
+	if err := c.Refresh(ctx); err == nil {
+		t.Fatalf("expected refreshing from a closed server to fail")
+	}
+
+	if c.String("string", admin, "default") != "hello" {
+		t.Fatalf("expected the last definitions to still be used")
+	}
+}
+
+func Test_ClientStream(t *testing.T) {
+	// SETUP
+	fake := &fakeServer{}
+	fake.set(adminToggle("string", `"before"`))
+	hub := http.NewHub()
+	s := fake.routes(hub)
+	defer s.Close()
+	// the client has to stop streaming before the server can close
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	accountID := uid.New()
+	c := client.New(client.Config{BaseURL: s.URL, AccountID: accountID, Stream: true, PollInterval: time.Hour})
+	admin := rules.Metadata{"userType": rules.NewString("admin")}
+
+	// RUN
+	go c.Run(ctx)
+	waitFor(t, func() bool { return c.String("string", admin, "") == "before" })
+
+	fake.set(adminToggle("string", `"after"`))
+	hub.Notify(accountID)
+	waitFor(t, func() bool { return c.String("string", admin, "") == "after" })
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for condition")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func Test_ClientBootstrap(t *testing.T) {
+	// SETUP
+	ctx := context.TODO()
+	dir := t.TempDir()
+	bootstrapFile := filepath.Join(dir, "bootstrap.yaml")
+	persistFile := filepath.Join(dir, "persisted.json")
+	admin := rules.Metadata{"userType": rules.NewString("admin")}
+
+	bootstrap := togglr.Definitions{Version: 2, Toggles: []togglr.Toggle{adminToggle("string", `"bootstrapped"`)}}
+	if err := client.WriteDefinitions(bootstrapFile, bootstrap); err != nil {
+		t.Fatalf("failed to write bootstrap file: %s", err)
+	}
+
+	embedded, err := client.MarshalDefinitions(togglr.Definitions{Version: 1, Toggles: []togglr.Toggle{adminToggle("string", `"embedded"`)}}, true)
+	if err != nil {
+		t.Fatalf("failed to marshal embedded definitions: %s", err)
+	}
+
+	fake := &fakeServer{version: 2}
+	fake.set(adminToggle("string", `"downloaded"`))
+	s := fake.routes(nil)
+	cfg := client.Config{
+		BaseURL:       s.URL,
+		AccountID:     uid.New(),
+		BootstrapFile: bootstrapFile,
+		Bootstrap:     embedded,
+		PersistFile:   persistFile,
+	}
+
+	// RUN
+	c := client.New(cfg)
+	if status := c.Status(); status.Source != client.SourceBootstrap || status.Version != 2 {
+		t.Fatalf("expected the newest bootstrap to be used, got %+v", status)
+	}
+
+	if c.String("string", admin, "") != "bootstrapped" {
+		t.Fatalf("expected bootstrapped definitions to be evaluated")
+	}
+
+	if err := c.Refresh(ctx); err != nil {
+		t.Fatalf("failed to refresh: %s", err)
+	}
+
+	if status := c.Status(); status.Source != client.SourceServer || status.Version != 3 {
+		t.Fatalf("expected downloaded definitions to be used, got %+v", status)
+	}
+
+	// a client starting while the server is down falls back to what was last downloaded
+	s.Close()
+	c = client.New(cfg)
+	if status := c.Status(); status.Source != client.SourcePersisted || status.Version != 3 {
+		t.Fatalf("expected persisted definitions to be used, got %+v", status)
+	}
+
+	if err := c.Refresh(ctx); err == nil {
+		t.Fatalf("expected refreshing from a closed server to fail")
+	}
+
+	status := c.Status()
+	if status.Source != client.SourcePersisted || status.LastError == nil {
+		t.Fatalf("expected the failed refresh to be reported, got %+v", status)
+	}
+
+	if c.String("string", admin, "") != "downloaded" {
+		t.Fatalf("expected persisted definitions to be evaluated")
+	}
+}
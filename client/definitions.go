@@ -0,0 +1,94 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/togglr-io/togglr"
+	"gopkg.in/yaml.v3"
+)
+
+// isYAML returns whether definitions at a path are stored as YAML rather than JSON
+func isYAML(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// ParseDefinitions decodes definitions given as either JSON or YAML
+func ParseDefinitions(data []byte) (togglr.Definitions, error) {
+	var defs togglr.Definitions
+	if !json.Valid(data) {
+		// toggles only know how to decode themselves from JSON, so YAML is converted first
+		var raw interface{}
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return defs, fmt.Errorf("definitions are neither JSON nor YAML: %w", err)
+		}
+
+		converted, err := json.Marshal(raw)
+		if err != nil {
+			return defs, err
+		}
+		data = converted
+	}
+
+	if err := json.Unmarshal(data, &defs); err != nil {
+		return defs, err
+	}
+
+	return defs, nil
+}
+
+// MarshalDefinitions encodes definitions as JSON, or as YAML if asYAML is set
+func MarshalDefinitions(defs togglr.Definitions, asYAML bool) ([]byte, error) {
+	data, err := json.MarshalIndent(defs, "", "  ")
+	if err != nil || !asYAML {
+		return data, err
+	}
+
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	return yaml.Marshal(raw)
+}
+
+// ReadDefinitions reads definitions from a JSON or YAML file
+func ReadDefinitions(path string) (togglr.Definitions, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return togglr.Definitions{}, err
+	}
+
+	return ParseDefinitions(data)
+}
+
+// WriteDefinitions writes definitions to a file, as YAML if it has a .yaml or .yml extension and JSON otherwise.
+// The file is replaced atomically, so readers never see partially written definitions
+func WriteDefinitions(path string, defs togglr.Definitions) error {
+	data, err := MarshalDefinitions(defs, isYAML(path))
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,110 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/rules"
+	"go.uber.org/zap"
+)
+
+// the number of events that can be waiting to be sent before new ones are dropped
+const eventBufferSize = 5000
+
+// how long the last batch of events has to be sent once Run is stopped
+const finalEventTimeout = 5 * time.Second
+
+// record queues an evaluation to be sent to the server, dropping it if the queue is full or events aren't being sent
+func (c *Client) record(key string, md rules.Metadata, result Result) {
+	if c.events == nil {
+		return
+	}
+
+	event := togglr.EvaluationEvent{
+		Key:        key,
+		Variant:    togglr.VariantOff,
+		Reason:     result.Reason,
+		ContextKey: togglr.ContextKey(md),
+		Timestamp:  time.Now(),
+	}
+
+	switch {
+	case result.Variant != "":
+		event.Variant = result.Variant
+	case result.Value:
+		event.Variant = togglr.VariantOn
+	}
+
+	select {
+	case c.events <- event:
+	default:
+	}
+}
+
+// sendEvents sends queued events every EventInterval until the context is cancelled, at which point whatever is
+// left is sent one last time
+func (c *Client) sendEvents(ctx context.Context) {
+	ticker := time.NewTicker(c.cfg.EventInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.flushEvents(ctx)
+		case <-ctx.Done():
+			ctx, cancel := context.WithTimeout(context.Background(), finalEventTimeout)
+			c.flushEvents(ctx)
+			cancel()
+			return
+		}
+	}
+}
+
+// flushEvents sends everything that's queued. Events that fail to send are dropped, since holding on to them would
+// only delay newer ones
+func (c *Client) flushEvents(ctx context.Context) {
+	// nothing else reads from the queue, so at least this many events can be read without blocking
+	queued := len(c.events)
+	if queued == 0 {
+		return
+	}
+
+	events := make([]togglr.EvaluationEvent, queued)
+	for i := range events {
+		events[i] = <-c.events
+	}
+
+	if err := c.postEvents(ctx, events); err != nil {
+		c.log.Warn("failed to send evaluation events", zap.Error(err), zap.Int("events", len(events)))
+	}
+}
+
+func (c *Client) postEvents(ctx context.Context, events []togglr.EvaluationEvent) error {
+	data, err := json.Marshal(events)
+	if err != nil {
+		return err
+	}
+
+	req, err := c.newRequest(ctx, http.MethodPost, "/events/"+c.cfg.AccountID.String(), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("unexpected status sending events: %s", res.Status)
+	}
+
+	return nil
+}
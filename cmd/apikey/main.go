@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/pg"
+	"github.com/togglr-io/togglr/uid"
+)
+
+// run creates an APIKey directly in the database and prints its secret. It's how the first management key of an
+// account is made, since creating keys through the API already takes one
+func run(key togglr.APIKey) error {
+	db, err := pg.NewClient(pg.ConfigFromEnv("TOGGLE"))
+	if err != nil {
+		return fmt.Errorf("failed to create database connection: %w", err)
+	}
+
+	key, secret, err := togglr.NewAPIKey(key)
+	if err != nil {
+		return err
+	}
+
+	if _, err := db.CreateAPIKey(context.Background(), key); err != nil {
+		return fmt.Errorf("failed to create api key: %w", err)
+	}
+
+	fmt.Printf("created %s key %s (%s)\n%s\n", key.Kind, key.Name, key.ID, secret)
+	return nil
+}
+
+func main() {
+	if len(os.Args) < 4 {
+		log.Fatal("apikey must be called with an account ID, a kind of client, server or management, a name and optionally an environment")
+	}
+
+	accountID, err := uid.FromString(os.Args[1])
+	if err != nil {
+		log.Fatalf("invalid account ID: %s", err)
+	}
+
+	key := togglr.APIKey{
+		AccountID: accountID,
+		Kind:      togglr.APIKeyKind(os.Args[2]),
+		Name:      os.Args[3],
+	}
+
+	if len(os.Args) > 4 {
+		key.Environment = os.Args[4]
+	}
+
+	if err := run(key); err != nil {
+		log.Fatal(err)
+	}
+}
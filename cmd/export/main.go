@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/client"
+	"github.com/togglr-io/togglr/pg"
+	"github.com/togglr-io/togglr/uid"
+)
+
+// run exports the definitions of an account so that clients can be bootstrapped from them. They're written to path
+// as JSON or YAML depending on its extension, or printed as JSON when there's no path
+func run(accountID uid.UID, path string) error {
+	db, err := pg.NewClient(pg.ConfigFromEnv("TOGGLE"))
+	if err != nil {
+		return fmt.Errorf("failed to create database connection: %w", err)
+	}
+
+	ctx := context.Background()
+	// the version is read first so that the toggles are at least as new as the version they're exported with
+	version, err := db.FetchConfigVersion(ctx, accountID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch config version: %w", err)
+	}
+
+	toggles, err := db.ListToggles(ctx, togglr.ListTogglesReq{AccountID: accountID})
+	if err != nil {
+		return fmt.Errorf("failed to list toggles: %w", err)
+	}
+
+	defs := togglr.Definitions{Version: version, Toggles: toggles}
+	if path == "" {
+		data, err := client.MarshalDefinitions(defs, false)
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Println(string(data))
+		return err
+	}
+
+	if err := client.WriteDefinitions(path, defs); err != nil {
+		return fmt.Errorf("failed to write definitions: %w", err)
+	}
+
+	fmt.Printf("exported %d toggles at version %d to %s\n", len(toggles), version, path)
+	return nil
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		log.Fatal("export must be called with an account ID and optionally a file to write to")
+	}
+
+	accountID, err := uid.FromString(os.Args[1])
+	if err != nil {
+		log.Fatalf("invalid account ID: %s", err)
+	}
+
+	path := ""
+	if len(os.Args) > 2 {
+		path = os.Args[2]
+	}
+
+	if err := run(accountID, path); err != nil {
+		log.Fatal(err)
+	}
+}
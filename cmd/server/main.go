@@ -1,13 +1,19 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"strings"
+	"time"
 
 	"github.com/mattn/go-colorable"
 	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/cache"
 	"github.com/togglr-io/togglr/env"
+	"github.com/togglr-io/togglr/hmac"
 	"github.com/togglr-io/togglr/http"
+	"github.com/togglr-io/togglr/oauth"
 	"github.com/togglr-io/togglr/pg"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -37,13 +43,78 @@ func run() error {
 		return fmt.Errorf("failed to create database connection: %w", err)
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// evaluations are aggregated in memory and flushed in the background to keep resolves fast
+	usageRecorder := togglr.NewUsageRecorder(db, time.Duration(env.GetUint("TOGGLE_USAGE_FLUSH_SECONDS", 10))*time.Second, log)
+	go usageRecorder.Run(ctx)
+	eventRecorder := togglr.NewEventRecorder(db, time.Duration(env.GetUint("TOGGLE_EVENT_FLUSH_SECONDS", 10))*time.Second, log)
+	go eventRecorder.Run(ctx)
+	exposureRecorder := togglr.NewExposureRecorder(db, time.Duration(env.GetUint("TOGGLE_EXPOSURE_FLUSH_SECONDS", 10))*time.Second, log)
+	go exposureRecorder.Run(ctx)
+	recorders := togglr.Recorders{usageRecorder, eventRecorder, exposureRecorder}
+
+	// resolves are answered from in-memory snapshots that are invalidated by writes made through toggleService
+	cachedToggles := cache.NewToggleService(db, time.Duration(env.GetUint("TOGGLE_CACHE_TTL_SECONDS", 30))*time.Second)
+
+	// writes made by any server are picked up through postgres change notifications. The cache has to be
+	// invalidated before streams re-resolve, so both are handled in order here
+	hub := http.NewHub()
+	listener := pg.NewListener(pg.ConfigFromEnv("TOGGLE"), log)
+	changes := listener.Subscribe()
+	go func() {
+		for event := range changes {
+			cachedToggles.HandleChange(event)
+			hub.HandleChange(event)
+		}
+	}()
+	go func() {
+		if err := listener.Run(ctx); err != nil {
+			log.Error("change listener stopped", zap.Error(err))
+		}
+	}()
+
 	// initialize services to be used
+	toggleService := togglr.NewToggleService(cachedToggles, db, log)
 	services := http.Services{
-		ToggleService:   togglr.NewToggleService(db, db, log),
-		MetadataService: db,
-		AccountService:  db,
-		UserService:     db,
-		Resolver:        togglr.NewResolver(db),
+		ToggleService:        toggleService,
+		MetadataService:      db,
+		AccountService:       db,
+		UserService:          db,
+		ChangeRequestService: togglr.NewChangeRequestService(db, toggleService, log),
+		UsageService:         db,
+		EventService:         db,
+		ExperimentService:    togglr.NewExperimentService(db, db, toggleService, log),
+		HoldoutService:       db,
+		SessionService:       db,
+		PasswordService:      db,
+		ConfigVersionService: db,
+		Resolver:             togglr.NewResolver(cachedToggles, recorders),
+		EvaluationRecorder:   recorders,
+	}
+
+	// resolved toggles can only be requested as signed tokens, and users can only log in, when signing keys are
+	// configured
+	signingKeys, err := hmac.KeySetFromEnv("TOGGLE_SIGNING_KEYS")
+	if err != nil {
+		return fmt.Errorf("failed to load signing keys: %w", err)
+	}
+
+	if signingKeys != nil {
+		services.Signer = signingKeys
+	}
+
+	// users can log in with github and google once their oauth apps are configured
+	for _, identity := range []togglr.IdentityType{togglr.IdentityTypeGithub, togglr.IdentityTypeGoogle} {
+		if provider, ok := oauth.FromEnv("TOGGLE", identity); ok {
+			services.IdentityProviders = append(services.IdentityProviders, provider)
+		}
+	}
+
+	// requests have to be authenticated with api keys unless auth is explicitly turned off, e.g. for local development
+	if env.GetString("TOGGLE_AUTH", "on") != "off" {
+		services.APIKeyService = cache.NewAPIKeyService(db, time.Duration(env.GetUint("TOGGLE_API_KEY_CACHE_SECONDS", 30))*time.Second)
 	}
 
 	// build server
@@ -52,12 +123,32 @@ func run() error {
 		Port:     port,
 		Logger:   log,
 		Services: services,
+		Hub:      hub,
+		TokenTTL: time.Duration(env.GetUint("TOGGLE_TOKEN_TTL_SECONDS", 300)) * time.Second,
+		// session cookies are only sent over HTTPS unless turned off, e.g. for local development
+		SessionTTL:    time.Duration(env.GetUint("TOGGLE_SESSION_TTL_SECONDS", 86400)) * time.Second,
+		SecureCookies: env.GetString("TOGGLE_SECURE_COOKIES", "on") != "off",
+		LoginRedirect: env.GetString("TOGGLE_LOGIN_REDIRECT", "/"),
+		// nobody can sign up with an identity provider unless their email domain is listed, e.g. "example.com"
+		SignupDomains: signupDomains(env.GetString("TOGGLE_SIGNUP_DOMAINS", "")),
 	}
 
 	log.Info("starting server", zap.String("host", host), zap.Uint("port", port))
 	return http.Listen(cfg)
 }
 
+// signupDomains splits a comma separated list of email domains
+func signupDomains(list string) []string {
+	var domains []string
+	for _, domain := range strings.Split(list, ",") {
+		if domain = strings.TrimSpace(domain); domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+
+	return domains
+}
+
 func main() {
 	if err := run(); err != nil {
 		log.Fatal(err)
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/pg"
+	"github.com/togglr-io/togglr/uid"
+)
+
+const defaultDays = 30
+
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return "never"
+	}
+
+	return t.Format("2006-01-02")
+}
+
+func run(accountID uid.UID, days int) error {
+	db, err := pg.NewClient(pg.ConfigFromEnv("TOGGLE"))
+	if err != nil {
+		return fmt.Errorf("failed to create database connection: %w", err)
+	}
+
+	stale, err := togglr.FindStaleToggles(context.Background(), db, db, accountID, time.Duration(days)*24*time.Hour)
+	if err != nil {
+		return fmt.Errorf("failed to find stale toggles: %w", err)
+	}
+
+	if len(stale) == 0 {
+		fmt.Printf("no toggles have gone stale in the last %d days\n", days)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tREASON\tOWNER\tLAST EVALUATED\tLAST TRUE\tLAST FALSE")
+	for _, s := range stale {
+		var evaluated, lastTrue, lastFalse *time.Time
+		if s.Usage != nil {
+			evaluated, lastTrue, lastFalse = &s.Usage.LastEvaluatedAt, s.Usage.LastTrueAt, s.Usage.LastFalseAt
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			s.Toggle.Key,
+			s.Reason,
+			s.Toggle.Owner,
+			formatTime(evaluated),
+			formatTime(lastTrue),
+			formatTime(lastFalse),
+		)
+	}
+
+	return w.Flush()
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		log.Fatal("stale must be called with an account ID and optionally a number of days")
+	}
+
+	accountID, err := uid.FromString(os.Args[1])
+	if err != nil {
+		log.Fatalf("invalid account ID: %s", err)
+	}
+
+	days := defaultDays
+	if len(os.Args) > 2 {
+		if days, err = strconv.Atoi(os.Args[2]); err != nil || days < 1 {
+			log.Fatal("days must be a positive number")
+		}
+	}
+
+	if err := run(accountID, days); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/pg"
+)
+
+// run creates a User with the basic IdentityType and a password directly in the database. It's how the first User
+// is made, since creating Users through the API already takes a Session, so Users made here are admins
+func run(user togglr.User, password string) error {
+	db, err := pg.NewClient(pg.ConfigFromEnv("TOGGLE"))
+	if err != nil {
+		return fmt.Errorf("failed to create database connection: %w", err)
+	}
+
+	hash, err := togglr.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	userID, err := db.CreateUser(ctx, user)
+	if err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
+	if err := db.SetPasswordHash(ctx, userID, hash); err != nil {
+		return fmt.Errorf("failed to set password: %w", err)
+	}
+
+	fmt.Printf("created admin %s (%s)\n", user.Email, userID)
+	return nil
+}
+
+func main() {
+	if len(os.Args) < 3 {
+		log.Fatal("user must be called with an email and a name, the password is read from stdin")
+	}
+
+	user := togglr.User{
+		Email:    os.Args[1],
+		Name:     os.Args[2],
+		Identity: togglr.IdentityTypeBasic,
+		Admin:    true,
+	}
+
+	// the password isn't taken as an argument so that it doesn't end up in the shell's history
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && password == "" {
+		log.Fatalf("failed to read password: %s", err)
+	}
+
+	if err := run(user, strings.TrimRight(password, "\r\n")); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,51 @@
+package togglr
+
+import "errors"
+
+// Errors returned by service implementations that callers are expected to handle explicitly
+var (
+	// ErrNotFound is returned when the resource being operated on doesn't exist
+	ErrNotFound = errors.New("resource not found")
+	// ErrConflict is returned when an update is made against a version of a resource that is no longer current
+	ErrConflict = errors.New("resource was modified by another request")
+	// ErrInvalidPage is returned when the sorting or pagination parameters of a list request can't be used
+	ErrInvalidPage = errors.New("invalid page")
+	// ErrApprovalRequired is returned when a Toggle that requires approval is edited directly
+	ErrApprovalRequired = errors.New("toggle requires an approved change request to be modified")
+	// ErrReviewerRequired is returned when a ChangeRequest is approved or rejected without identifying the reviewer
+	ErrReviewerRequired = errors.New("change requests must be reviewed by a user")
+	// ErrSelfApproval is returned when the author of a ChangeRequest attempts to review it
+	ErrSelfApproval = errors.New("change requests cannot be reviewed by their author")
+	// ErrInvalidTransition is returned when a ChangeRequest or Experiment can't move to the requested status
+	ErrInvalidTransition = errors.New("resource cannot move to the requested status")
+	// ErrNotArchived is returned when a Toggle is purged without being archived first
+	ErrNotArchived = errors.New("toggle must be archived before it can be purged")
+	// ErrPrerequisite is returned when archiving or purging a Toggle that other toggles list as a prerequisite
+	ErrPrerequisite = errors.New("toggle is a prerequisite of other toggles")
+	// ErrKeyTaken is returned when restoring a Toggle whose key has since been given to another Toggle
+	ErrKeyTaken = errors.New("toggle key is already in use")
+	// ErrInvalidVariants is returned when the Variants of a Toggle have missing or duplicate keys, negative weights
+	// or no weight at all
+	ErrInvalidVariants = errors.New("invalid variants")
+	// ErrInvalidKind is returned when a Toggle is given a ToggleKind that doesn't exist
+	ErrInvalidKind = errors.New("invalid toggle kind")
+	// ErrInvalidExperiment is returned when an Experiment doesn't fit the Toggle it's created for
+	ErrInvalidExperiment = errors.New("invalid experiment")
+	// ErrExperimentRunning is returned when starting an Experiment on a Toggle that already has one running, or
+	// when changing the Variants of a Toggle while an Experiment is running on it
+	ErrExperimentRunning = errors.New("toggle has a running experiment")
+	// ErrLayerFull is returned when starting an Experiment in a Layer that doesn't have enough free traffic left
+	ErrLayerFull = errors.New("layer does not have enough free traffic")
+	// ErrInvalidAPIKey is returned when authenticating with an APIKey that doesn't exist or has been revoked
+	ErrInvalidAPIKey = errors.New("invalid api key")
+	// ErrInvalidPassword is returned when setting a password that's too short or too long to be hashed
+	ErrInvalidPassword = errors.New("passwords must be between 8 and 72 bytes long")
+	// ErrInvalidCredentials is returned when logging in with an unknown email or the wrong password
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	// ErrInvalidSession is returned when authenticating with a Session that doesn't exist or has expired
+	ErrInvalidSession = errors.New("invalid session")
+	// ErrUserExists is returned when creating a User with the email and IdentityType of another User
+	ErrUserExists = errors.New("a user with this email and identity type already exists")
+	// ErrUnknownUser is returned when logging in with an IdentityProvider as someone who has no User and can't sign up
+	ErrUnknownUser = errors.New("unknown user")
+)
@@ -0,0 +1,156 @@
+package togglr
+
+import (
+	"context"
+	"time"
+
+	"github.com/togglr-io/togglr/rules"
+	"github.com/togglr-io/togglr/uid"
+	"go.uber.org/zap"
+)
+
+// the number of evaluations that can be waiting to be counted before new ones are dropped
+const eventBufferSize = 10000
+
+// EventBucketSize is the size of the time buckets evaluations are counted in. Coarser intervals are reported by
+// adding buckets together
+const EventBucketSize = time.Hour
+
+// ContextKeyName is the metadata key identifying who toggles are being evaluated for. It matches the targeting key
+// of OpenFeature evaluation contexts
+const ContextKeyName = "targetingKey"
+
+// Variants a Toggle without Variants of its own can resolve to
+const (
+	VariantOn  = "on"
+	VariantOff = "off"
+)
+
+// A Reason explains why a Toggle resolved to the value it did
+type Reason string
+
+// Enumeration of possible Reasons
+const (
+	// ReasonStatic means the Toggle has no rules or prerequisites, so it resolves the same for everyone
+	ReasonStatic = Reason("static")
+	// ReasonTargetingMatch means the value came from evaluating the Toggle's rules against the metadata
+	ReasonTargetingMatch = Reason("targeting_match")
+	// ReasonPrerequisiteFailed means the Toggle resolved to false because one of its prerequisites did
+	ReasonPrerequisiteFailed = Reason("prerequisite_failed")
+	// ReasonSplit means the Toggle's rules matched and the metadata was assigned one of its Variants
+	ReasonSplit = Reason("split")
+	// ReasonHoldout means the metadata belongs to one of the account's Holdouts, so it was served the Control of
+	// the Toggle's Experiment without being exposed
+	ReasonHoldout = Reason("holdout")
+	// ReasonExcluded means the metadata fell outside of the traffic of the Toggle's Experiment, so it was served
+	// the Control without being exposed
+	ReasonExcluded = Reason("excluded")
+)
+
+// Valid returns true for the known Reasons
+func (r Reason) Valid() bool {
+	switch r {
+	case ReasonStatic, ReasonTargetingMatch, ReasonPrerequisiteFailed, ReasonSplit, ReasonHoldout, ReasonExcluded:
+		return true
+	}
+
+	return false
+}
+
+// ContextKey returns the ContextKeyName value of the metadata, or an empty string if it doesn't have one that's a
+// string
+func ContextKey(md rules.Metadata) string {
+	if key, ok := md[ContextKeyName].(rules.String); ok {
+		return key.Value
+	}
+
+	return ""
+}
+
+// An EvaluationEvent is an Evaluation reported by an SDK that evaluated a Toggle locally
+type EvaluationEvent struct {
+	Key        string    `json:"key"`
+	Variant    string    `json:"variant"`
+	Reason     Reason    `json:"reason"`
+	ContextKey string    `json:"contextKey,omitempty"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// Recorders is an EvaluationRecorder that passes every evaluation on to each of its EvaluationRecorders
+type Recorders []EvaluationRecorder
+
+// RecordEvaluation implements the EvaluationRecorder interface
+func (rs Recorders) RecordEvaluation(eval Evaluation) {
+	for _, r := range rs {
+		r.RecordEvaluation(eval)
+	}
+}
+
+// An EventRecorder is an EvaluationRecorder that counts evaluations by Toggle, variant and reason in time buckets
+// of EventBucketSize, periodically adding the counts to an EventService. Context keys aren't kept, so the number of
+// counts stays bounded by the number of Toggles rather than growing with the number of users
+type EventRecorder struct {
+	batchRecorder
+	es      EventService
+	pending map[countKey]EvaluationCount
+}
+
+// NewEventRecorder returns a new EventRecorder that flushes to the given EventService every interval. Run must be
+// called for anything to be flushed
+func NewEventRecorder(es EventService, interval time.Duration, logger *zap.Logger) *EventRecorder {
+	r := &EventRecorder{
+		es:      es,
+		pending: make(map[countKey]EvaluationCount),
+	}
+	r.batchRecorder = newBatchRecorder(eventBufferSize, interval, r.count, r.flushCounts, logger)
+
+	return r
+}
+
+func (r *EventRecorder) flushCounts(ctx context.Context) {
+	if len(r.pending) == 0 {
+		return
+	}
+
+	counts := make([]EvaluationCount, 0, len(r.pending))
+	for _, count := range r.pending {
+		counts = append(counts, count)
+	}
+	r.pending = make(map[countKey]EvaluationCount)
+
+	if err := r.es.RecordEvaluationCounts(ctx, counts...); err != nil {
+		r.log.Error("failed to record evaluation counts", zap.Error(err), zap.Int("counts", len(counts)))
+	}
+}
+
+// a countKey identifies everything counted together
+type countKey struct {
+	toggleID uid.UID
+	bucket   time.Time
+	variant  string
+	reason   Reason
+}
+
+// count adds an Evaluation to the count for its Toggle, bucket, variant and reason
+func (r *EventRecorder) count(eval Evaluation) {
+	key := countKey{
+		toggleID: eval.ToggleID,
+		bucket:   eval.Time.UTC().Truncate(EventBucketSize),
+		variant:  eval.Variant(),
+		reason:   eval.Reason,
+	}
+
+	count, ok := r.pending[key]
+	if !ok {
+		count = EvaluationCount{
+			ToggleID:  eval.ToggleID,
+			AccountID: eval.AccountID,
+			Bucket:    key.bucket,
+			Variant:   key.variant,
+			Reason:    key.reason,
+		}
+	}
+
+	count.Count++
+	r.pending[key] = count
+}
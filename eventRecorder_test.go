@@ -0,0 +1,77 @@
+package togglr_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/mock"
+	"github.com/togglr-io/togglr/uid"
+	"go.uber.org/zap"
+)
+
+func Test_EventRecorder(t *testing.T) {
+	// SETUP
+	es := mock.NewEventService(nil)
+	var counts []togglr.EvaluationCount
+	es.RecordEvaluationCountsFn = func(ctx context.Context, c ...togglr.EvaluationCount) error {
+		counts = append(counts, c...)
+		return nil
+	}
+
+	// the interval is long enough that only the final flush happens
+	recorder := togglr.NewEventRecorder(es, time.Hour, zap.NewNop())
+	accountID, toggleID := uid.New(), uid.New()
+	bucket := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	evals := []togglr.Evaluation{
+		{Value: true, Reason: togglr.ReasonTargetingMatch, Time: bucket.Add(time.Minute)},
+		{Value: true, Reason: togglr.ReasonTargetingMatch, Time: bucket.Add(59 * time.Minute)},
+		{Value: false, Reason: togglr.ReasonTargetingMatch, Time: bucket.Add(30 * time.Minute)},
+		{Value: false, Reason: togglr.ReasonPrerequisiteFailed, Time: bucket.Add(30 * time.Minute)},
+		{Value: true, Reason: togglr.ReasonTargetingMatch, Time: bucket.Add(time.Hour)},
+	}
+
+	// RUN
+	for _, eval := range evals {
+		eval.AccountID = accountID
+		eval.ToggleID = toggleID
+		recorder.RecordEvaluation(eval)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	recorder.Run(ctx)
+
+	if es.RecordEvaluationCountsCalled != 1 {
+		t.Fatalf("expected counts to be flushed once, got %d", es.RecordEvaluationCountsCalled)
+	}
+
+	type key struct {
+		bucket  time.Time
+		variant string
+		reason  togglr.Reason
+	}
+
+	expected := map[key]int64{
+		{bucket, togglr.VariantOn, togglr.ReasonTargetingMatch}:                2,
+		{bucket, togglr.VariantOff, togglr.ReasonTargetingMatch}:               1,
+		{bucket, togglr.VariantOff, togglr.ReasonPrerequisiteFailed}:           1,
+		{bucket.Add(time.Hour), togglr.VariantOn, togglr.ReasonTargetingMatch}: 1,
+	}
+
+	if len(counts) != len(expected) {
+		t.Fatalf("expected %d counts, got %d", len(expected), len(counts))
+	}
+
+	for _, count := range counts {
+		if !count.ToggleID.Equals(toggleID) || !count.AccountID.Equals(accountID) {
+			t.Fatalf("expected counts to belong to the evaluated toggle")
+		}
+
+		k := key{count.Bucket, count.Variant, count.Reason}
+		if expected[k] != count.Count {
+			t.Fatalf("expected %d evaluations for %v, got %d", expected[k], k, count.Count)
+		}
+	}
+}
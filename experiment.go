@@ -0,0 +1,195 @@
+package togglr
+
+import (
+	"context"
+	"hash/fnv"
+	"time"
+
+	"github.com/togglr-io/togglr/stats"
+	"github.com/togglr-io/togglr/uid"
+)
+
+// Assign picks the Variant for a context key. Assignment is deterministic, so a context keeps its Variant for as
+// long as the seed and weights stay the same. Every context without a key gets the same Variant. There must be at
+// least one Variant
+func (v Variants) Assign(seed, contextKey string) Variant {
+	total := 0
+	for _, variant := range v {
+		if variant.Weight > 0 {
+			total += variant.Weight
+		}
+	}
+
+	if total == 0 {
+		return v[0]
+	}
+
+	slot := bucket(seed, contextKey, total)
+	for _, variant := range v {
+		if variant.Weight <= 0 {
+			continue
+		}
+
+		if slot < variant.Weight {
+			return variant
+		}
+		slot -= variant.Weight
+	}
+
+	return v[len(v)-1]
+}
+
+// Holds returns true if the context key belongs to the Holdout
+func (h Holdout) Holds(contextKey string) bool {
+	return bucket("holdout."+h.ID.String(), contextKey, LayerSlots) < h.Percent
+}
+
+// bucket deterministically hashes a context key into one of n buckets. Different seeds give independent buckets,
+// so being in a bucket for one seed says nothing about the bucket for another
+func bucket(seed, contextKey string, n int) int {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(seed + "." + contextKey))
+	return int(h.Sum64() % uint64(n))
+}
+
+// bucketSeed returns what Variants are assigned with. Each Experiment reshuffles assignments so that one doesn't
+// carry over into the next
+func (t Toggle) bucketSeed() string {
+	if !t.ExperimentID.IsNull() {
+		return t.ExperimentID.String()
+	}
+
+	return t.ID.String()
+}
+
+// layerSeed returns what the slots of the Layer of the Toggle's Experiment are assigned with. Every Toggle with an
+// Experiment in the same Layer of an account shares it, which is what keeps the Experiments mutually exclusive
+func (t Toggle) layerSeed() string {
+	if t.Allocation.Layer == "" {
+		return "layer." + t.ExperimentID.String()
+	}
+
+	return "layer." + t.AccountID.String() + "." + t.Allocation.Layer
+}
+
+// assign picks the Variant served to a context key, why, and whether the context is exposed to the Toggle's running
+// Experiment. Contexts that are held out or outside of the Experiment's slots are served the Control. There must be
+// at least one Variant
+func (t Toggle) assign(contextKey string) (Variant, Reason, bool) {
+	if t.ExperimentID.IsNull() || t.Allocation == nil {
+		return t.Variants.Assign(t.bucketSeed(), contextKey), ReasonSplit, !t.ExperimentID.IsNull()
+	}
+
+	control, ok := t.Variants.Find(t.Allocation.Control)
+	if !ok {
+		control = t.Variants[0]
+	}
+
+	for _, holdout := range t.Allocation.Holdouts {
+		if holdout.Holds(contextKey) {
+			return control, ReasonHoldout, false
+		}
+	}
+
+	slot := bucket(t.layerSeed(), contextKey, LayerSlots)
+	if slot < t.Allocation.SlotStart || slot >= t.Allocation.SlotEnd {
+		return control, ReasonExcluded, false
+	}
+
+	return t.Variants.Assign(t.bucketSeed(), contextKey), ReasonSplit, true
+}
+
+// RunningAt returns true if the Experiment was running at the given time
+func (e Experiment) RunningAt(at time.Time) bool {
+	if e.StartedAt == nil || at.Before(*e.StartedAt) {
+		return false
+	}
+
+	return e.StoppedAt == nil || at.Before(*e.StoppedAt)
+}
+
+// A VariantResult describes how a Variant of an Experiment performed. The comparisons are with the Control and are
+// left empty for the Control itself
+type VariantResult struct {
+	VariantTally
+	ConversionRate     float64    `json:"conversionRate"`
+	ConfidenceInterval [2]float64 `json:"confidenceInterval"`
+	ValuePerExposure   float64    `json:"valuePerExposure"`
+
+	Lift                     float64 `json:"lift"`
+	PValue                   float64 `json:"pValue"`
+	Significant              bool    `json:"significant"`
+	ProbabilityToBeatControl float64 `json:"probabilityToBeatControl"`
+}
+
+// ExperimentResults are the results of an Experiment so far, with a VariantResult for each of its Variants
+type ExperimentResults struct {
+	Experiment Experiment      `json:"experiment"`
+	Confidence float64         `json:"confidence"`
+	Variants   []VariantResult `json:"variants"`
+}
+
+// the confidence level of intervals and significance in ExperimentResults, and the z-score it corresponds to
+const (
+	resultConfidence = 0.95
+	resultZ          = stats.Z95
+)
+
+// AnalyzeExperiment computes the results of an Experiment. Conversion rates come with Wilson score intervals, and
+// every Variant is compared to the Control with a two-proportion z-test and the Bayesian probability that it
+// converts at a higher rate. Experiments that haven't started have no results
+func AnalyzeExperiment(ctx context.Context, es ExperimentService, id uid.UID) (ExperimentResults, error) {
+	exp, err := es.FetchExperiment(ctx, id)
+	if err != nil {
+		return ExperimentResults{}, err
+	}
+
+	results := ExperimentResults{
+		Experiment: exp,
+		Confidence: resultConfidence,
+		Variants:   []VariantResult{},
+	}
+
+	if exp.Status == ExperimentStatusDraft {
+		return results, nil
+	}
+
+	tallies, err := es.TallyExperiment(ctx, exp)
+	if err != nil {
+		return results, err
+	}
+
+	byVariant := make(map[string]VariantTally, len(tallies))
+	for _, tally := range tallies {
+		byVariant[tally.Variant] = tally
+	}
+
+	control := byVariant[exp.Control]
+	controlRate := stats.Rate(control.Conversions, control.Exposures)
+	for _, key := range exp.Variants {
+		tally := byVariant[key]
+		tally.Variant = key
+
+		result := VariantResult{
+			VariantTally:   tally,
+			ConversionRate: stats.Rate(tally.Conversions, tally.Exposures),
+		}
+		result.ConfidenceInterval[0], result.ConfidenceInterval[1] = stats.WilsonInterval(tally.Conversions, tally.Exposures, resultZ)
+		if tally.Exposures > 0 {
+			result.ValuePerExposure = tally.Value / float64(tally.Exposures)
+		}
+
+		if key != exp.Control {
+			if controlRate > 0 {
+				result.Lift = (result.ConversionRate - controlRate) / controlRate
+			}
+			result.PValue = stats.TwoProportionPValue(control.Conversions, control.Exposures, tally.Conversions, tally.Exposures)
+			result.Significant = result.PValue < 1-resultConfidence
+			result.ProbabilityToBeatControl = stats.ProbabilityToBeat(control.Conversions, control.Exposures, tally.Conversions, tally.Exposures)
+		}
+
+		results.Variants = append(results.Variants, result)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,227 @@
+package togglr
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/togglr-io/togglr/uid"
+	"go.uber.org/zap"
+)
+
+// A DefaultExperimentService provides a default implementation of the ExperimentService interface that wraps
+// another ExperimentService, enforces the lifecycle of Experiments and keeps their Toggles in sync
+type DefaultExperimentService struct {
+	es ExperimentService
+	hs HoldoutService
+	ts ToggleService
+
+	log *zap.Logger
+}
+
+// NewExperimentService returns a new DefaultExperimentService. Toggles are read from the given ToggleService when
+// Experiments start and stop, and the account's Holdouts are read from the given HoldoutService when they start
+func NewExperimentService(es ExperimentService, hs HoldoutService, ts ToggleService, logger *zap.Logger) DefaultExperimentService {
+	return DefaultExperimentService{
+		es:  es,
+		hs:  hs,
+		ts:  ts,
+		log: logger,
+	}
+}
+
+// CreateExperiment creates a draft Experiment on a multivariate Toggle. The Control defaults to the Toggle's first
+// Variant and Traffic defaults to all of the Layer
+func (s DefaultExperimentService) CreateExperiment(ctx context.Context, exp Experiment) (uid.UID, error) {
+	toggle, err := s.ts.FetchToggle(ctx, exp.ToggleID)
+	if err != nil {
+		return uid.UID{}, err
+	}
+
+	if len(toggle.Variants) < 2 {
+		return uid.UID{}, fmt.Errorf("%w: toggle %s needs at least two variants", ErrInvalidExperiment, toggle.Key)
+	}
+
+	if exp.Control == "" {
+		exp.Control = toggle.Variants[0].Key
+	}
+
+	if _, ok := toggle.Variants.Find(exp.Control); !ok {
+		return uid.UID{}, fmt.Errorf("%w: toggle %s has no variant %s", ErrInvalidExperiment, toggle.Key, exp.Control)
+	}
+
+	if exp.Traffic == 0 {
+		exp.Traffic = LayerSlots
+	}
+
+	if exp.Traffic < 0 || exp.Traffic > LayerSlots {
+		return uid.UID{}, fmt.Errorf("%w: traffic must be a percentage", ErrInvalidExperiment)
+	}
+
+	exp.ID = uid.New()
+	exp.AccountID = toggle.AccountID
+	exp.SlotStart = 0
+	exp.SlotEnd = 0
+	exp.Status = ExperimentStatusDraft
+	exp.Variants = Keys{}
+	exp.StartedAt = nil
+	exp.StoppedAt = nil
+
+	return s.es.CreateExperiment(ctx, exp)
+}
+
+// UpdateExperiment moves an Experiment through its lifecycle. Starting a draft claims free slots in its Layer and
+// points its Toggle at it, which starts exposing contexts, and stopping it points the Toggle back at nothing and frees
+// the slots. The Toggle is updated by the wrapped ExperimentService along with the Experiment, so Experiments on a
+// Toggle that RequiresApproval can't be started or stopped outside of an approved ChangeRequest
+func (s DefaultExperimentService) UpdateExperiment(ctx context.Context, req UpdateExperimentReq) error {
+	if req.Status == nil {
+		return s.es.UpdateExperiment(ctx, req)
+	}
+
+	exp, err := s.es.FetchExperiment(ctx, req.ID)
+	if err != nil {
+		return err
+	}
+
+	toggle, err := s.ts.FetchToggle(ctx, exp.ToggleID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	switch *req.Status {
+	case ExperimentStatusRunning:
+		if exp.Status != ExperimentStatusDraft {
+			return ErrInvalidTransition
+		}
+
+		if !toggle.ExperimentID.IsNull() {
+			return ErrExperimentRunning
+		}
+
+		// the Variants may have changed since the Experiment was created
+		if _, ok := toggle.Variants.Find(exp.Control); !ok || len(toggle.Variants) < 2 {
+			return fmt.Errorf("%w: toggle %s no longer has the variants the experiment needs", ErrInvalidExperiment, toggle.Key)
+		}
+
+		start, err := s.freeSlots(ctx, exp)
+		if err != nil {
+			return err
+		}
+
+		holdouts, err := s.hs.ListHoldouts(ctx, exp.AccountID)
+		if err != nil {
+			return err
+		}
+
+		end := start + exp.Traffic
+		req.Variants = make(Keys, len(toggle.Variants))
+		for i, variant := range toggle.Variants {
+			req.Variants[i] = variant.Key
+		}
+		req.SlotStart = &start
+		req.SlotEnd = &end
+		req.StartedAt = &now
+		req.FromStatus = ExperimentStatusDraft
+
+		allocation := Allocation{
+			Layer:     exp.Layer,
+			SlotStart: start,
+			SlotEnd:   end,
+			Control:   exp.Control,
+			Holdouts:  holdouts,
+		}
+		req.Toggle = &UpdateToggleReq{ID: toggle.ID, AccountID: toggle.AccountID, Version: toggle.Version, ExperimentID: &exp.ID, Allocation: &allocation}
+	case ExperimentStatusStopped:
+		if exp.Status != ExperimentStatusRunning {
+			return ErrInvalidTransition
+		}
+
+		req.StoppedAt = &now
+		req.FromStatus = ExperimentStatusRunning
+		// the Toggle may have been pointed at something else if it was changed by hand
+		if toggle.ExperimentID.Equals(exp.ID) {
+			none := uid.UID{}
+			req.Toggle = &UpdateToggleReq{ID: toggle.ID, AccountID: toggle.AccountID, Version: toggle.Version, ExperimentID: &none, Allocation: &Allocation{}}
+		}
+	default:
+		return ErrInvalidTransition
+	}
+
+	// the Toggle is written directly rather than through a ToggleService, so Toggles that require approval are
+	// protected here in the same way
+	if req.Toggle != nil && toggle.RequiresApproval && !isApprovedChange(ctx) {
+		return ErrApprovalRequired
+	}
+
+	// the Experiment and its Toggle are updated together, so the slots are only claimed if the Toggle is pointed at
+	// the Experiment and the Toggle is never pointed at an Experiment that isn't running
+	if err := s.es.UpdateExperiment(ctx, req); err != nil {
+		return err
+	}
+
+	s.log.Info("moved experiment", zap.String("experimentID", exp.ID.String()), zap.String("toggleID", toggle.ID.String()), zap.String("status", string(*req.Status)))
+	return nil
+}
+
+// freeSlots finds the first slot of a run of free slots in the Experiment's Layer that's long enough for its Traffic.
+// Slots are taken by the other running Experiments in the Layer, and an Experiment without a Layer has all of them.
+// Another Experiment may claim the same slots before this one starts, which the wrapped ExperimentService has to
+// reject with ErrLayerFull
+func (s DefaultExperimentService) freeSlots(ctx context.Context, exp Experiment) (int, error) {
+	if exp.Layer == "" {
+		return 0, nil
+	}
+
+	running, err := s.es.ListExperiments(ctx, ListExperimentsReq{AccountID: exp.AccountID, Layer: exp.Layer, Status: ExperimentStatusRunning})
+	if err != nil {
+		return 0, err
+	}
+
+	sort.Slice(running, func(i, j int) bool {
+		return running[i].SlotStart < running[j].SlotStart
+	})
+
+	start := 0
+	for _, other := range running {
+		if other.ID.Equals(exp.ID) {
+			continue
+		}
+
+		if other.SlotStart-start >= exp.Traffic {
+			return start, nil
+		}
+
+		if other.SlotEnd > start {
+			start = other.SlotEnd
+		}
+	}
+
+	if LayerSlots-start < exp.Traffic {
+		return 0, fmt.Errorf("%w: layer %s can't fit %d%% of traffic", ErrLayerFull, exp.Layer, exp.Traffic)
+	}
+
+	return start, nil
+}
+
+func (s DefaultExperimentService) FetchExperiment(ctx context.Context, id uid.UID) (Experiment, error) {
+	return s.es.FetchExperiment(ctx, id)
+}
+
+func (s DefaultExperimentService) ListExperiments(ctx context.Context, req ListExperimentsReq) ([]Experiment, error) {
+	return s.es.ListExperiments(ctx, req)
+}
+
+func (s DefaultExperimentService) RecordExposures(ctx context.Context, exposures ...Exposure) error {
+	return s.es.RecordExposures(ctx, exposures...)
+}
+
+func (s DefaultExperimentService) RecordMetricEvents(ctx context.Context, events ...MetricEvent) error {
+	return s.es.RecordMetricEvents(ctx, events...)
+}
+
+func (s DefaultExperimentService) TallyExperiment(ctx context.Context, exp Experiment) ([]VariantTally, error) {
+	return s.es.TallyExperiment(ctx, exp)
+}
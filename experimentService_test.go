@@ -0,0 +1,262 @@
+package togglr_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/mock"
+	"github.com/togglr-io/togglr/uid"
+	"go.uber.org/zap"
+)
+
+func Test_DefaultExperimentService(t *testing.T) {
+	// SETUP
+	ctx := context.TODO()
+	toggle := togglr.Toggle{
+		ID:        uid.New(),
+		AccountID: uid.New(),
+		Key:       "checkout-button",
+		Variants: togglr.Variants{
+			{Key: "control", Weight: 50},
+			{Key: "green", Weight: 50, Value: true},
+		},
+		Version: 2,
+	}
+
+	mockTS := mock.NewToggleService(nil)
+	mockTS.FetchToggleFn = func(ctx context.Context, id uid.UID) (togglr.Toggle, error) {
+		return toggle, nil
+	}
+	mockTS.UpdateToggleFn = func(ctx context.Context, req togglr.UpdateToggleReq) error {
+		if req.ExperimentID != nil {
+			toggle.ExperimentID = *req.ExperimentID
+		}
+		return nil
+	}
+	ts := togglr.NewToggleService(mockTS, mock.NewMetadataService(nil), zap.NewNop())
+
+	var stored togglr.Experiment
+	mockES := mock.NewExperimentService(nil)
+	mockES.CreateExperimentFn = func(ctx context.Context, exp togglr.Experiment) (uid.UID, error) {
+		stored = exp
+		return exp.ID, nil
+	}
+	mockES.FetchExperimentFn = func(ctx context.Context, id uid.UID) (togglr.Experiment, error) {
+		return stored, nil
+	}
+	mockES.UpdateExperimentFn = func(ctx context.Context, req togglr.UpdateExperimentReq) error {
+		if req.FromStatus != stored.Status {
+			return togglr.ErrInvalidTransition
+		}
+
+		stored.Status = *req.Status
+		if req.Variants != nil {
+			stored.Variants = req.Variants
+		}
+
+		if req.Toggle != nil {
+			toggle.ExperimentID = *req.Toggle.ExperimentID
+		}
+		return nil
+	}
+	es := togglr.NewExperimentService(mockES, mock.NewHoldoutService(nil), ts, zap.NewNop())
+
+	running := togglr.ExperimentStatusRunning
+	stopped := togglr.ExperimentStatusStopped
+
+	// RUN
+	if _, err := es.CreateExperiment(ctx, togglr.Experiment{ToggleID: toggle.ID, Name: "green button", Metric: "purchase"}); err != nil {
+		t.Fatalf("failed to create experiment: %s", err)
+	}
+
+	if stored.Status != togglr.ExperimentStatusDraft || stored.Control != "control" {
+		t.Fatalf("expected a draft experiment controlled by the first variant, got %s controlled by '%s'", stored.Status, stored.Control)
+	}
+
+	if err := es.UpdateExperiment(ctx, togglr.UpdateExperimentReq{ID: stored.ID, Status: &stopped}); !errors.Is(err, togglr.ErrInvalidTransition) {
+		t.Fatalf("expected stopping a draft to fail, got %v", err)
+	}
+
+	if err := es.UpdateExperiment(ctx, togglr.UpdateExperimentReq{ID: stored.ID, Status: &running}); err != nil {
+		t.Fatalf("failed to start experiment: %s", err)
+	}
+
+	if !toggle.ExperimentID.Equals(stored.ID) {
+		t.Fatalf("expected toggle to point at the running experiment")
+	}
+
+	if len(stored.Variants) != 2 {
+		t.Fatalf("expected the variants to be snapshotted when starting, got %v", stored.Variants)
+	}
+
+	err := ts.UpdateToggle(ctx, togglr.UpdateToggleReq{ID: toggle.ID, Variants: togglr.Variants{{Key: "control", Weight: 1}}})
+	if !errors.Is(err, togglr.ErrExperimentRunning) {
+		t.Fatalf("expected changing variants of a running experiment to fail, got %v", err)
+	}
+
+	if err := es.UpdateExperiment(ctx, togglr.UpdateExperimentReq{ID: stored.ID, Status: &stopped}); err != nil {
+		t.Fatalf("failed to stop experiment: %s", err)
+	}
+
+	if !toggle.ExperimentID.IsNull() {
+		t.Fatalf("expected toggle to stop pointing at the experiment")
+	}
+
+	if mockTS.UpdateToggleCalled != 0 {
+		t.Fatalf("expected the toggle to only be updated along with the experiment")
+	}
+}
+
+func Test_DefaultExperimentServiceApproval(t *testing.T) {
+	// SETUP
+	ctx := context.TODO()
+	exp := togglr.Experiment{ID: uid.New(), Control: "control", Traffic: 100, Status: togglr.ExperimentStatusDraft}
+	toggle := togglr.Toggle{
+		ID:               uid.New(),
+		Key:              "checkout-button",
+		RequiresApproval: true,
+		Variants: togglr.Variants{
+			{Key: "control", Weight: 50},
+			{Key: "green", Weight: 50, Value: true},
+		},
+	}
+	exp.ToggleID = toggle.ID
+
+	mockTS := mock.NewToggleService(nil)
+	mockTS.FetchToggleFn = func(ctx context.Context, id uid.UID) (togglr.Toggle, error) {
+		return toggle, nil
+	}
+	mockES := mock.NewExperimentService(nil)
+	mockES.FetchExperimentFn = func(ctx context.Context, id uid.UID) (togglr.Experiment, error) {
+		return exp, nil
+	}
+	es := togglr.NewExperimentService(mockES, mock.NewHoldoutService(nil), mockTS, zap.NewNop())
+
+	running := togglr.ExperimentStatusRunning
+	stopped := togglr.ExperimentStatusStopped
+
+	// RUN
+	if err := es.UpdateExperiment(ctx, togglr.UpdateExperimentReq{ID: exp.ID, Status: &running}); !errors.Is(err, togglr.ErrApprovalRequired) {
+		t.Fatalf("expected starting an experiment on a protected toggle to fail with ErrApprovalRequired, got %v", err)
+	}
+
+	exp.Status = togglr.ExperimentStatusRunning
+	toggle.ExperimentID = exp.ID
+	if err := es.UpdateExperiment(ctx, togglr.UpdateExperimentReq{ID: exp.ID, Status: &stopped}); !errors.Is(err, togglr.ErrApprovalRequired) {
+		t.Fatalf("expected stopping an experiment on a protected toggle to fail with ErrApprovalRequired, got %v", err)
+	}
+
+	if mockES.UpdateExperimentCalled != 0 {
+		t.Fatalf("expected the experiment and its toggle not to be updated, updated %d times", mockES.UpdateExperimentCalled)
+	}
+}
+
+func Test_DefaultExperimentServiceVariants(t *testing.T) {
+	// SETUP
+	ctx := context.TODO()
+	toggle := togglr.Toggle{ID: uid.New(), Key: "single", Variants: togglr.Variants{{Key: "only", Weight: 1}}}
+	mockTS := mock.NewToggleService(nil)
+	mockTS.FetchToggleFn = func(ctx context.Context, id uid.UID) (togglr.Toggle, error) {
+		return toggle, nil
+	}
+	mockES := mock.NewExperimentService(nil)
+	es := togglr.NewExperimentService(mockES, mock.NewHoldoutService(nil), mockTS, zap.NewNop())
+
+	// RUN
+	_, err := es.CreateExperiment(ctx, togglr.Experiment{ToggleID: toggle.ID, Name: "nothing to compare"})
+	if !errors.Is(err, togglr.ErrInvalidExperiment) {
+		t.Fatalf("expected experiment with a single variant to be invalid, got %v", err)
+	}
+
+	toggle.Variants = append(toggle.Variants, togglr.Variant{Key: "other", Weight: 1})
+	_, err = es.CreateExperiment(ctx, togglr.Experiment{ToggleID: toggle.ID, Name: "missing control", Control: "nope"})
+	if !errors.Is(err, togglr.ErrInvalidExperiment) {
+		t.Fatalf("expected experiment with an unknown control to be invalid, got %v", err)
+	}
+
+	if mockES.CreateExperimentCalled != 0 {
+		t.Fatalf("expected invalid experiments not to be stored")
+	}
+}
+
+func Test_DefaultExperimentServiceLayers(t *testing.T) {
+	// SETUP
+	ctx := context.TODO()
+	toggle := togglr.Toggle{
+		ID:        uid.New(),
+		AccountID: uid.New(),
+		Key:       "checkout-button",
+		Variants:  togglr.Variants{{Key: "control", Weight: 1}, {Key: "green", Weight: 1}},
+	}
+	mockTS := mock.NewToggleService(nil)
+	mockTS.FetchToggleFn = func(ctx context.Context, id uid.UID) (togglr.Toggle, error) {
+		return toggle, nil
+	}
+
+	exp := togglr.Experiment{ID: uid.New(), ToggleID: toggle.ID, Control: "control", Layer: "checkout", Traffic: 30, Status: togglr.ExperimentStatusDraft}
+	running := []togglr.Experiment{
+		{ID: uid.New(), Layer: "checkout", SlotStart: 0, SlotEnd: 20},
+		{ID: uid.New(), Layer: "checkout", SlotStart: 40, SlotEnd: 80},
+	}
+	mockES := mock.NewExperimentService(nil)
+	mockES.FetchExperimentFn = func(ctx context.Context, id uid.UID) (togglr.Experiment, error) {
+		return exp, nil
+	}
+	mockES.ListExperimentsFn = func(ctx context.Context, req togglr.ListExperimentsReq) ([]togglr.Experiment, error) {
+		if req.Layer != "checkout" || req.Status != togglr.ExperimentStatusRunning {
+			t.Fatalf("expected only running experiments in the layer to be listed")
+		}
+		return running, nil
+	}
+	var started togglr.UpdateExperimentReq
+	claimed := false
+	mockES.UpdateExperimentFn = func(ctx context.Context, req togglr.UpdateExperimentReq) error {
+		// another experiment claims the slots between listing and starting the first time around
+		if !claimed {
+			claimed = true
+			return togglr.ErrLayerFull
+		}
+
+		started = req
+		return nil
+	}
+
+	holdouts := []togglr.Holdout{{ID: uid.New(), Percent: 5}}
+	mockHS := mock.NewHoldoutService(nil)
+	mockHS.ListHoldoutsFn = func(ctx context.Context, accountID uid.UID) ([]togglr.Holdout, error) {
+		return holdouts, nil
+	}
+	es := togglr.NewExperimentService(mockES, mockHS, mockTS, zap.NewNop())
+	status := togglr.ExperimentStatusRunning
+
+	// RUN
+	err := es.UpdateExperiment(ctx, togglr.UpdateExperimentReq{ID: exp.ID, Status: &status})
+	if !errors.Is(err, togglr.ErrLayerFull) {
+		t.Fatalf("expected experiment not to fit between the running ones, got %v", err)
+	}
+
+	exp.Traffic = 20
+	err = es.UpdateExperiment(ctx, togglr.UpdateExperimentReq{ID: exp.ID, Status: &status})
+	if !errors.Is(err, togglr.ErrLayerFull) {
+		t.Fatalf("expected experiment not to start in slots claimed in the meantime, got %v", err)
+	}
+
+	if err := es.UpdateExperiment(ctx, togglr.UpdateExperimentReq{ID: exp.ID, Status: &status}); err != nil {
+		t.Fatalf("failed to start experiment: %s", err)
+	}
+
+	if *started.SlotStart != 20 || *started.SlotEnd != 40 {
+		t.Fatalf("expected experiment to take the free slots 20 to 40, got %d to %d", *started.SlotStart, *started.SlotEnd)
+	}
+
+	allocation := *started.Toggle.Allocation
+	if !started.Toggle.ExperimentID.Equals(exp.ID) || started.FromStatus != togglr.ExperimentStatusDraft {
+		t.Fatalf("expected the toggle to be pointed at the experiment as it leaves draft")
+	}
+
+	if allocation.Layer != "checkout" || allocation.SlotStart != 20 || allocation.SlotEnd != 40 || len(allocation.Holdouts) != 1 {
+		t.Fatalf("expected the toggle to be allocated the experiment's slots and holdouts, got %+v", allocation)
+	}
+}
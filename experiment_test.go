@@ -0,0 +1,123 @@
+package togglr_test
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/mock"
+	"github.com/togglr-io/togglr/uid"
+)
+
+func Test_VariantsAssign(t *testing.T) {
+	// SETUP
+	variants := togglr.Variants{
+		{Key: "a", Weight: 25},
+		{Key: "b", Weight: 75},
+		{Key: "never", Weight: 0},
+	}
+	seed := uid.New().String()
+	counts := make(map[string]int)
+
+	// RUN
+	for i := 0; i < 10000; i++ {
+		key := fmt.Sprintf("user-%d", i)
+		variant := variants.Assign(seed, key)
+		if again := variants.Assign(seed, key); again.Key != variant.Key {
+			t.Fatalf("expected '%s' to be assigned the same variant twice, got %s and %s", key, variant.Key, again.Key)
+		}
+		counts[variant.Key]++
+	}
+
+	if counts["never"] != 0 {
+		t.Fatalf("expected variants without weight never to be assigned")
+	}
+
+	if share := float64(counts["a"]) / 10000; math.Abs(share-0.25) > 0.02 {
+		t.Fatalf("expected roughly a quarter of contexts to be assigned 'a', got %.3f", share)
+	}
+}
+
+func Test_ExperimentRunningAt(t *testing.T) {
+	started := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	stopped := started.Add(24 * time.Hour)
+	exp := togglr.Experiment{StartedAt: &started}
+
+	if (togglr.Experiment{}).RunningAt(started) {
+		t.Fatal("expected an experiment that never started not to be running")
+	}
+
+	if exp.RunningAt(started.Add(-time.Second)) || !exp.RunningAt(started) || !exp.RunningAt(stopped) {
+		t.Fatal("expected a running experiment to be running from when it started")
+	}
+
+	exp.StoppedAt = &stopped
+	if !exp.RunningAt(stopped.Add(-time.Second)) || exp.RunningAt(stopped) {
+		t.Fatal("expected a stopped experiment to be running until it stopped")
+	}
+}
+
+func Test_AnalyzeExperiment(t *testing.T) {
+	// SETUP
+	ctx := context.TODO()
+	exp := togglr.Experiment{
+		ID:       uid.New(),
+		Status:   togglr.ExperimentStatusRunning,
+		Control:  "control",
+		Variants: togglr.Keys{"control", "treatment", "unexposed"},
+	}
+	es := mock.NewExperimentService(nil)
+	es.FetchExperimentFn = func(ctx context.Context, id uid.UID) (togglr.Experiment, error) {
+		return exp, nil
+	}
+	es.TallyExperimentFn = func(ctx context.Context, exp togglr.Experiment) ([]togglr.VariantTally, error) {
+		return []togglr.VariantTally{
+			{Variant: "treatment", Exposures: 1000, Conversions: 150, Value: 300},
+			{Variant: "control", Exposures: 1000, Conversions: 100, Value: 200},
+		}, nil
+	}
+
+	// RUN
+	results, err := togglr.AnalyzeExperiment(ctx, es, exp.ID)
+	if err != nil {
+		t.Fatalf("failed to analyze experiment: %s", err)
+	}
+
+	if len(results.Variants) != 3 {
+		t.Fatalf("expected a result for every variant, got %d", len(results.Variants))
+	}
+
+	control, treatment, unexposed := results.Variants[0], results.Variants[1], results.Variants[2]
+	if control.Variant != "control" || control.ConversionRate != 0.1 || control.PValue != 0 {
+		t.Fatalf("expected the control to come first without comparisons, got %+v", control)
+	}
+
+	if math.Abs(treatment.Lift-0.5) > 1e-9 || treatment.ValuePerExposure != 0.3 {
+		t.Fatalf("expected 50%% lift and 0.3 value per exposure, got %f and %f", treatment.Lift, treatment.ValuePerExposure)
+	}
+
+	if !treatment.Significant || treatment.ProbabilityToBeatControl < 0.99 {
+		t.Fatalf("expected treatment to significantly beat the control, got p=%f and P(beat)=%f", treatment.PValue, treatment.ProbabilityToBeatControl)
+	}
+
+	if treatment.ConfidenceInterval[0] >= 0.15 || treatment.ConfidenceInterval[1] <= 0.15 {
+		t.Fatalf("expected the confidence interval to contain the conversion rate, got %v", treatment.ConfidenceInterval)
+	}
+
+	if unexposed.Exposures != 0 || unexposed.Significant {
+		t.Fatalf("expected a variant without exposures to have no results, got %+v", unexposed)
+	}
+
+	exp.Status = togglr.ExperimentStatusDraft
+	results, err = togglr.AnalyzeExperiment(ctx, es, exp.ID)
+	if err != nil {
+		t.Fatalf("failed to analyze draft experiment: %s", err)
+	}
+
+	if len(results.Variants) != 0 || es.TallyExperimentCalled != 1 {
+		t.Fatalf("expected draft experiments not to be tallied")
+	}
+}
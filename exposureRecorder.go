@@ -0,0 +1,80 @@
+package togglr
+
+import (
+	"context"
+	"time"
+
+	"github.com/togglr-io/togglr/uid"
+	"go.uber.org/zap"
+)
+
+// the number of evaluations that can be waiting to be turned into exposures before new ones are dropped
+const exposureBufferSize = 10000
+
+// An ExposureRecorder is an EvaluationRecorder that turns evaluations of Toggles with a running Experiment into
+// Exposures, periodically flushing them to an ExperimentService. Evaluations without a context key can't be tied to
+// later MetricEvents, so they aren't exposures
+type ExposureRecorder struct {
+	batchRecorder
+	es      ExperimentService
+	pending map[exposureKey]Exposure
+}
+
+// NewExposureRecorder returns a new ExposureRecorder that flushes to the given ExperimentService every interval.
+// Run must be called for anything to be flushed
+func NewExposureRecorder(es ExperimentService, interval time.Duration, logger *zap.Logger) *ExposureRecorder {
+	r := &ExposureRecorder{
+		es:      es,
+		pending: make(map[exposureKey]Exposure),
+	}
+	r.batchRecorder = newBatchRecorder(exposureBufferSize, interval, r.merge, r.flushExposures, logger)
+
+	return r
+}
+
+// RecordEvaluation queues an evaluation if it's an exposure. If the buffer is full the evaluation is dropped rather
+// than slowing down the caller
+func (r *ExposureRecorder) RecordEvaluation(eval Evaluation) {
+	if eval.ExperimentID.IsNull() || eval.VariantKey == "" || eval.ContextKey == "" {
+		return
+	}
+
+	r.batchRecorder.RecordEvaluation(eval)
+}
+
+func (r *ExposureRecorder) flushExposures(ctx context.Context) {
+	if len(r.pending) == 0 {
+		return
+	}
+
+	exposures := make([]Exposure, 0, len(r.pending))
+	for _, exposure := range r.pending {
+		exposures = append(exposures, exposure)
+	}
+	r.pending = make(map[exposureKey]Exposure)
+
+	if err := r.es.RecordExposures(ctx, exposures...); err != nil {
+		r.log.Error("failed to record exposures", zap.Error(err), zap.Int("exposures", len(exposures)))
+	}
+}
+
+// an exposureKey identifies a context in an Experiment, which is only ever exposed once
+type exposureKey struct {
+	experimentID uid.UID
+	contextKey   string
+}
+
+// merge keeps the earliest exposure of each context
+func (r *ExposureRecorder) merge(eval Evaluation) {
+	key := exposureKey{experimentID: eval.ExperimentID, contextKey: eval.ContextKey}
+	if exposure, ok := r.pending[key]; ok && !eval.Time.Before(exposure.ExposedAt) {
+		return
+	}
+
+	r.pending[key] = Exposure{
+		ExperimentID: eval.ExperimentID,
+		ContextKey:   eval.ContextKey,
+		Variant:      eval.VariantKey,
+		ExposedAt:    eval.Time,
+	}
+}
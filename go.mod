@@ -2,17 +2,23 @@ module github.com/togglr-io/togglr
 
 go 1.17
 
-require github.com/google/uuid v1.3.0
+require (
+	github.com/doug-martin/goqu/v9 v9.15.1
+	github.com/go-chi/chi v1.5.4
+	github.com/go-chi/cors v1.2.0
+	github.com/google/uuid v1.3.0
+	github.com/gorilla/websocket v1.5.0
+	github.com/lib/pq v1.10.2
+	github.com/mattn/go-colorable v0.1.8
+	go.uber.org/zap v1.19.0
+	golang.org/x/crypto v0.1.0
+	golang.org/x/sync v0.1.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
-	github.com/doug-martin/goqu/v9 v9.15.1 // indirect
-	github.com/go-chi/chi v1.5.4 // indirect
-	github.com/go-chi/cors v1.2.0 // indirect
-	github.com/lib/pq v1.10.2 // indirect
-	github.com/mattn/go-colorable v0.1.8 // indirect
 	github.com/mattn/go-isatty v0.0.12 // indirect
 	go.uber.org/atomic v1.9.0 // indirect
 	go.uber.org/multierr v1.7.0 // indirect
-	go.uber.org/zap v1.19.0 // indirect
-	golang.org/x/sys v0.0.0-20200223170610-d5e6a3e2c0ae // indirect
+	golang.org/x/sys v0.1.0 // indirect
 )
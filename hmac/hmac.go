@@ -11,6 +11,9 @@ import (
 // our hmac hashes are 512 bits in length, so we're grabbing the byte length
 const hmacHashLength = 512 / 8
 
+// ErrInvalidSignature is returned when signed data fails validation
+var ErrInvalidSignature = errors.New("invalid signature")
+
 // A Signer implements the togglr.Signer interface using HMACSHA512 to generate signatures
 type Signer struct {
 	secret []byte
@@ -44,28 +47,32 @@ func (s Signer) Sign(data []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	return append(data, sig...), nil
+	// copy the data so that signing never writes into spare capacity of the caller's slice
+	signed := make([]byte, 0, len(data)+len(sig))
+	signed = append(signed, data...)
+	return append(signed, sig...), nil
 }
 
 // Validate some signed data, returning the original payload if validation succeeds
 func (s Signer) Validate(signed []byte) ([]byte, error) {
+	if len(signed) < hmacHashLength {
+		return nil, ErrInvalidSignature
+	}
+
 	sigStart := len(signed) - hmacHashLength
 	data := signed[:sigStart]
 	signature := signed[sigStart:]
 
-	// sign the source data and compare it to the signature given
+	// sign the source data and compare it to the signature given. The comparison takes the same time no matter
+	// where the signatures differ, so it can't be used to guess a valid signature byte by byte
 	expected, err := s.getSignature(data)
 	if err != nil {
 		return nil, err
 	}
 
-	for i, b := range signature {
-		// if the signature given doesn't match the new signature,
-		// the payload is invalid
-		if b != expected[i] {
-			return nil, errors.New("invalid signature")
-		}
+	if !hmac.Equal(signature, expected) {
+		return nil, ErrInvalidSignature
 	}
 
-	return signed, nil
+	return data, nil
 }
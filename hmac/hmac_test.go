@@ -27,3 +27,111 @@ func Test_Signer(t *testing.T) {
 		}
 	}
 }
+
+func Test_SignerValidate(t *testing.T) {
+	signer := hmac.NewSigner("testing")
+	signed, err := signer.Sign([]byte("Hello, world!"))
+	if err != nil {
+		t.Fatal("failed to sign data")
+	}
+
+	original, err := signer.Validate(signed)
+	if err != nil {
+		t.Fatalf("failed to validate signed data: %s", err)
+	}
+
+	if string(original) != "Hello, world!" {
+		t.Fatalf("expected only the original data back, got %q", original)
+	}
+
+	tampered := append([]byte{}, signed...)
+	tampered[0] = 'J'
+	if _, err := signer.Validate(tampered); err != hmac.ErrInvalidSignature {
+		t.Fatalf("expected tampered data to be invalid, got %v", err)
+	}
+
+	if _, err := signer.Validate([]byte("short")); err != hmac.ErrInvalidSignature {
+		t.Fatalf("expected data shorter than a signature to be invalid, got %v", err)
+	}
+
+	if _, err := hmac.NewSigner("other").Validate(signed); err != hmac.ErrInvalidSignature {
+		t.Fatalf("expected data signed with another secret to be invalid, got %v", err)
+	}
+}
+
+func Test_KeySet(t *testing.T) {
+	old, err := hmac.ParseKeySet("old:secret-1")
+	if err != nil {
+		t.Fatalf("failed to parse key set: %s", err)
+	}
+
+	signed, err := old.Sign([]byte("Hello, world!"))
+	if err != nil {
+		t.Fatal("failed to sign data")
+	}
+
+	rotated, err := hmac.ParseKeySet("new:secret-2,old:secret-1")
+	if err != nil {
+		t.Fatalf("failed to parse key set: %s", err)
+	}
+
+	original, err := rotated.Validate(signed)
+	if err != nil {
+		t.Fatalf("failed to validate data signed with a previous key: %s", err)
+	}
+
+	if string(original) != "Hello, world!" {
+		t.Fatalf("expected only the original data back, got %q", original)
+	}
+
+	resigned, err := rotated.Sign(original)
+	if err != nil {
+		t.Fatal("failed to sign data")
+	}
+
+	if _, err := old.Validate(resigned); err != hmac.ErrInvalidSignature {
+		t.Fatalf("expected data signed with an unknown key to be invalid, got %v", err)
+	}
+
+	for _, spec := range []string{"", "nosecret", "a:1,a:2", "a.b:1", "a:"} {
+		if _, err := hmac.ParseKeySet(spec); err == nil {
+			t.Fatalf("expected %q to be rejected", spec)
+		}
+	}
+}
+
+func Test_Purpose(t *testing.T) {
+	keys, err := hmac.ParseKeySet("primary:secret")
+	if err != nil {
+		t.Fatalf("failed to parse key set: %s", err)
+	}
+	tokens := hmac.WithPurpose(keys, "token")
+	sessions := hmac.WithPurpose(keys, "session")
+
+	signed, err := tokens.Sign([]byte("Hello, world!"))
+	if err != nil {
+		t.Fatal("failed to sign data")
+	}
+
+	original, err := tokens.Validate(signed)
+	if err != nil {
+		t.Fatalf("failed to validate signed data: %s", err)
+	}
+
+	if string(original) != "Hello, world!" {
+		t.Fatalf("expected only the original data back, got %q", original)
+	}
+
+	if _, err := sessions.Validate(signed); err != hmac.ErrInvalidSignature {
+		t.Fatalf("expected data signed for another purpose to be invalid, got %v", err)
+	}
+
+	plain, err := keys.Sign([]byte("token:Hello, world!"))
+	if err != nil {
+		t.Fatal("failed to sign data")
+	}
+
+	if _, err := sessions.Validate(plain); err != hmac.ErrInvalidSignature {
+		t.Fatalf("expected data signed without the purpose to be invalid, got %v", err)
+	}
+}
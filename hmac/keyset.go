@@ -0,0 +1,109 @@
+package hmac
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/togglr-io/togglr/env"
+)
+
+// separates the key ID from the rest of the signed data
+const keyIDSeparator = '.'
+
+// A KeySet implements the togglr.Signer interface using several keys, so that keys can be rotated without
+// invalidating everything signed with the previous one. Data is always signed with the primary key, and the ID of
+// the key used is prepended to the signed data so that it can be validated with any key in the set
+type KeySet struct {
+	primary string
+	signers map[string]Signer
+}
+
+// NewKeySet creates a new KeySet from a map of key IDs to secrets. The primary key must be one of them
+func NewKeySet(primary string, secrets map[string]string) (KeySet, error) {
+	if _, ok := secrets[primary]; !ok {
+		return KeySet{}, fmt.Errorf("primary key %q is not in the key set", primary)
+	}
+
+	signers := make(map[string]Signer, len(secrets))
+	for id, secret := range secrets {
+		if id == "" || strings.ContainsRune(id, keyIDSeparator) {
+			return KeySet{}, fmt.Errorf("key ID %q must be non-empty and can't contain %q", id, keyIDSeparator)
+		}
+
+		if secret == "" {
+			return KeySet{}, fmt.Errorf("key %q has an empty secret", id)
+		}
+
+		signers[id] = NewSigner(secret)
+	}
+
+	return KeySet{
+		primary: primary,
+		signers: signers,
+	}, nil
+}
+
+// ParseKeySet creates a new KeySet from a comma separated list of id:secret pairs. The first key is the primary
+func ParseKeySet(spec string) (KeySet, error) {
+	primary := ""
+	secrets := make(map[string]string)
+	for _, pair := range strings.Split(spec, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), ":", 2)
+		if len(parts) != 2 {
+			return KeySet{}, fmt.Errorf("key %q should be given as id:secret", pair)
+		}
+
+		if _, ok := secrets[parts[0]]; ok {
+			return KeySet{}, fmt.Errorf("key %q is given more than once", parts[0])
+		}
+
+		if primary == "" {
+			primary = parts[0]
+		}
+		secrets[parts[0]] = parts[1]
+	}
+
+	return NewKeySet(primary, secrets)
+}
+
+// KeySetFromEnv creates a new KeySet using the id:secret pairs found at some environment key. A nil KeySet is
+// returned when the key isn't set
+func KeySetFromEnv(key string) (*KeySet, error) {
+	spec := env.GetString(key, "")
+	if spec == "" {
+		return nil, nil
+	}
+
+	keys, err := ParseKeySet(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	return &keys, nil
+}
+
+// Sign some data with the primary key
+func (k KeySet) Sign(data []byte) ([]byte, error) {
+	signed, err := k.signers[k.primary].Sign(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(k.primary+string(keyIDSeparator)), signed...), nil
+}
+
+// Validate some signed data with the key it was signed with, returning the original payload if validation succeeds
+func (k KeySet) Validate(signed []byte) ([]byte, error) {
+	idEnd := bytes.IndexByte(signed, keyIDSeparator)
+	if idEnd < 0 {
+		return nil, ErrInvalidSignature
+	}
+
+	signer, ok := k.signers[string(signed[:idEnd])]
+	if !ok {
+		return nil, ErrInvalidSignature
+	}
+
+	return signer.Validate(signed[idEnd+1:])
+}
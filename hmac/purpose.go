@@ -0,0 +1,44 @@
+package hmac
+
+import (
+	"bytes"
+
+	"github.com/togglr-io/togglr"
+)
+
+// A Purpose wraps a togglr.Signer so that everything it signs is bound to what it's signed for. The purpose is
+// prefixed to the data before it's signed, so data signed for one purpose never validates for another even when
+// both share the same keys
+type Purpose struct {
+	signer togglr.Signer
+	tag    []byte
+}
+
+// WithPurpose binds the signatures of a togglr.Signer to a purpose
+func WithPurpose(signer togglr.Signer, purpose string) Purpose {
+	return Purpose{
+		signer: signer,
+		tag:    []byte(purpose + ":"),
+	}
+}
+
+// Sign some data for the Purpose
+func (p Purpose) Sign(data []byte) ([]byte, error) {
+	tagged := make([]byte, 0, len(p.tag)+len(data))
+	tagged = append(tagged, p.tag...)
+	return p.signer.Sign(append(tagged, data...))
+}
+
+// Validate some data signed for the Purpose, returning the original payload if validation succeeds
+func (p Purpose) Validate(signed []byte) ([]byte, error) {
+	tagged, err := p.signer.Validate(signed)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.HasPrefix(tagged, p.tag) {
+		return nil, ErrInvalidSignature
+	}
+
+	return tagged[len(p.tag):], nil
+}
@@ -2,6 +2,7 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"net/http"
 
@@ -57,7 +58,43 @@ func HandleAccountPOST(log *zap.Logger, as togglr.AccountService) http.HandlerFu
 				return
 			}
 
+			version, err := versionFromRequest(r, updateReq.Version)
+			if errors.Is(err, errMissingVersion) {
+				log.Error("update req is missing a version", zap.Error(err))
+				preconditionRequired(w, "a version is required to update a account")
+				return
+			}
+
+			if errors.Is(err, errWeakVersion) {
+				log.Info("rejected update with weak etag")
+				preconditionFailed(w, "If-Match requires a strong etag")
+				return
+			}
+
+			if err != nil {
+				log.Error("failed to parse version", zap.Error(err))
+				badRequest(w, "version was badly formed")
+				return
+			}
+
+			updateReq.Version = version
 			if err := as.UpdateAccount(r.Context(), updateReq); err != nil {
+				if errors.Is(err, togglr.ErrConflict) {
+					log.Info("rejected update to stale account", zap.Int("version", version))
+					current, err := as.FetchAccount(r.Context(), updateReq.ID)
+					if err != nil {
+						log.Error("failed to fetch current account", zap.Error(err))
+						serverError(w, "could not save account")
+						return
+					}
+
+					if err := conflictWithCurrent(w, current, current.Version); err != nil {
+						log.Error("failed to marshal current account", zap.Error(err))
+						serverError(w, "could not save account")
+					}
+					return
+				}
+
 				log.Error("failed to update account", zap.Error(err))
 				serverError(w, "could not save account")
 				return
@@ -82,16 +119,42 @@ func HandleAccountGET(log *zap.Logger, as togglr.AccountService) http.HandlerFun
 		log.Debug("listing accounts")
 		defer log.Sync()
 
-		req := togglr.ListAccountsReq{}
+		page, err := pageFromQuery(r.URL.Query())
+		if err != nil {
+			log.Error("failed to parse page", zap.Error(err))
+			badRequest(w, err.Error())
+			return
+		}
+
+		req := togglr.ListAccountsReq{Page: page}
+		// fetch one more account than requested to find out whether there's another page
+		if page.Limit > 0 {
+			req.Limit++
+		}
 
 		accounts, err := as.ListAccounts(r.Context(), req)
 		if err != nil {
+			if errors.Is(err, togglr.ErrInvalidPage) {
+				log.Error("invalid page requested", zap.Error(err))
+				badRequest(w, err.Error())
+				return
+			}
+
 			log.Error("failed to list accounts", zap.Error(err))
 			serverError(w, "could not list accounts")
 			return
 		}
 
-		data, err := json.Marshal(accounts)
+		count, cursor, err := nextCursor(len(accounts), page, func(idx int) (togglr.Cursor, error) {
+			return accounts[idx].CursorFor(page.SortBy)
+		})
+		if err != nil {
+			log.Error("failed to build next cursor", zap.Error(err))
+			serverError(w, "could not list accounts")
+			return
+		}
+
+		data, err := json.Marshal(listBody(accounts[:count], page, cursor))
 		if err != nil {
 			log.Error("failed to marshal accounts", zap.Error(err))
 			serverError(w, "could not list accounts")
@@ -132,6 +195,7 @@ func HandleAccountIdGET(log *zap.Logger, as togglr.AccountService) http.HandlerF
 			return
 		}
 
+		w.Header().Set("ETag", etag(account.Version))
 		ok(w, data)
 	})
 }
@@ -152,18 +216,46 @@ func HandleAccountUsersGET(log *zap.Logger, us togglr.UserService) http.HandlerF
 			return
 		}
 
+		page, err := pageFromQuery(r.URL.Query())
+		if err != nil {
+			log.Error("failed to parse page", zap.Error(err))
+			badRequest(w, err.Error())
+			return
+		}
+
 		req := togglr.ListUsersReq{
 			AccountID: uid,
+			Page:      page,
+		}
+
+		// fetch one more user than requested to find out whether there's another page
+		if page.Limit > 0 {
+			req.Limit++
 		}
 
 		users, err := us.ListUsers(r.Context(), req)
 		if err != nil {
-			log.Error("failed to fetch account users", zap.Error(err))
-			serverError(w, "could not fetch account users")
+			if errors.Is(err, togglr.ErrInvalidPage) {
+				log.Error("invalid page requested", zap.Error(err))
+				badRequest(w, err.Error())
+				return
+			}
+
+			log.Error("failed to list account users", zap.Error(err))
+			serverError(w, "could not list account users")
+			return
+		}
+
+		count, cursor, err := nextCursor(len(users), page, func(idx int) (togglr.Cursor, error) {
+			return users[idx].CursorFor(page.SortBy)
+		})
+		if err != nil {
+			log.Error("failed to build next cursor", zap.Error(err))
+			serverError(w, "could not list account users")
 			return
 		}
 
-		data, err := json.Marshal(users)
+		data, err := json.Marshal(listBody(users[:count], page, cursor))
 		if err != nil {
 			log.Error("failed to marshal account", zap.Error(err))
 			serverError(w, "could not fetch account")
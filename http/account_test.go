@@ -2,23 +2,30 @@ package http_test
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	stdhttp "net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/togglr-io/togglr"
 	"github.com/togglr-io/togglr/http"
 	"github.com/togglr-io/togglr/mock"
 	"github.com/togglr-io/togglr/uid"
 	"go.uber.org/zap"
 )
 
+func staleAccountUpdate(ctx context.Context, req togglr.UpdateAccountReq) error {
+	return togglr.ErrConflict
+}
+
 func Test_HandleAccountPOST(t *testing.T) {
 	id := uid.New().String()
 	cases := []struct {
 		name                string
 		payload             string
+		ifMatch             string
 		accountService      *mock.AccountService
 		expectedStatus      int
 		expectedCreateCalls int
@@ -50,7 +57,7 @@ func Test_HandleAccountPOST(t *testing.T) {
 		},
 		{
 			name:                "successful update",
-			payload:             fmt.Sprintf(`{"id": "%s", "name": "New Account"}`, id),
+			payload:             fmt.Sprintf(`{"id": "%s", "version": 1, "name": "New Account"}`, id),
 			accountService:      mock.NewAccountService(nil),
 			expectedStatus:      200,
 			expectedCreateCalls: 0,
@@ -58,12 +65,46 @@ func Test_HandleAccountPOST(t *testing.T) {
 		},
 		{
 			name:                "failed update",
-			payload:             fmt.Sprintf(`{"id": "%s", "name": "New Account"}`, id),
+			payload:             fmt.Sprintf(`{"id": "%s", "version": 1, "name": "New Account"}`, id),
 			accountService:      mock.NewAccountService(errors.New("forced")),
 			expectedStatus:      500,
 			expectedCreateCalls: 0,
 			expectedUpdateCalls: 1,
 		},
+		{
+			name:                "update with If-Match",
+			payload:             fmt.Sprintf(`{"id": "%s", "name": "New Account"}`, id),
+			ifMatch:             `"3"`,
+			accountService:      mock.NewAccountService(nil),
+			expectedStatus:      200,
+			expectedCreateCalls: 0,
+			expectedUpdateCalls: 1,
+		},
+		{
+			name:                "update without version",
+			payload:             fmt.Sprintf(`{"id": "%s", "name": "New Account"}`, id),
+			accountService:      mock.NewAccountService(nil),
+			expectedStatus:      428,
+			expectedCreateCalls: 0,
+			expectedUpdateCalls: 0,
+		},
+		{
+			name:                "update with bad If-Match",
+			payload:             fmt.Sprintf(`{"id": "%s", "name": "New Account"}`, id),
+			ifMatch:             "latest",
+			accountService:      mock.NewAccountService(nil),
+			expectedStatus:      400,
+			expectedCreateCalls: 0,
+			expectedUpdateCalls: 0,
+		},
+		{
+			name:                "stale update",
+			payload:             fmt.Sprintf(`{"id": "%s", "version": 1, "name": "New Account"}`, id),
+			accountService:      &mock.AccountService{UpdateAccountFn: staleAccountUpdate},
+			expectedStatus:      409,
+			expectedCreateCalls: 0,
+			expectedUpdateCalls: 1,
+		},
 	}
 
 	for _, c := range cases {
@@ -83,6 +124,10 @@ func Test_HandleAccountPOST(t *testing.T) {
 				t.Fatalf("failed to create request: %s", err)
 			}
 
+			if c.ifMatch != "" {
+				req.Header.Set("If-Match", c.ifMatch)
+			}
+
 			res, err := stdhttp.DefaultClient.Do(req)
 			if err != nil {
 				t.Fatalf("failed to send request: %s", err)
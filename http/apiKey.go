@@ -0,0 +1,140 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/uid"
+	"go.uber.org/zap"
+)
+
+// a createdAPIKey is the response to creating an APIKey, the only time its secret is ever shown
+type createdAPIKey struct {
+	togglr.APIKey
+	Secret string `json:"secret"`
+}
+
+// HandleAccountKeyPOST handles POST requests to the /account/{id}/key endpoint. The secret of the new APIKey is
+// part of the response and can't be retrieved again
+func HandleAccountKeyPOST(log *zap.Logger, ks togglr.APIKeyService) http.HandlerFunc {
+	log = log.With(zap.String("handler", "HandleAccountKeyPOST"))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		log := log.With(zap.String("accountID", id))
+		log.Debug("creating api key")
+		defer log.Sync()
+
+		accountID, err := uid.FromString(id)
+		if err != nil {
+			log.Error("failed to parse account ID", zap.Error(err))
+			badRequest(w, "account ID was badly formed")
+			return
+		}
+
+		var key togglr.APIKey
+		if err := json.NewDecoder(r.Body).Decode(&key); err != nil {
+			log.Error("failed to unmarshal api key", zap.Error(err))
+			badRequest(w, "could not unmarshal api key")
+			return
+		}
+
+		if key.Name == "" || !togglr.ValidAPIKeyKind(key.Kind) {
+			badRequest(w, "api keys must have a name and a kind of client, server or management")
+			return
+		}
+
+		key.AccountID = accountID
+		key, secret, err := togglr.NewAPIKey(key)
+		if err != nil {
+			log.Error("failed to generate api key", zap.Error(err))
+			serverError(w, "could not create api key")
+			return
+		}
+
+		if _, err := ks.CreateAPIKey(r.Context(), key); err != nil {
+			log.Error("failed to create api key", zap.Error(err))
+			serverError(w, "could not create api key")
+			return
+		}
+
+		data, err := json.Marshal(createdAPIKey{APIKey: key, Secret: secret})
+		if err != nil {
+			log.Error("failed to marshal api key", zap.Error(err))
+			serverError(w, "could not create api key")
+			return
+		}
+
+		ok(w, data)
+	})
+}
+
+// HandleAccountKeyGET handles GET requests to the /account/{id}/key endpoint
+func HandleAccountKeyGET(log *zap.Logger, ks togglr.APIKeyService) http.HandlerFunc {
+	log = log.With(zap.String("handler", "HandleAccountKeyGET"))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		log := log.With(zap.String("accountID", id))
+		log.Debug("listing api keys")
+		defer log.Sync()
+
+		accountID, err := uid.FromString(id)
+		if err != nil {
+			log.Error("failed to parse account ID", zap.Error(err))
+			badRequest(w, "account ID was badly formed")
+			return
+		}
+
+		keys, err := ks.ListAPIKeys(r.Context(), accountID)
+		if err != nil {
+			log.Error("failed to list api keys", zap.Error(err))
+			serverError(w, "could not list api keys")
+			return
+		}
+
+		data, err := json.Marshal(keys)
+		if err != nil {
+			log.Error("failed to marshal api keys", zap.Error(err))
+			serverError(w, "could not list api keys")
+			return
+		}
+
+		ok(w, data)
+	})
+}
+
+// HandleKeyDELETE handles DELETE requests to the /key/{id} endpoint, revoking the APIKey
+func HandleKeyDELETE(log *zap.Logger, ks togglr.APIKeyService) http.HandlerFunc {
+	log = log.With(zap.String("handler", "HandleKeyDELETE"))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		log := log.With(zap.String("keyID", id))
+		log.Debug("revoking api key")
+		defer log.Sync()
+
+		keyID, err := uid.FromString(id)
+		if err != nil {
+			log.Error("failed to parse api key ID", zap.Error(err))
+			badRequest(w, "api key ID was badly formed")
+			return
+		}
+
+		if err := ks.RevokeAPIKey(r.Context(), keyID); err != nil {
+			if errors.Is(err, togglr.ErrNotFound) {
+				notFound(w, "api key does not exist")
+				return
+			}
+
+			log.Error("failed to revoke api key", zap.Error(err))
+			serverError(w, "could not revoke api key")
+			return
+		}
+
+		noContent(w)
+	})
+}
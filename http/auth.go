@@ -0,0 +1,279 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi"
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/uid"
+	"go.uber.org/zap"
+)
+
+// WithAPIKey attaches the APIKey a request was authenticated with to its context
+func WithAPIKey(ctx context.Context, key togglr.APIKey) context.Context {
+	return context.WithValue(ctx, ctxKey{name: "apiKey"}, key)
+}
+
+// GetAPIKey returns the APIKey a request was authenticated with. The returned bool is false if there isn't one
+func GetAPIKey(ctx context.Context) (togglr.APIKey, bool) {
+	key, ok := ctx.Value(ctxKey{name: "apiKey"}).(togglr.APIKey)
+	return key, ok
+}
+
+// scopedAccounts returns the accounts a request is limited to. Requests authenticated with an APIKey are limited to
+// the key's account, and Users to the accounts they belong to unless they're admins. The returned bool is false if
+// the request isn't limited to any accounts
+func scopedAccounts(ctx context.Context) ([]uid.UID, bool) {
+	if key, ok := GetAPIKey(ctx); ok {
+		return []uid.UID{key.AccountID}, true
+	}
+
+	user, ok := GetUser(ctx)
+	if !ok || user.Admin {
+		return nil, false
+	}
+
+	accounts, _ := getUserAccounts(ctx)
+	return accounts, true
+}
+
+// canAccess returns true if a request can access the given account
+func canAccess(ctx context.Context, accountID uid.UID) bool {
+	accounts, scoped := scopedAccounts(ctx)
+	if !scoped {
+		return true
+	}
+
+	for _, id := range accounts {
+		if id.Equals(accountID) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// defaultAccount returns the account that requests that don't name one are made against, which is the only account
+// they're limited to. The returned bool is false if there's no such account, and the request is limited to either
+// none or several
+func defaultAccount(ctx context.Context) (uid.UID, bool) {
+	accounts, scoped := scopedAccounts(ctx)
+	if !scoped || len(accounts) != 1 {
+		return uid.UID{}, false
+	}
+
+	return accounts[0], true
+}
+
+// an accountLookup finds the account that the resource with the given ID belongs to
+type accountLookup func(ctx context.Context, id uid.UID) (uid.UID, error)
+
+// sameAccount is the accountLookup of routes that are addressed by the account ID itself
+func sameAccount(ctx context.Context, id uid.UID) (uid.UID, error) {
+	return id, nil
+}
+
+// An authorizer builds the middleware that authenticates requests with APIKeys and checks what they're allowed to
+// do. Requests without a key are allowed when there's no APIKeyService, which keeps local development and tests
+// simple, but Users that log in are still limited to what they're allowed to do as long as sessions are enabled
+type authorizer struct {
+	ks       togglr.APIKeyService
+	sessions bool
+	log      *zap.Logger
+}
+
+func (a authorizer) enabled() bool {
+	return a.ks != nil
+}
+
+// checksUsers returns whether requests can be made by logged in Users, whose access has to be checked even when
+// there are no APIKeys
+func (a authorizer) checksUsers() bool {
+	return a.enabled() || a.sessions
+}
+
+// authenticate attaches the APIKey a request was made with to its context. Keys are passed as bearer tokens, or in
+// the apiKey query parameter for streams and sockets opened by browsers, which can't set headers. Only client keys
+// can be passed in the query, since URLs end up in logs. Requests without a key are passed on, it's up to require to
+// turn them away
+func (a authorizer) authenticate(next http.Handler) http.Handler {
+	if !a.enabled() {
+		return next
+	}
+
+	log := a.log.With(zap.String("middleware", "authenticate"))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secret := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		fromQuery := false
+		if secret == "" {
+			secret = r.URL.Query().Get("apiKey")
+			fromQuery = true
+		}
+
+		if secret == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key, err := togglr.AuthenticateAPIKey(r.Context(), a.ks, secret)
+		if err != nil {
+			if errors.Is(err, togglr.ErrInvalidAPIKey) {
+				unauthorized(w, "api key is invalid or has been revoked")
+				return
+			}
+
+			log.Error("failed to authenticate api key", zap.Error(err))
+			serverError(w, "could not authenticate request")
+			return
+		}
+
+		if fromQuery && key.Kind != togglr.APIKeyKindClient {
+			unauthorized(w, "only client api keys can be passed in the query")
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithAPIKey(r.Context(), key)))
+	})
+}
+
+// require only lets through requests authenticated with one of the given kinds of APIKey. Requests without a key
+// are unauthorized and requests with the wrong kind of key are forbidden. With no kinds given, no key is allowed.
+// Users that are logged in are let through wherever management keys are, and wherever no key is allowed. Like
+// keys, they're limited to the accounts they belong to by account
+func (a authorizer) require(kinds ...togglr.APIKeyKind) Middleware {
+	allowsUsers := len(kinds) == 0
+	for _, kind := range kinds {
+		if kind == togglr.APIKeyKindManagement {
+			allowsUsers = true
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		if !a.enabled() {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, ok := GetAPIKey(r.Context())
+			if !ok {
+				if _, loggedIn := GetUser(r.Context()); loggedIn && allowsUsers {
+					next.ServeHTTP(w, r)
+					return
+				}
+
+				unauthorized(w, "an api key or a session is required")
+				return
+			}
+
+			for _, kind := range kinds {
+				if key.Kind == kind {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			forbidden(w, "api key can't be used for this request")
+		})
+	}
+}
+
+// account only lets through requests for resources that belong to an account the request can access, which is the
+// account of its APIKey or one of the accounts its User belongs to. The resource is identified by the given URL
+// parameter, and its account is found with the lookup. Resources that don't exist are passed on so that handlers can
+// respond as usual
+func (a authorizer) account(param string, lookup accountLookup) Middleware {
+	return func(next http.Handler) http.Handler {
+		if !a.checksUsers() {
+			return next
+		}
+
+		log := a.log.With(zap.String("middleware", "account"))
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, scoped := scopedAccounts(r.Context()); !scoped {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			id, err := uid.FromString(chi.URLParam(r, param))
+			if err != nil {
+				// handlers already reject malformed IDs
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			owner, err := lookup(r.Context(), id)
+			if errors.Is(err, togglr.ErrNotFound) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if err != nil {
+				log.Error("failed to look up account", zap.Error(err))
+				serverError(w, "could not authorize request")
+				return
+			}
+
+			if !canAccess(r.Context(), owner) {
+				forbidden(w, "can't access other accounts")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// admin only lets through Users that are admins. Requests without a User are left to require, so admin has to come
+// after it
+func (a authorizer) admin(next http.Handler) http.Handler {
+	if !a.checksUsers() {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, ok := GetUser(r.Context()); ok && !user.Admin {
+			forbidden(w, "only admins can manage accounts and users")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func toggleAccount(ts togglr.ToggleService) accountLookup {
+	return func(ctx context.Context, id uid.UID) (uid.UID, error) {
+		toggle, err := ts.FetchToggle(ctx, id)
+		return toggle.AccountID, err
+	}
+}
+
+func changeAccount(cs togglr.ChangeRequestService) accountLookup {
+	return func(ctx context.Context, id uid.UID) (uid.UID, error) {
+		cr, err := cs.FetchChangeRequest(ctx, id)
+		return cr.AccountID, err
+	}
+}
+
+func experimentAccount(es togglr.ExperimentService) accountLookup {
+	return func(ctx context.Context, id uid.UID) (uid.UID, error) {
+		exp, err := es.FetchExperiment(ctx, id)
+		return exp.AccountID, err
+	}
+}
+
+func holdoutAccount(hs togglr.HoldoutService) accountLookup {
+	return func(ctx context.Context, id uid.UID) (uid.UID, error) {
+		holdout, err := hs.FetchHoldout(ctx, id)
+		return holdout.AccountID, err
+	}
+}
+
+func keyAccount(ks togglr.APIKeyService) accountLookup {
+	return func(ctx context.Context, id uid.UID) (uid.UID, error) {
+		key, err := ks.FetchAPIKey(ctx, id)
+		return key.AccountID, err
+	}
+}
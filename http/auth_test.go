@@ -0,0 +1,181 @@
+package http_test
+
+import (
+	"context"
+	"fmt"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/http"
+	"github.com/togglr-io/togglr/mock"
+	"github.com/togglr-io/togglr/uid"
+	"go.uber.org/zap"
+)
+
+func Test_APIKeyAuth(t *testing.T) {
+	accountID := uid.New()
+	otherAccountID := uid.New()
+	toggleID := uid.New()
+
+	secrets := make(map[string]togglr.APIKey)
+	for _, kind := range []togglr.APIKeyKind{togglr.APIKeyKindClient, togglr.APIKeyKindServer, togglr.APIKeyKindManagement} {
+		key, secret, err := togglr.NewAPIKey(togglr.APIKey{AccountID: accountID, Name: string(kind), Kind: kind})
+		if err != nil {
+			t.Fatalf("failed to create api key: %s", err)
+		}
+		secrets[secret] = key
+	}
+
+	secretFor := func(kind togglr.APIKeyKind) string {
+		for secret, key := range secrets {
+			if key.Kind == kind {
+				return secret
+			}
+		}
+		return ""
+	}
+
+	cases := []struct {
+		name           string
+		path           string
+		secret         string
+		query          bool
+		expectedStatus int
+	}{
+		{
+			name:           "missing key",
+			path:           fmt.Sprintf("/resolve/%s", accountID),
+			expectedStatus: 401,
+		},
+		{
+			name:           "invalid key",
+			path:           fmt.Sprintf("/resolve/%s", accountID),
+			secret:         "togglr_client_nope",
+			expectedStatus: 401,
+		},
+		{
+			name:           "client key resolving",
+			path:           fmt.Sprintf("/resolve/%s", accountID),
+			secret:         secretFor(togglr.APIKeyKindClient),
+			expectedStatus: 200,
+		},
+		{
+			name:           "client key in the query",
+			path:           fmt.Sprintf("/resolve/%s", accountID),
+			secret:         secretFor(togglr.APIKeyKindClient),
+			query:          true,
+			expectedStatus: 200,
+		},
+		{
+			name:           "server key in the query",
+			path:           fmt.Sprintf("/definitions/%s", accountID),
+			secret:         secretFor(togglr.APIKeyKindServer),
+			query:          true,
+			expectedStatus: 401,
+		},
+		{
+			name:           "client key resolving another account",
+			path:           fmt.Sprintf("/resolve/%s", otherAccountID),
+			secret:         secretFor(togglr.APIKeyKindClient),
+			expectedStatus: 403,
+		},
+		{
+			name:           "client key downloading definitions",
+			path:           fmt.Sprintf("/definitions/%s", accountID),
+			secret:         secretFor(togglr.APIKeyKindClient),
+			expectedStatus: 403,
+		},
+		{
+			name:           "server key downloading definitions",
+			path:           fmt.Sprintf("/definitions/%s", accountID),
+			secret:         secretFor(togglr.APIKeyKindServer),
+			expectedStatus: 200,
+		},
+		{
+			name:           "server key managing toggles",
+			path:           fmt.Sprintf("/toggle/%s", toggleID),
+			secret:         secretFor(togglr.APIKeyKindServer),
+			expectedStatus: 403,
+		},
+		{
+			name:           "management key fetching toggle",
+			path:           fmt.Sprintf("/toggle/%s", toggleID),
+			secret:         secretFor(togglr.APIKeyKindManagement),
+			expectedStatus: 200,
+		},
+		{
+			name:           "management key fetching toggle of another account",
+			path:           fmt.Sprintf("/toggle/%s", uid.New()),
+			secret:         secretFor(togglr.APIKeyKindManagement),
+			expectedStatus: 403,
+		},
+		{
+			name:           "management key listing accounts",
+			path:           "/account",
+			secret:         secretFor(togglr.APIKeyKindManagement),
+			expectedStatus: 403,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ks := mock.NewAPIKeyService(nil)
+			ks.FetchAPIKeyByHashFn = func(ctx context.Context, hash string) (togglr.APIKey, error) {
+				for _, key := range secrets {
+					if key.Hash == hash {
+						return key, nil
+					}
+				}
+				return togglr.APIKey{}, togglr.ErrNotFound
+			}
+
+			ts := mock.NewToggleService(nil)
+			ts.FetchToggleFn = func(ctx context.Context, id uid.UID) (togglr.Toggle, error) {
+				if id.Equals(toggleID) {
+					return togglr.Toggle{ID: id, AccountID: accountID}, nil
+				}
+				return togglr.Toggle{ID: id, AccountID: otherAccountID}, nil
+			}
+
+			cfg := http.Config{
+				Logger: zap.NewNop(),
+				Services: http.Services{
+					ToggleService:        ts,
+					AccountService:       mock.NewAccountService(nil),
+					ConfigVersionService: mock.NewConfigVersionService(nil),
+					Resolver:             togglr.NewResolver(ts, nil),
+					APIKeyService:        ks,
+				},
+			}
+
+			s := httptest.NewServer(http.BuildRoutes(cfg))
+			defer s.Close()
+
+			url := s.URL + c.path
+			if c.query {
+				url += "?apiKey=" + c.secret
+			}
+
+			req, err := stdhttp.NewRequest(stdhttp.MethodGet, url, nil)
+			if err != nil {
+				t.Fatalf("failed to create request: %s", err)
+			}
+
+			if c.secret != "" && !c.query {
+				req.Header.Set("Authorization", "Bearer "+c.secret)
+			}
+
+			res, err := stdhttp.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("failed to send request: %s", err)
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode != c.expectedStatus {
+				t.Fatalf("expected status %d, got %d", c.expectedStatus, res.StatusCode)
+			}
+		})
+	}
+}
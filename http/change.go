@@ -0,0 +1,249 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/uid"
+	"go.uber.org/zap"
+)
+
+// HandleToggleChangePOST handles POST requests to the /toggle/{id}/change endpoint, submitting a new ChangeRequest
+func HandleToggleChangePOST(log *zap.Logger, cs togglr.ChangeRequestService) http.HandlerFunc {
+	log = log.With(zap.String("handler", "HandleToggleChangePOST"))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		log = log.With(zap.String("toggleID", id))
+		log.Debug("submitting change request")
+		defer log.Sync()
+
+		toggleID, err := uid.FromString(id)
+		if err != nil {
+			log.Error("failed to parse toggle ID", zap.Error(err))
+			badRequest(w, "toggle ID was badly formed")
+			return
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			log.Error("failed to read request", zap.Error(err))
+			serverError(w, "could not read request")
+			return
+		}
+
+		var cr togglr.ChangeRequest
+		if err := json.Unmarshal(body, &cr); err != nil {
+			log.Error("failed to unmarshal change request", zap.Error(err))
+			badRequest(w, "could not unmarshal change request")
+			return
+		}
+
+		author, found := changeUser(w, r)
+		if !found {
+			return
+		}
+
+		cr.AuthorID = author.ID
+		cr.ToggleID = toggleID
+		crID, err := cs.CreateChangeRequest(r.Context(), cr)
+		if err != nil {
+			log.Error("failed to create change request", zap.Error(err))
+			serverError(w, "could not save change request")
+			return
+		}
+
+		data, err := json.Marshal(togglr.ID{ID: crID})
+		if err != nil {
+			log.Error("failed to marshal response", zap.Error(err))
+			serverError(w, "could not save change request")
+			return
+		}
+
+		ok(w, data)
+	})
+}
+
+// HandleToggleChangeGET handles GET requests to the /toggle/{id}/change endpoint
+func HandleToggleChangeGET(log *zap.Logger, cs togglr.ChangeRequestService) http.HandlerFunc {
+	log = log.With(zap.String("handler", "HandleToggleChangeGET"))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		log = log.With(zap.String("toggleID", id))
+		log.Debug("listing change requests")
+		defer log.Sync()
+
+		toggleID, err := uid.FromString(id)
+		if err != nil {
+			log.Error("failed to parse toggle ID", zap.Error(err))
+			badRequest(w, "toggle ID was badly formed")
+			return
+		}
+
+		req := togglr.ListChangeRequestsReq{
+			ToggleID: toggleID,
+			Status:   togglr.ChangeRequestStatus(r.URL.Query().Get("status")),
+		}
+
+		crs, err := cs.ListChangeRequests(r.Context(), req)
+		if err != nil {
+			log.Error("failed to list change requests", zap.Error(err))
+			serverError(w, "could not list change requests")
+			return
+		}
+
+		data, err := json.Marshal(crs)
+		if err != nil {
+			log.Error("failed to marshal change requests", zap.Error(err))
+			serverError(w, "could not list change requests")
+			return
+		}
+
+		ok(w, data)
+	})
+}
+
+// HandleChangeIdGET handles GET requests to the /change/{id} endpoint
+func HandleChangeIdGET(log *zap.Logger, cs togglr.ChangeRequestService) http.HandlerFunc {
+	log = log.With(zap.String("handler", "HandleChangeIdGET"))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		log = log.With(zap.String("changeRequestID", id))
+		log.Debug("fetching change request")
+		defer log.Sync()
+
+		uid, err := uid.FromString(id)
+		if err != nil {
+			log.Error("failed to parse change request ID", zap.Error(err))
+			badRequest(w, "change request ID was badly formed")
+			return
+		}
+
+		cr, err := cs.FetchChangeRequest(r.Context(), uid)
+		if err != nil {
+			log.Error("failed to fetch change request", zap.Error(err))
+			serverError(w, "could not fetch change request")
+			return
+		}
+
+		data, err := json.Marshal(cr)
+		if err != nil {
+			log.Error("failed to marshal change request", zap.Error(err))
+			serverError(w, "could not fetch change request")
+			return
+		}
+
+		ok(w, data)
+	})
+}
+
+// HandleChangeApprovePOST handles POST requests to the /change/{id}/approve endpoint
+func HandleChangeApprovePOST(log *zap.Logger, cs togglr.ChangeRequestService) http.HandlerFunc {
+	return handleChangeStatus(log.With(zap.String("handler", "HandleChangeApprovePOST")), cs, togglr.ChangeRequestStatusApproved)
+}
+
+// HandleChangeRejectPOST handles POST requests to the /change/{id}/reject endpoint
+func HandleChangeRejectPOST(log *zap.Logger, cs togglr.ChangeRequestService) http.HandlerFunc {
+	return handleChangeStatus(log.With(zap.String("handler", "HandleChangeRejectPOST")), cs, togglr.ChangeRequestStatusRejected)
+}
+
+// HandleChangeApplyPOST handles POST requests to the /change/{id}/apply endpoint
+func HandleChangeApplyPOST(log *zap.Logger, cs togglr.ChangeRequestService) http.HandlerFunc {
+	return handleChangeStatus(log.With(zap.String("handler", "HandleChangeApplyPOST")), cs, togglr.ChangeRequestStatusApplied)
+}
+
+// handleChangeStatus builds a handler that moves a ChangeRequest to the given status. The body may contain a
+// comment and the reviewer is whoever made the request
+func handleChangeStatus(log *zap.Logger, cs togglr.ChangeRequestService, status togglr.ChangeRequestStatus) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		log = log.With(zap.String("changeRequestID", id), zap.String("status", string(status)))
+		log.Debug("updating change request")
+		defer log.Sync()
+
+		crID, err := uid.FromString(id)
+		if err != nil {
+			log.Error("failed to parse change request ID", zap.Error(err))
+			badRequest(w, "change request ID was badly formed")
+			return
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			log.Error("failed to read request", zap.Error(err))
+			serverError(w, "could not read request")
+			return
+		}
+
+		var req togglr.UpdateChangeRequestReq
+		if len(body) > 0 {
+			if err := json.Unmarshal(body, &req); err != nil {
+				log.Error("failed to unmarshal update req", zap.Error(err))
+				badRequest(w, "could not unmarshal change request")
+				return
+			}
+		}
+
+		reviewer, found := changeUser(w, r)
+		if !found {
+			return
+		}
+
+		req.ID = crID
+		req.ReviewerID = reviewer.ID
+		req.Status = &status
+		if err := cs.UpdateChangeRequest(r.Context(), req); err != nil {
+			switch {
+			case errors.Is(err, togglr.ErrSelfApproval), errors.Is(err, togglr.ErrReviewerRequired):
+				log.Info("rejected change request review", zap.Error(err))
+				forbidden(w, err.Error())
+			case errors.Is(err, togglr.ErrInvalidTransition), errors.Is(err, togglr.ErrConflict):
+				log.Info("change request could not be updated", zap.Error(err))
+				current, err := cs.FetchChangeRequest(r.Context(), crID)
+				if err != nil {
+					log.Error("failed to fetch current change request", zap.Error(err))
+					serverError(w, "could not update change request")
+					return
+				}
+
+				data, err := json.Marshal(current)
+				if err != nil {
+					log.Error("failed to marshal current change request", zap.Error(err))
+					serverError(w, "could not update change request")
+					return
+				}
+
+				conflict(w, data)
+			default:
+				log.Error("failed to update change request", zap.Error(err))
+				serverError(w, "could not update change request")
+			}
+			return
+		}
+
+		noContent(w)
+	})
+}
+
+// changeUser returns the User a request to author or review a ChangeRequest was made by. ChangeRequests record who
+// wrote and reviewed them, so requests made without a Session are refused since an APIKey doesn't belong to a User
+func changeUser(w http.ResponseWriter, r *http.Request) (togglr.User, bool) {
+	user, found := GetUser(r.Context())
+	if found {
+		return user, true
+	}
+
+	if _, found := GetAPIKey(r.Context()); found {
+		forbidden(w, "change requests must be authored and reviewed by a logged in user")
+		return user, false
+	}
+
+	unauthorized(w, "change requests must be authored and reviewed by a logged in user")
+	return user, false
+}
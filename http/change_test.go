@@ -0,0 +1,169 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	stdhttp "net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/http"
+	"github.com/togglr-io/togglr/mock"
+	"github.com/togglr-io/togglr/uid"
+	"go.uber.org/zap"
+)
+
+func Test_HandleToggleChangePost(t *testing.T) {
+	// SETUP
+	user := togglr.User{ID: uid.New(), Email: "jane@example.com", Identity: togglr.IdentityTypeBasic}
+	services, _ := sessionServices(t, user, "correct horse")
+	cs := mock.NewChangeRequestService(nil)
+	var created togglr.ChangeRequest
+	cs.CreateChangeRequestFn = func(ctx context.Context, cr togglr.ChangeRequest) (uid.UID, error) {
+		created = cr
+		return uid.New(), nil
+	}
+	services.ChangeRequestService = cs
+
+	// the toggle belongs to the user's account
+	toggle := togglr.Toggle{ID: uid.New(), AccountID: uid.New()}
+	ts := mock.NewToggleService(nil)
+	ts.FetchToggleFn = func(ctx context.Context, id uid.UID) (togglr.Toggle, error) {
+		return toggle, nil
+	}
+	services.ToggleService = ts
+	services.UserService.(*mock.UserService).ListUserAccountsFn = func(ctx context.Context, userID uid.UID) ([]uid.UID, error) {
+		return []uid.UID{toggle.AccountID}, nil
+	}
+
+	s := httptest.NewServer(http.BuildRoutes(http.Config{Logger: zap.NewNop(), Services: services}))
+	defer s.Close()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatalf("failed to create cookie jar: %s", err)
+	}
+	client := &stdhttp.Client{Jar: jar}
+
+	url := fmt.Sprintf("%s/toggle/%s/change", s.URL, toggle.ID)
+	body := fmt.Sprintf(`{"authorId": "%s", "change": {"description": "switch providers"}}`, uid.New())
+
+	// RUN
+	res, err := client.Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to send request: %s", err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode != 401 {
+		t.Fatalf("expected submitting a change without logging in to be unauthorized, got %d", res.StatusCode)
+	}
+
+	res, err = client.Post(s.URL+"/session", "application/json", strings.NewReader(`{"email":"jane@example.com","password":"correct horse"}`))
+	if err != nil {
+		t.Fatalf("failed to log in: %s", err)
+	}
+
+	var session struct {
+		CSRFToken string `json:"csrfToken"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&session); err != nil {
+		t.Fatalf("failed to decode session: %s", err)
+	}
+	res.Body.Close()
+
+	req, err := stdhttp.NewRequest(stdhttp.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to create request: %s", err)
+	}
+	req.Header.Set("X-CSRF-Token", session.CSRFToken)
+
+	res, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("failed to send request: %s", err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode != 200 {
+		t.Fatalf("expected change to be submitted, got %d", res.StatusCode)
+	}
+
+	if !created.AuthorID.Equals(user.ID) {
+		t.Fatalf("expected the author to be the logged in user %s, not %s", user.ID, created.AuthorID)
+	}
+}
+
+func Test_HandleChangeApprovePost(t *testing.T) {
+	cases := []struct {
+		name           string
+		apiKey         bool
+		expectedStatus int
+		expectedCalls  int
+	}{
+		{
+			name:           "without a principal",
+			expectedStatus: 401,
+		},
+		{
+			name:           "with an api key",
+			apiKey:         true,
+			expectedStatus: 403,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			accountID := uid.New()
+			cs := mock.NewChangeRequestService(nil)
+			cs.FetchChangeRequestFn = func(ctx context.Context, id uid.UID) (togglr.ChangeRequest, error) {
+				return togglr.ChangeRequest{ID: id, AccountID: accountID, Status: togglr.ChangeRequestStatusPending}, nil
+			}
+			services := http.Services{ChangeRequestService: cs}
+
+			header := ""
+			if c.apiKey {
+				key, secret, err := togglr.NewAPIKey(togglr.APIKey{AccountID: accountID, Name: "management", Kind: togglr.APIKeyKindManagement})
+				if err != nil {
+					t.Fatalf("failed to create api key: %s", err)
+				}
+
+				ks := mock.NewAPIKeyService(nil)
+				ks.FetchAPIKeyByHashFn = func(ctx context.Context, hash string) (togglr.APIKey, error) {
+					return key, nil
+				}
+				services.APIKeyService = ks
+				header = "Bearer " + secret
+			}
+
+			s := httptest.NewServer(http.BuildRoutes(http.Config{Logger: zap.NewNop(), Services: services}))
+			defer s.Close()
+
+			req, err := stdhttp.NewRequest(stdhttp.MethodPost, fmt.Sprintf("%s/change/%s/approve", s.URL, uid.New()), strings.NewReader(fmt.Sprintf(`{"reviewerId": "%s"}`, uid.New())))
+			if err != nil {
+				t.Fatalf("failed to create request: %s", err)
+			}
+
+			if header != "" {
+				req.Header.Set("Authorization", header)
+			}
+
+			res, err := stdhttp.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("failed to send request: %s", err)
+			}
+			res.Body.Close()
+
+			if res.StatusCode != c.expectedStatus {
+				t.Fatalf("expected status code of %d, but got %d", c.expectedStatus, res.StatusCode)
+			}
+
+			if cs.UpdateChangeRequestCalled != c.expectedCalls {
+				t.Fatalf("expected UpdateChangeRequest to be called %d times, but it was called %d times", c.expectedCalls, cs.UpdateChangeRequestCalled)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+var (
+	errNotArray        = errors.New("expected a JSON array")
+	errTooManyElements = errors.New("JSON array has too many elements")
+)
+
+// decodeArray decodes a JSON array one element at a time, calling fn with the decoder positioned at each element.
+// Arrays with more than max elements are rejected as soon as the extra element is reached rather than after
+// decoding all of it. A max of zero allows any number of elements
+func decodeArray(r io.Reader, max int, fn func(dec *json.Decoder) error) error {
+	dec := json.NewDecoder(r)
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return errNotArray
+	}
+
+	for n := 0; dec.More(); n++ {
+		if max > 0 && n >= max {
+			return errTooManyElements
+		}
+
+		if err := fn(dec); err != nil {
+			return err
+		}
+	}
+
+	// consume the closing bracket so that a truncated array is an error
+	_, err = dec.Token()
+	return err
+}
@@ -0,0 +1,151 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/uid"
+	"go.uber.org/zap"
+)
+
+// HandleDefinitionsGET handles GET requests to the /definitions/{accountID} endpoint. It returns every resolvable
+// toggle in the account along with the config version they were read at, so that clients can evaluate toggles
+// locally. Like HandleResolveGET, the config version is used as the ETag so polling clients can skip downloading
+// definitions that haven't changed
+func HandleDefinitionsGET(log *zap.Logger, ts togglr.ToggleService, cvs togglr.ConfigVersionService) http.HandlerFunc {
+	log = log.With(zap.String("handler", "HandleDefinitionsGET"))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accountID := chi.URLParam(r, "accountID")
+		log := log.With(zap.String("accountID", accountID))
+		log.Debug("fetching definitions")
+		defer log.Sync()
+
+		accountUID, err := uid.FromString(accountID)
+		if err != nil {
+			log.Error("failed to parse account ID", zap.Error(err))
+			badRequest(w, "account ID was badly formed")
+			return
+		}
+
+		version, done, err := respondNotModified(r.Context(), w, r, cvs, accountUID)
+		if err != nil {
+			log.Error("failed to fetch config version", zap.Error(err))
+			serverError(w, "could not fetch definitions")
+			return
+		}
+
+		if done {
+			return
+		}
+
+		toggles, err := ts.ListToggles(r.Context(), togglr.ListTogglesReq{AccountID: accountUID})
+		if err != nil {
+			log.Error("failed to list toggles", zap.Error(err))
+			serverError(w, "could not fetch definitions")
+			return
+		}
+
+		data, err := json.Marshal(togglr.Definitions{Version: version, Toggles: toggles})
+		if err != nil {
+			log.Error("failed to marshal response", zap.Error(err))
+			serverError(w, "could not fetch definitions")
+			return
+		}
+
+		ok(w, data)
+	})
+}
+
+// HandleDefinitionsStreamGET handles GET requests to the /definitions/{accountID}/stream endpoint. It sends the
+// account's config version as a version event on connect and whenever its toggles change, which tells clients
+// evaluating locally when to download the definitions again
+func HandleDefinitionsStreamGET(log *zap.Logger, cvs togglr.ConfigVersionService, hub *Hub) http.HandlerFunc {
+	log = log.With(zap.String("handler", "HandleDefinitionsStreamGET"))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accountID := chi.URLParam(r, "accountID")
+		log := log.With(zap.String("accountID", accountID))
+		log.Debug("streaming config versions")
+		defer log.Sync()
+
+		accountUID, err := uid.FromString(accountID)
+		if err != nil {
+			log.Error("failed to parse account ID", zap.Error(err))
+			badRequest(w, "account ID was badly formed")
+			return
+		}
+
+		flusher, canFlush := w.(http.Flusher)
+		if !canFlush {
+			log.Error("response writer doesn't support flushing")
+			serverError(w, "streaming is not supported")
+			return
+		}
+
+		// subscribe before reading the version so that changes made in between aren't missed
+		changes, unsubscribe := hub.Subscribe(accountUID)
+		defer unsubscribe()
+
+		version, err := cvs.FetchConfigVersion(r.Context(), accountUID)
+		if err != nil {
+			log.Error("failed to fetch config version", zap.Error(err))
+			serverError(w, "could not fetch config version")
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		// stop nginx from buffering the stream
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		if err := writeEvent(w, fmt.Sprint(version), "version", version); err != nil {
+			log.Error("failed to write version", zap.Error(err))
+			return
+		}
+		flusher.Flush()
+
+		heartbeat := time.NewTicker(streamHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				log.Debug("stream closed")
+				return
+			case <-heartbeat.C:
+				if _, err := io.WriteString(w, ": heartbeat\n\n"); err != nil {
+					log.Debug("failed to write heartbeat", zap.Error(err))
+					return
+				}
+				flusher.Flush()
+			case <-changes:
+				next, err := cvs.FetchConfigVersion(r.Context(), accountUID)
+				if err != nil {
+					// keep the stream open, the next change will try again
+					log.Error("failed to fetch config version", zap.Error(err))
+					continue
+				}
+
+				// resyncs notify every account, most of which won't have changed
+				if next == version {
+					continue
+				}
+				version = next
+
+				if err := writeEvent(w, fmt.Sprint(version), "version", version); err != nil {
+					log.Debug("failed to write version", zap.Error(err))
+					return
+				}
+				flusher.Flush()
+			}
+		}
+	})
+}
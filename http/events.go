@@ -0,0 +1,308 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/uid"
+	"go.uber.org/zap"
+)
+
+// the most evaluation events accepted in a single request
+const maxEventBatchSize = 10000
+
+// the largest body accepted when reporting evaluation events
+const maxEventBytes = 8 << 20
+
+var errUnknownReason = errors.New("unknown reason")
+
+// how far back evaluation counts are reported by default for each interval
+var defaultEvaluationRanges = map[togglr.Interval]time.Duration{
+	togglr.IntervalHour: 24 * time.Hour,
+	togglr.IntervalDay:  30 * 24 * time.Hour,
+}
+
+// the most buckets a single report of evaluation counts can cover
+const maxEvaluationBuckets = 2000
+
+// HandleEventsPOST handles POST requests to the /events/{accountID} endpoint. SDKs that evaluate toggles locally
+// report their evaluations here as a JSON array of togglr.EvaluationEvent. Events for toggles that don't exist or
+// are archived and events with unknown variants are ignored. Split events only count towards the Toggle's Experiment
+// when they happened while it was running. Events with a reason that isn't a togglr.Reason fail the whole request
+func HandleEventsPOST(log *zap.Logger, ts togglr.ToggleService, es togglr.ExperimentService, recorder togglr.EvaluationRecorder) http.HandlerFunc {
+	log = log.With(zap.String("handler", "HandleEventsPOST"))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accountID := chi.URLParam(r, "accountID")
+		log := log.With(zap.String("accountID", accountID))
+		log.Debug("recording evaluation events")
+		defer log.Sync()
+
+		accountUID, err := uid.FromString(accountID)
+		if err != nil {
+			log.Error("failed to parse account ID", zap.Error(err))
+			badRequest(w, "account ID was badly formed")
+			return
+		}
+
+		var events []togglr.EvaluationEvent
+		body := http.MaxBytesReader(w, r.Body, maxEventBytes)
+		err = decodeArray(body, maxEventBatchSize, func(dec *json.Decoder) error {
+			var event togglr.EvaluationEvent
+			if err := dec.Decode(&event); err != nil {
+				return err
+			}
+
+			// reasons are stored as they're reported, so anything else is turned away before it reaches the recorder
+			if event.Reason != "" && !event.Reason.Valid() {
+				return fmt.Errorf("%w: %q", errUnknownReason, event.Reason)
+			}
+
+			events = append(events, event)
+			return nil
+		})
+
+		if errors.Is(err, errTooManyElements) {
+			log.Info("too many events")
+			badRequest(w, fmt.Sprintf("at most %d events can be sent at once", maxEventBatchSize))
+			return
+		}
+
+		if errors.Is(err, errUnknownReason) {
+			log.Info("unknown reason", zap.Error(err))
+			badRequest(w, err.Error())
+			return
+		}
+
+		if err != nil {
+			log.Error("failed to unmarshal events from request", zap.Error(err))
+			badRequest(w, "could not unmarshal events")
+			return
+		}
+
+		if recorder == nil {
+			noContent(w)
+			return
+		}
+
+		// events don't carry toggle IDs, so each key is looked up once per request, along with the Toggle's running
+		// Experiment. Toggles that don't exist or are archived are remembered with a null ID
+		toggles := make(map[string]togglr.Toggle)
+		experiments := make(map[string]togglr.Experiment)
+		now := time.Now()
+		for _, event := range events {
+			toggle, seen := toggles[event.Key]
+			if !seen {
+				var err error
+				toggle, err = ts.FetchToggleByKey(r.Context(), accountUID, event.Key)
+				if err != nil && !errors.Is(err, togglr.ErrNotFound) {
+					log.Error("failed to fetch toggle", zap.Error(err), zap.String("key", event.Key))
+					serverError(w, "could not record events")
+					return
+				}
+
+				if err != nil || toggle.Archived() {
+					toggle = togglr.Toggle{}
+				}
+				toggles[event.Key] = toggle
+
+				if !toggle.ExperimentID.IsNull() && es != nil {
+					exp, err := es.FetchExperiment(r.Context(), toggle.ExperimentID)
+					if err != nil && !errors.Is(err, togglr.ErrNotFound) {
+						log.Error("failed to fetch experiment", zap.Error(err), zap.String("key", event.Key))
+						serverError(w, "could not record events")
+						return
+					}
+					experiments[event.Key] = exp
+				}
+			}
+
+			if toggle.ID.IsNull() {
+				continue
+			}
+
+			// SDK clocks can't be trusted to be in the past
+			at := event.Timestamp
+			if at.IsZero() || at.After(now) {
+				at = now
+			}
+
+			eval := togglr.Evaluation{
+				AccountID:  accountUID,
+				ToggleID:   toggle.ID,
+				Key:        event.Key,
+				Reason:     event.Reason,
+				ContextKey: event.ContextKey,
+				Time:       at,
+			}
+
+			if variant, ok := toggle.Variants.Find(event.Variant); ok {
+				eval.Value = variant.Value
+				eval.VariantKey = variant.Key
+				// held out and excluded contexts are served a Variant without being exposed to the Experiment, and
+				// splits from before it started belong to no Experiment at all
+				exp, ok := experiments[event.Key]
+				if event.Reason == togglr.ReasonSplit && ok && exp.RunningAt(at) {
+					eval.ExperimentID = exp.ID
+				}
+			} else if event.Variant == togglr.VariantOn || event.Variant == togglr.VariantOff {
+				eval.Value = event.Variant == togglr.VariantOn
+			} else {
+				continue
+			}
+
+			recorder.RecordEvaluation(eval)
+		}
+
+		noContent(w)
+	})
+}
+
+// an evaluationBucket is the distribution of evaluations of a Toggle within one interval
+type evaluationBucket struct {
+	Start    time.Time               `json:"start"`
+	Total    int64                   `json:"total"`
+	Variants map[string]int64        `json:"variants"`
+	Reasons  map[togglr.Reason]int64 `json:"reasons"`
+}
+
+// an evaluationReport is the distribution of evaluations of a Toggle over time. Intervals without any evaluations
+// are left out
+type evaluationReport struct {
+	ToggleID uid.UID            `json:"toggleId"`
+	Interval togglr.Interval    `json:"interval"`
+	From     time.Time          `json:"from"`
+	To       time.Time          `json:"to"`
+	Total    int64              `json:"total"`
+	Variants map[string]int64   `json:"variants"`
+	Buckets  []evaluationBucket `json:"buckets"`
+}
+
+// buildEvaluationReport groups evaluation counts, which must be ordered by bucket, into a report
+func buildEvaluationReport(req togglr.ListEvaluationCountsReq, counts []togglr.EvaluationCount) evaluationReport {
+	report := evaluationReport{
+		ToggleID: req.ToggleID,
+		Interval: req.Interval,
+		From:     req.From,
+		To:       req.To,
+		Variants: make(map[string]int64),
+		Buckets:  []evaluationBucket{},
+	}
+
+	for _, count := range counts {
+		last := len(report.Buckets) - 1
+		if last < 0 || !report.Buckets[last].Start.Equal(count.Bucket) {
+			report.Buckets = append(report.Buckets, evaluationBucket{
+				Start:    count.Bucket,
+				Variants: make(map[string]int64),
+				Reasons:  make(map[togglr.Reason]int64),
+			})
+			last++
+		}
+
+		bucket := &report.Buckets[last]
+		bucket.Total += count.Count
+		bucket.Variants[count.Variant] += count.Count
+		bucket.Reasons[count.Reason] += count.Count
+		report.Total += count.Count
+		report.Variants[count.Variant] += count.Count
+	}
+
+	return report
+}
+
+// parseEvaluationRange reads the interval and time range of an evaluation report from the query parameters
+func parseEvaluationRange(r *http.Request, req *togglr.ListEvaluationCountsReq) error {
+	query := r.URL.Query()
+	req.Interval = togglr.IntervalHour
+	if raw := query.Get("interval"); raw != "" {
+		req.Interval = togglr.Interval(raw)
+	}
+
+	defaultRange, ok := defaultEvaluationRanges[req.Interval]
+	if !ok {
+		return fmt.Errorf("interval must be %q or %q", togglr.IntervalHour, togglr.IntervalDay)
+	}
+
+	req.To = time.Now().UTC()
+	if raw := query.Get("to"); raw != "" {
+		to, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return errors.New("to must be an RFC 3339 timestamp")
+		}
+		req.To = to.UTC()
+	}
+
+	req.From = req.To.Add(-defaultRange)
+	if raw := query.Get("from"); raw != "" {
+		from, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return errors.New("from must be an RFC 3339 timestamp")
+		}
+		req.From = from.UTC()
+	}
+
+	if !req.From.Before(req.To) {
+		return errors.New("from must be before to")
+	}
+
+	bucketSize := togglr.EventBucketSize
+	if req.Interval == togglr.IntervalDay {
+		bucketSize = 24 * time.Hour
+	}
+
+	if req.To.Sub(req.From) > maxEvaluationBuckets*bucketSize {
+		return fmt.Errorf("at most %d %ss can be reported at once", maxEvaluationBuckets, req.Interval)
+	}
+
+	return nil
+}
+
+// HandleToggleEvaluationsGET handles GET requests to the /toggle/{id}/evaluations endpoint. The optional interval
+// query parameter is either hour, the default, or day. The optional from and to parameters are RFC 3339 timestamps,
+// and default to the last day for hourly reports and the last 30 days for daily ones
+func HandleToggleEvaluationsGET(log *zap.Logger, es togglr.EventService) http.HandlerFunc {
+	log = log.With(zap.String("handler", "HandleToggleEvaluationsGET"))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		log := log.With(zap.String("toggleID", id))
+		log.Debug("reporting evaluations")
+		defer log.Sync()
+
+		toggleID, err := uid.FromString(id)
+		if err != nil {
+			log.Error("failed to parse toggle ID", zap.Error(err))
+			badRequest(w, "toggle ID was badly formed")
+			return
+		}
+
+		req := togglr.ListEvaluationCountsReq{ToggleID: toggleID}
+		if err := parseEvaluationRange(r, &req); err != nil {
+			log.Error("failed to parse range", zap.Error(err))
+			badRequest(w, err.Error())
+			return
+		}
+
+		counts, err := es.ListEvaluationCounts(r.Context(), req)
+		if err != nil {
+			log.Error("failed to list evaluation counts", zap.Error(err))
+			serverError(w, "could not report evaluations")
+			return
+		}
+
+		data, err := json.Marshal(buildEvaluationReport(req, counts))
+		if err != nil {
+			log.Error("failed to marshal evaluation report", zap.Error(err))
+			serverError(w, "could not report evaluations")
+			return
+		}
+
+		ok(w, data)
+	})
+}
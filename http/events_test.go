@@ -0,0 +1,311 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/http"
+	"github.com/togglr-io/togglr/mock"
+	"github.com/togglr-io/togglr/uid"
+	"go.uber.org/zap"
+)
+
+// evaluationList is an EvaluationRecorder that keeps every evaluation
+type evaluationList []togglr.Evaluation
+
+func (l *evaluationList) RecordEvaluation(eval togglr.Evaluation) {
+	*l = append(*l, eval)
+}
+
+func Test_HandleEventsPost(t *testing.T) {
+	cases := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedValues []bool
+		// each key is only fetched once per request
+		expectedFetches int
+	}{
+		{
+			name: "events",
+			body: `[
+				{"key":"feature","variant":"on","reason":"targeting_match","contextKey":"user-1","timestamp":"2021-06-01T12:00:00Z"},
+				{"key":"feature","variant":"off","reason":"targeting_match"}
+			]`,
+			expectedStatus:  204,
+			expectedValues:  []bool{true, false},
+			expectedFetches: 1,
+		},
+		{
+			name:            "unknown toggles and variants",
+			body:            `[{"key":"unknown","variant":"on"},{"key":"feature","variant":"maybe"}]`,
+			expectedStatus:  204,
+			expectedFetches: 2,
+		},
+		{
+			name:           "bad events",
+			body:           "nope",
+			expectedStatus: 400,
+		},
+		{
+			name:           "unknown reason",
+			body:           `[{"key":"feature","variant":"on","reason":"targeting_match"},{"key":"feature","variant":"on","reason":"because"}]`,
+			expectedStatus: 400,
+		},
+		{
+			name:           "too many events",
+			body:           "[" + strings.Repeat(`{"key":"feature","variant":"on"},`, 10000) + `{"key":"feature","variant":"on"}]`,
+			expectedStatus: 400,
+		},
+		{
+			name:           "truncated events",
+			body:           `[{"key":"feature","variant":"on"}`,
+			expectedStatus: 400,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ts := mock.NewToggleService(nil)
+			toggleID := uid.New()
+			ts.FetchToggleByKeyFn = func(ctx context.Context, accountID uid.UID, key string) (togglr.Toggle, error) {
+				if key != "feature" {
+					return togglr.Toggle{}, togglr.ErrNotFound
+				}
+
+				return togglr.Toggle{ID: toggleID, Key: key}, nil
+			}
+
+			evals := &evaluationList{}
+			cfg := http.Config{
+				Logger: zap.NewNop(),
+				Services: http.Services{
+					ToggleService:      ts,
+					EvaluationRecorder: evals,
+				},
+			}
+
+			s := httptest.NewServer(http.BuildRoutes(cfg))
+			defer s.Close()
+			url := fmt.Sprintf("%s/events/c149f08b-b0fa-4a5d-8a6c-03ac992aa454", s.URL)
+			res, err := stdhttp.Post(url, "application/json", strings.NewReader(c.body))
+			if err != nil {
+				t.Fatalf("failed to send request: %s", err)
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode != c.expectedStatus {
+				t.Fatalf("expected status %d, got %d", c.expectedStatus, res.StatusCode)
+			}
+
+			if len(*evals) != len(c.expectedValues) {
+				t.Fatalf("expected %d evaluations to be recorded, got %d", len(c.expectedValues), len(*evals))
+			}
+
+			for i, eval := range *evals {
+				if eval.Value != c.expectedValues[i] || !eval.ToggleID.Equals(toggleID) {
+					t.Fatalf("expected evaluation %d to be recorded for the toggle with value %t", i, c.expectedValues[i])
+				}
+
+				if eval.Time.IsZero() {
+					t.Fatalf("expected evaluation %d to have a time", i)
+				}
+			}
+
+			if ts.FetchToggleByKeyCalled != c.expectedFetches {
+				t.Fatalf("expected %d fetches, got %d", c.expectedFetches, ts.FetchToggleByKeyCalled)
+			}
+		})
+	}
+}
+
+func Test_HandleEventsPostExperiment(t *testing.T) {
+	// SETUP
+	started := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	exp := togglr.Experiment{ID: uid.New(), Status: togglr.ExperimentStatusRunning, StartedAt: &started}
+	toggle := togglr.Toggle{
+		ID:           uid.New(),
+		Key:          "checkout",
+		Variants:     togglr.Variants{{Key: "control", Weight: 1}, {Key: "treatment", Value: true, Weight: 1}},
+		ExperimentID: exp.ID,
+	}
+	archivedAt := started
+	archived := togglr.Toggle{ID: uid.New(), Key: "old-checkout", ArchivedAt: &archivedAt}
+
+	ts := mock.NewToggleService(nil)
+	ts.FetchToggleByKeyFn = func(ctx context.Context, accountID uid.UID, key string) (togglr.Toggle, error) {
+		if key == archived.Key {
+			return archived, nil
+		}
+
+		return toggle, nil
+	}
+	es := mock.NewExperimentService(nil)
+	es.FetchExperimentFn = func(ctx context.Context, id uid.UID) (togglr.Experiment, error) {
+		return exp, nil
+	}
+
+	evals := &evaluationList{}
+	cfg := http.Config{
+		Logger: zap.NewNop(),
+		Services: http.Services{
+			ToggleService:      ts,
+			ExperimentService:  es,
+			EvaluationRecorder: evals,
+		},
+	}
+
+	s := httptest.NewServer(http.BuildRoutes(cfg))
+	defer s.Close()
+
+	// RUN
+	body := `[
+		{"key":"checkout","variant":"treatment","reason":"split","timestamp":"2021-05-31T23:59:00Z"},
+		{"key":"checkout","variant":"treatment","reason":"split","timestamp":"2021-06-01T00:01:00Z"},
+		{"key":"checkout","variant":"control","reason":"holdout","timestamp":"2021-06-01T00:01:00Z"},
+		{"key":"old-checkout","variant":"on","reason":"static","timestamp":"2021-06-01T00:01:00Z"}
+	]`
+	url := fmt.Sprintf("%s/events/c149f08b-b0fa-4a5d-8a6c-03ac992aa454", s.URL)
+	res, err := stdhttp.Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to send request: %s", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != 204 {
+		t.Fatalf("expected status 204, got %d", res.StatusCode)
+	}
+
+	if len(*evals) != 3 {
+		t.Fatalf("expected events for archived toggles to be ignored, got %d evaluations", len(*evals))
+	}
+
+	expected := []uid.UID{{}, exp.ID, {}}
+	for i, eval := range *evals {
+		if !eval.ExperimentID.Equals(expected[i]) {
+			t.Fatalf("expected evaluation %d to belong to experiment %q, got %q", i, expected[i], eval.ExperimentID)
+		}
+	}
+
+	if es.FetchExperimentCalled != 1 {
+		t.Fatalf("expected the experiment to be fetched once, got %d fetches", es.FetchExperimentCalled)
+	}
+}
+
+func Test_HandleToggleEvaluationsGet(t *testing.T) {
+	toggleID := uid.New()
+	bucket := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	counts := []togglr.EvaluationCount{
+		{ToggleID: toggleID, Bucket: bucket, Variant: togglr.VariantOn, Reason: togglr.ReasonTargetingMatch, Count: 3},
+		{ToggleID: toggleID, Bucket: bucket, Variant: togglr.VariantOff, Reason: togglr.ReasonPrerequisiteFailed, Count: 1},
+		{ToggleID: toggleID, Bucket: bucket.Add(time.Hour), Variant: togglr.VariantOff, Reason: togglr.ReasonTargetingMatch, Count: 2},
+	}
+
+	cases := []struct {
+		name             string
+		query            string
+		expectedStatus   int
+		expectedInterval togglr.Interval
+		expectedRange    time.Duration
+	}{
+		{
+			name:             "defaults",
+			expectedStatus:   200,
+			expectedInterval: togglr.IntervalHour,
+			expectedRange:    24 * time.Hour,
+		},
+		{
+			name:             "daily",
+			query:            "interval=day&from=2021-05-01T00:00:00Z&to=2021-06-01T00:00:00Z",
+			expectedStatus:   200,
+			expectedInterval: togglr.IntervalDay,
+			expectedRange:    31 * 24 * time.Hour,
+		},
+		{
+			name:           "bad interval",
+			query:          "interval=minute",
+			expectedStatus: 400,
+		},
+		{
+			name:           "backwards",
+			query:          "from=2021-06-01T00:00:00Z&to=2021-05-01T00:00:00Z",
+			expectedStatus: 400,
+		},
+		{
+			name:           "too long",
+			query:          "from=2020-01-01T00:00:00Z&to=2021-06-01T00:00:00Z",
+			expectedStatus: 400,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			es := mock.NewEventService(nil)
+			var req togglr.ListEvaluationCountsReq
+			es.ListEvaluationCountsFn = func(ctx context.Context, r togglr.ListEvaluationCountsReq) ([]togglr.EvaluationCount, error) {
+				req = r
+				return counts, nil
+			}
+
+			cfg := http.Config{
+				Logger:   zap.NewNop(),
+				Services: http.Services{EventService: es},
+			}
+
+			s := httptest.NewServer(http.BuildRoutes(cfg))
+			defer s.Close()
+			url := fmt.Sprintf("%s/toggle/%s/evaluations?%s", s.URL, toggleID, c.query)
+			res, err := stdhttp.Get(url)
+			if err != nil {
+				t.Fatalf("failed to send request: %s", err)
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode != c.expectedStatus {
+				t.Fatalf("expected status %d, got %d", c.expectedStatus, res.StatusCode)
+			}
+
+			if c.expectedStatus != 200 {
+				return
+			}
+
+			if req.Interval != c.expectedInterval || req.To.Sub(req.From) != c.expectedRange {
+				t.Fatalf("expected %s counts over %s, got %s counts over %s", c.expectedInterval, c.expectedRange, req.Interval, req.To.Sub(req.From))
+			}
+
+			var report struct {
+				Total    int64
+				Variants map[string]int64
+				Buckets  []struct {
+					Start    time.Time
+					Total    int64
+					Variants map[string]int64
+					Reasons  map[string]int64
+				}
+			}
+			if err := json.NewDecoder(res.Body).Decode(&report); err != nil {
+				t.Fatalf("failed to decode response: %s", err)
+			}
+
+			if report.Total != 6 || report.Variants[togglr.VariantOn] != 3 || report.Variants[togglr.VariantOff] != 3 {
+				t.Fatalf("expected totals across every bucket, got %+v", report)
+			}
+
+			if len(report.Buckets) != 2 {
+				t.Fatalf("expected 2 buckets, got %d", len(report.Buckets))
+			}
+
+			first := report.Buckets[0]
+			if !first.Start.Equal(bucket) || first.Total != 4 || first.Variants[togglr.VariantOff] != 1 || first.Reasons[string(togglr.ReasonTargetingMatch)] != 3 {
+				t.Fatalf("expected counts in the same bucket to be grouped, got %+v", first)
+			}
+		})
+	}
+}
@@ -0,0 +1,342 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/uid"
+	"go.uber.org/zap"
+)
+
+// the most metric events accepted in a single request
+const maxMetricBatchSize = 10000
+
+// HandleToggleExperimentPOST handles POST requests to the /toggle/{id}/experiment endpoint, creating a draft
+// Experiment on a multivariate Toggle
+func HandleToggleExperimentPOST(log *zap.Logger, es togglr.ExperimentService) http.HandlerFunc {
+	log = log.With(zap.String("handler", "HandleToggleExperimentPOST"))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		log := log.With(zap.String("toggleID", id))
+		log.Debug("creating experiment")
+		defer log.Sync()
+
+		toggleID, err := uid.FromString(id)
+		if err != nil {
+			log.Error("failed to parse toggle ID", zap.Error(err))
+			badRequest(w, "toggle ID was badly formed")
+			return
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			log.Error("failed to read request", zap.Error(err))
+			serverError(w, "could not read request")
+			return
+		}
+
+		var exp togglr.Experiment
+		if err := json.Unmarshal(body, &exp); err != nil {
+			log.Error("failed to unmarshal experiment", zap.Error(err))
+			badRequest(w, "could not unmarshal experiment")
+			return
+		}
+
+		if exp.Name == "" || exp.Metric == "" {
+			badRequest(w, "experiments must have a name and a metric")
+			return
+		}
+
+		if exp.Traffic < 0 || exp.Traffic > togglr.LayerSlots {
+			badRequest(w, "experiment traffic must be a percentage")
+			return
+		}
+
+		exp.ToggleID = toggleID
+		expID, err := es.CreateExperiment(r.Context(), exp)
+		if err != nil {
+			if errors.Is(err, togglr.ErrInvalidExperiment) {
+				log.Info("rejected invalid experiment", zap.Error(err))
+				badRequest(w, err.Error())
+				return
+			}
+
+			log.Error("failed to create experiment", zap.Error(err))
+			serverError(w, "could not save experiment")
+			return
+		}
+
+		data, err := json.Marshal(togglr.ID{ID: expID})
+		if err != nil {
+			log.Error("failed to marshal response", zap.Error(err))
+			serverError(w, "could not save experiment")
+			return
+		}
+
+		ok(w, data)
+	})
+}
+
+// HandleToggleExperimentGET handles GET requests to the /toggle/{id}/experiment endpoint
+func HandleToggleExperimentGET(log *zap.Logger, es togglr.ExperimentService) http.HandlerFunc {
+	log = log.With(zap.String("handler", "HandleToggleExperimentGET"))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		log := log.With(zap.String("toggleID", id))
+		log.Debug("listing experiments")
+		defer log.Sync()
+
+		toggleID, err := uid.FromString(id)
+		if err != nil {
+			log.Error("failed to parse toggle ID", zap.Error(err))
+			badRequest(w, "toggle ID was badly formed")
+			return
+		}
+
+		req := togglr.ListExperimentsReq{
+			ToggleID: toggleID,
+			Status:   togglr.ExperimentStatus(r.URL.Query().Get("status")),
+		}
+
+		exps, err := es.ListExperiments(r.Context(), req)
+		if err != nil {
+			log.Error("failed to list experiments", zap.Error(err))
+			serverError(w, "could not list experiments")
+			return
+		}
+
+		data, err := json.Marshal(exps)
+		if err != nil {
+			log.Error("failed to marshal experiments", zap.Error(err))
+			serverError(w, "could not list experiments")
+			return
+		}
+
+		ok(w, data)
+	})
+}
+
+// HandleExperimentIdGET handles GET requests to the /experiment/{id} endpoint
+func HandleExperimentIdGET(log *zap.Logger, es togglr.ExperimentService) http.HandlerFunc {
+	log = log.With(zap.String("handler", "HandleExperimentIdGET"))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		log := log.With(zap.String("experimentID", id))
+		log.Debug("fetching experiment")
+		defer log.Sync()
+
+		expID, err := uid.FromString(id)
+		if err != nil {
+			log.Error("failed to parse experiment ID", zap.Error(err))
+			badRequest(w, "experiment ID was badly formed")
+			return
+		}
+
+		exp, err := es.FetchExperiment(r.Context(), expID)
+		if err != nil {
+			if errors.Is(err, togglr.ErrNotFound) {
+				notFound(w, "experiment does not exist")
+				return
+			}
+
+			log.Error("failed to fetch experiment", zap.Error(err))
+			serverError(w, "could not fetch experiment")
+			return
+		}
+
+		data, err := json.Marshal(exp)
+		if err != nil {
+			log.Error("failed to marshal experiment", zap.Error(err))
+			serverError(w, "could not fetch experiment")
+			return
+		}
+
+		ok(w, data)
+	})
+}
+
+// HandleExperimentStartPOST handles POST requests to the /experiment/{id}/start endpoint
+func HandleExperimentStartPOST(log *zap.Logger, es togglr.ExperimentService) http.HandlerFunc {
+	return handleExperimentStatus(log.With(zap.String("handler", "HandleExperimentStartPOST")), es, togglr.ExperimentStatusRunning)
+}
+
+// HandleExperimentStopPOST handles POST requests to the /experiment/{id}/stop endpoint
+func HandleExperimentStopPOST(log *zap.Logger, es togglr.ExperimentService) http.HandlerFunc {
+	return handleExperimentStatus(log.With(zap.String("handler", "HandleExperimentStopPOST")), es, togglr.ExperimentStatusStopped)
+}
+
+// handleExperimentStatus builds a handler that moves an Experiment to the given status
+func handleExperimentStatus(log *zap.Logger, es togglr.ExperimentService, status togglr.ExperimentStatus) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		log := log.With(zap.String("experimentID", id), zap.String("status", string(status)))
+		log.Debug("updating experiment")
+		defer log.Sync()
+
+		expID, err := uid.FromString(id)
+		if err != nil {
+			log.Error("failed to parse experiment ID", zap.Error(err))
+			badRequest(w, "experiment ID was badly formed")
+			return
+		}
+
+		req := togglr.UpdateExperimentReq{ID: expID, Status: &status}
+		if err := es.UpdateExperiment(r.Context(), req); err != nil {
+			switch {
+			case errors.Is(err, togglr.ErrNotFound):
+				notFound(w, "experiment does not exist")
+			case errors.Is(err, togglr.ErrApprovalRequired):
+				log.Info("rejected experiment on protected toggle")
+				forbidden(w, "toggle requires approval, experiments can't change it directly")
+			case errors.Is(err, togglr.ErrInvalidExperiment):
+				log.Info("experiment no longer fits its toggle", zap.Error(err))
+				badRequest(w, err.Error())
+			case errors.Is(err, togglr.ErrExperimentRunning):
+				log.Info("rejected second experiment on toggle")
+				conflictMessage(w, "toggle already has a running experiment")
+			case errors.Is(err, togglr.ErrLayerFull):
+				log.Info("rejected experiment in full layer", zap.Error(err))
+				conflictMessage(w, err.Error())
+			case errors.Is(err, togglr.ErrInvalidTransition), errors.Is(err, togglr.ErrConflict):
+				log.Info("experiment could not be updated", zap.Error(err))
+				current, err := es.FetchExperiment(r.Context(), expID)
+				if err != nil {
+					log.Error("failed to fetch current experiment", zap.Error(err))
+					serverError(w, "could not update experiment")
+					return
+				}
+
+				data, err := json.Marshal(current)
+				if err != nil {
+					log.Error("failed to marshal current experiment", zap.Error(err))
+					serverError(w, "could not update experiment")
+					return
+				}
+
+				conflict(w, data)
+			default:
+				log.Error("failed to update experiment", zap.Error(err))
+				serverError(w, "could not update experiment")
+			}
+			return
+		}
+
+		noContent(w)
+	})
+}
+
+// HandleExperimentResultsGET handles GET requests to the /experiment/{id}/results endpoint
+func HandleExperimentResultsGET(log *zap.Logger, es togglr.ExperimentService) http.HandlerFunc {
+	log = log.With(zap.String("handler", "HandleExperimentResultsGET"))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		log := log.With(zap.String("experimentID", id))
+		log.Debug("analyzing experiment")
+		defer log.Sync()
+
+		expID, err := uid.FromString(id)
+		if err != nil {
+			log.Error("failed to parse experiment ID", zap.Error(err))
+			badRequest(w, "experiment ID was badly formed")
+			return
+		}
+
+		results, err := togglr.AnalyzeExperiment(r.Context(), es, expID)
+		if err != nil {
+			if errors.Is(err, togglr.ErrNotFound) {
+				notFound(w, "experiment does not exist")
+				return
+			}
+
+			log.Error("failed to analyze experiment", zap.Error(err))
+			serverError(w, "could not analyze experiment")
+			return
+		}
+
+		data, err := json.Marshal(results)
+		if err != nil {
+			log.Error("failed to marshal results", zap.Error(err))
+			serverError(w, "could not analyze experiment")
+			return
+		}
+
+		ok(w, data)
+	})
+}
+
+// HandleMetricsPOST handles POST requests to the /metrics/{accountID} endpoint. The body is a JSON array of
+// togglr.MetricEvent. Events without a metric or a context key are ignored, since they can't count as conversions
+func HandleMetricsPOST(log *zap.Logger, es togglr.ExperimentService) http.HandlerFunc {
+	log = log.With(zap.String("handler", "HandleMetricsPOST"))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accountID := chi.URLParam(r, "accountID")
+		log := log.With(zap.String("accountID", accountID))
+		log.Debug("recording metric events")
+		defer log.Sync()
+
+		accountUID, err := uid.FromString(accountID)
+		if err != nil {
+			log.Error("failed to parse account ID", zap.Error(err))
+			badRequest(w, "account ID was badly formed")
+			return
+		}
+
+		var raw []struct {
+			togglr.MetricEvent
+			// distinguishes a missing value from an explicit zero
+			Value *float64 `json:"value"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+			log.Error("failed to unmarshal metric events from request", zap.Error(err))
+			badRequest(w, "could not unmarshal metric events")
+			return
+		}
+
+		if len(raw) > maxMetricBatchSize {
+			log.Info("too many metric events", zap.Int("size", len(raw)))
+			badRequest(w, fmt.Sprintf("at most %d metric events can be sent at once", maxMetricBatchSize))
+			return
+		}
+
+		now := time.Now()
+		events := make([]togglr.MetricEvent, 0, len(raw))
+		for _, r := range raw {
+			event := r.MetricEvent
+			if event.Metric == "" || event.ContextKey == "" {
+				continue
+			}
+
+			event.AccountID = accountUID
+			event.Value = 1
+			if r.Value != nil {
+				event.Value = *r.Value
+			}
+
+			// SDK clocks can't be trusted to be in the past
+			if event.OccurredAt.IsZero() || event.OccurredAt.After(now) {
+				event.OccurredAt = now
+			}
+
+			events = append(events, event)
+		}
+
+		if err := es.RecordMetricEvents(r.Context(), events...); err != nil {
+			log.Error("failed to record metric events", zap.Error(err))
+			serverError(w, "could not record metric events")
+			return
+		}
+
+		noContent(w)
+	})
+}
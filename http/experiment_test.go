@@ -0,0 +1,202 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/http"
+	"github.com/togglr-io/togglr/mock"
+	"github.com/togglr-io/togglr/uid"
+	"go.uber.org/zap"
+)
+
+func Test_HandleMetricsPost(t *testing.T) {
+	cases := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedValues []float64
+	}{
+		{
+			name: "metric events",
+			body: `[
+				{"metric":"purchase","contextKey":"user-1","value":19.99,"timestamp":"2021-06-01T12:00:00Z"},
+				{"metric":"purchase","contextKey":"user-2"},
+				{"metric":"purchase","contextKey":"user-3","value":0}
+			]`,
+			expectedStatus: 204,
+			expectedValues: []float64{19.99, 1, 0},
+		},
+		{
+			name:           "events without a context",
+			body:           `[{"metric":"purchase"},{"contextKey":"user-1"}]`,
+			expectedStatus: 204,
+		},
+		{
+			name:           "bad events",
+			body:           "nope",
+			expectedStatus: 400,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var recorded []togglr.MetricEvent
+			es := mock.NewExperimentService(nil)
+			es.RecordMetricEventsFn = func(ctx context.Context, events ...togglr.MetricEvent) error {
+				recorded = events
+				return nil
+			}
+
+			cfg := http.Config{
+				Logger:   zap.NewNop(),
+				Services: http.Services{ExperimentService: es},
+			}
+
+			s := httptest.NewServer(http.BuildRoutes(cfg))
+			defer s.Close()
+			accountID := uid.New()
+			url := fmt.Sprintf("%s/metrics/%s", s.URL, accountID)
+			res, err := stdhttp.Post(url, "application/json", strings.NewReader(c.body))
+			if err != nil {
+				t.Fatalf("failed to send request: %s", err)
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode != c.expectedStatus {
+				t.Fatalf("expected status %d, got %d", c.expectedStatus, res.StatusCode)
+			}
+
+			if len(recorded) != len(c.expectedValues) {
+				t.Fatalf("expected %d metric events to be recorded, got %d", len(c.expectedValues), len(recorded))
+			}
+
+			for i, event := range recorded {
+				if event.Value != c.expectedValues[i] {
+					t.Fatalf("expected metric event %d to have value %f, got %f", i, c.expectedValues[i], event.Value)
+				}
+
+				if !event.AccountID.Equals(accountID) || event.OccurredAt.IsZero() || event.OccurredAt.After(time.Now()) {
+					t.Fatalf("expected metric event %d to belong to the account and have happened, got %+v", i, event)
+				}
+			}
+		})
+	}
+}
+
+func Test_HandleExperimentStartPost(t *testing.T) {
+	cases := []struct {
+		name           string
+		updateErr      error
+		expectedStatus int
+	}{
+		{
+			name:           "started",
+			expectedStatus: 204,
+		},
+		{
+			name:           "already started",
+			updateErr:      togglr.ErrInvalidTransition,
+			expectedStatus: 409,
+		},
+		{
+			name:           "toggle has another experiment",
+			updateErr:      togglr.ErrExperimentRunning,
+			expectedStatus: 409,
+		},
+		{
+			name:           "missing",
+			updateErr:      togglr.ErrNotFound,
+			expectedStatus: 404,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			es := mock.NewExperimentService(nil)
+			es.UpdateExperimentFn = func(ctx context.Context, req togglr.UpdateExperimentReq) error {
+				if req.Status == nil || *req.Status != togglr.ExperimentStatusRunning {
+					t.Fatalf("expected experiment to be started")
+				}
+				return c.updateErr
+			}
+
+			cfg := http.Config{
+				Logger:   zap.NewNop(),
+				Services: http.Services{ExperimentService: es},
+			}
+
+			s := httptest.NewServer(http.BuildRoutes(cfg))
+			defer s.Close()
+			url := fmt.Sprintf("%s/experiment/%s/start", s.URL, uid.New())
+			res, err := stdhttp.Post(url, "application/json", nil)
+			if err != nil {
+				t.Fatalf("failed to send request: %s", err)
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode != c.expectedStatus {
+				t.Fatalf("expected status %d, got %d", c.expectedStatus, res.StatusCode)
+			}
+		})
+	}
+}
+
+func Test_HandleExperimentResultsGet(t *testing.T) {
+	// SETUP
+	expID := uid.New()
+	es := mock.NewExperimentService(nil)
+	es.FetchExperimentFn = func(ctx context.Context, id uid.UID) (togglr.Experiment, error) {
+		return togglr.Experiment{
+			ID:       id,
+			Status:   togglr.ExperimentStatusStopped,
+			Control:  "control",
+			Variants: togglr.Keys{"control", "green"},
+		}, nil
+	}
+	es.TallyExperimentFn = func(ctx context.Context, exp togglr.Experiment) ([]togglr.VariantTally, error) {
+		return []togglr.VariantTally{
+			{Variant: "control", Exposures: 200, Conversions: 20},
+			{Variant: "green", Exposures: 200, Conversions: 30},
+		}, nil
+	}
+
+	cfg := http.Config{
+		Logger:   zap.NewNop(),
+		Services: http.Services{ExperimentService: es},
+	}
+
+	s := httptest.NewServer(http.BuildRoutes(cfg))
+	defer s.Close()
+
+	// RUN
+	res, err := stdhttp.Get(fmt.Sprintf("%s/experiment/%s/results", s.URL, expID))
+	if err != nil {
+		t.Fatalf("failed to send request: %s", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+
+	var results togglr.ExperimentResults
+	if err := json.NewDecoder(res.Body).Decode(&results); err != nil {
+		t.Fatalf("failed to decode results: %s", err)
+	}
+
+	if len(results.Variants) != 2 || results.Variants[1].Variant != "green" {
+		t.Fatalf("expected results for both variants, got %+v", results.Variants)
+	}
+
+	if results.Variants[1].ConversionRate != 0.15 || results.Variants[1].PValue == 0 {
+		t.Fatalf("expected green to be compared to the control, got %+v", results.Variants[1])
+	}
+}
@@ -0,0 +1,129 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/uid"
+	"go.uber.org/zap"
+)
+
+// HandleAccountHoldoutPOST handles POST requests to the /account/{id}/holdout endpoint
+func HandleAccountHoldoutPOST(log *zap.Logger, hs togglr.HoldoutService) http.HandlerFunc {
+	log = log.With(zap.String("handler", "HandleAccountHoldoutPOST"))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		log := log.With(zap.String("accountID", id))
+		log.Debug("creating holdout")
+		defer log.Sync()
+
+		accountID, err := uid.FromString(id)
+		if err != nil {
+			log.Error("failed to parse account ID", zap.Error(err))
+			badRequest(w, "account ID was badly formed")
+			return
+		}
+
+		var holdout togglr.Holdout
+		if err := json.NewDecoder(r.Body).Decode(&holdout); err != nil {
+			log.Error("failed to unmarshal holdout", zap.Error(err))
+			badRequest(w, "could not unmarshal holdout")
+			return
+		}
+
+		if holdout.Name == "" || holdout.Percent < 1 || holdout.Percent > togglr.LayerSlots {
+			badRequest(w, "holdouts must have a name and a percent between 1 and 100")
+			return
+		}
+
+		holdout.ID = uid.UID{}
+		holdout.AccountID = accountID
+		holdoutID, err := hs.CreateHoldout(r.Context(), holdout)
+		if err != nil {
+			log.Error("failed to create holdout", zap.Error(err))
+			serverError(w, "could not save holdout")
+			return
+		}
+
+		data, err := json.Marshal(togglr.ID{ID: holdoutID})
+		if err != nil {
+			log.Error("failed to marshal response", zap.Error(err))
+			serverError(w, "could not save holdout")
+			return
+		}
+
+		ok(w, data)
+	})
+}
+
+// HandleAccountHoldoutGET handles GET requests to the /account/{id}/holdout endpoint
+func HandleAccountHoldoutGET(log *zap.Logger, hs togglr.HoldoutService) http.HandlerFunc {
+	log = log.With(zap.String("handler", "HandleAccountHoldoutGET"))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		log := log.With(zap.String("accountID", id))
+		log.Debug("listing holdouts")
+		defer log.Sync()
+
+		accountID, err := uid.FromString(id)
+		if err != nil {
+			log.Error("failed to parse account ID", zap.Error(err))
+			badRequest(w, "account ID was badly formed")
+			return
+		}
+
+		holdouts, err := hs.ListHoldouts(r.Context(), accountID)
+		if err != nil {
+			log.Error("failed to list holdouts", zap.Error(err))
+			serverError(w, "could not list holdouts")
+			return
+		}
+
+		data, err := json.Marshal(holdouts)
+		if err != nil {
+			log.Error("failed to marshal holdouts", zap.Error(err))
+			serverError(w, "could not list holdouts")
+			return
+		}
+
+		ok(w, data)
+	})
+}
+
+// HandleHoldoutDELETE handles DELETE requests to the /holdout/{id} endpoint. Experiments that are already running
+// keep holding out the contexts that were in the Holdout when they started
+func HandleHoldoutDELETE(log *zap.Logger, hs togglr.HoldoutService) http.HandlerFunc {
+	log = log.With(zap.String("handler", "HandleHoldoutDELETE"))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		log := log.With(zap.String("holdoutID", id))
+		log.Debug("deleting holdout")
+		defer log.Sync()
+
+		holdoutID, err := uid.FromString(id)
+		if err != nil {
+			log.Error("failed to parse holdout ID", zap.Error(err))
+			badRequest(w, "holdout ID was badly formed")
+			return
+		}
+
+		if err := hs.DeleteHoldout(r.Context(), holdoutID); err != nil {
+			if errors.Is(err, togglr.ErrNotFound) {
+				notFound(w, "holdout does not exist")
+				return
+			}
+
+			log.Error("failed to delete holdout", zap.Error(err))
+			serverError(w, "could not delete holdout")
+			return
+		}
+
+		noContent(w)
+	})
+}
@@ -0,0 +1,77 @@
+package http_test
+
+import (
+	"context"
+	"fmt"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/http"
+	"github.com/togglr-io/togglr/mock"
+	"github.com/togglr-io/togglr/uid"
+	"go.uber.org/zap"
+)
+
+func Test_HandleAccountHoldoutPost(t *testing.T) {
+	cases := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedCalls  int
+	}{
+		{
+			name:           "holdout",
+			body:           `{"name":"global","percent":5}`,
+			expectedStatus: 200,
+			expectedCalls:  1,
+		},
+		{
+			name:           "missing name",
+			body:           `{"percent":5}`,
+			expectedStatus: 400,
+		},
+		{
+			name:           "too large",
+			body:           `{"name":"global","percent":101}`,
+			expectedStatus: 400,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			accountID := uid.New()
+			hs := mock.NewHoldoutService(nil)
+			hs.CreateHoldoutFn = func(ctx context.Context, holdout togglr.Holdout) (uid.UID, error) {
+				if !holdout.AccountID.Equals(accountID) {
+					t.Fatalf("expected holdout to belong to the account")
+				}
+				return uid.New(), nil
+			}
+
+			cfg := http.Config{
+				Logger:   zap.NewNop(),
+				Services: http.Services{HoldoutService: hs},
+			}
+
+			s := httptest.NewServer(http.BuildRoutes(cfg))
+			defer s.Close()
+			url := fmt.Sprintf("%s/account/%s/holdout", s.URL, accountID)
+			res, err := stdhttp.Post(url, "application/json", strings.NewReader(c.body))
+			if err != nil {
+				t.Fatalf("failed to send request: %s", err)
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode != c.expectedStatus {
+				t.Fatalf("expected status %d, got %d", c.expectedStatus, res.StatusCode)
+			}
+
+			if hs.CreateHoldoutCalled != c.expectedCalls {
+				t.Fatalf("expected %d holdouts to be created, got %d", c.expectedCalls, hs.CreateHoldoutCalled)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package http
 import (
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
@@ -11,13 +12,37 @@ import (
 	"go.uber.org/zap"
 )
 
+// origins that browsers are allowed to make requests from
+var allowedOrigins = []string{"http://localhost:3000", "http://localhost:9001"}
+
+// headers that browsers are allowed to send, the defaults plus the API key and the CSRF token of Sessions
+var allowedHeaders = []string{"Origin", "Accept", "Content-Type", "X-Requested-With", "Authorization", csrfHeader}
+
 // Services define all of the injectable service interfaces used by the HTTP handlers
 type Services struct {
-	ToggleService   togglr.ToggleService
-	MetadataService togglr.MetadataService
-	AccountService  togglr.AccountService
-	UserService     togglr.UserService
-	Resolver        togglr.Resolver
+	ToggleService        togglr.ToggleService
+	MetadataService      togglr.MetadataService
+	AccountService       togglr.AccountService
+	UserService          togglr.UserService
+	ChangeRequestService togglr.ChangeRequestService
+	UsageService         togglr.UsageService
+	EventService         togglr.EventService
+	ExperimentService    togglr.ExperimentService
+	HoldoutService       togglr.HoldoutService
+	// APIKeyService authenticates requests. Every request is allowed when it's nil
+	APIKeyService togglr.APIKeyService
+	// SessionService stores the Sessions of Users that log in. Users can't log in when it or the Signer is nil
+	SessionService  togglr.SessionService
+	PasswordService togglr.PasswordService
+	// IdentityProviders log Users in with their external IdentityType. Only the ones given can be used
+	IdentityProviders    []togglr.IdentityProvider
+	ConfigVersionService togglr.ConfigVersionService
+	Resolver             togglr.Resolver
+	// EvaluationRecorder is passed evaluations reported by SDKs. They're ignored when it's nil
+	EvaluationRecorder togglr.EvaluationRecorder
+	// Signer signs resolved toggles returned as tokens and Session cookies, each for its own purpose so that one
+	// can't stand in for the other. Tokens can't be requested when it's nil
+	Signer togglr.Signer
 }
 
 // A Config captures all of the information necessary to setup an HTTP server
@@ -26,39 +51,179 @@ type Config struct {
 	Port     uint
 	Services Services
 	Logger   *zap.Logger
+	// Hub notifies open streams of toggle changes. A Hub that's never notified is used when none is given
+	Hub *Hub
+	// TokenTTL is how long signed tokens of resolved toggles are valid for. defaultTokenTTL is used when it's zero
+	TokenTTL time.Duration
+	// SessionTTL is how long Users stay logged in for. defaultSessionTTL is used when it's zero
+	SessionTTL time.Duration
+	// SecureCookies limits Session cookies to HTTPS
+	SecureCookies bool
+	// LoginRedirect is where Users are sent after logging in with an IdentityProvider. defaultLoginRedirect is used
+	// when it's empty
+	LoginRedirect string
+	// SignupDomains are the email domains that Users logging in with an IdentityProvider for the first time are
+	// created for. Anyone else has to be created by an admin before they can log in
+	SignupDomains []string
 }
 
 // BuildRoutes creates a Router and binds HTTP handlers to the routes. Exported mostly for testing purposes, should
 // call Listen with a Config for real use-cases
 func BuildRoutes(cfg Config) chi.Router {
 	r := chi.NewRouter()
+	hub := cfg.Hub
+	if hub == nil {
+		hub = NewHub()
+	}
+
+	sessions := sessionManager{
+		ss:      cfg.Services.SessionService,
+		us:      cfg.Services.UserService,
+		ps:      cfg.Services.PasswordService,
+		signer:  cfg.Services.Signer,
+		ttl:     cfg.SessionTTL,
+		secure:  cfg.SecureCookies,
+		log:     cfg.Logger,
+		domains: cfg.SignupDomains,
+	}
+	if sessions.ttl == 0 {
+		sessions.ttl = defaultSessionTTL
+	}
+	auth := authorizer{ks: cfg.Services.APIKeyService, sessions: sessions.enabled(), log: cfg.Logger}
+
+	providers := make(map[togglr.IdentityType]togglr.IdentityProvider, len(cfg.Services.IdentityProviders))
+	for _, provider := range cfg.Services.IdentityProviders {
+		providers[provider.Identity()] = provider
+	}
+
+	loginRedirect := cfg.LoginRedirect
+	if loginRedirect == "" {
+		loginRedirect = defaultLoginRedirect
+	}
+	tokens := tokenIssuer{signer: cfg.Services.Signer, ttl: cfg.TokenTTL}
+	if tokens.ttl == 0 {
+		tokens.ttl = defaultTokenTTL
+	}
 
 	r.Use(middleware.RealIP)
 	// r.Use(Telemetry(cfg.Logger))
 	r.Use(middleware.Recoverer)
+	r.Use(middleware.Compress(5))
 	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins: []string{"http://localhost:3000", "http://localhost:9001"},
+		AllowedOrigins: allowedOrigins,
+		AllowedHeaders: allowedHeaders,
+		// the UI is served from another origin and has to send its Session cookie along
+		AllowCredentials: true,
 	}))
+	r.Use(sessions.authenticate)
+	r.Use(auth.authenticate)
+
+	// logging in and out is open to everyone
+	r.Post("/session", HandleSessionPOST(cfg.Logger, sessions))
+	r.Get("/session", HandleSessionGET(cfg.Logger))
+	r.Delete("/session", HandleSessionDELETE(cfg.Logger, sessions))
+	r.Get("/oauth/{identity}", HandleOAuthGET(cfg.Logger, providers, sessions))
+	r.Get("/oauth/{identity}/callback", HandleOAuthCallbackGET(cfg.Logger, providers, sessions, loginRedirect))
+
+	// resolving toggles is open to every kind of key
+	r.Group(func(r chi.Router) {
+		r.Use(auth.require(togglr.APIKeyKindClient, togglr.APIKeyKindServer, togglr.APIKeyKindManagement))
+		r.Use(auth.account("accountID", sameAccount))
+
+		r.Get("/resolve/{accountID}", HandleResolveGET(cfg.Logger, cfg.Services.Resolver, cfg.Services.ConfigVersionService, tokens))
+		r.Post("/resolve/{accountID}", HandleResolvePOST(cfg.Logger, cfg.Services.Resolver, tokens))
+		r.Get("/resolve/{accountID}/stream", HandleResolveStreamGET(cfg.Logger, cfg.Services.Resolver, hub))
+		r.Get("/resolve/{accountID}/socket", HandleResolveSocketGET(cfg.Logger, cfg.Services.Resolver, hub))
+		r.Post("/resolve/{accountID}/batch", HandleResolveBatchPOST(cfg.Logger, cfg.Services.Resolver))
+		// stream, socket and batch take precedence, so toggles with those keys can only be resolved in a set
+		r.Get("/resolve/{accountID}/{key}", HandleResolveKeyGET(cfg.Logger, cfg.Services.Resolver, cfg.Services.ConfigVersionService))
+		r.Post("/resolve/{accountID}/{key}", HandleResolveKeyPOST(cfg.Logger, cfg.Services.Resolver))
+	})
+
+	// definitions and events are for SDKs that evaluate toggles themselves, which need a secret key
+	r.Group(func(r chi.Router) {
+		r.Use(auth.require(togglr.APIKeyKindServer, togglr.APIKeyKindManagement))
+		r.Use(auth.account("accountID", sameAccount))
+
+		r.Get("/definitions/{accountID}", HandleDefinitionsGET(cfg.Logger, cfg.Services.ToggleService, cfg.Services.ConfigVersionService))
+		r.Get("/definitions/{accountID}/stream", HandleDefinitionsStreamGET(cfg.Logger, cfg.Services.ConfigVersionService, hub))
+
+		r.Post("/events/{accountID}", HandleEventsPOST(cfg.Logger, cfg.Services.ToggleService, cfg.Services.ExperimentService, cfg.Services.EvaluationRecorder))
+		r.Post("/metrics/{accountID}", HandleMetricsPOST(cfg.Logger, cfg.Services.ExperimentService))
+	})
+
+	// everything else manages an account, and every resource is checked to belong to the key's account
+	r.Group(func(r chi.Router) {
+		r.Use(auth.require(togglr.APIKeyKindManagement))
+
+		// listing and creating toggles limit themselves to the key's account
+		r.Post("/toggle", HandleTogglePOST(cfg.Logger, cfg.Services.ToggleService))
+		r.Get("/toggle", HandleToggleGET(cfg.Logger, cfg.Services.ToggleService, cfg.Services.ConfigVersionService))
+
+		r.With(auth.account("id", toggleAccount(cfg.Services.ToggleService))).Route("/toggle/{id}", func(r chi.Router) {
+			r.Get("/", HandleToggleIdGET(cfg.Logger, cfg.Services.ToggleService))
+			r.Delete("/", HandleToggleDELETE(cfg.Logger, cfg.Services.ToggleService))
+			r.Post("/archive", HandleToggleArchivePOST(cfg.Logger, cfg.Services.ToggleService))
+			r.Post("/restore", HandleToggleRestorePOST(cfg.Logger, cfg.Services.ToggleService))
+			r.Get("/revision", HandleToggleRevisionsGET(cfg.Logger, cfg.Services.ToggleService))
+			r.Post("/revision/{revision}/rollback", HandleToggleRollbackPOST(cfg.Logger, cfg.Services.ToggleService))
+			r.Post("/change", HandleToggleChangePOST(cfg.Logger, cfg.Services.ChangeRequestService))
+			r.Get("/change", HandleToggleChangeGET(cfg.Logger, cfg.Services.ChangeRequestService))
+			r.Get("/evaluations", HandleToggleEvaluationsGET(cfg.Logger, cfg.Services.EventService))
+			r.Post("/experiment", HandleToggleExperimentPOST(cfg.Logger, cfg.Services.ExperimentService))
+			r.Get("/experiment", HandleToggleExperimentGET(cfg.Logger, cfg.Services.ExperimentService))
+		})
+
+		r.With(auth.account("id", changeAccount(cfg.Services.ChangeRequestService))).Route("/change/{id}", func(r chi.Router) {
+			r.Get("/", HandleChangeIdGET(cfg.Logger, cfg.Services.ChangeRequestService))
+			r.Post("/approve", HandleChangeApprovePOST(cfg.Logger, cfg.Services.ChangeRequestService))
+			r.Post("/reject", HandleChangeRejectPOST(cfg.Logger, cfg.Services.ChangeRequestService))
+			r.Post("/apply", HandleChangeApplyPOST(cfg.Logger, cfg.Services.ChangeRequestService))
+		})
+
+		r.With(auth.account("id", experimentAccount(cfg.Services.ExperimentService))).Route("/experiment/{id}", func(r chi.Router) {
+			r.Get("/", HandleExperimentIdGET(cfg.Logger, cfg.Services.ExperimentService))
+			r.Post("/start", HandleExperimentStartPOST(cfg.Logger, cfg.Services.ExperimentService))
+			r.Post("/stop", HandleExperimentStopPOST(cfg.Logger, cfg.Services.ExperimentService))
+			r.Get("/results", HandleExperimentResultsGET(cfg.Logger, cfg.Services.ExperimentService))
+		})
+
+		r.With(auth.account("id", holdoutAccount(cfg.Services.HoldoutService))).Delete("/holdout/{id}", HandleHoldoutDELETE(cfg.Logger, cfg.Services.HoldoutService))
+		r.With(auth.account("id", keyAccount(cfg.Services.APIKeyService))).Delete("/key/{id}", HandleKeyDELETE(cfg.Logger, cfg.Services.APIKeyService))
+		r.With(auth.account("accountID", sameAccount)).Get("/metadata/{accountID}", HandleMetadataGET(cfg.Logger, cfg.Services.MetadataService))
+
+		r.With(auth.account("id", sameAccount)).Route("/account/{id}", func(r chi.Router) {
+			r.Get("/", HandleAccountIdGET(cfg.Logger, cfg.Services.AccountService))
+			r.Get("/user", HandleAccountUsersGET(cfg.Logger, cfg.Services.UserService))
+			r.Post("/user", HandleAccountUsersPOST(cfg.Logger, cfg.Services.AccountService))
+			r.Get("/stale", HandleAccountStaleGET(cfg.Logger, cfg.Services.ToggleService, cfg.Services.UsageService))
+			r.Post("/holdout", HandleAccountHoldoutPOST(cfg.Logger, cfg.Services.HoldoutService))
+			r.Get("/holdout", HandleAccountHoldoutGET(cfg.Logger, cfg.Services.HoldoutService))
+			r.Post("/key", HandleAccountKeyPOST(cfg.Logger, cfg.Services.APIKeyService))
+			r.Get("/key", HandleAccountKeyGET(cfg.Logger, cfg.Services.APIKeyService))
+		})
+	})
+
+	// accounts and users aren't scoped to a single account, so no key can manage them, only Users that are logged in.
+	// Users can see themselves and set their own password, everything else takes an admin
+	r.Group(func(r chi.Router) {
+		r.Use(auth.require())
+
+		// a GET on /user returns the currently logged in user
+		r.Get("/user", HandleUserGET(cfg.Logger))
+		r.Post("/user/{id}/password", HandleUserPasswordPOST(cfg.Logger, cfg.Services.UserService, cfg.Services.PasswordService, cfg.Services.SessionService))
+
+		r.Group(func(r chi.Router) {
+			r.Use(auth.admin)
+
+			r.Post("/account", HandleAccountPOST(cfg.Logger, cfg.Services.AccountService))
+			r.Get("/account", HandleAccountGET(cfg.Logger, cfg.Services.AccountService))
 
-	r.Post("/toggle", HandleTogglePOST(cfg.Logger, cfg.Services.ToggleService))
-	r.Get("/toggle", HandleToggleGET(cfg.Logger, cfg.Services.ToggleService))
-	r.Get("/toggle/{id}", HandleToggleIdGET(cfg.Logger, cfg.Services.ToggleService))
-	r.Delete("/toggle/{id}", HandleToggleDELETE(cfg.Logger, cfg.Services.ToggleService))
-
-	r.Get("/metadata/{accountID}", HandleMetadataGET(cfg.Logger, cfg.Services.MetadataService))
-	r.Post("/resolve/{accountID}", HandleResolvePOST(cfg.Logger, cfg.Services.Resolver))
-
-	r.Post("/account", HandleAccountPOST(cfg.Logger, cfg.Services.AccountService))
-	r.Get("/account", HandleAccountGET(cfg.Logger, cfg.Services.AccountService))
-	r.Get("/account/{id}", HandleAccountIdGET(cfg.Logger, cfg.Services.AccountService))
-	r.Get("/account/{id}/user", HandleAccountUsersGET(cfg.Logger, cfg.Services.UserService))
-	r.Post("/account/{id}/user", HandleAccountUsersPOST(cfg.Logger, cfg.Services.AccountService))
-
-	r.Post("/user", HandleUserPOST(cfg.Logger, cfg.Services.UserService))
-	// a GET on /user returns the currently logged in user
-	r.Get("/user", HandleUserGET(cfg.Logger, cfg.Services.UserService))
-	r.Get("/user/{id}", HandleUserIdGET(cfg.Logger, cfg.Services.UserService))
-	r.Delete("/user/{id}", HandleUserDELETE(cfg.Logger, cfg.Services.UserService))
+			r.Post("/user", HandleUserPOST(cfg.Logger, cfg.Services.UserService))
+			r.Get("/user/{id}", HandleUserIdGET(cfg.Logger, cfg.Services.UserService))
+			r.Delete("/user/{id}", HandleUserDELETE(cfg.Logger, cfg.Services.UserService))
+		})
+	})
 
 	return r
 }
@@ -0,0 +1,88 @@
+package http
+
+import (
+	"sync"
+
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/uid"
+)
+
+// A Hub fans out notifications about toggle changes to every stream open for an account. Notifications carry no
+// data, each stream re-resolves its toggles when notified. Notifications that arrive while a stream is still busy
+// are coalesced
+type Hub struct {
+	mu      sync.Mutex
+	streams map[uid.UID]map[chan struct{}]struct{}
+}
+
+// NewHub returns a new Hub without any streams
+func NewHub() *Hub {
+	return &Hub{
+		streams: make(map[uid.UID]map[chan struct{}]struct{}),
+	}
+}
+
+// Subscribe registers a stream for an account. The returned channel receives a value whenever toggles in the
+// account change, and the returned function must be called once the stream is closed
+func (h *Hub) Subscribe(accountID uid.UID) (<-chan struct{}, func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	changes := make(chan struct{}, 1)
+	if _, ok := h.streams[accountID]; !ok {
+		h.streams[accountID] = make(map[chan struct{}]struct{})
+	}
+	h.streams[accountID][changes] = struct{}{}
+
+	return changes, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		delete(h.streams[accountID], changes)
+		if len(h.streams[accountID]) == 0 {
+			delete(h.streams, accountID)
+		}
+	}
+}
+
+func notify(changes chan struct{}) {
+	select {
+	case changes <- struct{}{}:
+	default:
+		// a notification is already pending, so the stream will pick up this change as well
+	}
+}
+
+// Notify signals every stream open for an account
+func (h *Hub) Notify(accountID uid.UID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for changes := range h.streams[accountID] {
+		notify(changes)
+	}
+}
+
+// NotifyAll signals every open stream
+func (h *Hub) NotifyAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, streams := range h.streams {
+		for changes := range streams {
+			notify(changes)
+		}
+	}
+}
+
+// HandleChange notifies the streams affected by a ChangeEvent
+func (h *Hub) HandleChange(event togglr.ChangeEvent) {
+	if event.Resync {
+		h.NotifyAll()
+		return
+	}
+
+	if !event.AccountID.IsNull() {
+		h.Notify(event.AccountID)
+	}
+}
@@ -0,0 +1,156 @@
+package http
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/togglr-io/togglr"
+	"go.uber.org/zap"
+)
+
+// the cookie that holds the state a User was sent to an IdentityProvider with, and how long they have to come back
+const (
+	oauthStateCookie = "togglr_oauth_state"
+	oauthStateMaxAge = 10 * 60
+)
+
+// the number of random bytes in the state sent to an IdentityProvider
+const oauthStateSize = 32
+
+// where Users are sent after logging in with an IdentityProvider when the Config doesn't say
+const defaultLoginRedirect = "/"
+
+// HandleOAuthGET handles GET requests to the /oauth/{identity} endpoint, sending the User to the IdentityProvider
+// to log in. A random state is kept in a cookie so that the callback can tell the User started logging in here
+func HandleOAuthGET(log *zap.Logger, providers map[togglr.IdentityType]togglr.IdentityProvider, sessions sessionManager) http.HandlerFunc {
+	log = log.With(zap.String("handler", "HandleOAuthGET"))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity := togglr.IdentityType(chi.URLParam(r, "identity"))
+		log := log.With(zap.String("identity", string(identity)))
+		log.Debug("starting oauth login")
+		defer log.Sync()
+
+		provider, found := providers[identity]
+		if !found || !sessions.enabled() {
+			notFound(w, "logging in with this identity type is not enabled")
+			return
+		}
+
+		random := make([]byte, oauthStateSize)
+		if _, err := rand.Read(random); err != nil {
+			log.Error("failed to generate state", zap.Error(err))
+			serverError(w, "could not log in")
+			return
+		}
+
+		state := base64.RawURLEncoding.EncodeToString(random)
+		http.SetCookie(w, &http.Cookie{
+			Name:     oauthStateCookie,
+			Value:    state,
+			Path:     "/oauth",
+			MaxAge:   oauthStateMaxAge,
+			HttpOnly: true,
+			Secure:   sessions.secure,
+			SameSite: http.SameSiteLaxMode,
+		})
+
+		http.Redirect(w, r, provider.AuthCodeURL(state), http.StatusFound)
+	})
+}
+
+// HandleOAuthCallbackGET handles GET requests to the /oauth/{identity}/callback endpoint that IdentityProviders send
+// Users back to. The User with the provider's email for them is logged in, or created if it's the first time they
+// log in, and sent on to the redirect
+func HandleOAuthCallbackGET(log *zap.Logger, providers map[togglr.IdentityType]togglr.IdentityProvider, sessions sessionManager, redirect string) http.HandlerFunc {
+	log = log.With(zap.String("handler", "HandleOAuthCallbackGET"))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity := togglr.IdentityType(chi.URLParam(r, "identity"))
+		log := log.With(zap.String("identity", string(identity)))
+		log.Debug("finishing oauth login")
+		defer log.Sync()
+
+		provider, found := providers[identity]
+		if !found || !sessions.enabled() {
+			notFound(w, "logging in with this identity type is not enabled")
+			return
+		}
+
+		query := r.URL.Query()
+		if reason := query.Get("error"); reason != "" {
+			log.Info("oauth login was not completed", zap.String("reason", reason))
+			unauthorized(w, "login was not completed: "+reason)
+			return
+		}
+
+		// the state has to come back from the same browser that was sent to the provider, otherwise another site
+		// could log the User in as someone else
+		cookie, err := r.Cookie(oauthStateCookie)
+		if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(query.Get("state"))) != 1 {
+			log.Info("rejected oauth callback with mismatched state")
+			forbidden(w, "login state is missing or doesn't match")
+			return
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     oauthStateCookie,
+			Path:     "/oauth",
+			MaxAge:   -1,
+			HttpOnly: true,
+			Secure:   sessions.secure,
+			SameSite: http.SameSiteLaxMode,
+		})
+
+		ext, err := provider.Authenticate(r.Context(), query.Get("code"))
+		if err != nil {
+			if errors.Is(err, togglr.ErrInvalidCredentials) {
+				log.Info("rejected oauth login", zap.Error(err))
+				unauthorized(w, "could not log in with "+string(identity))
+				return
+			}
+
+			log.Error("failed to authenticate with provider", zap.Error(err))
+			serverError(w, "could not log in")
+			return
+		}
+
+		user, err := togglr.LoginExternal(r.Context(), sessions.us, provider.Identity(), ext, sessions.domains)
+		if errors.Is(err, togglr.ErrUnknownUser) {
+			log.Info("rejected oauth login of unknown user")
+			forbidden(w, "no user exists for "+ext.Email+", ask an admin to add you")
+			return
+		}
+
+		if err != nil {
+			log.Error("failed to log in external user", zap.Error(err))
+			serverError(w, "could not log in")
+			return
+		}
+
+		session, err := togglr.NewSession(user.ID, sessions.ttl)
+		if err != nil {
+			log.Error("failed to generate session", zap.Error(err))
+			serverError(w, "could not log in")
+			return
+		}
+
+		if _, err := sessions.ss.CreateSession(r.Context(), session); err != nil {
+			log.Error("failed to create session", zap.Error(err))
+			serverError(w, "could not log in")
+			return
+		}
+
+		if err := sessions.setCookies(w, session); err != nil {
+			log.Error("failed to sign session cookie", zap.Error(err))
+			serverError(w, "could not log in")
+			return
+		}
+
+		http.Redirect(w, r, redirect, http.StatusFound)
+	})
+}
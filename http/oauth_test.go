@@ -0,0 +1,259 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	stdhttp "net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/hmac"
+	"github.com/togglr-io/togglr/http"
+	"github.com/togglr-io/togglr/mock"
+	"github.com/togglr-io/togglr/oauth"
+	"github.com/togglr-io/togglr/uid"
+	"go.uber.org/zap"
+)
+
+// fakeGithub logs every user in as octocat. Its authorize endpoint sends users straight back to the redirect with
+// the code "good", keeping the state they came with unless tamper is set
+func fakeGithub(tamper bool) *httptest.Server {
+	mux := stdhttp.NewServeMux()
+	mux.HandleFunc("/authorize", func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+		state := r.URL.Query().Get("state")
+		if tamper {
+			state = "forged"
+		}
+
+		redirect := fmt.Sprintf("%s?code=good&state=%s", r.URL.Query().Get("redirect_uri"), url.QueryEscape(state))
+		stdhttp.Redirect(w, r, redirect, stdhttp.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{"access_token": "token"})
+	})
+	mux.HandleFunc("/user", func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{"login": "octocat", "name": "The Octocat"})
+	})
+	mux.HandleFunc("/user/emails", func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+		_ = json.NewEncoder(w).Encode([]map[string]interface{}{{"email": "octocat@example.com", "primary": true, "verified": true}})
+	})
+
+	return httptest.NewServer(mux)
+}
+
+// oauthServices returns Services that keep Users and Sessions in memory, starting with the given Users
+func oauthServices(users map[string]togglr.User) (http.Services, *mock.UserService, *mock.SessionService) {
+	us := mock.NewUserService(nil)
+	us.FetchUserByEmailFn = func(ctx context.Context, email string, identity togglr.IdentityType) (togglr.User, error) {
+		for _, user := range users {
+			if user.Email == email && user.Identity == identity {
+				return user, nil
+			}
+		}
+		return togglr.User{}, togglr.ErrNotFound
+	}
+	us.CreateUserFn = func(ctx context.Context, user togglr.User) (uid.UID, error) {
+		user.ID = uid.New()
+		users[user.ID.String()] = user
+		return user.ID, nil
+	}
+	us.FetchUserFn = func(ctx context.Context, id uid.UID) (togglr.User, error) {
+		return users[id.String()], nil
+	}
+
+	sessions := make(map[string]togglr.Session)
+	ss := mock.NewSessionService(nil)
+	ss.CreateSessionFn = func(ctx context.Context, session togglr.Session) (uid.UID, error) {
+		sessions[session.ID.String()] = session
+		return session.ID, nil
+	}
+	ss.FetchSessionFn = func(ctx context.Context, id uid.UID) (togglr.Session, error) {
+		return sessions[id.String()], nil
+	}
+
+	return http.Services{
+		UserService:    us,
+		SessionService: ss,
+		Signer:         hmac.NewSigner("testing"),
+	}, us, ss
+}
+
+// oauthServer starts a server that logs users in with the fake github
+func oauthServer(fake *httptest.Server, cfg http.Config) *httptest.Server {
+	provider := oauth.NewGithub("client", "secret", "")
+	provider.AuthURL = fake.URL + "/authorize"
+	provider.TokenURL = fake.URL + "/token"
+	provider.UserURL = fake.URL + "/user"
+	provider.EmailsURL = fake.URL + "/user/emails"
+
+	// the provider has to know where to send users back to before the server starts
+	s := httptest.NewUnstartedServer(nil)
+	provider.RedirectURL = "http://" + s.Listener.Addr().String() + "/oauth/github/callback"
+	cfg.Services.IdentityProviders = []togglr.IdentityProvider{provider}
+	s.Config.Handler = http.BuildRoutes(cfg)
+	s.Start()
+
+	return s
+}
+
+func Test_HandleOAuthCallbackGet(t *testing.T) {
+	cases := []struct {
+		name           string
+		tamper         bool
+		existing       bool
+		domains        []string
+		expectedStatus int
+		expectedCalls  int
+	}{
+		{
+			name:           "first login creates user",
+			domains:        []string{"example.com"},
+			expectedStatus: 200,
+			expectedCalls:  1,
+		},
+		{
+			name:           "later login links user",
+			existing:       true,
+			expectedStatus: 200,
+		},
+		{
+			name:           "unknown user",
+			domains:        []string{"example.org"},
+			expectedStatus: 403,
+		},
+		{
+			name:           "forged state",
+			tamper:         true,
+			domains:        []string{"example.com"},
+			expectedStatus: 403,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fake := fakeGithub(c.tamper)
+			defer fake.Close()
+
+			users := make(map[string]togglr.User)
+			if c.existing {
+				user := togglr.User{ID: uid.New(), Email: "octocat@example.com", Identity: togglr.IdentityTypeGithub}
+				users[user.ID.String()] = user
+			}
+
+			services, us, ss := oauthServices(users)
+			s := oauthServer(fake, http.Config{
+				Logger:        zap.NewNop(),
+				Services:      services,
+				LoginRedirect: "/user",
+				SignupDomains: c.domains,
+			})
+			defer s.Close()
+
+			jar, err := cookiejar.New(nil)
+			if err != nil {
+				t.Fatalf("failed to create cookie jar: %s", err)
+			}
+			client := &stdhttp.Client{Jar: jar}
+
+			// logging in ends up at the current user once every redirect is followed
+			res, err := client.Get(s.URL + "/oauth/github")
+			if err != nil {
+				t.Fatalf("failed to log in: %s", err)
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode != c.expectedStatus {
+				t.Fatalf("expected status %d, got %d", c.expectedStatus, res.StatusCode)
+			}
+
+			if us.CreateUserCalled != c.expectedCalls {
+				t.Fatalf("expected %d users to be created, got %d", c.expectedCalls, us.CreateUserCalled)
+			}
+
+			if c.expectedStatus != 200 {
+				if ss.CreateSessionCalled != 0 {
+					t.Fatalf("expected no session to be created")
+				}
+				return
+			}
+
+			var user togglr.User
+			if err := json.NewDecoder(res.Body).Decode(&user); err != nil {
+				t.Fatalf("failed to decode user: %s", err)
+			}
+
+			if user.Email != "octocat@example.com" || user.Identity != togglr.IdentityTypeGithub {
+				t.Fatalf("expected to be logged in as the github user, got %+v", user)
+			}
+		})
+	}
+}
+
+func Test_OAuthUserAccounts(t *testing.T) {
+	// SETUP
+	fake := fakeGithub(false)
+	defer fake.Close()
+
+	toggle := togglr.Toggle{ID: uid.New(), AccountID: uid.New(), Key: "secret-feature"}
+	ts := mock.NewToggleService(nil)
+	ts.FetchToggleFn = func(ctx context.Context, id uid.UID) (togglr.Toggle, error) {
+		return toggle, nil
+	}
+
+	services, us, _ := oauthServices(make(map[string]togglr.User))
+	services.APIKeyService = mock.NewAPIKeyService(nil)
+	services.ToggleService = ts
+	s := oauthServer(fake, http.Config{
+		Logger:        zap.NewNop(),
+		Services:      services,
+		LoginRedirect: "/user",
+		SignupDomains: []string{"example.com"},
+	})
+	defer s.Close()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatalf("failed to create cookie jar: %s", err)
+	}
+	client := &stdhttp.Client{Jar: jar}
+
+	// RUN
+	res, err := client.Get(s.URL + "/oauth/github")
+	if err != nil {
+		t.Fatalf("failed to log in: %s", err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode != 200 || us.CreateUserCalled != 1 {
+		t.Fatalf("expected to sign up, got %d", res.StatusCode)
+	}
+
+	res, err = client.Get(fmt.Sprintf("%s/toggle/%s", s.URL, toggle.ID))
+	if err != nil {
+		t.Fatalf("failed to send request: %s", err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode != 403 {
+		t.Fatalf("expected a new user not to access another account's toggle, got %d", res.StatusCode)
+	}
+}
+
+func Test_HandleOAuthGetDisabled(t *testing.T) {
+	s := httptest.NewServer(http.BuildRoutes(http.Config{Logger: zap.NewNop()}))
+	defer s.Close()
+
+	res, err := stdhttp.Get(s.URL + "/oauth/google")
+	if err != nil {
+		t.Fatalf("failed to send request: %s", err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode != 404 {
+		t.Fatalf("expected unconfigured provider to be missing, got %d", res.StatusCode)
+	}
+}
@@ -0,0 +1,72 @@
+package http
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/togglr-io/togglr"
+)
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+// A listResponse wraps a page of results with the cursor needed to fetch the next page. NextCursor is omitted
+// on the last page
+type listResponse struct {
+	Items      interface{} `json:"items"`
+	NextCursor string      `json:"nextCursor,omitempty"`
+}
+
+// listBody builds the body returned for a list of results. Lists requested without a limit or cursor aren't paged
+// and keep their original shape of a bare array, while pages are wrapped in a listResponse
+func listBody(items interface{}, page togglr.Page, cursor string) interface{} {
+	if page.Limit == 0 {
+		return items
+	}
+
+	return listResponse{Items: items, NextCursor: cursor}
+}
+
+// pageFromQuery builds a Page from the pagination parameters in a query string. Results are only paged when a limit
+// or cursor is given, in which case the limit defaults to defaultPageLimit and can't exceed maxPageLimit
+func pageFromQuery(query url.Values) (togglr.Page, error) {
+	page := togglr.Page{
+		Cursor: query.Get("cursor"),
+		SortBy: query.Get("sortBy"),
+		Order:  togglr.SortOrder(query.Get("order")),
+	}
+
+	if page.Cursor != "" {
+		page.Limit = defaultPageLimit
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		val, err := strconv.ParseUint(limit, 10, 32)
+		if err != nil || val == 0 || val > maxPageLimit {
+			return page, fmt.Errorf("limit must be between 1 and %d", maxPageLimit)
+		}
+
+		page.Limit = uint(val)
+	}
+
+	return page, page.Validate()
+}
+
+// nextCursor determines the cursor for the page following a list of results. Lists are fetched with one more
+// result than the page's limit so that the presence of that extra result signals another page. Unpaged lists never
+// have a next page
+func nextCursor(count int, page togglr.Page, cursorAt func(idx int) (togglr.Cursor, error)) (int, string, error) {
+	if page.Limit == 0 || uint(count) <= page.Limit {
+		return count, "", nil
+	}
+
+	cursor, err := cursorAt(int(page.Limit) - 1)
+	if err != nil {
+		return count, "", err
+	}
+
+	return int(page.Limit), cursor.Encode(), nil
+}
@@ -2,8 +2,12 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/go-chi/chi"
 	"github.com/togglr-io/togglr"
@@ -12,8 +16,9 @@ import (
 	"go.uber.org/zap"
 )
 
-// HandleResolvePost handles POST requests to the /resolve endpoint
-func HandleResolvePOST(log *zap.Logger, resolver togglr.Resolver) http.HandlerFunc {
+// HandleResolvePost handles POST requests to the /resolve endpoint. When the format=token query parameter is given,
+// the resolved toggles are returned as a signed, expiring token that can be verified offline with the token package
+func HandleResolvePOST(log *zap.Logger, resolver togglr.Resolver, tokens tokenIssuer) http.HandlerFunc {
 	log = log.With(zap.String("handler", "handleResolvePOST"))
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -42,14 +47,318 @@ func HandleResolvePOST(log *zap.Logger, resolver togglr.Resolver) http.HandlerFu
 			return
 		}
 
-		resolved, err := resolver.Resolve(r.Context(), accountUID, rules.MetaFromRaw(rawMetadata))
+		asToken := wantsToken(r.URL.Query())
+		if asToken && !tokens.enabled() {
+			log.Info("token requested without a signer")
+			badRequest(w, "signed tokens are not enabled")
+			return
+		}
+
+		resolved, err := resolver.Resolve(r.Context(), accountUID, rules.MetaFromRaw(rawMetadata), keysFromQuery(r.URL.Query())...)
 		if err != nil {
 			log.Error("failed to resolve toggles", zap.Error(err))
 			serverError(w, "could not resolve toggles")
 			return
 		}
 
-		data, err := json.Marshal(resolved)
+		var data []byte
+		if asToken {
+			data, err = tokens.issue(accountUID, resolved)
+		} else {
+			data, err = json.Marshal(resolved)
+		}
+
+		if err != nil {
+			log.Error("failed to marshal response", zap.Error(err))
+			serverError(w, "could not resolve toggles")
+			return
+		}
+
+		ok(w, data)
+	})
+}
+
+// metadataFromQuery reads metadata given as JSON in the metadata query parameter, for clients that can't send a
+// request body
+func metadataFromQuery(query url.Values) (rules.Metadata, error) {
+	rawMetadata := map[string]interface{}{}
+	if raw := query.Get("metadata"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &rawMetadata); err != nil {
+			return nil, err
+		}
+	}
+
+	return rules.MetaFromRaw(rawMetadata), nil
+}
+
+// keysFromQuery reads the comma separated list of toggle keys a resolve is limited to
+func keysFromQuery(query url.Values) []string {
+	keys := []string{}
+	for _, key := range strings.Split(query.Get("keys"), ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// HandleResolveGET handles GET requests to the /resolve/{accountID} endpoint. Metadata is given as JSON in the
+// metadata query parameter. Responses carry the account's config version as their ETag, so polling clients can
+// use If-None-Match to skip downloading toggles that haven't changed. Like HandleResolvePOST, the toggles are
+// returned as a signed token when format=token is given
+func HandleResolveGET(log *zap.Logger, resolver togglr.Resolver, cvs togglr.ConfigVersionService, tokens tokenIssuer) http.HandlerFunc {
+	log = log.With(zap.String("handler", "HandleResolveGET"))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accountID := chi.URLParam(r, "accountID")
+		log := log.With(zap.String("accountID", accountID))
+		log.Debug("resolving toggles")
+		defer log.Sync()
+
+		accountUID, err := uid.FromString(accountID)
+		if err != nil {
+			log.Error("failed to parse account ID", zap.Error(err))
+			badRequest(w, "account ID was badly formed")
+			return
+		}
+
+		metadata, err := metadataFromQuery(r.URL.Query())
+		if err != nil {
+			log.Error("failed to unmarshal metadata from request", zap.Error(err))
+			badRequest(w, "could not unmarshal metadata")
+			return
+		}
+
+		asToken := wantsToken(r.URL.Query())
+		if asToken && !tokens.enabled() {
+			log.Info("token requested without a signer")
+			badRequest(w, "signed tokens are not enabled")
+			return
+		}
+
+		// tokens expire, so a client holding one can't be told nothing has changed
+		if !asToken {
+			_, done, err := respondNotModified(r.Context(), w, r, cvs, accountUID)
+			if err != nil {
+				log.Error("failed to fetch config version", zap.Error(err))
+				serverError(w, "could not resolve toggles")
+				return
+			}
+
+			if done {
+				return
+			}
+		}
+
+		resolved, err := resolver.Resolve(r.Context(), accountUID, metadata, keysFromQuery(r.URL.Query())...)
+		if err != nil {
+			log.Error("failed to resolve toggles", zap.Error(err))
+			serverError(w, "could not resolve toggles")
+			return
+		}
+
+		var data []byte
+		if asToken {
+			data, err = tokens.issue(accountUID, resolved)
+		} else {
+			data, err = json.Marshal(resolved)
+		}
+
+		if err != nil {
+			log.Error("failed to marshal response", zap.Error(err))
+			serverError(w, "could not resolve toggles")
+			return
+		}
+
+		ok(w, data)
+	})
+}
+
+// resolveKey resolves a single toggle and writes it as a ResolvedToggles with one entry
+func resolveKey(log *zap.Logger, w http.ResponseWriter, r *http.Request, resolver togglr.Resolver, accountID uid.UID, key string, metadata rules.Metadata) {
+	value, err := resolver.ResolveOne(r.Context(), accountID, key, metadata)
+	if err != nil {
+		if errors.Is(err, togglr.ErrNotFound) {
+			log.Info("toggle not found")
+			notFound(w, "toggle not found")
+			return
+		}
+
+		log.Error("failed to resolve toggle", zap.Error(err))
+		serverError(w, "could not resolve toggle")
+		return
+	}
+
+	data, err := json.Marshal(togglr.ResolvedToggles{key: value})
+	if err != nil {
+		log.Error("failed to marshal response", zap.Error(err))
+		serverError(w, "could not resolve toggle")
+		return
+	}
+
+	ok(w, data)
+}
+
+// HandleResolveKeyGET handles GET requests to the /resolve/{accountID}/{key} endpoint. It behaves like
+// HandleResolveGET, only evaluating a single toggle
+func HandleResolveKeyGET(log *zap.Logger, resolver togglr.Resolver, cvs togglr.ConfigVersionService) http.HandlerFunc {
+	log = log.With(zap.String("handler", "HandleResolveKeyGET"))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accountID := chi.URLParam(r, "accountID")
+		key := chi.URLParam(r, "key")
+		log := log.With(zap.String("accountID", accountID), zap.String("key", key))
+		log.Debug("resolving toggle")
+		defer log.Sync()
+
+		accountUID, err := uid.FromString(accountID)
+		if err != nil {
+			log.Error("failed to parse account ID", zap.Error(err))
+			badRequest(w, "account ID was badly formed")
+			return
+		}
+
+		metadata, err := metadataFromQuery(r.URL.Query())
+		if err != nil {
+			log.Error("failed to unmarshal metadata from request", zap.Error(err))
+			badRequest(w, "could not unmarshal metadata")
+			return
+		}
+
+		_, done, err := respondNotModified(r.Context(), w, r, cvs, accountUID)
+		if err != nil {
+			log.Error("failed to fetch config version", zap.Error(err))
+			serverError(w, "could not resolve toggle")
+			return
+		}
+
+		if done {
+			return
+		}
+
+		resolveKey(log, w, r, resolver, accountUID, key, metadata)
+	})
+}
+
+// HandleResolveKeyPOST handles POST requests to the /resolve/{accountID}/{key} endpoint. It behaves like
+// HandleResolvePOST, only evaluating a single toggle
+func HandleResolveKeyPOST(log *zap.Logger, resolver togglr.Resolver) http.HandlerFunc {
+	log = log.With(zap.String("handler", "HandleResolveKeyPOST"))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accountID := chi.URLParam(r, "accountID")
+		key := chi.URLParam(r, "key")
+		log := log.With(zap.String("accountID", accountID), zap.String("key", key))
+		log.Debug("resolving toggle")
+		defer log.Sync()
+
+		accountUID, err := uid.FromString(accountID)
+		if err != nil {
+			log.Error("failed to parse account ID", zap.Error(err))
+			badRequest(w, "account ID was badly formed")
+			return
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			log.Error("failed to read request", zap.Error(err))
+			serverError(w, "could not read request")
+			return
+		}
+
+		var rawMetadata map[string]interface{}
+		if err := json.Unmarshal(body, &rawMetadata); err != nil {
+			log.Error("failed to unmarshal metadata from request", zap.Error(err))
+			badRequest(w, "could not unmarshal metadata")
+			return
+		}
+
+		resolveKey(log, w, r, resolver, accountUID, key, rules.MetaFromRaw(rawMetadata))
+	})
+}
+
+// the most contexts a batch can hold when the results are returned as a single JSON array. Larger batches have to
+// be streamed as NDJSON
+const maxBatchSize = 10000
+
+// how many NDJSON lines are written between flushes when streaming a batch
+const batchFlushInterval = 100
+
+// the largest body a batch can be sent in, streamed or not
+const maxBatchBytes = 64 << 20
+
+// HandleResolveBatchPOST handles POST requests to the /resolve/{accountID}/batch endpoint. The body is a JSON array
+// of metadata objects, and the response is an array of resolved toggles in the same order. Clients that accept
+// application/x-ndjson get each result as its own line as soon as it's ready instead, which is how batches larger
+// than maxBatchSize have to be resolved. Either way the body can't be larger than maxBatchBytes
+func HandleResolveBatchPOST(log *zap.Logger, resolver togglr.Resolver) http.HandlerFunc {
+	log = log.With(zap.String("handler", "HandleResolveBatchPOST"))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accountID := chi.URLParam(r, "accountID")
+		log := log.With(zap.String("accountID", accountID))
+		log.Debug("resolving batch")
+		defer log.Sync()
+
+		accountUID, err := uid.FromString(accountID)
+		if err != nil {
+			log.Error("failed to parse account ID", zap.Error(err))
+			badRequest(w, "account ID was badly formed")
+			return
+		}
+
+		stream := strings.Contains(r.Header.Get("Accept"), "application/x-ndjson")
+		limit := maxBatchSize
+		if stream {
+			limit = 0
+		}
+
+		req := togglr.ResolveBatchReq{
+			AccountID: accountUID,
+			Metadata:  []rules.Metadata{},
+			Keys:      keysFromQuery(r.URL.Query()),
+		}
+
+		body := http.MaxBytesReader(w, r.Body, maxBatchBytes)
+		err = decodeArray(body, limit, func(dec *json.Decoder) error {
+			var raw map[string]interface{}
+			if err := dec.Decode(&raw); err != nil {
+				return err
+			}
+
+			req.Metadata = append(req.Metadata, rules.MetaFromRaw(raw))
+			return nil
+		})
+		if errors.Is(err, errTooManyElements) {
+			log.Info("batch too large")
+			badRequest(w, fmt.Sprintf("batches of more than %d contexts must be streamed as application/x-ndjson", maxBatchSize))
+			return
+		}
+
+		if err != nil {
+			log.Error("failed to unmarshal metadata from request", zap.Error(err))
+			badRequest(w, "could not unmarshal metadata")
+			return
+		}
+
+		if stream {
+			streamBatch(log, w, r, resolver, req)
+			return
+		}
+
+		results := make([]togglr.ResolvedToggles, len(req.Metadata))
+		err = resolver.ResolveBatch(r.Context(), req, func(i int, resolved togglr.ResolvedToggles) error {
+			results[i] = resolved
+			return nil
+		})
+		if err != nil {
+			log.Error("failed to resolve batch", zap.Error(err))
+			serverError(w, "could not resolve toggles")
+			return
+		}
+
+		data, err := json.Marshal(results)
 		if err != nil {
 			log.Error("failed to marshal response", zap.Error(err))
 			serverError(w, "could not resolve toggles")
@@ -59,3 +368,36 @@ func HandleResolvePOST(log *zap.Logger, resolver togglr.Resolver) http.HandlerFu
 		ok(w, data)
 	})
 }
+
+// streamBatch writes the results of a batch as NDJSON. Since the status has already been sent by the time most
+// errors can happen, a failed batch ends with an error line instead
+func streamBatch(log *zap.Logger, w http.ResponseWriter, r *http.Request, resolver togglr.Resolver, req togglr.ResolveBatchReq) {
+	flusher, canFlush := w.(http.Flusher)
+	flush := func() {
+		if canFlush {
+			flusher.Flush()
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	enc := json.NewEncoder(w)
+	err := resolver.ResolveBatch(r.Context(), req, func(i int, resolved togglr.ResolvedToggles) error {
+		if err := enc.Encode(resolved); err != nil {
+			return err
+		}
+
+		if (i+1)%batchFlushInterval == 0 {
+			flush()
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Error("failed to stream batch", zap.Error(err))
+		_ = enc.Encode(map[string]string{"error": "could not resolve toggles"})
+	}
+
+	flush()
+}
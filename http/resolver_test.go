@@ -0,0 +1,392 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/hmac"
+	"github.com/togglr-io/togglr/http"
+	"github.com/togglr-io/togglr/mock"
+	"github.com/togglr-io/togglr/rules"
+	"github.com/togglr-io/togglr/token"
+	"github.com/togglr-io/togglr/uid"
+	"go.uber.org/zap"
+)
+
+func Test_HandleResolveGet(t *testing.T) {
+	cases := []struct {
+		name           string
+		accountID      string
+		query          string
+		ifNoneMatch    string
+		acceptEncoding string
+		expectedStatus int
+		expectedCalls  int
+	}{
+		{
+			name:           "successful test",
+			accountID:      "c149f08b-b0fa-4a5d-8a6c-03ac992aa454",
+			query:          `metadata={"userType":"admin"}`,
+			expectedStatus: 200,
+			expectedCalls:  1,
+		},
+		{
+			name:           "not modified",
+			accountID:      "c149f08b-b0fa-4a5d-8a6c-03ac992aa454",
+			ifNoneMatch:    `"2", W/"3"`,
+			expectedStatus: 304,
+			expectedCalls:  0,
+		},
+		{
+			name:           "stale etag",
+			accountID:      "c149f08b-b0fa-4a5d-8a6c-03ac992aa454",
+			ifNoneMatch:    `W/"2"`,
+			expectedStatus: 200,
+			expectedCalls:  1,
+		},
+		{
+			name:           "compressed",
+			accountID:      "c149f08b-b0fa-4a5d-8a6c-03ac992aa454",
+			acceptEncoding: "gzip",
+			expectedStatus: 200,
+			expectedCalls:  1,
+		},
+		{
+			name:           "bad account ID",
+			accountID:      "123",
+			expectedStatus: 400,
+			expectedCalls:  0,
+		},
+		{
+			name:           "bad metadata",
+			accountID:      "c149f08b-b0fa-4a5d-8a6c-03ac992aa454",
+			query:          "metadata=nope",
+			expectedStatus: 400,
+			expectedCalls:  0,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ts := mock.NewToggleService(nil)
+			cvs := mock.NewConfigVersionService(nil)
+			cvs.FetchConfigVersionFn = func(ctx context.Context, accountID uid.UID) (int64, error) {
+				return 3, nil
+			}
+
+			cfg := http.Config{
+				Logger: zap.NewNop(),
+				Services: http.Services{
+					Resolver:             togglr.NewResolver(ts, nil),
+					ConfigVersionService: cvs,
+				},
+			}
+
+			s := httptest.NewServer(http.BuildRoutes(cfg))
+			defer s.Close()
+			url := fmt.Sprintf("%s/resolve/%s?%s", s.URL, c.accountID, c.query)
+			req, err := stdhttp.NewRequest("GET", url, nil)
+			if err != nil {
+				t.Fatalf("failed to create request: %s", err)
+			}
+
+			if c.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", c.ifNoneMatch)
+			}
+
+			if c.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", c.acceptEncoding)
+			}
+
+			res, err := stdhttp.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("failed to send request: %s", err)
+			}
+			res.Body.Close()
+
+			if res.StatusCode != c.expectedStatus {
+				t.Fatalf("expected status code of %d, but got %d", c.expectedStatus, res.StatusCode)
+			}
+
+			if c.expectedStatus < 400 && res.Header.Get("ETag") != `W/"3"` {
+				t.Fatalf("expected the config version as the ETag, got %q", res.Header.Get("ETag"))
+			}
+
+			if c.acceptEncoding != "" && res.Header.Get("Content-Encoding") != c.acceptEncoding {
+				t.Fatalf("expected a %s response, got %q", c.acceptEncoding, res.Header.Get("Content-Encoding"))
+			}
+
+			if ts.ListTogglesCalled != c.expectedCalls {
+				t.Fatalf("expected ListToggles to be called %d times, but it was called %d times", c.expectedCalls, ts.ListTogglesCalled)
+			}
+		})
+	}
+}
+
+func Test_HandleResolveKey(t *testing.T) {
+	cases := []struct {
+		name           string
+		method         string
+		key            string
+		body           string
+		query          string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "get",
+			method:         "GET",
+			key:            "feature",
+			query:          `metadata={"userType":"admin"}`,
+			expectedStatus: 200,
+			expectedBody:   `{"feature":true}`,
+		},
+		{
+			name:           "post",
+			method:         "POST",
+			key:            "feature",
+			body:           `{"userType":"admin"}`,
+			expectedStatus: 200,
+			expectedBody:   `{"feature":true}`,
+		},
+		{
+			name:           "not found",
+			method:         "GET",
+			key:            "unknown",
+			expectedStatus: 404,
+		},
+		{
+			name:           "bad metadata",
+			method:         "POST",
+			key:            "feature",
+			body:           "nope",
+			expectedStatus: 400,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ts := mock.NewToggleService(nil)
+			ts.FetchToggleByKeyFn = func(ctx context.Context, accountID uid.UID, key string) (togglr.Toggle, error) {
+				if key != "feature" {
+					return togglr.Toggle{}, togglr.ErrNotFound
+				}
+
+				return togglr.Toggle{ID: uid.New(), Key: key}, nil
+			}
+
+			cfg := http.Config{
+				Logger: zap.NewNop(),
+				Services: http.Services{
+					Resolver:             togglr.NewResolver(ts, nil),
+					ConfigVersionService: mock.NewConfigVersionService(nil),
+				},
+			}
+
+			s := httptest.NewServer(http.BuildRoutes(cfg))
+			defer s.Close()
+			url := fmt.Sprintf("%s/resolve/c149f08b-b0fa-4a5d-8a6c-03ac992aa454/%s?%s", s.URL, c.key, c.query)
+			req, err := stdhttp.NewRequest(c.method, url, strings.NewReader(c.body))
+			if err != nil {
+				t.Fatalf("failed to create request: %s", err)
+			}
+
+			res, err := stdhttp.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("failed to send request: %s", err)
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode != c.expectedStatus {
+				t.Fatalf("expected status code of %d, but got %d", c.expectedStatus, res.StatusCode)
+			}
+
+			if c.expectedBody != "" {
+				body, _ := ioutil.ReadAll(res.Body)
+				if string(body) != c.expectedBody {
+					t.Fatalf("expected a body of %s, got %s", c.expectedBody, body)
+				}
+			}
+		})
+	}
+}
+
+func Test_HandleResolveBatchPost(t *testing.T) {
+	cases := []struct {
+		name           string
+		body           string
+		accept         string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "json",
+			body:           `[{"userType":"admin"},{"userType":"user"}]`,
+			expectedStatus: 200,
+			expectedBody:   `[{"admin-feature":true},{"admin-feature":false}]`,
+		},
+		{
+			name:           "ndjson",
+			body:           `[{"userType":"admin"},{"userType":"user"}]`,
+			accept:         "application/x-ndjson",
+			expectedStatus: 200,
+			expectedBody:   "{\"admin-feature\":true}\n{\"admin-feature\":false}\n",
+		},
+		{
+			name:           "bad metadata",
+			body:           `{"userType":"admin"}`,
+			expectedStatus: 400,
+		},
+		{
+			name:           "truncated batch",
+			body:           `[{"userType":"admin"},{"userType":"user"}`,
+			expectedStatus: 400,
+		},
+		{
+			name:           "too many contexts",
+			body:           "[" + strings.Repeat(`{"userType":"admin"},`, 10000) + `{"userType":"user"}]`,
+			expectedStatus: 400,
+		},
+		{
+			name:           "too many contexts streamed",
+			body:           "[" + strings.Repeat(`{"userType":"admin"},`, 10000) + `{"userType":"user"}]`,
+			accept:         "application/x-ndjson",
+			expectedStatus: 200,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ts := mock.NewToggleService(nil)
+			ts.ListTogglesFn = func(ctx context.Context, req togglr.ListTogglesReq) ([]togglr.Toggle, error) {
+				return []togglr.Toggle{
+					{
+						ID:  uid.New(),
+						Key: "admin-feature",
+						Rules: rules.Rules{
+							{
+								Op: rules.BinOpAnd,
+								Expr: rules.Expression{
+									Type:   rules.ExprTypeBinary,
+									Binary: rules.NewBinary(rules.NewIdent("userType"), rules.NewString("admin"), rules.BinOpEq),
+								},
+							},
+						},
+					},
+				}, nil
+			}
+
+			cfg := http.Config{
+				Logger: zap.NewNop(),
+				Services: http.Services{
+					Resolver: togglr.NewResolver(ts, nil),
+				},
+			}
+
+			s := httptest.NewServer(http.BuildRoutes(cfg))
+			defer s.Close()
+			url := fmt.Sprintf("%s/resolve/c149f08b-b0fa-4a5d-8a6c-03ac992aa454/batch", s.URL)
+			req, err := stdhttp.NewRequest("POST", url, strings.NewReader(c.body))
+			if err != nil {
+				t.Fatalf("failed to create request: %s", err)
+			}
+
+			if c.accept != "" {
+				req.Header.Set("Accept", c.accept)
+			}
+
+			res, err := stdhttp.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("failed to send request: %s", err)
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode != c.expectedStatus {
+				t.Fatalf("expected status code of %d, but got %d", c.expectedStatus, res.StatusCode)
+			}
+
+			if c.expectedBody != "" {
+				body, _ := ioutil.ReadAll(res.Body)
+				if string(body) != c.expectedBody {
+					t.Fatalf("expected a body of %q, got %q", c.expectedBody, body)
+				}
+			}
+		})
+	}
+}
+
+func Test_HandleResolveToken(t *testing.T) {
+	cases := []struct {
+		name           string
+		signer         togglr.Signer
+		expectedStatus int
+	}{
+		{
+			name:           "signed",
+			signer:         hmac.NewSigner("testing"),
+			expectedStatus: 200,
+		},
+		{
+			name:           "no signer",
+			expectedStatus: 400,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ts := mock.NewToggleService(nil)
+			ts.ListTogglesFn = func(ctx context.Context, req togglr.ListTogglesReq) ([]togglr.Toggle, error) {
+				return []togglr.Toggle{{ID: uid.New(), Key: "feature"}}, nil
+			}
+
+			cfg := http.Config{
+				Logger: zap.NewNop(),
+				Services: http.Services{
+					Resolver: togglr.NewResolver(ts, nil),
+					Signer:   c.signer,
+				},
+			}
+
+			s := httptest.NewServer(http.BuildRoutes(cfg))
+			defer s.Close()
+			url := fmt.Sprintf("%s/resolve/c149f08b-b0fa-4a5d-8a6c-03ac992aa454?format=token", s.URL)
+			res, err := stdhttp.Post(url, "application/json", strings.NewReader("{}"))
+			if err != nil {
+				t.Fatalf("failed to send request: %s", err)
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode != c.expectedStatus {
+				t.Fatalf("expected status code of %d, but got %d", c.expectedStatus, res.StatusCode)
+			}
+
+			if c.signer == nil {
+				return
+			}
+
+			var body struct {
+				Token string `json:"token"`
+			}
+			if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+				t.Fatalf("failed to decode response: %s", err)
+			}
+
+			claims, err := token.Verify(c.signer, body.Token, time.Now())
+			if err != nil {
+				t.Fatalf("failed to verify token: %s", err)
+			}
+
+			if !claims.Toggles["feature"] {
+				t.Fatalf("expected the token to carry the resolved toggles, got %v", claims.Toggles)
+			}
+		})
+	}
+}
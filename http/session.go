@@ -0,0 +1,364 @@
+package http
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/hmac"
+	"github.com/togglr-io/togglr/uid"
+	"go.uber.org/zap"
+)
+
+// the cookies that hold a signed Session ID and the Session's CSRF token, and the header the token is repeated in
+const (
+	sessionCookie = "togglr_session"
+	csrfCookie    = "togglr_csrf"
+	csrfHeader    = "X-CSRF-Token"
+)
+
+// Session cookies are signed for their own purpose, since the same keys also sign tokens
+const sessionPurpose = "session"
+
+// how long Sessions last when the Config doesn't say
+const defaultSessionTTL = 24 * time.Hour
+
+// WithUser attaches the User a request was made by to its context
+func WithUser(ctx context.Context, user togglr.User) context.Context {
+	return context.WithValue(ctx, ctxKey{name: "user"}, user)
+}
+
+// GetUser returns the User a request was made by. The returned bool is false if it wasn't made with a Session
+func GetUser(ctx context.Context) (togglr.User, bool) {
+	user, ok := ctx.Value(ctxKey{name: "user"}).(togglr.User)
+	return user, ok
+}
+
+func withUserAccounts(ctx context.Context, accounts []uid.UID) context.Context {
+	return context.WithValue(ctx, ctxKey{name: "userAccounts"}, accounts)
+}
+
+// getUserAccounts returns the accounts the User a request was made by belongs to. The returned bool is false if
+// they weren't looked up, which is the case for admins
+func getUserAccounts(ctx context.Context) ([]uid.UID, bool) {
+	accounts, ok := ctx.Value(ctxKey{name: "userAccounts"}).([]uid.UID)
+	return accounts, ok
+}
+
+func withSession(ctx context.Context, session togglr.Session) context.Context {
+	return context.WithValue(ctx, ctxKey{name: "session"}, session)
+}
+
+func getSession(ctx context.Context) (togglr.Session, bool) {
+	session, ok := ctx.Value(ctxKey{name: "session"}).(togglr.Session)
+	return session, ok
+}
+
+// a loginRequest is the body of a request to log in with the basic IdentityType
+type loginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// a sessionResponse describes the Session a request was made with. The CSRFToken has to be sent in the
+// X-CSRF-Token header of every request made with the Session that changes anything
+type sessionResponse struct {
+	User      togglr.User `json:"user"`
+	CSRFToken string      `json:"csrfToken"`
+	ExpiresAt time.Time   `json:"expiresAt"`
+}
+
+// A sessionManager logs Users in and authenticates the requests they make with their Session cookie. Sessions are
+// disabled when there's no SessionService or no Signer to sign their cookies with
+type sessionManager struct {
+	ss     togglr.SessionService
+	us     togglr.UserService
+	ps     togglr.PasswordService
+	signer togglr.Signer
+	ttl    time.Duration
+	secure bool
+	// the email domains Users logging in with an IdentityProvider can sign up from
+	domains []string
+	log     *zap.Logger
+}
+
+func (s sessionManager) enabled() bool {
+	return s.ss != nil && s.signer != nil
+}
+
+// cookieValue signs the ID of a Session so that it can't be tampered with in the cookie. The ID is signed for the
+// session purpose, so tokens signed with the same keys can't be used as cookies
+func (s sessionManager) cookieValue(id uid.UID) (string, error) {
+	signed, err := hmac.WithPurpose(s.signer, sessionPurpose).Sign([]byte(id.String()))
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(signed), nil
+}
+
+// sessionID validates the signature of a cookie and returns the Session ID inside it
+func (s sessionManager) sessionID(value string) (uid.UID, error) {
+	signed, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return uid.UID{}, err
+	}
+
+	data, err := hmac.WithPurpose(s.signer, sessionPurpose).Validate(signed)
+	if err != nil {
+		return uid.UID{}, err
+	}
+
+	return uid.FromString(string(data))
+}
+
+// setCookies sets the Session cookie, which scripts can't read, and the CSRF cookie, which scripts on our own
+// origin read to fill in the X-CSRF-Token header
+func (s sessionManager) setCookies(w http.ResponseWriter, session togglr.Session) error {
+	value, err := s.cookieValue(session.ID)
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    value,
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		HttpOnly: true,
+		Secure:   s.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookie,
+		Value:    session.CSRFToken,
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		Secure:   s.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return nil
+}
+
+func (s sessionManager) clearCookies(w http.ResponseWriter) {
+	for _, name := range []string{sessionCookie, csrfCookie} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Path:     "/",
+			MaxAge:   -1,
+			HttpOnly: name == sessionCookie,
+			Secure:   s.secure,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+}
+
+// authenticate attaches the User and Session of requests made with a Session cookie to their context. Requests
+// with a cookie that's invalid or expired are passed on without a User and have the cookies cleared. Requests that
+// can change anything are forbidden unless they repeat the Session's CSRF token in the X-CSRF-Token header, since
+// browsers send the cookie along with requests made by any site. Requests with an Authorization header are
+// authenticated with an APIKey instead and are passed on untouched
+func (s sessionManager) authenticate(next http.Handler) http.Handler {
+	if !s.enabled() {
+		return next
+	}
+
+	log := s.log.With(zap.String("middleware", "session"))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(sessionCookie)
+		if err != nil || r.Header.Get("Authorization") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		id, err := s.sessionID(cookie.Value)
+		if err != nil {
+			log.Info("rejected invalid session cookie", zap.Error(err))
+			s.clearCookies(w)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		session, err := togglr.AuthenticateSession(r.Context(), s.ss, id)
+		if errors.Is(err, togglr.ErrInvalidSession) {
+			s.clearCookies(w)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if err != nil {
+			log.Error("failed to authenticate session", zap.Error(err))
+			serverError(w, "could not authenticate request")
+			return
+		}
+
+		user, err := s.us.FetchUser(r.Context(), session.UserID)
+		if err != nil && !errors.Is(err, togglr.ErrNotFound) {
+			log.Error("failed to fetch session user", zap.Error(err))
+			serverError(w, "could not authenticate request")
+			return
+		}
+
+		// the User was deleted after logging in
+		if user.ID.IsNull() {
+			s.clearCookies(w)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !safeMethod(r.Method) && subtle.ConstantTimeCompare([]byte(r.Header.Get(csrfHeader)), []byte(session.CSRFToken)) != 1 {
+			log.Info("rejected request without csrf token", zap.String("userID", user.ID.String()))
+			forbidden(w, "missing or invalid csrf token")
+			return
+		}
+
+		ctx := withSession(WithUser(r.Context(), user), session)
+		// admins can access every account, so there's no need to know which ones they belong to
+		if !user.Admin {
+			accounts, err := s.us.ListUserAccounts(r.Context(), user.ID)
+			if err != nil {
+				log.Error("failed to list accounts of session user", zap.Error(err), zap.String("userID", user.ID.String()))
+				serverError(w, "could not authenticate request")
+				return
+			}
+
+			ctx = withUserAccounts(ctx, accounts)
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// safeMethod returns true for the HTTP methods that never change anything
+func safeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+
+	return false
+}
+
+// HandleSessionPOST handles POST requests to the /session endpoint, logging a User with the basic IdentityType in
+// with their email and password
+func HandleSessionPOST(log *zap.Logger, sessions sessionManager) http.HandlerFunc {
+	log = log.With(zap.String("handler", "HandleSessionPOST"))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.Debug("logging in")
+		defer log.Sync()
+
+		if !sessions.enabled() {
+			notFound(w, "logging in is not enabled")
+			return
+		}
+
+		var req loginRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Error("failed to unmarshal login", zap.Error(err))
+			badRequest(w, "could not unmarshal login")
+			return
+		}
+
+		if req.Email == "" || req.Password == "" {
+			badRequest(w, "an email and a password are required to log in")
+			return
+		}
+
+		user, err := togglr.Login(r.Context(), sessions.us, sessions.ps, req.Email, req.Password)
+		if err != nil {
+			if errors.Is(err, togglr.ErrInvalidCredentials) {
+				log.Info("rejected login")
+				unauthorized(w, err.Error())
+				return
+			}
+
+			log.Error("failed to log in", zap.Error(err))
+			serverError(w, "could not log in")
+			return
+		}
+
+		session, err := togglr.NewSession(user.ID, sessions.ttl)
+		if err != nil {
+			log.Error("failed to generate session", zap.Error(err))
+			serverError(w, "could not log in")
+			return
+		}
+
+		if _, err := sessions.ss.CreateSession(r.Context(), session); err != nil {
+			log.Error("failed to create session", zap.Error(err))
+			serverError(w, "could not log in")
+			return
+		}
+
+		data, err := json.Marshal(sessionResponse{User: user, CSRFToken: session.CSRFToken, ExpiresAt: session.ExpiresAt})
+		if err != nil {
+			log.Error("failed to marshal session", zap.Error(err))
+			serverError(w, "could not log in")
+			return
+		}
+
+		if err := sessions.setCookies(w, session); err != nil {
+			log.Error("failed to sign session cookie", zap.Error(err))
+			serverError(w, "could not log in")
+			return
+		}
+
+		ok(w, data)
+	})
+}
+
+// HandleSessionGET handles GET requests to the /session endpoint, describing the Session the request was made with
+// so that a reloaded page can find its CSRF token again
+func HandleSessionGET(log *zap.Logger) http.HandlerFunc {
+	log = log.With(zap.String("handler", "HandleSessionGET"))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.Debug("fetching session")
+		defer log.Sync()
+
+		session, found := getSession(r.Context())
+		user, _ := GetUser(r.Context())
+		if !found {
+			unauthorized(w, "not logged in")
+			return
+		}
+
+		data, err := json.Marshal(sessionResponse{User: user, CSRFToken: session.CSRFToken, ExpiresAt: session.ExpiresAt})
+		if err != nil {
+			log.Error("failed to marshal session", zap.Error(err))
+			serverError(w, "could not fetch session")
+			return
+		}
+
+		ok(w, data)
+	})
+}
+
+// HandleSessionDELETE handles DELETE requests to the /session endpoint, logging the User out. The cookies are
+// cleared even if the request wasn't made with a Session
+func HandleSessionDELETE(log *zap.Logger, sessions sessionManager) http.HandlerFunc {
+	log = log.With(zap.String("handler", "HandleSessionDELETE"))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.Debug("logging out")
+		defer log.Sync()
+
+		if session, found := getSession(r.Context()); found {
+			if err := sessions.ss.DeleteSession(r.Context(), session.ID); err != nil {
+				log.Error("failed to delete session", zap.Error(err))
+				serverError(w, "could not log out")
+				return
+			}
+		}
+
+		sessions.clearCookies(w)
+		noContent(w)
+	})
+}
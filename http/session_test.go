@@ -0,0 +1,432 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	stdhttp "net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/hmac"
+	"github.com/togglr-io/togglr/http"
+	"github.com/togglr-io/togglr/mock"
+	"github.com/togglr-io/togglr/uid"
+	"go.uber.org/zap"
+)
+
+// sessionServices returns Services that log in the given User with the given password, keeping Sessions in memory
+func sessionServices(t *testing.T, user togglr.User, password string) (http.Services, *mock.SessionService) {
+	hash, err := togglr.HashPassword(password)
+	if err != nil {
+		t.Fatalf("failed to hash password: %s", err)
+	}
+
+	us := mock.NewUserService(nil)
+	us.FetchUserByEmailFn = func(ctx context.Context, email string, identity togglr.IdentityType) (togglr.User, error) {
+		if email != user.Email || identity != user.Identity {
+			return togglr.User{}, togglr.ErrNotFound
+		}
+		return user, nil
+	}
+	us.FetchUserFn = func(ctx context.Context, id uid.UID) (togglr.User, error) {
+		if !id.Equals(user.ID) {
+			return togglr.User{}, togglr.ErrNotFound
+		}
+		return user, nil
+	}
+
+	ps := mock.NewPasswordService(nil)
+	ps.FetchPasswordHashFn = func(ctx context.Context, userID uid.UID) (string, error) {
+		return hash, nil
+	}
+
+	sessions := make(map[string]togglr.Session)
+	ss := mock.NewSessionService(nil)
+	ss.CreateSessionFn = func(ctx context.Context, session togglr.Session) (uid.UID, error) {
+		sessions[session.ID.String()] = session
+		return session.ID, nil
+	}
+	ss.FetchSessionFn = func(ctx context.Context, id uid.UID) (togglr.Session, error) {
+		session, ok := sessions[id.String()]
+		if !ok {
+			return session, togglr.ErrNotFound
+		}
+		return session, nil
+	}
+	ss.DeleteSessionFn = func(ctx context.Context, id uid.UID) error {
+		delete(sessions, id.String())
+		return nil
+	}
+
+	return http.Services{
+		UserService:     us,
+		PasswordService: ps,
+		SessionService:  ss,
+		Signer:          hmac.NewSigner("testing"),
+	}, ss
+}
+
+// login logs in to a server, returning a client that sends the Session cookie along and the Session's CSRF token
+func login(t *testing.T, s *httptest.Server, email, password string) (*stdhttp.Client, string) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatalf("failed to create cookie jar: %s", err)
+	}
+	client := &stdhttp.Client{Jar: jar}
+
+	body := fmt.Sprintf(`{"email":%q,"password":%q}`, email, password)
+	res, err := client.Post(s.URL+"/session", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to log in: %s", err)
+	}
+	defer res.Body.Close()
+
+	var session struct {
+		CSRFToken string `json:"csrfToken"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&session); err != nil {
+		t.Fatalf("failed to decode session: %s", err)
+	}
+
+	return client, session.CSRFToken
+}
+
+func Test_HandleSessionPost(t *testing.T) {
+	user := togglr.User{ID: uid.New(), Email: "jane@example.com", Identity: togglr.IdentityTypeBasic}
+
+	cases := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedCalls  int
+	}{
+		{
+			name:           "login",
+			body:           `{"email":"jane@example.com","password":"correct horse"}`,
+			expectedStatus: 200,
+			expectedCalls:  1,
+		},
+		{
+			name:           "wrong password",
+			body:           `{"email":"jane@example.com","password":"battery staple"}`,
+			expectedStatus: 401,
+		},
+		{
+			name:           "unknown email",
+			body:           `{"email":"john@example.com","password":"correct horse"}`,
+			expectedStatus: 401,
+		},
+		{
+			name:           "missing password",
+			body:           `{"email":"jane@example.com"}`,
+			expectedStatus: 400,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			services, ss := sessionServices(t, user, "correct horse")
+			cfg := http.Config{
+				Logger:   zap.NewNop(),
+				Services: services,
+			}
+
+			s := httptest.NewServer(http.BuildRoutes(cfg))
+			defer s.Close()
+
+			res, err := stdhttp.Post(s.URL+"/session", "application/json", strings.NewReader(c.body))
+			if err != nil {
+				t.Fatalf("failed to send request: %s", err)
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode != c.expectedStatus {
+				t.Fatalf("expected status %d, got %d", c.expectedStatus, res.StatusCode)
+			}
+
+			if ss.CreateSessionCalled != c.expectedCalls {
+				t.Fatalf("expected %d sessions to be created, got %d", c.expectedCalls, ss.CreateSessionCalled)
+			}
+
+			if c.expectedCalls > 0 && len(res.Cookies()) != 2 {
+				t.Fatalf("expected session and csrf cookies to be set, got %v", res.Cookies())
+			}
+		})
+	}
+}
+
+func Test_SessionAuth(t *testing.T) {
+	// SETUP
+	user := togglr.User{ID: uid.New(), Email: "jane@example.com", Identity: togglr.IdentityTypeBasic}
+	accountID, otherAccountID := uid.New(), uid.New()
+	services, ss := sessionServices(t, user, "correct horse")
+	services.APIKeyService = mock.NewAPIKeyService(nil)
+	services.AccountService = mock.NewAccountService(nil)
+	services.ToggleService = mock.NewToggleService(nil)
+	services.ConfigVersionService = mock.NewConfigVersionService(nil)
+	services.UserService.(*mock.UserService).ListUserAccountsFn = func(ctx context.Context, userID uid.UID) ([]uid.UID, error) {
+		return []uid.UID{accountID}, nil
+	}
+	cfg := http.Config{
+		Logger:   zap.NewNop(),
+		Services: services,
+	}
+
+	s := httptest.NewServer(http.BuildRoutes(cfg))
+	defer s.Close()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatalf("failed to create cookie jar: %s", err)
+	}
+	client := &stdhttp.Client{Jar: jar}
+
+	send := func(method, path, csrfToken string, body string) *stdhttp.Response {
+		req, err := stdhttp.NewRequest(method, s.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("failed to create request: %s", err)
+		}
+
+		if csrfToken != "" {
+			req.Header.Set("X-CSRF-Token", csrfToken)
+		}
+
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("failed to send request: %s", err)
+		}
+		res.Body.Close()
+		return res
+	}
+
+	// RUN
+	if res := send(stdhttp.MethodGet, "/user", "", ""); res.StatusCode != 401 {
+		t.Fatalf("expected fetching the current user before logging in to be unauthorized, got %d", res.StatusCode)
+	}
+
+	req, err := stdhttp.NewRequest(stdhttp.MethodPost, s.URL+"/session", strings.NewReader(`{"email":"jane@example.com","password":"correct horse"}`))
+	if err != nil {
+		t.Fatalf("failed to create request: %s", err)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("failed to log in: %s", err)
+	}
+
+	var session struct {
+		User      togglr.User `json:"user"`
+		CSRFToken string      `json:"csrfToken"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&session); err != nil {
+		t.Fatalf("failed to decode session: %s", err)
+	}
+	res.Body.Close()
+
+	if !session.User.ID.Equals(user.ID) || session.CSRFToken == "" {
+		t.Fatalf("expected to be logged in as %s with a csrf token, got %+v", user.ID, session)
+	}
+
+	if res := send(stdhttp.MethodGet, "/user", "", ""); res.StatusCode != 200 {
+		t.Fatalf("expected to fetch the current user, got %d", res.StatusCode)
+	}
+
+	if res := send(stdhttp.MethodGet, "/account", "", ""); res.StatusCode != 403 {
+		t.Fatalf("expected users that aren't admins not to manage accounts, got %d", res.StatusCode)
+	}
+
+	if res := send(stdhttp.MethodGet, fmt.Sprintf("/toggle?accountId=%s", accountID), "", ""); res.StatusCode != 200 {
+		t.Fatalf("expected users to list the toggles of their account, got %d", res.StatusCode)
+	}
+
+	if res := send(stdhttp.MethodGet, "/toggle", "", ""); res.StatusCode != 200 {
+		t.Fatalf("expected users of a single account to list its toggles by default, got %d", res.StatusCode)
+	}
+
+	if res := send(stdhttp.MethodGet, fmt.Sprintf("/toggle?accountId=%s", otherAccountID), "", ""); res.StatusCode != 403 {
+		t.Fatalf("expected listing the toggles of another account to be forbidden, got %d", res.StatusCode)
+	}
+
+	if res := send(stdhttp.MethodGet, fmt.Sprintf("/account/%s", otherAccountID), "", ""); res.StatusCode != 403 {
+		t.Fatalf("expected fetching another account to be forbidden, got %d", res.StatusCode)
+	}
+
+	password := fmt.Sprintf("/user/%s/password", user.ID)
+	change := `{"currentPassword":"correct horse","password":"hunter2hunter2"}`
+	if res := send(stdhttp.MethodPost, password, "", change); res.StatusCode != 403 {
+		t.Fatalf("expected change without csrf token to be forbidden, got %d", res.StatusCode)
+	}
+
+	if res := send(stdhttp.MethodPost, password, "nope", change); res.StatusCode != 403 {
+		t.Fatalf("expected change with wrong csrf token to be forbidden, got %d", res.StatusCode)
+	}
+
+	if res := send(stdhttp.MethodPost, password, session.CSRFToken, `{"password":"hunter2hunter2"}`); res.StatusCode != 403 {
+		t.Fatalf("expected change without the current password to be forbidden, got %d", res.StatusCode)
+	}
+
+	if res := send(stdhttp.MethodPost, password, session.CSRFToken, `{"currentPassword":"battery staple","password":"hunter2hunter2"}`); res.StatusCode != 403 {
+		t.Fatalf("expected change with the wrong current password to be forbidden, got %d", res.StatusCode)
+	}
+
+	if res := send(stdhttp.MethodPost, password, session.CSRFToken, change); res.StatusCode != 204 {
+		t.Fatalf("expected change with csrf token to be allowed, got %d", res.StatusCode)
+	}
+
+	if ss.DeleteUserSessionsCalled != 1 {
+		t.Fatalf("expected the other sessions of the user to be revoked")
+	}
+
+	if res := send(stdhttp.MethodGet, "/user", "", ""); res.StatusCode != 200 {
+		t.Fatalf("expected the session the password was changed with to stay logged in, got %d", res.StatusCode)
+	}
+
+	if res := send(stdhttp.MethodPost, fmt.Sprintf("/user/%s/password", uid.New()), session.CSRFToken, `{"password":"hunter2hunter2"}`); res.StatusCode != 403 {
+		t.Fatalf("expected setting the password of another user to be forbidden, got %d", res.StatusCode)
+	}
+
+	if res := send(stdhttp.MethodDelete, "/session", session.CSRFToken, ""); res.StatusCode != 204 {
+		t.Fatalf("expected to log out, got %d", res.StatusCode)
+	}
+
+	if ss.DeleteSessionCalled != 1 {
+		t.Fatalf("expected the session to be deleted")
+	}
+
+	if res := send(stdhttp.MethodGet, "/user", "", ""); res.StatusCode != 401 {
+		t.Fatalf("expected fetching the current user after logging out to be unauthorized, got %d", res.StatusCode)
+	}
+}
+
+func Test_SessionAdmin(t *testing.T) {
+	// SETUP
+	user := togglr.User{ID: uid.New(), Email: "root@example.com", Identity: togglr.IdentityTypeBasic, Admin: true}
+	services, _ := sessionServices(t, user, "correct horse")
+	services.APIKeyService = mock.NewAPIKeyService(nil)
+	services.AccountService = mock.NewAccountService(nil)
+	services.ToggleService = mock.NewToggleService(nil)
+	services.ConfigVersionService = mock.NewConfigVersionService(nil)
+	us := services.UserService.(*mock.UserService)
+
+	s := httptest.NewServer(http.BuildRoutes(http.Config{Logger: zap.NewNop(), Services: services}))
+	defer s.Close()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatalf("failed to create cookie jar: %s", err)
+	}
+	client := &stdhttp.Client{Jar: jar}
+
+	res, err := client.Post(s.URL+"/session", "application/json", strings.NewReader(`{"email":"root@example.com","password":"correct horse"}`))
+	if err != nil {
+		t.Fatalf("failed to log in: %s", err)
+	}
+
+	var session struct {
+		CSRFToken string `json:"csrfToken"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&session); err != nil {
+		t.Fatalf("failed to decode session: %s", err)
+	}
+	res.Body.Close()
+
+	// RUN
+	req, err := stdhttp.NewRequest(stdhttp.MethodPost, s.URL+"/account", strings.NewReader(`{"name":"acme"}`))
+	if err != nil {
+		t.Fatalf("failed to create request: %s", err)
+	}
+	req.Header.Set("X-CSRF-Token", session.CSRFToken)
+
+	res, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("failed to send request: %s", err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode != 200 {
+		t.Fatalf("expected admins to create accounts, got %d", res.StatusCode)
+	}
+
+	res, err = client.Get(fmt.Sprintf("%s/toggle?accountId=%s", s.URL, uid.New()))
+	if err != nil {
+		t.Fatalf("failed to send request: %s", err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode != 200 {
+		t.Fatalf("expected admins to access every account, got %d", res.StatusCode)
+	}
+
+	if us.ListUserAccountsCalled != 0 {
+		t.Fatalf("expected the accounts of admins not to be looked up")
+	}
+}
+
+func Test_SessionAuthOff(t *testing.T) {
+	// SETUP
+	user := togglr.User{ID: uid.New(), Email: "jane@example.com", Identity: togglr.IdentityTypeBasic}
+	services, _ := sessionServices(t, user, "correct horse")
+	services.AccountService = mock.NewAccountService(nil)
+	services.UserService.(*mock.UserService).ListUserAccountsFn = func(ctx context.Context, userID uid.UID) ([]uid.UID, error) {
+		return []uid.UID{uid.New()}, nil
+	}
+
+	// without an APIKeyService anonymous requests are let through, but Users that log in are still checked
+	s := httptest.NewServer(http.BuildRoutes(http.Config{Logger: zap.NewNop(), Services: services}))
+	defer s.Close()
+	client, _ := login(t, s, user.Email, "correct horse")
+
+	// RUN
+	for _, path := range []string{"/account", fmt.Sprintf("/account/%s", uid.New()), fmt.Sprintf("/user/%s", user.ID)} {
+		res, err := client.Get(s.URL + path)
+		if err != nil {
+			t.Fatalf("failed to send request: %s", err)
+		}
+		res.Body.Close()
+
+		if res.StatusCode != 403 {
+			t.Fatalf("expected %s to be forbidden to users that aren't admins, got %d", path, res.StatusCode)
+		}
+	}
+}
+
+func Test_SessionTamperedCookie(t *testing.T) {
+	// SETUP
+	user := togglr.User{ID: uid.New(), Email: "jane@example.com", Identity: togglr.IdentityTypeBasic}
+	services, ss := sessionServices(t, user, "correct horse")
+	session, err := togglr.NewSession(user.ID, 0)
+	if err != nil {
+		t.Fatalf("failed to create session: %s", err)
+	}
+	ss.FetchSessionFn = func(ctx context.Context, id uid.UID) (togglr.Session, error) {
+		return session, nil
+	}
+
+	s := httptest.NewServer(http.BuildRoutes(http.Config{Logger: zap.NewNop(), Services: services}))
+	defer s.Close()
+
+	// RUN
+	req, err := stdhttp.NewRequest(stdhttp.MethodGet, fmt.Sprintf("%s/user", s.URL), nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %s", err)
+	}
+	req.AddCookie(&stdhttp.Cookie{Name: "togglr_session", Value: session.ID.String()})
+
+	res, err := stdhttp.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to send request: %s", err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode != 401 {
+		t.Fatalf("expected unsigned session cookie to be ignored, got %d", res.StatusCode)
+	}
+
+	if ss.FetchSessionCalled != 0 {
+		t.Fatalf("expected unsigned session cookie not to be looked up")
+	}
+}
@@ -0,0 +1,195 @@
+package http
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/gorilla/websocket"
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/rules"
+	"github.com/togglr-io/togglr/uid"
+	"go.uber.org/zap"
+)
+
+// Types of messages exchanged over a toggle socket. Clients send a context message with their metadata whenever it
+// changes and the server answers with the full set of toggles resolved for it. From then on the server sends a diff
+// message every time toggle changes affect the resolved values. Messages the server can't handle are answered with
+// an error message and otherwise ignored
+const (
+	socketMessageContext = "context"
+	socketMessageToggles = "toggles"
+	socketMessageDiff    = "diff"
+	socketMessageError   = "error"
+)
+
+// how long a socket can go without hearing from the client before it's considered dead
+const socketReadTimeout = 2 * streamHeartbeat
+
+type socketContextMessage struct {
+	Type     string                 `json:"type"`
+	Metadata map[string]interface{} `json:"metadata"`
+}
+
+type socketTogglesMessage struct {
+	Type    string                 `json:"type"`
+	Toggles togglr.ResolvedToggles `json:"toggles"`
+}
+
+type socketDiffMessage struct {
+	Type    string         `json:"type"`
+	Updates []toggleUpdate `json:"updates"`
+}
+
+type socketErrorMessage struct {
+	Type  string `json:"type"`
+	Error string `json:"error"`
+}
+
+// checkSocketOrigin accepts sockets opened by browsers on one of the allowed origins or on the same host as the
+// server. Requests without an Origin header don't come from browsers and are always accepted
+func checkSocketOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	for _, allowed := range allowedOrigins {
+		if origin == allowed {
+			return true
+		}
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	return u.Host == r.Host
+}
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin: checkSocketOrigin,
+}
+
+// HandleResolveSocketGET handles GET requests to the /resolve/{accountID}/socket endpoint by upgrading them to a
+// WebSocket that keeps the client's resolved toggles in sync
+func HandleResolveSocketGET(log *zap.Logger, resolver togglr.Resolver, hub *Hub) http.HandlerFunc {
+	log = log.With(zap.String("handler", "HandleResolveSocketGET"))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accountID := chi.URLParam(r, "accountID")
+		log := log.With(zap.String("accountID", accountID))
+		log.Debug("opening toggle socket")
+		defer log.Sync()
+
+		accountUID, err := uid.FromString(accountID)
+		if err != nil {
+			log.Error("failed to parse account ID", zap.Error(err))
+			badRequest(w, "account ID was badly formed")
+			return
+		}
+
+		// the upgrader responds to the client itself when the upgrade fails
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Error("failed to upgrade connection", zap.Error(err))
+			return
+		}
+		defer conn.Close()
+
+		changes, unsubscribe := hub.Subscribe(accountUID)
+		defer unsubscribe()
+
+		// gorilla/websocket supports a single concurrent reader, so messages are read in the background and handed
+		// over to the loop below, which does all of the writing
+		incoming := make(chan socketContextMessage)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_ = conn.SetReadDeadline(time.Now().Add(socketReadTimeout))
+			conn.SetPongHandler(func(string) error {
+				return conn.SetReadDeadline(time.Now().Add(socketReadTimeout))
+			})
+
+			for {
+				var msg socketContextMessage
+				if err := conn.ReadJSON(&msg); err != nil {
+					if _, ok := err.(*websocket.CloseError); !ok {
+						log.Debug("failed to read from socket", zap.Error(err))
+					}
+					return
+				}
+
+				_ = conn.SetReadDeadline(time.Now().Add(socketReadTimeout))
+				select {
+				case incoming <- msg:
+				case <-r.Context().Done():
+					return
+				}
+			}
+		}()
+
+		heartbeat := time.NewTicker(streamHeartbeat)
+		defer heartbeat.Stop()
+
+		// nothing is resolved until the client sends its first context
+		var metadata rules.Metadata
+		var resolved togglr.ResolvedToggles
+		for {
+			var reply interface{}
+			select {
+			case <-done:
+				log.Debug("socket closed")
+				return
+			case <-heartbeat.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(socketReadTimeout)); err != nil {
+					log.Debug("failed to ping socket", zap.Error(err))
+					return
+				}
+				continue
+			case msg := <-incoming:
+				if msg.Type != socketMessageContext {
+					reply = socketErrorMessage{Type: socketMessageError, Error: "unknown message type"}
+					break
+				}
+
+				md := rules.MetaFromRaw(msg.Metadata)
+				next, err := resolver.Resolve(r.Context(), accountUID, md)
+				if err != nil {
+					log.Error("failed to resolve toggles", zap.Error(err))
+					reply = socketErrorMessage{Type: socketMessageError, Error: "could not resolve toggles"}
+					break
+				}
+
+				metadata, resolved = md, next
+				reply = socketTogglesMessage{Type: socketMessageToggles, Toggles: resolved}
+			case <-changes:
+				if resolved == nil {
+					continue
+				}
+
+				next, err := resolver.Resolve(r.Context(), accountUID, metadata)
+				if err != nil {
+					// keep the socket open, the next change will try again
+					log.Error("failed to resolve toggles", zap.Error(err))
+					continue
+				}
+
+				updates := diffResolved(resolved, next)
+				resolved = next
+				if len(updates) == 0 {
+					continue
+				}
+
+				reply = socketDiffMessage{Type: socketMessageDiff, Updates: updates}
+			}
+
+			if err := conn.WriteJSON(reply); err != nil {
+				log.Debug("failed to write to socket", zap.Error(err))
+				return
+			}
+		}
+	})
+}
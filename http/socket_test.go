@@ -0,0 +1,125 @@
+package http_test
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/http"
+	"github.com/togglr-io/togglr/mock"
+	"github.com/togglr-io/togglr/rules"
+	"github.com/togglr-io/togglr/uid"
+	"go.uber.org/zap"
+)
+
+type socketMessage struct {
+	Type    string                 `json:"type"`
+	Toggles togglr.ResolvedToggles `json:"toggles"`
+	Updates []struct {
+		Key     string `json:"key"`
+		Value   bool   `json:"value"`
+		Removed bool   `json:"removed"`
+	} `json:"updates"`
+	Error string `json:"error"`
+}
+
+func Test_HandleResolveSocketGet(t *testing.T) {
+	// SETUP
+	accountID := uid.New()
+	adminOnly := togglr.Toggle{
+		ID:        uid.New(),
+		AccountID: accountID,
+		Key:       "admin-only",
+		Rules: rules.Rules{
+			{
+				Op: rules.BinOpAnd,
+				Expr: rules.Expression{
+					Type: rules.ExprTypeBinary,
+					Binary: rules.NewBinary(
+						rules.NewIdent("userType"),
+						rules.NewString("admin"),
+						rules.BinOpEq,
+					),
+				},
+			},
+		},
+	}
+
+	var mu sync.Mutex
+	toggles := []togglr.Toggle{adminOnly}
+	ts := mock.NewToggleService(nil)
+	ts.ListTogglesFn = func(ctx context.Context, req togglr.ListTogglesReq) ([]togglr.Toggle, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]togglr.Toggle{}, toggles...), nil
+	}
+
+	hub := http.NewHub()
+	cfg := http.Config{
+		Logger: zap.NewNop(),
+		Services: http.Services{
+			Resolver: togglr.NewResolver(ts, nil),
+		},
+		Hub: hub,
+	}
+
+	s := httptest.NewServer(http.BuildRoutes(cfg))
+	defer s.Close()
+	url := fmt.Sprintf("%s/resolve/%s/socket", strings.Replace(s.URL, "http", "ws", 1), accountID)
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("failed to open socket: %s", err)
+	}
+	defer conn.Close()
+
+	send := func(msg interface{}) socketMessage {
+		if err := conn.WriteJSON(msg); err != nil {
+			t.Fatalf("failed to write message: %s", err)
+		}
+
+		return receive(t, conn)
+	}
+
+	// RUN
+	res := send(map[string]interface{}{"type": "context", "metadata": map[string]interface{}{"userType": "user"}})
+	if res.Type != "toggles" || res.Toggles["admin-only"] {
+		t.Fatalf("expected admin-only to resolve false, got %+v", res)
+	}
+
+	// updating the context re-resolves the toggles
+	res = send(map[string]interface{}{"type": "context", "metadata": map[string]interface{}{"userType": "admin"}})
+	if res.Type != "toggles" || !res.Toggles["admin-only"] {
+		t.Fatalf("expected admin-only to resolve true, got %+v", res)
+	}
+
+	// toggle changes are pushed as diffs against the current context
+	mu.Lock()
+	toggles = append(toggles, togglr.Toggle{ID: uid.New(), AccountID: accountID, Key: "everyone"})
+	mu.Unlock()
+	hub.Notify(accountID)
+
+	res = receive(t, conn)
+	if res.Type != "diff" || len(res.Updates) != 1 || res.Updates[0].Key != "everyone" || !res.Updates[0].Value {
+		t.Fatalf("expected a diff adding the everyone toggle, got %+v", res)
+	}
+
+	res = send(map[string]interface{}{"type": "unsubscribe"})
+	if res.Type != "error" {
+		t.Fatalf("expected an error for an unknown message type, got %+v", res)
+	}
+}
+
+func receive(t *testing.T, conn *websocket.Conn) socketMessage {
+	var msg socketMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("failed to read message: %s", err)
+	}
+
+	return msg
+}
@@ -0,0 +1,61 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/uid"
+	"go.uber.org/zap"
+)
+
+// toggles that haven't changed behavior in this many days are reported as stale by default
+const defaultStaleDays = 30
+
+// HandleAccountStaleGET handles GET requests to the /account/{id}/stale endpoint
+func HandleAccountStaleGET(log *zap.Logger, ts togglr.ToggleService, us togglr.UsageService) http.HandlerFunc {
+	log = log.With(zap.String("handler", "HandleAccountStaleGET"))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		log = log.With(zap.String("accountID", id))
+		log.Debug("reporting stale toggles")
+		defer log.Sync()
+
+		accountID, err := uid.FromString(id)
+		if err != nil {
+			log.Error("failed to parse account ID", zap.Error(err))
+			badRequest(w, "account ID was badly formed")
+			return
+		}
+
+		days := defaultStaleDays
+		if raw := r.URL.Query().Get("days"); raw != "" {
+			days, err = strconv.Atoi(raw)
+			if err != nil || days < 1 {
+				log.Error("failed to parse days", zap.Error(err))
+				badRequest(w, "days must be a positive number")
+				return
+			}
+		}
+
+		stale, err := togglr.FindStaleToggles(r.Context(), ts, us, accountID, time.Duration(days)*24*time.Hour)
+		if err != nil {
+			log.Error("failed to find stale toggles", zap.Error(err))
+			serverError(w, "could not report stale toggles")
+			return
+		}
+
+		data, err := json.Marshal(stale)
+		if err != nil {
+			log.Error("failed to marshal stale toggles", zap.Error(err))
+			serverError(w, "could not report stale toggles")
+			return
+		}
+
+		ok(w, data)
+	})
+}
@@ -13,12 +13,15 @@ func noContent(w http.ResponseWriter) {
 	_, _ = w.Write(nil)
 }
 
+func notModified(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusNotModified)
+}
+
 func badRequest(w http.ResponseWriter, msg string) {
 	w.WriteHeader(http.StatusBadRequest)
 	_, _ = w.Write([]byte(msg))
 }
 
-// nolint
 func forbidden(w http.ResponseWriter, msg string) {
 	w.WriteHeader(http.StatusForbidden)
 	_, _ = w.Write([]byte(msg))
@@ -30,6 +33,32 @@ func unauthorized(w http.ResponseWriter, msg string) {
 	_, _ = w.Write([]byte(msg))
 }
 
+func notFound(w http.ResponseWriter, msg string) {
+	w.WriteHeader(http.StatusNotFound)
+	_, _ = w.Write([]byte(msg))
+}
+
+func conflict(w http.ResponseWriter, data []byte) {
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	_, _ = w.Write(data)
+}
+
+func conflictMessage(w http.ResponseWriter, msg string) {
+	w.WriteHeader(http.StatusConflict)
+	_, _ = w.Write([]byte(msg))
+}
+
+func preconditionRequired(w http.ResponseWriter, msg string) {
+	w.WriteHeader(http.StatusPreconditionRequired)
+	_, _ = w.Write([]byte(msg))
+}
+
+func preconditionFailed(w http.ResponseWriter, msg string) {
+	w.WriteHeader(http.StatusPreconditionFailed)
+	_, _ = w.Write([]byte(msg))
+}
+
 func serverError(w http.ResponseWriter, msg string) {
 	w.WriteHeader(http.StatusInternalServerError)
 	_, _ = w.Write([]byte(msg))
@@ -0,0 +1,179 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/uid"
+	"go.uber.org/zap"
+)
+
+// how often a comment is sent on idle streams so that proxies don't close them
+const streamHeartbeat = 15 * time.Second
+
+// A toggleUpdate is sent to streams whenever the resolved value of a single toggle changes. Removed is set when the
+// toggle no longer resolves at all, e.g. because it was archived
+type toggleUpdate struct {
+	Key     string `json:"key"`
+	Value   bool   `json:"value"`
+	Removed bool   `json:"removed,omitempty"`
+}
+
+// resolvedDigest identifies a set of ResolvedToggles. It's used as the ID of stream events so that a client
+// reconnecting with a Last-Event-ID can be told whether anything changed while it was away
+func resolvedDigest(resolved togglr.ResolvedToggles) string {
+	keys := make([]string, 0, len(resolved))
+	for key := range resolved {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	hash := fnv.New64a()
+	for _, key := range keys {
+		fmt.Fprintf(hash, "%s=%t\n", key, resolved[key])
+	}
+
+	return fmt.Sprintf("%x", hash.Sum64())
+}
+
+// diffResolved lists the updates that turn prev into next, ordered by key
+func diffResolved(prev, next togglr.ResolvedToggles) []toggleUpdate {
+	updates := []toggleUpdate{}
+	for key, value := range next {
+		if old, ok := prev[key]; !ok || old != value {
+			updates = append(updates, toggleUpdate{Key: key, Value: value})
+		}
+	}
+
+	for key := range prev {
+		if _, ok := next[key]; !ok {
+			updates = append(updates, toggleUpdate{Key: key, Removed: true})
+		}
+	}
+
+	sort.Slice(updates, func(i, j int) bool {
+		return updates[i].Key < updates[j].Key
+	})
+
+	return updates
+}
+
+func writeEvent(w io.Writer, id, event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", id, event, payload)
+	return err
+}
+
+// HandleResolveStreamGET handles GET requests to the /resolve/{accountID}/stream endpoint. Metadata is given as JSON
+// in the metadata query parameter, since EventSource clients can't send a request body. The full set of resolved
+// toggles is sent as a snapshot event on connect, followed by an update event for every toggle whose value changes.
+// A client reconnecting with the Last-Event-ID it last saw only gets a new snapshot if something changed
+func HandleResolveStreamGET(log *zap.Logger, resolver togglr.Resolver, hub *Hub) http.HandlerFunc {
+	log = log.With(zap.String("handler", "HandleResolveStreamGET"))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accountID := chi.URLParam(r, "accountID")
+		log := log.With(zap.String("accountID", accountID))
+		log.Debug("streaming resolved toggles")
+		defer log.Sync()
+
+		accountUID, err := uid.FromString(accountID)
+		if err != nil {
+			log.Error("failed to parse account ID", zap.Error(err))
+			badRequest(w, "account ID was badly formed")
+			return
+		}
+
+		metadata, err := metadataFromQuery(r.URL.Query())
+		if err != nil {
+			log.Error("failed to unmarshal metadata from request", zap.Error(err))
+			badRequest(w, "could not unmarshal metadata")
+			return
+		}
+
+		flusher, canFlush := w.(http.Flusher)
+		if !canFlush {
+			log.Error("response writer doesn't support flushing")
+			serverError(w, "streaming is not supported")
+			return
+		}
+
+		// subscribe before the first resolve so that changes made in between aren't missed
+		changes, unsubscribe := hub.Subscribe(accountUID)
+		defer unsubscribe()
+
+		resolved, err := resolver.Resolve(r.Context(), accountUID, metadata)
+		if err != nil {
+			log.Error("failed to resolve toggles", zap.Error(err))
+			serverError(w, "could not resolve toggles")
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		// stop nginx from buffering the stream
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		digest := resolvedDigest(resolved)
+		if r.Header.Get("Last-Event-ID") != digest {
+			if err := writeEvent(w, digest, "snapshot", resolved); err != nil {
+				log.Error("failed to write snapshot", zap.Error(err))
+				return
+			}
+		}
+		flusher.Flush()
+
+		heartbeat := time.NewTicker(streamHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				log.Debug("stream closed")
+				return
+			case <-heartbeat.C:
+				if _, err := io.WriteString(w, ": heartbeat\n\n"); err != nil {
+					log.Debug("failed to write heartbeat", zap.Error(err))
+					return
+				}
+				flusher.Flush()
+			case <-changes:
+				next, err := resolver.Resolve(r.Context(), accountUID, metadata)
+				if err != nil {
+					// keep the stream open, the next change will try again
+					log.Error("failed to resolve toggles", zap.Error(err))
+					continue
+				}
+
+				// every update is applied in turn so that each event's ID identifies the state the client has
+				// after processing it
+				for _, update := range diffResolved(resolved, next) {
+					if update.Removed {
+						delete(resolved, update.Key)
+					} else {
+						resolved[update.Key] = update.Value
+					}
+
+					if err := writeEvent(w, resolvedDigest(resolved), "update", update); err != nil {
+						log.Debug("failed to write update", zap.Error(err))
+						return
+					}
+				}
+				flusher.Flush()
+			}
+		}
+	})
+}
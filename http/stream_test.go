@@ -0,0 +1,176 @@
+package http_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	stdhttp "net/http"
+	"net/http/httptest"
+	neturl "net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/http"
+	"github.com/togglr-io/togglr/mock"
+	"github.com/togglr-io/togglr/uid"
+	"go.uber.org/zap"
+)
+
+type streamEvent struct {
+	id    string
+	event string
+	data  string
+}
+
+// readEvent reads the next event from a stream, skipping comments
+func readEvent(t *testing.T, r *bufio.Reader) streamEvent {
+	var ev streamEvent
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("failed to read stream: %s", err)
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && ev.event != "":
+			return ev
+		case strings.HasPrefix(line, "id: "):
+			ev.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			ev.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			ev.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func Test_HandleResolveStreamGet(t *testing.T) {
+	// SETUP
+	accountID := uid.New()
+	var mu sync.Mutex
+	toggles := []togglr.Toggle{{ID: uid.New(), AccountID: accountID, Key: "feature-a"}}
+
+	ts := mock.NewToggleService(nil)
+	ts.ListTogglesFn = func(ctx context.Context, req togglr.ListTogglesReq) ([]togglr.Toggle, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]togglr.Toggle{}, toggles...), nil
+	}
+	setToggles := func(next ...togglr.Toggle) {
+		mu.Lock()
+		defer mu.Unlock()
+		toggles = next
+	}
+
+	hub := http.NewHub()
+	cfg := http.Config{
+		Logger: zap.NewNop(),
+		Services: http.Services{
+			Resolver: togglr.NewResolver(ts, nil),
+		},
+		Hub: hub,
+	}
+
+	// track running handlers so that the test can wait for a closed stream's handler to stop before opening the
+	// next one, otherwise both would use the ToggleService at once
+	var handlers sync.WaitGroup
+	routes := http.BuildRoutes(cfg)
+	s := httptest.NewServer(stdhttp.HandlerFunc(func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+		handlers.Add(1)
+		defer handlers.Done()
+		routes.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	url := fmt.Sprintf("%s/resolve/%s/stream?metadata=%s", s.URL, accountID, neturl.QueryEscape(`{"userType":"admin"}`))
+
+	connect := func(lastEventID string) (*stdhttp.Response, *bufio.Reader) {
+		req, err := stdhttp.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatalf("failed to create request: %s", err)
+		}
+
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+
+		res, err := stdhttp.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("failed to send request: %s", err)
+		}
+
+		if res.StatusCode != 200 {
+			t.Fatalf("expected status code of 200, but got %d", res.StatusCode)
+		}
+
+		return res, bufio.NewReader(res.Body)
+	}
+
+	// RUN
+	res, reader := connect("")
+	snapshot := readEvent(t, reader)
+	if snapshot.event != "snapshot" || snapshot.data != `{"feature-a":true}` {
+		t.Fatalf("expected a snapshot of the resolved toggles, got %+v", snapshot)
+	}
+
+	setToggles(
+		togglr.Toggle{ID: uid.New(), AccountID: accountID, Key: "feature-a", Prerequisites: togglr.Keys{"missing"}},
+		togglr.Toggle{ID: uid.New(), AccountID: accountID, Key: "feature-b"},
+	)
+	hub.Notify(accountID)
+
+	expected := []string{`{"key":"feature-a","value":false}`, `{"key":"feature-b","value":true}`}
+	var last streamEvent
+	for _, data := range expected {
+		last = readEvent(t, reader)
+		if last.event != "update" || last.data != data {
+			t.Fatalf("expected update %s, got %+v", data, last)
+		}
+	}
+	res.Body.Close()
+	handlers.Wait()
+
+	// resuming from the last event skips the snapshot since nothing changed in the meantime
+	res, reader = connect(last.id)
+	defer res.Body.Close()
+
+	setToggles(togglr.Toggle{ID: uid.New(), AccountID: accountID, Key: "feature-a", Prerequisites: togglr.Keys{"missing"}})
+	hub.Notify(accountID)
+
+	removed := readEvent(t, reader)
+	var update map[string]interface{}
+	if err := json.Unmarshal([]byte(removed.data), &update); err != nil {
+		t.Fatalf("failed to unmarshal update: %s", err)
+	}
+
+	if removed.event != "update" || update["key"] != "feature-b" || update["removed"] != true {
+		t.Fatalf("expected feature-b to be removed, got %+v", removed)
+	}
+}
+
+func Test_HandleResolveStreamGetBadRequest(t *testing.T) {
+	cfg := http.Config{
+		Logger: zap.NewNop(),
+		Services: http.Services{
+			Resolver: togglr.NewResolver(mock.NewToggleService(nil), nil),
+		},
+	}
+
+	s := httptest.NewServer(http.BuildRoutes(cfg))
+	defer s.Close()
+
+	for _, path := range []string{"/resolve/123/stream", fmt.Sprintf("/resolve/%s/stream?metadata=nope", uid.New())} {
+		res, err := stdhttp.Get(s.URL + path)
+		if err != nil {
+			t.Fatalf("failed to send request: %s", err)
+		}
+		res.Body.Close()
+
+		if res.StatusCode != 400 {
+			t.Fatalf("expected status code of 400 for %s, but got %d", path, res.StatusCode)
+		}
+	}
+}
@@ -2,8 +2,11 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strconv"
 
 	"github.com/go-chi/chi"
 	"github.com/togglr-io/togglr"
@@ -43,8 +46,28 @@ func HandleTogglePOST(log *zap.Logger, ts togglr.ToggleService) http.HandlerFunc
 				return
 			}
 
+			if accountID, ok := defaultAccount(r.Context()); ok && toggle.AccountID.IsNull() {
+				toggle.AccountID = accountID
+			}
+
+			if _, scoped := scopedAccounts(r.Context()); scoped && toggle.AccountID.IsNull() {
+				badRequest(w, "an account ID is required")
+				return
+			}
+
+			if !canAccess(r.Context(), toggle.AccountID) {
+				forbidden(w, "can't access other accounts")
+				return
+			}
+
 			id.ID, err = ts.CreateToggle(r.Context(), toggle)
 			if err != nil {
+				if errors.Is(err, togglr.ErrInvalidVariants) || errors.Is(err, togglr.ErrInvalidKind) {
+					log.Info("rejected invalid toggle", zap.Error(err))
+					badRequest(w, err.Error())
+					return
+				}
+
 				log.Error("failed to create toggle", zap.Error(err))
 				serverError(w, "could not save toggle")
 				return
@@ -57,7 +80,78 @@ func HandleTogglePOST(log *zap.Logger, ts togglr.ToggleService) http.HandlerFunc
 				return
 			}
 
+			version, err := versionFromRequest(r, updateReq.Version)
+			if errors.Is(err, errMissingVersion) {
+				log.Error("update req is missing a version", zap.Error(err))
+				preconditionRequired(w, "a version is required to update a toggle")
+				return
+			}
+
+			if errors.Is(err, errWeakVersion) {
+				log.Info("rejected update with weak etag")
+				preconditionFailed(w, "If-Match requires a strong etag")
+				return
+			}
+
+			if err != nil {
+				log.Error("failed to parse version", zap.Error(err))
+				badRequest(w, "version was badly formed")
+				return
+			}
+
+			if _, scoped := scopedAccounts(r.Context()); scoped {
+				toggle, err := ts.FetchToggle(r.Context(), updateReq.ID)
+				if err != nil && !errors.Is(err, togglr.ErrNotFound) {
+					log.Error("failed to fetch toggle", zap.Error(err))
+					serverError(w, "could not save toggle")
+					return
+				}
+
+				if err == nil {
+					if !canAccess(r.Context(), toggle.AccountID) {
+						forbidden(w, "can't access other accounts")
+						return
+					}
+					updateReq.AccountID = toggle.AccountID
+				}
+			}
+
+			updateReq.Version = version
 			if err := ts.UpdateToggle(r.Context(), updateReq); err != nil {
+				if errors.Is(err, togglr.ErrConflict) {
+					log.Info("rejected update to stale toggle", zap.Int("version", version))
+					current, err := ts.FetchToggle(r.Context(), updateReq.ID)
+					if err != nil {
+						log.Error("failed to fetch current toggle", zap.Error(err))
+						serverError(w, "could not save toggle")
+						return
+					}
+
+					if err := conflictWithCurrent(w, current, current.Version); err != nil {
+						log.Error("failed to marshal current toggle", zap.Error(err))
+						serverError(w, "could not save toggle")
+					}
+					return
+				}
+
+				if errors.Is(err, togglr.ErrApprovalRequired) {
+					log.Info("rejected direct update to protected toggle")
+					forbidden(w, "toggle requires approval, submit a change request instead")
+					return
+				}
+
+				if errors.Is(err, togglr.ErrInvalidVariants) || errors.Is(err, togglr.ErrInvalidKind) {
+					log.Info("rejected invalid update", zap.Error(err))
+					badRequest(w, err.Error())
+					return
+				}
+
+				if errors.Is(err, togglr.ErrExperimentRunning) {
+					log.Info("rejected update to variants of a running experiment")
+					conflictMessage(w, "variants can't change while an experiment is running, stop it first")
+					return
+				}
+
 				log.Error("failed to update toggle", zap.Error(err))
 				serverError(w, "could not save toggle")
 				return
@@ -75,23 +169,77 @@ func HandleTogglePOST(log *zap.Logger, ts togglr.ToggleService) http.HandlerFunc
 	})
 }
 
-// HandleToggleGET handles GET requests to the /toggle endpoint
-func HandleToggleGET(log *zap.Logger, ts togglr.ToggleService) http.HandlerFunc {
+// HandleToggleGET handles GET requests to the /toggle endpoint. Listings limited to a single account carry the
+// account's config version as their ETag and support conditional requests with If-None-Match
+func HandleToggleGET(log *zap.Logger, ts togglr.ToggleService, cvs togglr.ConfigVersionService) http.HandlerFunc {
 	log = log.With(zap.String("handler", "HandleToggleGET"))
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		log.Debug("listing toggles")
 		defer log.Sync()
 
-		req := togglr.ListTogglesReq{}
+		req, err := listTogglesReqFromQuery(r.URL.Query())
+		if err != nil {
+			log.Error("failed to parse toggle filters", zap.Error(err))
+			badRequest(w, "toggle filters were badly formed")
+			return
+		}
+
+		if accountID, ok := defaultAccount(r.Context()); ok && req.AccountID.IsNull() {
+			req.AccountID = accountID
+		}
+
+		if _, scoped := scopedAccounts(r.Context()); scoped && req.AccountID.IsNull() {
+			badRequest(w, "an account ID is required")
+			return
+		}
+
+		if !canAccess(r.Context(), req.AccountID) {
+			forbidden(w, "can't access other accounts")
+			return
+		}
+
+		if !req.AccountID.IsNull() {
+			_, done, err := respondNotModified(r.Context(), w, r, cvs, req.AccountID)
+			if err != nil {
+				log.Error("failed to fetch config version", zap.Error(err))
+				serverError(w, "could not list toggles")
+				return
+			}
+
+			if done {
+				return
+			}
+		}
+
+		page := req.Page
+		// fetch one more toggle than requested to find out whether there's another page
+		if page.Limit > 0 {
+			req.Limit++
+		}
 
 		toggles, err := ts.ListToggles(r.Context(), req)
 		if err != nil {
+			if errors.Is(err, togglr.ErrInvalidPage) {
+				log.Error("invalid page requested", zap.Error(err))
+				badRequest(w, err.Error())
+				return
+			}
+
 			log.Error("failed to list toggles", zap.Error(err))
 			serverError(w, "could not list toggles")
 			return
 		}
 
-		data, err := json.Marshal(toggles)
+		count, cursor, err := nextCursor(len(toggles), page, func(idx int) (togglr.Cursor, error) {
+			return toggles[idx].CursorFor(page.SortBy)
+		})
+		if err != nil {
+			log.Error("failed to build next cursor", zap.Error(err))
+			serverError(w, "could not list toggles")
+			return
+		}
+
+		data, err := json.Marshal(listBody(toggles[:count], page, cursor))
 		if err != nil {
 			log.Error("failed to marshal toggles", zap.Error(err))
 			serverError(w, "could not list toggles")
@@ -102,6 +250,55 @@ func HandleToggleGET(log *zap.Logger, ts togglr.ToggleService) http.HandlerFunc
 	})
 }
 
+// listTogglesReqFromQuery builds a ListTogglesReq from the filters and pagination parameters given in a query string
+func listTogglesReqFromQuery(query url.Values) (togglr.ListTogglesReq, error) {
+	page, err := pageFromQuery(query)
+	if err != nil {
+		return togglr.ListTogglesReq{}, err
+	}
+
+	req := togglr.ListTogglesReq{
+		Page:   page,
+		Tag:    query.Get("tag"),
+		Owner:  query.Get("owner"),
+		Kind:   togglr.ToggleKind(query.Get("kind")),
+		Search: query.Get("search"),
+	}
+
+	if req.Kind != "" && !req.Kind.Valid() {
+		return req, togglr.ErrInvalidKind
+	}
+
+	if accountID := query.Get("accountId"); accountID != "" {
+		id, err := uid.FromString(accountID)
+		if err != nil {
+			return req, err
+		}
+
+		req.AccountID = id
+	}
+
+	if active := query.Get("active"); active != "" {
+		val, err := strconv.ParseBool(active)
+		if err != nil {
+			return req, err
+		}
+
+		req.Active = &val
+	}
+
+	if archived := query.Get("archived"); archived != "" {
+		val, err := strconv.ParseBool(archived)
+		if err != nil {
+			return req, err
+		}
+
+		req.Archived = val
+	}
+
+	return req, nil
+}
+
 // HandleToggleGetIdGET handles GET requests to the /toggle/{id} endpoint
 func HandleToggleIdGET(log *zap.Logger, ts togglr.ToggleService) http.HandlerFunc {
 	log = log.With(zap.String("handler", "HandleToggleIdGET"))
@@ -133,6 +330,7 @@ func HandleToggleIdGET(log *zap.Logger, ts togglr.ToggleService) http.HandlerFun
 			return
 		}
 
+		w.Header().Set("ETag", etag(tog.Version))
 		ok(w, data)
 	})
 }
@@ -155,8 +353,170 @@ func HandleToggleDELETE(log *zap.Logger, ts togglr.ToggleService) http.HandlerFu
 		}
 
 		if err := ts.DeleteToggle(r.Context(), uid); err != nil {
-			log.Error("failed to delete toggle", zap.Error(err))
-			serverError(w, "could not delete toggle")
+			switch {
+			case errors.Is(err, togglr.ErrApprovalRequired):
+				log.Info("rejected delete of protected toggle")
+				forbidden(w, "toggle requires approval and cannot be deleted")
+			case errors.Is(err, togglr.ErrNotArchived), errors.Is(err, togglr.ErrPrerequisite):
+				log.Info("rejected purge of toggle", zap.Error(err))
+				conflictMessage(w, err.Error())
+			default:
+				log.Error("failed to delete toggle", zap.Error(err))
+				serverError(w, "could not delete toggle")
+			}
+			return
+		}
+
+		noContent(w)
+	})
+}
+
+// HandleToggleArchivePOST handles POST requests to the /toggle/{id}/archive endpoint
+func HandleToggleArchivePOST(log *zap.Logger, ts togglr.ToggleService) http.HandlerFunc {
+	log = log.With(zap.String("handler", "HandleToggleArchivePOST"))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		log = log.With(zap.String("toggleID", id))
+		log.Debug("archiving toggle")
+		defer log.Sync()
+
+		uid, err := uid.FromString(id)
+		if err != nil {
+			log.Error("failed to parse toggle ID", zap.Error(err))
+			badRequest(w, "toggle ID was badly formed")
+			return
+		}
+
+		if err := ts.ArchiveToggle(r.Context(), uid); err != nil {
+			switch {
+			case errors.Is(err, togglr.ErrApprovalRequired):
+				log.Info("rejected archive of protected toggle")
+				forbidden(w, "toggle requires approval and cannot be archived")
+			case errors.Is(err, togglr.ErrPrerequisite):
+				log.Info("rejected archive of toggle", zap.Error(err))
+				conflictMessage(w, err.Error())
+			default:
+				log.Error("failed to archive toggle", zap.Error(err))
+				serverError(w, "could not archive toggle")
+			}
+			return
+		}
+
+		noContent(w)
+	})
+}
+
+// HandleToggleRestorePOST handles POST requests to the /toggle/{id}/restore endpoint
+func HandleToggleRestorePOST(log *zap.Logger, ts togglr.ToggleService) http.HandlerFunc {
+	log = log.With(zap.String("handler", "HandleToggleRestorePOST"))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		log = log.With(zap.String("toggleID", id))
+		log.Debug("restoring toggle")
+		defer log.Sync()
+
+		uid, err := uid.FromString(id)
+		if err != nil {
+			log.Error("failed to parse toggle ID", zap.Error(err))
+			badRequest(w, "toggle ID was badly formed")
+			return
+		}
+
+		if err := ts.RestoreToggle(r.Context(), uid); err != nil {
+			switch {
+			case errors.Is(err, togglr.ErrApprovalRequired):
+				log.Info("rejected restore of protected toggle")
+				forbidden(w, "toggle requires approval and cannot be restored")
+			case errors.Is(err, togglr.ErrKeyTaken):
+				log.Info("rejected restore of toggle", zap.Error(err))
+				conflictMessage(w, "another toggle is using this key, rename it before restoring")
+			default:
+				log.Error("failed to restore toggle", zap.Error(err))
+				serverError(w, "could not restore toggle")
+			}
+			return
+		}
+
+		noContent(w)
+	})
+}
+
+// HandleToggleRevisionsGET handles GET requests to the /toggle/{id}/revision endpoint
+func HandleToggleRevisionsGET(log *zap.Logger, ts togglr.ToggleService) http.HandlerFunc {
+	log = log.With(zap.String("handler", "HandleToggleRevisionsGET"))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		log = log.With(zap.String("toggleID", id))
+		log.Debug("listing toggle revisions")
+		defer log.Sync()
+
+		uid, err := uid.FromString(id)
+		if err != nil {
+			log.Error("failed to parse toggle ID", zap.Error(err))
+			badRequest(w, "toggle ID was badly formed")
+			return
+		}
+
+		revisions, err := ts.ListToggleRevisions(r.Context(), uid)
+		if err != nil {
+			log.Error("failed to list toggle revisions", zap.Error(err))
+			serverError(w, "could not list toggle revisions")
+			return
+		}
+
+		data, err := json.Marshal(revisions)
+		if err != nil {
+			log.Error("failed to marshal toggle revisions", zap.Error(err))
+			serverError(w, "could not list toggle revisions")
+			return
+		}
+
+		ok(w, data)
+	})
+}
+
+// HandleToggleRollbackPOST handles POST requests to the /toggle/{id}/revision/{revision}/rollback endpoint
+func HandleToggleRollbackPOST(log *zap.Logger, ts togglr.ToggleService) http.HandlerFunc {
+	log = log.With(zap.String("handler", "HandleToggleRollbackPOST"))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		rev := chi.URLParam(r, "revision")
+		log = log.With(zap.String("toggleID", id), zap.String("revision", rev))
+		log.Debug("rolling back toggle")
+		defer log.Sync()
+
+		uid, err := uid.FromString(id)
+		if err != nil {
+			log.Error("failed to parse toggle ID", zap.Error(err))
+			badRequest(w, "toggle ID was badly formed")
+			return
+		}
+
+		revision, err := strconv.Atoi(rev)
+		if err != nil || revision < 1 {
+			log.Error("failed to parse revision", zap.Error(err))
+			badRequest(w, "revision was badly formed")
+			return
+		}
+
+		if err := ts.RollbackToggle(r.Context(), uid, revision); err != nil {
+			if errors.Is(err, togglr.ErrApprovalRequired) {
+				log.Info("rejected rollback of protected toggle")
+				forbidden(w, "toggle requires approval, submit a change request instead")
+				return
+			}
+
+			if errors.Is(err, togglr.ErrNotFound) {
+				notFound(w, "toggle or revision does not exist")
+				return
+			}
+
+			log.Error("failed to roll back toggle", zap.Error(err))
+			serverError(w, "could not roll back toggle")
 			return
 		}
 
@@ -2,23 +2,31 @@ package http_test
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	stdhttp "net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/togglr-io/togglr"
 	"github.com/togglr-io/togglr/http"
 	"github.com/togglr-io/togglr/mock"
 	"github.com/togglr-io/togglr/uid"
 	"go.uber.org/zap"
 )
 
+func staleToggleUpdate(ctx context.Context, req togglr.UpdateToggleReq) error {
+	return togglr.ErrConflict
+}
+
 func Test_HandleTogglePost(t *testing.T) {
 	id := uid.New().String()
 	cases := []struct {
 		name                string
 		payload             string
+		ifMatch             string
 		toggleService       *mock.ToggleService
 		expectedStatus      int
 		expectedCreateCalls int
@@ -50,7 +58,7 @@ func Test_HandleTogglePost(t *testing.T) {
 		},
 		{
 			name:                "successful update",
-			payload:             fmt.Sprintf(`{"id": "%s", "description": "New description"}`, id),
+			payload:             fmt.Sprintf(`{"id": "%s", "version": 1, "description": "New description"}`, id),
 			toggleService:       mock.NewToggleService(nil),
 			expectedStatus:      200,
 			expectedCreateCalls: 0,
@@ -58,12 +66,55 @@ func Test_HandleTogglePost(t *testing.T) {
 		},
 		{
 			name:                "failed update",
-			payload:             fmt.Sprintf(`{"id": "%s", "description": "New description"}`, id),
+			payload:             fmt.Sprintf(`{"id": "%s", "version": 1, "description": "New description"}`, id),
 			toggleService:       mock.NewToggleService(errors.New("forced")),
 			expectedStatus:      500,
 			expectedCreateCalls: 0,
 			expectedUpdateCalls: 1,
 		},
+		{
+			name:                "update with If-Match",
+			payload:             fmt.Sprintf(`{"id": "%s", "description": "New description"}`, id),
+			ifMatch:             `"3"`,
+			toggleService:       mock.NewToggleService(nil),
+			expectedStatus:      200,
+			expectedCreateCalls: 0,
+			expectedUpdateCalls: 1,
+		},
+		{
+			name:                "update with weak If-Match",
+			payload:             fmt.Sprintf(`{"id": "%s", "description": "New description"}`, id),
+			ifMatch:             `W/"3"`,
+			toggleService:       mock.NewToggleService(nil),
+			expectedStatus:      412,
+			expectedCreateCalls: 0,
+			expectedUpdateCalls: 0,
+		},
+		{
+			name:                "update without version",
+			payload:             fmt.Sprintf(`{"id": "%s", "description": "New description"}`, id),
+			toggleService:       mock.NewToggleService(nil),
+			expectedStatus:      428,
+			expectedCreateCalls: 0,
+			expectedUpdateCalls: 0,
+		},
+		{
+			name:                "update with bad If-Match",
+			payload:             fmt.Sprintf(`{"id": "%s", "description": "New description"}`, id),
+			ifMatch:             "latest",
+			toggleService:       mock.NewToggleService(nil),
+			expectedStatus:      400,
+			expectedCreateCalls: 0,
+			expectedUpdateCalls: 0,
+		},
+		{
+			name:                "stale update",
+			payload:             fmt.Sprintf(`{"id": "%s", "version": 1, "description": "New description"}`, id),
+			toggleService:       &mock.ToggleService{UpdateToggleFn: staleToggleUpdate},
+			expectedStatus:      409,
+			expectedCreateCalls: 0,
+			expectedUpdateCalls: 1,
+		},
 	}
 
 	for _, c := range cases {
@@ -83,6 +134,10 @@ func Test_HandleTogglePost(t *testing.T) {
 				t.Fatalf("failed to create request: %s", err)
 			}
 
+			if c.ifMatch != "" {
+				req.Header.Set("If-Match", c.ifMatch)
+			}
+
 			res, err := stdhttp.DefaultClient.Do(req)
 			if err != nil {
 				t.Fatalf("failed to send request: %s", err)
@@ -107,6 +162,7 @@ func Test_HandleToggleGet(t *testing.T) {
 	cases := []struct {
 		name           string
 		query          string
+		ifNoneMatch    string
 		toggleService  *mock.ToggleService
 		expectedStatus int
 		expectedCalls  int
@@ -125,6 +181,57 @@ func Test_HandleToggleGet(t *testing.T) {
 			expectedStatus: 500,
 			expectedCalls:  1,
 		},
+		{
+			name:           "filtered test",
+			query:          "accountId=c149f08b-b0fa-4a5d-8a6c-03ac992aa454&tag=checkout&owner=payments&kind=ops&active=true&search=provider",
+			toggleService:  mock.NewToggleService(nil),
+			expectedStatus: 200,
+			expectedCalls:  1,
+		},
+		{
+			name:           "not modified",
+			query:          "accountId=c149f08b-b0fa-4a5d-8a6c-03ac992aa454",
+			ifNoneMatch:    `W/"0"`,
+			toggleService:  mock.NewToggleService(nil),
+			expectedStatus: 304,
+			expectedCalls:  0,
+		},
+		{
+			name:           "modified",
+			query:          "accountId=c149f08b-b0fa-4a5d-8a6c-03ac992aa454",
+			ifNoneMatch:    `W/"41"`,
+			toggleService:  mock.NewToggleService(nil),
+			expectedStatus: 200,
+			expectedCalls:  1,
+		},
+		{
+			name:           "bad kind filter",
+			query:          "kind=chore",
+			toggleService:  mock.NewToggleService(nil),
+			expectedStatus: 400,
+			expectedCalls:  0,
+		},
+		{
+			name:           "bad active filter",
+			query:          "active=maybe",
+			toggleService:  mock.NewToggleService(nil),
+			expectedStatus: 400,
+			expectedCalls:  0,
+		},
+		{
+			name:           "bad account filter",
+			query:          "accountId=123",
+			toggleService:  mock.NewToggleService(nil),
+			expectedStatus: 400,
+			expectedCalls:  0,
+		},
+		{
+			name:           "bad limit",
+			query:          "limit=0",
+			toggleService:  mock.NewToggleService(nil),
+			expectedStatus: 400,
+			expectedCalls:  0,
+		},
 	}
 
 	for _, c := range cases {
@@ -132,7 +239,8 @@ func Test_HandleToggleGet(t *testing.T) {
 			cfg := http.Config{
 				Logger: zap.NewNop(),
 				Services: http.Services{
-					ToggleService: c.toggleService,
+					ToggleService:        c.toggleService,
+					ConfigVersionService: mock.NewConfigVersionService(nil),
 				},
 			}
 
@@ -144,6 +252,10 @@ func Test_HandleToggleGet(t *testing.T) {
 				t.Fatalf("failed to create request: %s", err)
 			}
 
+			if c.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", c.ifNoneMatch)
+			}
+
 			res, err := stdhttp.DefaultClient.Do(req)
 			if err != nil {
 				t.Fatalf("failed to send request: %s", err)
@@ -160,6 +272,86 @@ func Test_HandleToggleGet(t *testing.T) {
 	}
 }
 
+func Test_HandleToggleGetPagination(t *testing.T) {
+	toggles := []togglr.Toggle{
+		{ID: uid.New(), Key: "a"},
+		{ID: uid.New(), Key: "b"},
+		{ID: uid.New(), Key: "c"},
+	}
+
+	ts := mock.NewToggleService(nil)
+	ts.ListTogglesFn = func(ctx context.Context, req togglr.ListTogglesReq) ([]togglr.Toggle, error) {
+		return toggles, nil
+	}
+	ts.Paged = true
+
+	cfg := http.Config{
+		Logger: zap.NewNop(),
+		Services: http.Services{
+			ToggleService: ts,
+		},
+	}
+
+	s := httptest.NewServer(http.BuildRoutes(cfg))
+	defer s.Close()
+
+	fetch := func(query string) ([]togglr.Toggle, string) {
+		res, err := stdhttp.Get(fmt.Sprintf("%s/toggle?sortBy=key&limit=2&%s", s.URL, query))
+		if err != nil {
+			t.Fatalf("failed to send request: %s", err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != 200 {
+			t.Fatalf("expected status code of 200, but got %d", res.StatusCode)
+		}
+
+		var page struct {
+			Items      []togglr.Toggle `json:"items"`
+			NextCursor string          `json:"nextCursor"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&page); err != nil {
+			t.Fatalf("failed to decode response: %s", err)
+		}
+
+		return page.Items, page.NextCursor
+	}
+
+	first, cursor := fetch("")
+	if len(first) != 2 || first[0].Key != "a" || first[1].Key != "b" {
+		t.Fatalf("expected first page to contain 'a' and 'b', got %+v", first)
+	}
+
+	if cursor == "" {
+		t.Fatal("expected a cursor for the next page")
+	}
+
+	second, cursor := fetch("cursor=" + cursor)
+	if len(second) != 1 || second[0].Key != "c" {
+		t.Fatalf("expected second page to contain 'c', got %+v", second)
+	}
+
+	if cursor != "" {
+		t.Fatalf("expected no cursor on the last page, got %s", cursor)
+	}
+
+	// lists requested without a limit or cursor keep their original shape
+	res, err := stdhttp.Get(fmt.Sprintf("%s/toggle?sortBy=key", s.URL))
+	if err != nil {
+		t.Fatalf("failed to send request: %s", err)
+	}
+	defer res.Body.Close()
+
+	var all []togglr.Toggle
+	if err := json.NewDecoder(res.Body).Decode(&all); err != nil {
+		t.Fatalf("expected an unpaged list to be a bare array: %s", err)
+	}
+
+	if len(all) != len(toggles) {
+		t.Fatalf("expected every toggle in an unpaged list, got %d", len(all))
+	}
+}
+
 func Test_HandleToggleDelete(t *testing.T) {
 	cases := []struct {
 		name           string
@@ -182,6 +374,20 @@ func Test_HandleToggleDelete(t *testing.T) {
 			expectedStatus: 400,
 			expectedCalls:  0,
 		},
+		{
+			name:           "not archived",
+			id:             "c149f08b-b0fa-4a5d-8a6c-03ac992aa454",
+			toggleService:  mock.NewToggleService(togglr.ErrNotArchived),
+			expectedStatus: 409,
+			expectedCalls:  1,
+		},
+		{
+			name:           "prerequisite of another toggle",
+			id:             "c149f08b-b0fa-4a5d-8a6c-03ac992aa454",
+			toggleService:  mock.NewToggleService(togglr.ErrPrerequisite),
+			expectedStatus: 409,
+			expectedCalls:  1,
+		},
 		{
 			name:           "service failure",
 			id:             "c149f08b-b0fa-4a5d-8a6c-03ac992aa454",
@@ -223,3 +429,180 @@ func Test_HandleToggleDelete(t *testing.T) {
 		})
 	}
 }
+
+func Test_HandleToggleArchivePost(t *testing.T) {
+	cases := []struct {
+		name           string
+		id             string
+		action         string
+		toggleService  *mock.ToggleService
+		expectedStatus int
+		expectedCalls  int
+	}{
+		{
+			name:           "successful archive",
+			id:             "c149f08b-b0fa-4a5d-8a6c-03ac992aa454",
+			action:         "archive",
+			toggleService:  mock.NewToggleService(nil),
+			expectedStatus: 204,
+			expectedCalls:  1,
+		},
+		{
+			name:           "successful restore",
+			id:             "c149f08b-b0fa-4a5d-8a6c-03ac992aa454",
+			action:         "restore",
+			toggleService:  mock.NewToggleService(nil),
+			expectedStatus: 204,
+			expectedCalls:  1,
+		},
+		{
+			name:           "bad request",
+			id:             "123",
+			action:         "archive",
+			toggleService:  mock.NewToggleService(nil),
+			expectedStatus: 400,
+			expectedCalls:  0,
+		},
+		{
+			name:           "prerequisite of another toggle",
+			id:             "c149f08b-b0fa-4a5d-8a6c-03ac992aa454",
+			action:         "archive",
+			toggleService:  mock.NewToggleService(togglr.ErrPrerequisite),
+			expectedStatus: 409,
+			expectedCalls:  1,
+		},
+		{
+			name:           "key taken",
+			id:             "c149f08b-b0fa-4a5d-8a6c-03ac992aa454",
+			action:         "restore",
+			toggleService:  mock.NewToggleService(togglr.ErrKeyTaken),
+			expectedStatus: 409,
+			expectedCalls:  1,
+		},
+		{
+			name:           "protected toggle",
+			id:             "c149f08b-b0fa-4a5d-8a6c-03ac992aa454",
+			action:         "restore",
+			toggleService:  mock.NewToggleService(togglr.ErrApprovalRequired),
+			expectedStatus: 403,
+			expectedCalls:  1,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cfg := http.Config{
+				Logger: zap.NewNop(),
+				Services: http.Services{
+					ToggleService: c.toggleService,
+				},
+			}
+
+			s := httptest.NewServer(http.BuildRoutes(cfg))
+			defer s.Close()
+			url := fmt.Sprintf("%s/toggle/%s/%s", s.URL, c.id, c.action)
+			req, err := stdhttp.NewRequest("POST", url, nil)
+			if err != nil {
+				t.Fatalf("failed to create request: %s", err)
+			}
+
+			res, err := stdhttp.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("failed to send request: %s", err)
+			}
+
+			if res.StatusCode != c.expectedStatus {
+				t.Fatalf("expected status code of %d, but got %d", c.expectedStatus, res.StatusCode)
+			}
+
+			calls := c.toggleService.ArchiveToggleCalled + c.toggleService.RestoreToggleCalled
+			if calls != c.expectedCalls {
+				t.Fatalf("expected %s to be called %d times, but it was called %d times", c.action, c.expectedCalls, calls)
+			}
+		})
+	}
+}
+
+func Test_HandleToggleRollbackPost(t *testing.T) {
+	cases := []struct {
+		name           string
+		id             string
+		revision       string
+		toggleService  *mock.ToggleService
+		expectedStatus int
+		expectedCalls  int
+	}{
+		{
+			name:           "successful test",
+			id:             "c149f08b-b0fa-4a5d-8a6c-03ac992aa454",
+			revision:       "2",
+			toggleService:  mock.NewToggleService(nil),
+			expectedStatus: 204,
+			expectedCalls:  1,
+		},
+		{
+			name:           "bad toggle ID",
+			id:             "123",
+			revision:       "2",
+			toggleService:  mock.NewToggleService(nil),
+			expectedStatus: 400,
+			expectedCalls:  0,
+		},
+		{
+			name:           "bad revision",
+			id:             "c149f08b-b0fa-4a5d-8a6c-03ac992aa454",
+			revision:       "latest",
+			toggleService:  mock.NewToggleService(nil),
+			expectedStatus: 400,
+			expectedCalls:  0,
+		},
+		{
+			name:           "service failure",
+			id:             "c149f08b-b0fa-4a5d-8a6c-03ac992aa454",
+			revision:       "2",
+			toggleService:  mock.NewToggleService(errors.New("forced")),
+			expectedStatus: 500,
+			expectedCalls:  1,
+		},
+		{
+			name:           "missing revision",
+			id:             "c149f08b-b0fa-4a5d-8a6c-03ac992aa454",
+			revision:       "99",
+			toggleService:  mock.NewToggleService(fmt.Errorf("revision 99 does not exist: %w", togglr.ErrNotFound)),
+			expectedStatus: 404,
+			expectedCalls:  1,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cfg := http.Config{
+				Logger: zap.NewNop(),
+				Services: http.Services{
+					ToggleService: c.toggleService,
+				},
+			}
+
+			s := httptest.NewServer(http.BuildRoutes(cfg))
+			defer s.Close()
+			url := fmt.Sprintf("%s/toggle/%s/revision/%s/rollback", s.URL, c.id, c.revision)
+			req, err := stdhttp.NewRequest("POST", url, nil)
+			if err != nil {
+				t.Fatalf("failed to create request: %s", err)
+			}
+
+			res, err := stdhttp.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("failed to send request: %s", err)
+			}
+
+			if res.StatusCode != c.expectedStatus {
+				t.Fatalf("expected status code of %d, but got %d", c.expectedStatus, res.StatusCode)
+			}
+
+			if c.toggleService.RollbackToggleCalled != c.expectedCalls {
+				t.Fatalf("expected RollbackToggle to be called %d times, but it was called %d times", c.expectedCalls, c.toggleService.RollbackToggleCalled)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package http
+
+import (
+	"encoding/json"
+	"net/url"
+	"time"
+
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/token"
+	"github.com/togglr-io/togglr/uid"
+)
+
+// how long signed tokens are valid for when the Config doesn't say
+const defaultTokenTTL = 5 * time.Minute
+
+// A tokenResponse is returned instead of the resolved toggles when a token is requested
+type tokenResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// A tokenIssuer turns resolved toggles into signed tokens
+type tokenIssuer struct {
+	signer togglr.Signer
+	ttl    time.Duration
+}
+
+func (t tokenIssuer) enabled() bool {
+	return t.signer != nil
+}
+
+// issue signs resolved toggles and marshals them as a tokenResponse
+func (t tokenIssuer) issue(accountID uid.UID, resolved togglr.ResolvedToggles) ([]byte, error) {
+	now := time.Now().UTC()
+	claims := token.Claims{
+		AccountID: accountID,
+		Toggles:   resolved,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(t.ttl),
+	}
+
+	signed, err := token.Issue(t.signer, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(tokenResponse{
+		Token:     signed,
+		ExpiresAt: claims.ExpiresAt,
+	})
+}
+
+// wantsToken returns whether resolved toggles were requested as a signed token
+func wantsToken(query url.Values) bool {
+	return query.Get("format") == "token"
+}
@@ -2,6 +2,7 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"net/http"
 
@@ -44,6 +45,12 @@ func HandleUserPOST(log *zap.Logger, as togglr.UserService) http.HandlerFunc {
 			}
 
 			id.ID, err = as.CreateUser(r.Context(), user)
+			if errors.Is(err, togglr.ErrUserExists) {
+				log.Info("rejected duplicate user")
+				conflictMessage(w, err.Error())
+				return
+			}
+
 			if err != nil {
 				log.Error("failed to create user", zap.Error(err))
 				serverError(w, "could not save user")
@@ -57,7 +64,43 @@ func HandleUserPOST(log *zap.Logger, as togglr.UserService) http.HandlerFunc {
 				return
 			}
 
+			version, err := versionFromRequest(r, updateReq.Version)
+			if errors.Is(err, errMissingVersion) {
+				log.Error("update req is missing a version", zap.Error(err))
+				preconditionRequired(w, "a version is required to update a user")
+				return
+			}
+
+			if errors.Is(err, errWeakVersion) {
+				log.Info("rejected update with weak etag")
+				preconditionFailed(w, "If-Match requires a strong etag")
+				return
+			}
+
+			if err != nil {
+				log.Error("failed to parse version", zap.Error(err))
+				badRequest(w, "version was badly formed")
+				return
+			}
+
+			updateReq.Version = version
 			if err := as.UpdateUser(r.Context(), updateReq); err != nil {
+				if errors.Is(err, togglr.ErrConflict) {
+					log.Info("rejected update to stale user", zap.Int("version", version))
+					current, err := as.FetchUser(r.Context(), updateReq.ID)
+					if err != nil {
+						log.Error("failed to fetch current user", zap.Error(err))
+						serverError(w, "could not save user")
+						return
+					}
+
+					if err := conflictWithCurrent(w, current, current.Version); err != nil {
+						log.Error("failed to marshal current user", zap.Error(err))
+						serverError(w, "could not save user")
+					}
+					return
+				}
+
 				log.Error("failed to update user", zap.Error(err))
 				serverError(w, "could not save user")
 				return
@@ -75,30 +118,135 @@ func HandleUserPOST(log *zap.Logger, as togglr.UserService) http.HandlerFunc {
 	})
 }
 
-// HandleUserGET handles GET requests to the /user endpoint
-func HandleUserGET(log *zap.Logger, as togglr.UserService) http.HandlerFunc {
+// HandleUserGET handles GET requests to the /user endpoint, returning the User that's logged in
+func HandleUserGET(log *zap.Logger) http.HandlerFunc {
 	log = log.With(zap.String("handler", "HandleUserGET"))
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Debug("listing users")
+		log.Debug("fetching current user")
 		defer log.Sync()
 
-		req := togglr.ListUsersReq{}
+		user, found := GetUser(r.Context())
+		if !found {
+			unauthorized(w, "not logged in")
+			return
+		}
 
-		users, err := as.ListUsers(r.Context(), req)
+		data, err := json.Marshal(user)
 		if err != nil {
-			log.Error("failed to list users", zap.Error(err))
-			serverError(w, "could not list users")
+			log.Error("failed to marshal user", zap.Error(err))
+			serverError(w, "could not fetch user")
 			return
 		}
 
-		data, err := json.Marshal(users)
+		w.Header().Set("ETag", etag(user.Version))
+		ok(w, data)
+	})
+}
+
+// HandleUserPasswordPOST handles POST requests to the /user/{id}/password endpoint, setting the password of a User
+// with the basic IdentityType. Users have to be logged in, and have to give their current password to change their
+// own. Admins can reset the password of any other User without it. Every other Session of the User is logged out
+// once the password changes
+func HandleUserPasswordPOST(log *zap.Logger, us togglr.UserService, ps togglr.PasswordService, ss togglr.SessionService) http.HandlerFunc {
+	log = log.With(zap.String("handler", "HandleUserPasswordPOST"))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		log := log.With(zap.String("userID", id))
+		log.Debug("setting password")
+		defer log.Sync()
+
+		userID, err := uid.FromString(id)
 		if err != nil {
-			log.Error("failed to marshal users", zap.Error(err))
-			serverError(w, "could not list users")
+			log.Error("failed to parse user ID", zap.Error(err))
+			badRequest(w, "user ID was badly formed")
 			return
 		}
 
-		ok(w, data)
+		current, found := GetUser(r.Context())
+		if !found {
+			unauthorized(w, "a session is required to set a password")
+			return
+		}
+
+		self := current.ID.Equals(userID)
+		if !self && !current.Admin {
+			log.Info("rejected setting password of another user")
+			forbidden(w, "users can only set their own password")
+			return
+		}
+
+		var req struct {
+			CurrentPassword string `json:"currentPassword"`
+			Password        string `json:"password"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Error("failed to unmarshal password", zap.Error(err))
+			badRequest(w, "could not unmarshal password")
+			return
+		}
+
+		user, err := us.FetchUser(r.Context(), userID)
+		if err != nil && !errors.Is(err, togglr.ErrNotFound) {
+			log.Error("failed to fetch user", zap.Error(err))
+			serverError(w, "could not set password")
+			return
+		}
+
+		if user.ID.IsNull() {
+			notFound(w, "user does not exist")
+			return
+		}
+
+		if user.Identity != togglr.IdentityTypeBasic {
+			badRequest(w, "only users with the basic identity type have passwords")
+			return
+		}
+
+		if self {
+			if err := togglr.VerifyPassword(r.Context(), ps, userID, req.CurrentPassword); err != nil {
+				if errors.Is(err, togglr.ErrInvalidCredentials) {
+					log.Info("rejected password change with wrong current password")
+					forbidden(w, "current password is incorrect")
+					return
+				}
+
+				log.Error("failed to verify password", zap.Error(err))
+				serverError(w, "could not set password")
+				return
+			}
+		}
+
+		hash, err := togglr.HashPassword(req.Password)
+		if err != nil {
+			if errors.Is(err, togglr.ErrInvalidPassword) {
+				badRequest(w, err.Error())
+				return
+			}
+
+			log.Error("failed to hash password", zap.Error(err))
+			serverError(w, "could not set password")
+			return
+		}
+
+		if err := ps.SetPasswordHash(r.Context(), userID, hash); err != nil {
+			log.Error("failed to set password", zap.Error(err))
+			serverError(w, "could not set password")
+			return
+		}
+
+		// whoever knew the old password is logged out, except for the Session the change was made with
+		keep := uid.UID{}
+		if session, found := getSession(r.Context()); found && self {
+			keep = session.ID
+		}
+
+		if err := ss.DeleteUserSessions(r.Context(), userID, keep); err != nil {
+			log.Error("failed to revoke sessions", zap.Error(err))
+			serverError(w, "password was set but other sessions could not be logged out")
+			return
+		}
+
+		noContent(w)
 	})
 }
 
@@ -132,6 +280,7 @@ func HandleUserIdGET(log *zap.Logger, as togglr.UserService) http.HandlerFunc {
 			return
 		}
 
+		w.Header().Set("ETag", etag(user.Version))
 		ok(w, data)
 	})
 }
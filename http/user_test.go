@@ -2,24 +2,34 @@ package http_test
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	stdhttp "net/http"
+	"net/http/cookiejar"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github.com/togglr-io/togglr"
 	"github.com/togglr-io/togglr/http"
 	"github.com/togglr-io/togglr/mock"
 	"github.com/togglr-io/togglr/uid"
 	"go.uber.org/zap"
 )
 
+func staleUserUpdate(ctx context.Context, req togglr.UpdateUserReq) error {
+	return togglr.ErrConflict
+}
+
 func Test_HandleUserPost(t *testing.T) {
 	accountID := uid.New().String()
 	id := uid.New().String()
 	cases := []struct {
 		name                string
 		payload             string
+		ifMatch             string
 		userService         *mock.UserService
 		expectedStatus      int
 		expectedCreateCalls int
@@ -49,9 +59,17 @@ func Test_HandleUserPost(t *testing.T) {
 			expectedCreateCalls: 1,
 			expectedUpdateCalls: 0,
 		},
+		{
+			name:                "duplicate user",
+			payload:             fmt.Sprintf(`{"accountId": "%s", "email": "test@togglr.io"}`, accountID),
+			userService:         mock.NewUserService(togglr.ErrUserExists),
+			expectedStatus:      409,
+			expectedCreateCalls: 1,
+			expectedUpdateCalls: 0,
+		},
 		{
 			name:                "successful update",
-			payload:             fmt.Sprintf(`{"id": "%s", "name": "Test User"}`, id),
+			payload:             fmt.Sprintf(`{"id": "%s", "version": 1, "name": "Test User"}`, id),
 			userService:         mock.NewUserService(nil),
 			expectedStatus:      200,
 			expectedCreateCalls: 0,
@@ -59,12 +77,46 @@ func Test_HandleUserPost(t *testing.T) {
 		},
 		{
 			name:                "failed update",
-			payload:             fmt.Sprintf(`{"id": "%s", "name": "Test User"}`, id),
+			payload:             fmt.Sprintf(`{"id": "%s", "version": 1, "name": "Test User"}`, id),
 			userService:         mock.NewUserService(errors.New("forced")),
 			expectedStatus:      500,
 			expectedCreateCalls: 0,
 			expectedUpdateCalls: 1,
 		},
+		{
+			name:                "update with If-Match",
+			payload:             fmt.Sprintf(`{"id": "%s", "name": "Test User"}`, id),
+			ifMatch:             `"3"`,
+			userService:         mock.NewUserService(nil),
+			expectedStatus:      200,
+			expectedCreateCalls: 0,
+			expectedUpdateCalls: 1,
+		},
+		{
+			name:                "update without version",
+			payload:             fmt.Sprintf(`{"id": "%s", "name": "Test User"}`, id),
+			userService:         mock.NewUserService(nil),
+			expectedStatus:      428,
+			expectedCreateCalls: 0,
+			expectedUpdateCalls: 0,
+		},
+		{
+			name:                "update with bad If-Match",
+			payload:             fmt.Sprintf(`{"id": "%s", "name": "Test User"}`, id),
+			ifMatch:             "latest",
+			userService:         mock.NewUserService(nil),
+			expectedStatus:      400,
+			expectedCreateCalls: 0,
+			expectedUpdateCalls: 0,
+		},
+		{
+			name:                "stale update",
+			payload:             fmt.Sprintf(`{"id": "%s", "version": 1, "name": "Test User"}`, id),
+			userService:         &mock.UserService{UpdateUserFn: staleUserUpdate},
+			expectedStatus:      409,
+			expectedCreateCalls: 0,
+			expectedUpdateCalls: 1,
+		},
 	}
 
 	for _, c := range cases {
@@ -84,6 +136,10 @@ func Test_HandleUserPost(t *testing.T) {
 				t.Fatalf("failed to create request: %s", err)
 			}
 
+			if c.ifMatch != "" {
+				req.Header.Set("If-Match", c.ifMatch)
+			}
+
 			res, err := stdhttp.DefaultClient.Do(req)
 			if err != nil {
 				t.Fatalf("failed to send request: %s", err)
@@ -105,57 +161,70 @@ func Test_HandleUserPost(t *testing.T) {
 }
 
 func Test_HandleUserGet(t *testing.T) {
+	user := togglr.User{ID: uid.New(), Email: "jane@example.com", Identity: togglr.IdentityTypeBasic}
+
 	cases := []struct {
 		name           string
-		query          string
-		userService    *mock.UserService
+		login          bool
 		expectedStatus int
-		expectedCalls  int
 	}{
 		{
-			name:           "successful test",
-			query:          "",
-			userService:    mock.NewUserService(nil),
+			name:           "logged in",
+			login:          true,
 			expectedStatus: 200,
-			expectedCalls:  1,
 		},
 		{
-			name:           "service failure",
-			query:          "",
-			userService:    mock.NewUserService(errors.New("forced")),
-			expectedStatus: 500,
-			expectedCalls:  1,
+			name:           "not logged in",
+			expectedStatus: 401,
 		},
 	}
 
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
+			services, _ := sessionServices(t, user, "correct horse")
 			cfg := http.Config{
-				Logger: zap.NewNop(),
-				Services: http.Services{
-					UserService: c.userService,
-				},
+				Logger:   zap.NewNop(),
+				Services: services,
 			}
 
 			s := httptest.NewServer(http.BuildRoutes(cfg))
 			defer s.Close()
-			url := fmt.Sprintf("%s/user?%s", s.URL, c.query)
-			req, err := stdhttp.NewRequest("GET", url, nil)
+
+			jar, err := cookiejar.New(nil)
 			if err != nil {
-				t.Fatalf("failed to create request: %s", err)
+				t.Fatalf("failed to create cookie jar: %s", err)
 			}
+			client := &stdhttp.Client{Jar: jar}
 
-			res, err := stdhttp.DefaultClient.Do(req)
+			if c.login {
+				res, err := client.Post(s.URL+"/session", "application/json", strings.NewReader(`{"email":"jane@example.com","password":"correct horse"}`))
+				if err != nil {
+					t.Fatalf("failed to log in: %s", err)
+				}
+				res.Body.Close()
+			}
+
+			res, err := client.Get(fmt.Sprintf("%s/user", s.URL))
 			if err != nil {
 				t.Fatalf("failed to send request: %s", err)
 			}
+			defer res.Body.Close()
 
 			if res.StatusCode != c.expectedStatus {
 				t.Fatalf("expected status code of %d, but got %d", c.expectedStatus, res.StatusCode)
 			}
 
-			if c.userService.ListUsersCalled != c.expectedCalls {
-				t.Fatalf("expected FetchUser user to be called %d times, but it was called %d times", c.expectedCalls, c.userService.CreateUserCalled)
+			if !c.login {
+				return
+			}
+
+			var current togglr.User
+			if err := json.NewDecoder(res.Body).Decode(&current); err != nil {
+				t.Fatalf("failed to decode user: %s", err)
+			}
+
+			if !current.ID.Equals(user.ID) {
+				t.Fatalf("expected the logged in user %s, got %s", user.ID, current.ID)
 			}
 		})
 	}
@@ -224,3 +293,100 @@ func Test_HandleUserDelete(t *testing.T) {
 		})
 	}
 }
+
+func Test_HandleUserPasswordPost(t *testing.T) {
+	admin := togglr.User{ID: uid.New(), Email: "root@example.com", Identity: togglr.IdentityTypeBasic, Admin: true}
+	basic := togglr.User{ID: uid.New(), Identity: togglr.IdentityTypeBasic}
+	github := togglr.User{ID: uid.New(), Identity: togglr.IdentityTypeGithub}
+
+	cases := []struct {
+		name           string
+		id             uid.UID
+		body           string
+		anonymous      bool
+		expectedStatus int
+		expectedCalls  int
+	}{
+		{
+			name:           "reset password",
+			id:             basic.ID,
+			body:           `{"password":"correct horse"}`,
+			expectedStatus: 204,
+			expectedCalls:  1,
+		},
+		{
+			name:           "no session",
+			id:             basic.ID,
+			body:           `{"password":"correct horse"}`,
+			anonymous:      true,
+			expectedStatus: 401,
+		},
+		{
+			name:           "too short",
+			id:             basic.ID,
+			body:           `{"password":"short"}`,
+			expectedStatus: 400,
+		},
+		{
+			name:           "not a basic user",
+			id:             github.ID,
+			body:           `{"password":"correct horse"}`,
+			expectedStatus: 400,
+		},
+		{
+			name:           "missing user",
+			id:             uid.New(),
+			body:           `{"password":"correct horse"}`,
+			expectedStatus: 404,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			services, ss := sessionServices(t, admin, "battery staple")
+			us := services.UserService.(*mock.UserService)
+			us.FetchUserFn = func(ctx context.Context, id uid.UID) (togglr.User, error) {
+				for _, user := range []togglr.User{admin, basic, github} {
+					if user.ID.Equals(id) {
+						return user, nil
+					}
+				}
+				return togglr.User{}, togglr.ErrNotFound
+			}
+			ps := services.PasswordService.(*mock.PasswordService)
+
+			s := httptest.NewServer(http.BuildRoutes(http.Config{Logger: zap.NewNop(), Services: services}))
+			defer s.Close()
+
+			client, csrfToken := stdhttp.DefaultClient, ""
+			if !c.anonymous {
+				client, csrfToken = login(t, s, admin.Email, "battery staple")
+			}
+
+			req, err := stdhttp.NewRequest(stdhttp.MethodPost, fmt.Sprintf("%s/user/%s/password", s.URL, c.id), strings.NewReader(c.body))
+			if err != nil {
+				t.Fatalf("failed to create request: %s", err)
+			}
+			req.Header.Set("X-CSRF-Token", csrfToken)
+
+			res, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("failed to send request: %s", err)
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode != c.expectedStatus {
+				t.Fatalf("expected status code of %d, but got %d", c.expectedStatus, res.StatusCode)
+			}
+
+			if ps.SetPasswordHashCalled != c.expectedCalls {
+				t.Fatalf("expected SetPasswordHash to be called %d times, but it was called %d times", c.expectedCalls, ps.SetPasswordHashCalled)
+			}
+
+			// every Session of a User whose password is reset by an admin is logged out
+			if ss.DeleteUserSessionsCalled != c.expectedCalls {
+				t.Fatalf("expected the user's sessions to be revoked %d times, but they were revoked %d times", c.expectedCalls, ss.DeleteUserSessionsCalled)
+			}
+		})
+	}
+}
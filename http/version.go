@@ -0,0 +1,112 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/uid"
+)
+
+var (
+	errMissingVersion = errors.New("no version was provided")
+	// weak ETags can't be used with If-Match, which always uses strong comparison
+	errWeakVersion = errors.New("weak entity tags can't be used to update")
+)
+
+// etag formats a resource version as a strong ETag
+func etag(version int) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// versionFromRequest determines which version of a resource an update was based on. The If-Match header takes
+// precedence over a version included in the request body. errMissingVersion is returned if neither is present, and
+// errWeakVersion if the header is a weak ETag
+func versionFromRequest(r *http.Request, bodyVersion int) (int, error) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		if bodyVersion < 1 {
+			return 0, errMissingVersion
+		}
+
+		return bodyVersion, nil
+	}
+
+	header = strings.TrimSpace(header)
+	if strings.HasPrefix(header, "W/") {
+		return 0, errWeakVersion
+	}
+
+	tag := strings.Trim(header, `"`)
+	version, err := strconv.Atoi(tag)
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("invalid If-Match header %q", header)
+	}
+
+	return version, nil
+}
+
+// conflictWithCurrent responds with the current state of a resource after an update was rejected for being based
+// on a stale version, so that the client can reconcile its changes
+func conflictWithCurrent(w http.ResponseWriter, current interface{}, version int) error {
+	data, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("ETag", etag(version))
+	conflict(w, data)
+	return nil
+}
+
+// configETag formats the configuration version of an account as an ETag. It's weak because compressed and
+// uncompressed responses share it
+func configETag(version int64) string {
+	return fmt.Sprintf(`W/"%d"`, version)
+}
+
+// matchesETag checks the If-None-Match header of a request against an ETag using weak comparison, as required for
+// conditional GETs
+func matchesETag(r *http.Request, tag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+
+	opaque := strings.TrimPrefix(tag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == opaque {
+			return true
+		}
+	}
+
+	return false
+}
+
+// respondNotModified tags a response with the config version of an account and responds with 304 Not Modified if
+// the request's If-None-Match header shows the client already has it. The version should be checked before doing
+// any work based on the account's toggles, so that a change made in between can only make the ETag stale, which
+// costs the client an extra download rather than a missed change. The version is returned for responses that
+// include it
+func respondNotModified(ctx context.Context, w http.ResponseWriter, r *http.Request, cvs togglr.ConfigVersionService, accountID uid.UID) (int64, bool, error) {
+	version, err := cvs.FetchConfigVersion(ctx, accountID)
+	if err != nil {
+		return 0, false, err
+	}
+
+	tag := configETag(version)
+	w.Header().Set("ETag", tag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if matchesETag(r, tag) {
+		notModified(w)
+		return version, true, nil
+	}
+
+	return version, false, nil
+}
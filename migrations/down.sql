@@ -1,9 +1,23 @@
+DROP TABLE sessions;
+DROP TABLE user_passwords;
+DROP TABLE api_keys;
+DROP TABLE holdouts;
+DROP TABLE metric_events;
+DROP TABLE experiment_exposures;
+DROP TABLE experiments;
+DROP TABLE evaluation_counts;
+DROP TABLE toggle_usage;
+DROP TABLE change_requests;
+DROP TABLE toggle_revisions;
 DROP TABLE toggles;
+DROP TABLE toggle_kinds;
 DROP TABLE metadata_keys;
 DROP TABLE account_users;
 DROP TABLE users;
 DROP TABLE identity_types;
+DROP TABLE config_versions;
 DROP TABLE accounts;
+DROP EXTENSION IF EXISTS btree_gist;
 
 DROP OWNED BY toggle;
 DROP USER toggle;
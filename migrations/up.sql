@@ -1,3 +1,6 @@
+-- gist indexes over plain columns, which the exclusion constraint on running experiments needs
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
 -- create update trigger
 CREATE OR REPLACE FUNCTION updated_at_trigger()
 RETURNS TRIGGER AS $$
@@ -7,10 +10,51 @@ BEGIN
 END;
 $$ language 'plpgsql';
 
+-- create change notification trigger, listeners use these to keep their caches fresh
+CREATE OR REPLACE FUNCTION notify_change_trigger()
+RETURNS TRIGGER AS $$
+DECLARE
+	rec RECORD;
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		rec = OLD;
+	ELSE
+		rec = NEW;
+	END IF;
+
+	PERFORM pg_notify('togglr_changes', json_build_object(
+		'table', TG_TABLE_NAME,
+		'op', TG_OP,
+		'id', rec.id,
+		'accountId', rec.account_id
+	)::text);
+	RETURN NULL;
+END;
+$$ language 'plpgsql';
+
+-- create config version trigger, bumps the config version of the account a row belongs to
+CREATE OR REPLACE FUNCTION config_version_trigger()
+RETURNS TRIGGER AS $$
+DECLARE
+	rec RECORD;
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		rec = OLD;
+	ELSE
+		rec = NEW;
+	END IF;
+
+	INSERT INTO config_versions (account_id, version) VALUES (rec.account_id, 1)
+	ON CONFLICT (account_id) DO UPDATE SET version = config_versions.version + 1;
+	RETURN NULL;
+END;
+$$ language 'plpgsql';
+
 -- create app tables
 CREATE TABLE IF NOT EXISTS accounts(
 	id UUID PRIMARY KEY,
 	name VARCHAR(512),
+	version INTEGER NOT NULL DEFAULT 1,
 	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
 	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
@@ -18,6 +62,11 @@ CREATE TABLE IF NOT EXISTS accounts(
 CREATE TRIGGER accounts_updated_at BEFORE UPDATE
 ON accounts FOR EACH ROW EXECUTE PROCEDURE updated_at_trigger();
 
+CREATE TABLE IF NOT EXISTS config_versions(
+	account_id UUID PRIMARY KEY REFERENCES accounts(id),
+	version BIGINT NOT NULL DEFAULT 0
+);
+
 
 CREATE TABLE IF NOT EXISTS identity_types(
 	name VARCHAR(64) PRIMARY KEY,
@@ -29,6 +78,8 @@ CREATE TABLE IF NOT EXISTS users(
 	email VARCHAR(320) NOT NULL,
 	name VARCHAR(512) NOT NULL,
 	identity_type VARCHAR(64) NOT NULL REFERENCES identity_types(name),
+	admin BOOLEAN NOT NULL DEFAULT FALSE,
+	version INTEGER NOT NULL DEFAULT 1,
 	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
 	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
 	UNIQUE (email, identity_type)
@@ -39,6 +90,24 @@ ON users FOR EACH ROW EXECUTE PROCEDURE updated_at_trigger();
 
 
 
+CREATE TABLE IF NOT EXISTS user_passwords(
+	user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+	hash VARCHAR(255) NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS sessions(
+	id UUID PRIMARY KEY,
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	csrf_token VARCHAR(64) NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS sessions_user ON sessions (user_id);
+
+
+
 CREATE TABLE IF NOT EXISTS account_users(
 	account_id UUID NOT NULL REFERENCES accounts(id),
 	user_id UUID NOT NULL REFERENCES users(id),
@@ -48,6 +117,11 @@ CREATE TABLE IF NOT EXISTS account_users(
 
 
 
+CREATE TABLE IF NOT EXISTS toggle_kinds(
+	name VARCHAR(64) PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
 CREATE TABLE IF NOT EXISTS toggles(
 	id UUID PRIMARY KEY,
 	account_id UUID NOT NULL REFERENCES accounts(id),
@@ -55,12 +129,143 @@ CREATE TABLE IF NOT EXISTS toggles(
 	active BOOLEAN NOT NULL DEFAULT TRUE,
 	rules JSONB,
 	description VARCHAR(2048),
+	requires_approval BOOLEAN NOT NULL DEFAULT FALSE,
+	kind VARCHAR(64) NOT NULL DEFAULT 'release' REFERENCES toggle_kinds(name),
+	owner VARCHAR(512) NOT NULL DEFAULT '',
+	tags JSONB NOT NULL DEFAULT '[]',
+	prerequisites JSONB NOT NULL DEFAULT '[]',
+	payload JSONB,
+	variants JSONB NOT NULL DEFAULT '[]',
+	experiment_id UUID,
+	allocation JSONB,
+	version INTEGER NOT NULL DEFAULT 1,
+	archived_at TIMESTAMP,
 	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
-	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
-	UNIQUE (account_id, key)
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
 CREATE TRIGGER toggles_updated_at BEFORE UPDATE
 ON toggles FOR EACH ROW EXECUTE PROCEDURE updated_at_trigger();
+CREATE TRIGGER toggles_notify AFTER INSERT OR UPDATE OR DELETE
+ON toggles FOR EACH ROW EXECUTE PROCEDURE notify_change_trigger();
+CREATE TRIGGER toggles_config_version AFTER INSERT OR UPDATE OR DELETE
+ON toggles FOR EACH ROW EXECUTE PROCEDURE config_version_trigger();
+CREATE INDEX IF NOT EXISTS toggles_tags ON toggles USING GIN (tags);
+CREATE INDEX IF NOT EXISTS toggles_owner ON toggles (account_id, owner);
+CREATE INDEX IF NOT EXISTS toggles_prerequisites ON toggles USING GIN (prerequisites);
+-- archived toggles give up their key so that it can be reused
+CREATE UNIQUE INDEX IF NOT EXISTS toggles_key ON toggles (account_id, key) WHERE archived_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS toggle_revisions(
+	id UUID PRIMARY KEY,
+	toggle_id UUID NOT NULL REFERENCES toggles(id) ON DELETE CASCADE,
+	revision INTEGER NOT NULL,
+	active BOOLEAN NOT NULL,
+	rules JSONB,
+	description VARCHAR(2048),
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (toggle_id, revision)
+);
+
+CREATE TABLE IF NOT EXISTS change_requests(
+	id UUID PRIMARY KEY,
+	account_id UUID NOT NULL REFERENCES accounts(id),
+	toggle_id UUID NOT NULL REFERENCES toggles(id) ON DELETE CASCADE,
+	author_id UUID NOT NULL REFERENCES users(id),
+	reviewer_id UUID REFERENCES users(id),
+	status VARCHAR(64) NOT NULL,
+	comment VARCHAR(2048) NOT NULL DEFAULT '',
+	change JSONB NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TRIGGER change_requests_updated_at BEFORE UPDATE
+ON change_requests FOR EACH ROW EXECUTE PROCEDURE updated_at_trigger();
+
+CREATE TABLE IF NOT EXISTS toggle_usage(
+	toggle_id UUID PRIMARY KEY REFERENCES toggles(id) ON DELETE CASCADE,
+	account_id UUID NOT NULL REFERENCES accounts(id),
+	last_evaluated_at TIMESTAMP NOT NULL,
+	last_true_at TIMESTAMP,
+	last_false_at TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS toggle_usage_account ON toggle_usage (account_id);
+
+CREATE TABLE IF NOT EXISTS evaluation_counts(
+	toggle_id UUID NOT NULL REFERENCES toggles(id) ON DELETE CASCADE,
+	account_id UUID NOT NULL REFERENCES accounts(id),
+	bucket TIMESTAMP NOT NULL,
+	variant VARCHAR(64) NOT NULL,
+	reason VARCHAR(64) NOT NULL,
+	count BIGINT NOT NULL,
+	PRIMARY KEY (toggle_id, bucket, variant, reason)
+);
+
+CREATE TABLE IF NOT EXISTS experiments(
+	id UUID PRIMARY KEY,
+	account_id UUID NOT NULL REFERENCES accounts(id),
+	toggle_id UUID NOT NULL REFERENCES toggles(id) ON DELETE CASCADE,
+	name VARCHAR(512) NOT NULL,
+	description VARCHAR(2048) NOT NULL DEFAULT '',
+	metric VARCHAR(512) NOT NULL,
+	control VARCHAR(512) NOT NULL,
+	variants JSONB NOT NULL DEFAULT '[]',
+	layer VARCHAR(512) NOT NULL DEFAULT '',
+	traffic INT NOT NULL DEFAULT 100 CHECK (traffic BETWEEN 0 AND 100),
+	slot_start INT NOT NULL DEFAULT 0,
+	slot_end INT NOT NULL DEFAULT 0,
+	status VARCHAR(64) NOT NULL,
+	started_at TIMESTAMP,
+	stopped_at TIMESTAMP,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	-- running experiments can't share slots in a layer. Experiments without a layer have all of the slots to themselves
+	EXCLUDE USING gist (account_id WITH =, layer WITH =, int4range(slot_start, slot_end) WITH &&)
+		WHERE (status = 'running' AND layer <> '')
+);
+CREATE TRIGGER experiments_updated_at BEFORE UPDATE
+ON experiments FOR EACH ROW EXECUTE PROCEDURE updated_at_trigger();
+CREATE INDEX IF NOT EXISTS experiments_toggle ON experiments (toggle_id);
+CREATE INDEX IF NOT EXISTS experiments_layer ON experiments (account_id, layer, status);
+
+CREATE TABLE IF NOT EXISTS experiment_exposures(
+	experiment_id UUID NOT NULL REFERENCES experiments(id) ON DELETE CASCADE,
+	context_key VARCHAR(512) NOT NULL,
+	variant VARCHAR(512) NOT NULL,
+	exposed_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (experiment_id, context_key)
+);
+
+CREATE TABLE IF NOT EXISTS metric_events(
+	account_id UUID NOT NULL REFERENCES accounts(id),
+	metric VARCHAR(512) NOT NULL,
+	context_key VARCHAR(512) NOT NULL,
+	value DOUBLE PRECISION NOT NULL,
+	occurred_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS metric_events_context ON metric_events (account_id, metric, context_key, occurred_at);
+
+CREATE TABLE IF NOT EXISTS holdouts(
+	id UUID PRIMARY KEY,
+	account_id UUID NOT NULL REFERENCES accounts(id),
+	name VARCHAR(512) NOT NULL,
+	description VARCHAR(2048) NOT NULL DEFAULT '',
+	percent INT NOT NULL CHECK (percent BETWEEN 1 AND 100),
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS holdouts_account ON holdouts (account_id);
+
+CREATE TABLE IF NOT EXISTS api_keys(
+	id UUID PRIMARY KEY,
+	account_id UUID NOT NULL REFERENCES accounts(id),
+	name VARCHAR(512) NOT NULL,
+	environment VARCHAR(512) NOT NULL DEFAULT '',
+	kind VARCHAR(64) NOT NULL CHECK (kind IN ('client', 'server', 'management')),
+	prefix VARCHAR(64) NOT NULL,
+	hash VARCHAR(64) NOT NULL UNIQUE,
+	revoked_at TIMESTAMP,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS api_keys_account ON api_keys (account_id);
 
 
 
@@ -108,6 +313,13 @@ INSERT INTO identity_types (name) VALUES
 ('basic')
 ON CONFLICT DO NOTHING;
 
+INSERT INTO toggle_kinds (name) VALUES
+('release'),
+('experiment'),
+('ops'),
+('permission')
+ON CONFLICT DO NOTHING;
+
 -- add some test data
 INSERT INTO accounts (id, name) VALUES
 ('8dc8c3cd-7c2a-4a4c-bc1e-7ba042096029', 'Toggle Test')
@@ -19,6 +19,8 @@ type AccountService struct {
 
 	ListAccountsFn     func(ctx context.Context, req togglr.ListAccountsReq) ([]togglr.Account, error)
 	ListAccountsCalled int
+	// Paged sorts and pages the results of ListAccountsFn in memory like postgres would
+	Paged bool
 
 	DeleteAccountFn     func(ctx context.Context, id uid.UID) error
 	DeleteAccountCalled int
@@ -67,7 +69,16 @@ func (m *AccountService) FetchAccount(ctx context.Context, id uid.UID) (togglr.A
 func (m *AccountService) ListAccounts(ctx context.Context, req togglr.ListAccountsReq) ([]togglr.Account, error) {
 	m.ListAccountsCalled++
 	if m.ListAccountsFn != nil {
-		return m.ListAccountsFn(ctx, req)
+		accounts, err := m.ListAccountsFn(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		if !m.Paged {
+			return accounts, nil
+		}
+
+		return pageAccounts(accounts, req.Page)
 	}
 
 	return make([]togglr.Account, 0), m.Error
@@ -0,0 +1,80 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/uid"
+)
+
+type APIKeyService struct {
+	CreateAPIKeyFn     func(ctx context.Context, key togglr.APIKey) (uid.UID, error)
+	CreateAPIKeyCalled int
+
+	FetchAPIKeyFn     func(ctx context.Context, id uid.UID) (togglr.APIKey, error)
+	FetchAPIKeyCalled int
+
+	FetchAPIKeyByHashFn     func(ctx context.Context, hash string) (togglr.APIKey, error)
+	FetchAPIKeyByHashCalled int
+
+	ListAPIKeysFn     func(ctx context.Context, accountID uid.UID) ([]togglr.APIKey, error)
+	ListAPIKeysCalled int
+
+	RevokeAPIKeyFn     func(ctx context.Context, id uid.UID) error
+	RevokeAPIKeyCalled int
+
+	Error error
+}
+
+func NewAPIKeyService(err error) *APIKeyService {
+	return &APIKeyService{Error: err}
+}
+
+func (m *APIKeyService) CreateAPIKey(ctx context.Context, key togglr.APIKey) (uid.UID, error) {
+	m.CreateAPIKeyCalled++
+	if m.CreateAPIKeyFn != nil {
+		return m.CreateAPIKeyFn(ctx, key)
+	}
+
+	if key.ID.IsNull() {
+		return uid.New(), m.Error
+	}
+
+	return key.ID, m.Error
+}
+
+func (m *APIKeyService) FetchAPIKey(ctx context.Context, id uid.UID) (togglr.APIKey, error) {
+	m.FetchAPIKeyCalled++
+	if m.FetchAPIKeyFn != nil {
+		return m.FetchAPIKeyFn(ctx, id)
+	}
+
+	return togglr.APIKey{ID: id}, m.Error
+}
+
+func (m *APIKeyService) FetchAPIKeyByHash(ctx context.Context, hash string) (togglr.APIKey, error) {
+	m.FetchAPIKeyByHashCalled++
+	if m.FetchAPIKeyByHashFn != nil {
+		return m.FetchAPIKeyByHashFn(ctx, hash)
+	}
+
+	return togglr.APIKey{Hash: hash}, m.Error
+}
+
+func (m *APIKeyService) ListAPIKeys(ctx context.Context, accountID uid.UID) ([]togglr.APIKey, error) {
+	m.ListAPIKeysCalled++
+	if m.ListAPIKeysFn != nil {
+		return m.ListAPIKeysFn(ctx, accountID)
+	}
+
+	return make([]togglr.APIKey, 0), m.Error
+}
+
+func (m *APIKeyService) RevokeAPIKey(ctx context.Context, id uid.UID) error {
+	m.RevokeAPIKeyCalled++
+	if m.RevokeAPIKeyFn != nil {
+		return m.RevokeAPIKeyFn(ctx, id)
+	}
+
+	return m.Error
+}
@@ -0,0 +1,68 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/uid"
+)
+
+type ChangeRequestService struct {
+	CreateChangeRequestFn     func(ctx context.Context, cr togglr.ChangeRequest) (uid.UID, error)
+	CreateChangeRequestCalled int
+
+	UpdateChangeRequestFn     func(ctx context.Context, req togglr.UpdateChangeRequestReq) error
+	UpdateChangeRequestCalled int
+
+	FetchChangeRequestFn     func(ctx context.Context, id uid.UID) (togglr.ChangeRequest, error)
+	FetchChangeRequestCalled int
+
+	ListChangeRequestsFn     func(ctx context.Context, req togglr.ListChangeRequestsReq) ([]togglr.ChangeRequest, error)
+	ListChangeRequestsCalled int
+
+	Error error
+}
+
+func NewChangeRequestService(err error) *ChangeRequestService {
+	return &ChangeRequestService{Error: err}
+}
+
+func (m *ChangeRequestService) CreateChangeRequest(ctx context.Context, cr togglr.ChangeRequest) (uid.UID, error) {
+	m.CreateChangeRequestCalled++
+	if m.CreateChangeRequestFn != nil {
+		return m.CreateChangeRequestFn(ctx, cr)
+	}
+
+	if cr.ID.IsNull() {
+		return uid.New(), m.Error
+	}
+
+	return cr.ID, m.Error
+}
+
+func (m *ChangeRequestService) UpdateChangeRequest(ctx context.Context, req togglr.UpdateChangeRequestReq) error {
+	m.UpdateChangeRequestCalled++
+	if m.UpdateChangeRequestFn != nil {
+		return m.UpdateChangeRequestFn(ctx, req)
+	}
+
+	return m.Error
+}
+
+func (m *ChangeRequestService) FetchChangeRequest(ctx context.Context, id uid.UID) (togglr.ChangeRequest, error) {
+	m.FetchChangeRequestCalled++
+	if m.FetchChangeRequestFn != nil {
+		return m.FetchChangeRequestFn(ctx, id)
+	}
+
+	return togglr.ChangeRequest{}, m.Error
+}
+
+func (m *ChangeRequestService) ListChangeRequests(ctx context.Context, req togglr.ListChangeRequestsReq) ([]togglr.ChangeRequest, error) {
+	m.ListChangeRequestsCalled++
+	if m.ListChangeRequestsFn != nil {
+		return m.ListChangeRequestsFn(ctx, req)
+	}
+
+	return make([]togglr.ChangeRequest, 0), m.Error
+}
@@ -0,0 +1,27 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/togglr-io/togglr/uid"
+)
+
+type ConfigVersionService struct {
+	FetchConfigVersionFn     func(ctx context.Context, accountID uid.UID) (int64, error)
+	FetchConfigVersionCalled int
+
+	Error error
+}
+
+func NewConfigVersionService(err error) *ConfigVersionService {
+	return &ConfigVersionService{Error: err}
+}
+
+func (m *ConfigVersionService) FetchConfigVersion(ctx context.Context, accountID uid.UID) (int64, error) {
+	m.FetchConfigVersionCalled++
+	if m.FetchConfigVersionFn != nil {
+		return m.FetchConfigVersionFn(ctx, accountID)
+	}
+
+	return 0, m.Error
+}
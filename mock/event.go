@@ -0,0 +1,39 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/togglr-io/togglr"
+)
+
+type EventService struct {
+	RecordEvaluationCountsFn     func(ctx context.Context, counts ...togglr.EvaluationCount) error
+	RecordEvaluationCountsCalled int
+
+	ListEvaluationCountsFn     func(ctx context.Context, req togglr.ListEvaluationCountsReq) ([]togglr.EvaluationCount, error)
+	ListEvaluationCountsCalled int
+
+	Error error
+}
+
+func NewEventService(err error) *EventService {
+	return &EventService{Error: err}
+}
+
+func (m *EventService) RecordEvaluationCounts(ctx context.Context, counts ...togglr.EvaluationCount) error {
+	m.RecordEvaluationCountsCalled++
+	if m.RecordEvaluationCountsFn != nil {
+		return m.RecordEvaluationCountsFn(ctx, counts...)
+	}
+
+	return m.Error
+}
+
+func (m *EventService) ListEvaluationCounts(ctx context.Context, req togglr.ListEvaluationCountsReq) ([]togglr.EvaluationCount, error) {
+	m.ListEvaluationCountsCalled++
+	if m.ListEvaluationCountsFn != nil {
+		return m.ListEvaluationCountsFn(ctx, req)
+	}
+
+	return make([]togglr.EvaluationCount, 0), m.Error
+}
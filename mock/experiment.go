@@ -0,0 +1,104 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/uid"
+)
+
+type ExperimentService struct {
+	CreateExperimentFn     func(ctx context.Context, exp togglr.Experiment) (uid.UID, error)
+	CreateExperimentCalled int
+
+	UpdateExperimentFn     func(ctx context.Context, req togglr.UpdateExperimentReq) error
+	UpdateExperimentCalled int
+
+	FetchExperimentFn     func(ctx context.Context, id uid.UID) (togglr.Experiment, error)
+	FetchExperimentCalled int
+
+	ListExperimentsFn     func(ctx context.Context, req togglr.ListExperimentsReq) ([]togglr.Experiment, error)
+	ListExperimentsCalled int
+
+	RecordExposuresFn     func(ctx context.Context, exposures ...togglr.Exposure) error
+	RecordExposuresCalled int
+
+	RecordMetricEventsFn     func(ctx context.Context, events ...togglr.MetricEvent) error
+	RecordMetricEventsCalled int
+
+	TallyExperimentFn     func(ctx context.Context, exp togglr.Experiment) ([]togglr.VariantTally, error)
+	TallyExperimentCalled int
+
+	Error error
+}
+
+func NewExperimentService(err error) *ExperimentService {
+	return &ExperimentService{Error: err}
+}
+
+func (m *ExperimentService) CreateExperiment(ctx context.Context, exp togglr.Experiment) (uid.UID, error) {
+	m.CreateExperimentCalled++
+	if m.CreateExperimentFn != nil {
+		return m.CreateExperimentFn(ctx, exp)
+	}
+
+	if exp.ID.IsNull() {
+		return uid.New(), m.Error
+	}
+
+	return exp.ID, m.Error
+}
+
+func (m *ExperimentService) UpdateExperiment(ctx context.Context, req togglr.UpdateExperimentReq) error {
+	m.UpdateExperimentCalled++
+	if m.UpdateExperimentFn != nil {
+		return m.UpdateExperimentFn(ctx, req)
+	}
+
+	return m.Error
+}
+
+func (m *ExperimentService) FetchExperiment(ctx context.Context, id uid.UID) (togglr.Experiment, error) {
+	m.FetchExperimentCalled++
+	if m.FetchExperimentFn != nil {
+		return m.FetchExperimentFn(ctx, id)
+	}
+
+	return togglr.Experiment{ID: id}, m.Error
+}
+
+func (m *ExperimentService) ListExperiments(ctx context.Context, req togglr.ListExperimentsReq) ([]togglr.Experiment, error) {
+	m.ListExperimentsCalled++
+	if m.ListExperimentsFn != nil {
+		return m.ListExperimentsFn(ctx, req)
+	}
+
+	return make([]togglr.Experiment, 0), m.Error
+}
+
+func (m *ExperimentService) RecordExposures(ctx context.Context, exposures ...togglr.Exposure) error {
+	m.RecordExposuresCalled++
+	if m.RecordExposuresFn != nil {
+		return m.RecordExposuresFn(ctx, exposures...)
+	}
+
+	return m.Error
+}
+
+func (m *ExperimentService) RecordMetricEvents(ctx context.Context, events ...togglr.MetricEvent) error {
+	m.RecordMetricEventsCalled++
+	if m.RecordMetricEventsFn != nil {
+		return m.RecordMetricEventsFn(ctx, events...)
+	}
+
+	return m.Error
+}
+
+func (m *ExperimentService) TallyExperiment(ctx context.Context, exp togglr.Experiment) ([]togglr.VariantTally, error) {
+	m.TallyExperimentCalled++
+	if m.TallyExperimentFn != nil {
+		return m.TallyExperimentFn(ctx, exp)
+	}
+
+	return make([]togglr.VariantTally, 0), m.Error
+}
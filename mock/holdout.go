@@ -0,0 +1,68 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/uid"
+)
+
+type HoldoutService struct {
+	CreateHoldoutFn     func(ctx context.Context, holdout togglr.Holdout) (uid.UID, error)
+	CreateHoldoutCalled int
+
+	FetchHoldoutFn     func(ctx context.Context, id uid.UID) (togglr.Holdout, error)
+	FetchHoldoutCalled int
+
+	ListHoldoutsFn     func(ctx context.Context, accountID uid.UID) ([]togglr.Holdout, error)
+	ListHoldoutsCalled int
+
+	DeleteHoldoutFn     func(ctx context.Context, id uid.UID) error
+	DeleteHoldoutCalled int
+
+	Error error
+}
+
+func NewHoldoutService(err error) *HoldoutService {
+	return &HoldoutService{Error: err}
+}
+
+func (m *HoldoutService) CreateHoldout(ctx context.Context, holdout togglr.Holdout) (uid.UID, error) {
+	m.CreateHoldoutCalled++
+	if m.CreateHoldoutFn != nil {
+		return m.CreateHoldoutFn(ctx, holdout)
+	}
+
+	if holdout.ID.IsNull() {
+		return uid.New(), m.Error
+	}
+
+	return holdout.ID, m.Error
+}
+
+func (m *HoldoutService) FetchHoldout(ctx context.Context, id uid.UID) (togglr.Holdout, error) {
+	m.FetchHoldoutCalled++
+	if m.FetchHoldoutFn != nil {
+		return m.FetchHoldoutFn(ctx, id)
+	}
+
+	return togglr.Holdout{ID: id}, m.Error
+}
+
+func (m *HoldoutService) ListHoldouts(ctx context.Context, accountID uid.UID) ([]togglr.Holdout, error) {
+	m.ListHoldoutsCalled++
+	if m.ListHoldoutsFn != nil {
+		return m.ListHoldoutsFn(ctx, accountID)
+	}
+
+	return make([]togglr.Holdout, 0), m.Error
+}
+
+func (m *HoldoutService) DeleteHoldout(ctx context.Context, id uid.UID) error {
+	m.DeleteHoldoutCalled++
+	if m.DeleteHoldoutFn != nil {
+		return m.DeleteHoldoutFn(ctx, id)
+	}
+
+	return m.Error
+}
@@ -0,0 +1,126 @@
+package mock
+
+import (
+	"sort"
+
+	"github.com/togglr-io/togglr"
+)
+
+// pageIndexes sorts and paginates a list of results in memory, like postgres would, given the Cursor for each
+// result. It returns the indexes of the results that belong on the requested page, in order
+func pageIndexes(cursors []togglr.Cursor, page togglr.Page) ([]int, error) {
+	if err := page.Validate(); err != nil {
+		return nil, err
+	}
+
+	less := func(a, b togglr.Cursor) bool {
+		if a.Value != b.Value {
+			return a.Value < b.Value
+		}
+
+		return a.ID.String() < b.ID.String()
+	}
+
+	idxs := make([]int, len(cursors))
+	for i := range idxs {
+		idxs[i] = i
+	}
+
+	sort.SliceStable(idxs, func(i, j int) bool {
+		if page.Descending() {
+			return less(cursors[idxs[j]], cursors[idxs[i]])
+		}
+
+		return less(cursors[idxs[i]], cursors[idxs[j]])
+	})
+
+	if page.Cursor != "" {
+		after, _ := togglr.ParseCursor(page.Cursor)
+		start := len(idxs)
+		for i, idx := range idxs {
+			if (!page.Descending() && less(after, cursors[idx])) || (page.Descending() && less(cursors[idx], after)) {
+				start = i
+				break
+			}
+		}
+		idxs = idxs[start:]
+	}
+
+	if page.Limit > 0 && uint(len(idxs)) > page.Limit {
+		idxs = idxs[:page.Limit]
+	}
+
+	return idxs, nil
+}
+
+// pageToggles applies the sorting and pagination of a Page to an in-memory slice of Toggles
+func pageToggles(toggles []togglr.Toggle, page togglr.Page) ([]togglr.Toggle, error) {
+	cursors := make([]togglr.Cursor, len(toggles))
+	for i, t := range toggles {
+		cursor, err := t.CursorFor(page.SortBy)
+		if err != nil {
+			return nil, err
+		}
+		cursors[i] = cursor
+	}
+
+	idxs, err := pageIndexes(cursors, page)
+	if err != nil {
+		return nil, err
+	}
+
+	paged := make([]togglr.Toggle, len(idxs))
+	for i, idx := range idxs {
+		paged[i] = toggles[idx]
+	}
+
+	return paged, nil
+}
+
+// pageAccounts applies the sorting and pagination of a Page to an in-memory slice of Accounts
+func pageAccounts(accounts []togglr.Account, page togglr.Page) ([]togglr.Account, error) {
+	cursors := make([]togglr.Cursor, len(accounts))
+	for i, a := range accounts {
+		cursor, err := a.CursorFor(page.SortBy)
+		if err != nil {
+			return nil, err
+		}
+		cursors[i] = cursor
+	}
+
+	idxs, err := pageIndexes(cursors, page)
+	if err != nil {
+		return nil, err
+	}
+
+	paged := make([]togglr.Account, len(idxs))
+	for i, idx := range idxs {
+		paged[i] = accounts[idx]
+	}
+
+	return paged, nil
+}
+
+// pageUsers applies the sorting and pagination of a Page to an in-memory slice of Users
+func pageUsers(users []togglr.User, page togglr.Page) ([]togglr.User, error) {
+	cursors := make([]togglr.Cursor, len(users))
+	for i, u := range users {
+		cursor, err := u.CursorFor(page.SortBy)
+		if err != nil {
+			return nil, err
+		}
+		cursors[i] = cursor
+	}
+
+	idxs, err := pageIndexes(cursors, page)
+	if err != nil {
+		return nil, err
+	}
+
+	paged := make([]togglr.User, len(idxs))
+	for i, idx := range idxs {
+		paged[i] = users[idx]
+	}
+
+	return paged, nil
+}
@@ -0,0 +1,105 @@
+package mock_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/mock"
+	"github.com/togglr-io/togglr/uid"
+)
+
+func Test_ToggleServicePaged(t *testing.T) {
+	// SETUP
+	now := time.Now()
+	toggles := []togglr.Toggle{
+		{ID: uid.New(), Key: "c", CreatedAt: now.Add(-time.Minute)},
+		{ID: uid.New(), Key: "a", CreatedAt: now},
+		{ID: uid.New(), Key: "b", CreatedAt: now.Add(-time.Hour)},
+	}
+
+	ts := mock.NewToggleService(nil)
+	ts.ListTogglesFn = func(ctx context.Context, req togglr.ListTogglesReq) ([]togglr.Toggle, error) {
+		return toggles, nil
+	}
+	ts.Paged = true
+
+	list := func(page togglr.Page) ([]togglr.Toggle, error) {
+		return ts.ListToggles(context.TODO(), togglr.ListTogglesReq{Page: page})
+	}
+
+	cases := []struct {
+		name     string
+		page     togglr.Page
+		expected []string
+	}{
+		{
+			name:     "default sort",
+			page:     togglr.Page{},
+			expected: []string{"b", "c", "a"},
+		},
+		{
+			name:     "sort by key",
+			page:     togglr.Page{SortBy: togglr.SortByKey},
+			expected: []string{"a", "b", "c"},
+		},
+		{
+			name:     "sort by key descending",
+			page:     togglr.Page{SortBy: togglr.SortByKey, Order: togglr.SortOrderDesc},
+			expected: []string{"c", "b", "a"},
+		},
+		{
+			name:     "limit",
+			page:     togglr.Page{SortBy: togglr.SortByKey, Limit: 2},
+			expected: []string{"a", "b"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// RUN
+			paged, err := list(c.page)
+			if err != nil {
+				t.Fatalf("failed to page toggles: %s", err)
+			}
+
+			if len(paged) != len(c.expected) {
+				t.Fatalf("expected %d toggles, got %d", len(c.expected), len(paged))
+			}
+
+			for idx, key := range c.expected {
+				if paged[idx].Key != key {
+					t.Fatalf("expected toggle %d to be %s, got %s", idx, key, paged[idx].Key)
+				}
+			}
+		})
+	}
+
+	t.Run("cursor", func(t *testing.T) {
+		cursor, err := toggles[2].CursorFor(togglr.SortByKey)
+		if err != nil {
+			t.Fatalf("failed to create cursor: %s", err)
+		}
+
+		paged, err := list(togglr.Page{SortBy: togglr.SortByKey, Cursor: cursor.Encode()})
+		if err != nil {
+			t.Fatalf("failed to page toggles: %s", err)
+		}
+
+		if len(paged) != 1 || paged[0].Key != "c" {
+			t.Fatalf("expected only toggle 'c' after the cursor, got %+v", paged)
+		}
+	})
+
+	t.Run("invalid page", func(t *testing.T) {
+		if _, err := list(togglr.Page{SortBy: "color"}); !errors.Is(err, togglr.ErrInvalidPage) {
+			t.Fatalf("expected ErrInvalidPage for an unknown sort field, got %v", err)
+		}
+
+		if _, err := list(togglr.Page{Cursor: "not-a-cursor"}); !errors.Is(err, togglr.ErrInvalidPage) {
+			t.Fatalf("expected ErrInvalidPage for a malformed cursor, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,39 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/togglr-io/togglr/uid"
+)
+
+type PasswordService struct {
+	SetPasswordHashFn     func(ctx context.Context, userID uid.UID, hash string) error
+	SetPasswordHashCalled int
+
+	FetchPasswordHashFn     func(ctx context.Context, userID uid.UID) (string, error)
+	FetchPasswordHashCalled int
+
+	Error error
+}
+
+func NewPasswordService(err error) *PasswordService {
+	return &PasswordService{Error: err}
+}
+
+func (m *PasswordService) SetPasswordHash(ctx context.Context, userID uid.UID, hash string) error {
+	m.SetPasswordHashCalled++
+	if m.SetPasswordHashFn != nil {
+		return m.SetPasswordHashFn(ctx, userID, hash)
+	}
+
+	return m.Error
+}
+
+func (m *PasswordService) FetchPasswordHash(ctx context.Context, userID uid.UID) (string, error) {
+	m.FetchPasswordHashCalled++
+	if m.FetchPasswordHashFn != nil {
+		return m.FetchPasswordHashFn(ctx, userID)
+	}
+
+	return "", m.Error
+}
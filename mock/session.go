@@ -0,0 +1,68 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/uid"
+)
+
+type SessionService struct {
+	CreateSessionFn     func(ctx context.Context, session togglr.Session) (uid.UID, error)
+	CreateSessionCalled int
+
+	FetchSessionFn     func(ctx context.Context, id uid.UID) (togglr.Session, error)
+	FetchSessionCalled int
+
+	DeleteSessionFn     func(ctx context.Context, id uid.UID) error
+	DeleteSessionCalled int
+
+	DeleteUserSessionsFn     func(ctx context.Context, userID uid.UID, keep uid.UID) error
+	DeleteUserSessionsCalled int
+
+	Error error
+}
+
+func NewSessionService(err error) *SessionService {
+	return &SessionService{Error: err}
+}
+
+func (m *SessionService) CreateSession(ctx context.Context, session togglr.Session) (uid.UID, error) {
+	m.CreateSessionCalled++
+	if m.CreateSessionFn != nil {
+		return m.CreateSessionFn(ctx, session)
+	}
+
+	if session.ID.IsNull() {
+		return uid.New(), m.Error
+	}
+
+	return session.ID, m.Error
+}
+
+func (m *SessionService) FetchSession(ctx context.Context, id uid.UID) (togglr.Session, error) {
+	m.FetchSessionCalled++
+	if m.FetchSessionFn != nil {
+		return m.FetchSessionFn(ctx, id)
+	}
+
+	return togglr.Session{ID: id}, m.Error
+}
+
+func (m *SessionService) DeleteSession(ctx context.Context, id uid.UID) error {
+	m.DeleteSessionCalled++
+	if m.DeleteSessionFn != nil {
+		return m.DeleteSessionFn(ctx, id)
+	}
+
+	return m.Error
+}
+
+func (m *SessionService) DeleteUserSessions(ctx context.Context, userID uid.UID, keep uid.UID) error {
+	m.DeleteUserSessionsCalled++
+	if m.DeleteUserSessionsFn != nil {
+		return m.DeleteUserSessionsFn(ctx, userID, keep)
+	}
+
+	return m.Error
+}
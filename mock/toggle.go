@@ -17,12 +17,32 @@ type ToggleService struct {
 	FetchToggleFn     func(ctx context.Context, id uid.UID) (togglr.Toggle, error)
 	FetchToggleCalled int
 
+	FetchToggleByKeyFn     func(ctx context.Context, accountID uid.UID, key string) (togglr.Toggle, error)
+	FetchToggleByKeyCalled int
+
 	ListTogglesFn     func(ctx context.Context, req togglr.ListTogglesReq) ([]togglr.Toggle, error)
 	ListTogglesCalled int
+	// Paged sorts and pages the results of ListTogglesFn in memory like postgres would
+	Paged bool
+
+	ListDependentsFn     func(ctx context.Context, accountID uid.UID, key string) ([]togglr.Toggle, error)
+	ListDependentsCalled int
 
 	DeleteToggleFn     func(ctx context.Context, id uid.UID) error
 	DeleteToggleCalled int
 
+	ArchiveToggleFn     func(ctx context.Context, id uid.UID) error
+	ArchiveToggleCalled int
+
+	RestoreToggleFn     func(ctx context.Context, id uid.UID) error
+	RestoreToggleCalled int
+
+	ListToggleRevisionsFn     func(ctx context.Context, toggleID uid.UID) ([]togglr.ToggleRevision, error)
+	ListToggleRevisionsCalled int
+
+	RollbackToggleFn     func(ctx context.Context, toggleID uid.UID, revision int) error
+	RollbackToggleCalled int
+
 	Error error
 }
 
@@ -61,10 +81,37 @@ func (m *ToggleService) FetchToggle(ctx context.Context, id uid.UID) (togglr.Tog
 	return togglr.Toggle{}, m.Error
 }
 
+func (m *ToggleService) FetchToggleByKey(ctx context.Context, accountID uid.UID, key string) (togglr.Toggle, error) {
+	m.FetchToggleByKeyCalled++
+	if m.FetchToggleByKeyFn != nil {
+		return m.FetchToggleByKeyFn(ctx, accountID, key)
+	}
+
+	return togglr.Toggle{}, m.Error
+}
+
 func (m *ToggleService) ListToggles(ctx context.Context, req togglr.ListTogglesReq) ([]togglr.Toggle, error) {
 	m.ListTogglesCalled++
 	if m.ListTogglesFn != nil {
-		return m.ListTogglesFn(ctx, req)
+		toggles, err := m.ListTogglesFn(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		if !m.Paged {
+			return toggles, nil
+		}
+
+		return pageToggles(toggles, req.Page)
+	}
+
+	return make([]togglr.Toggle, 0), m.Error
+}
+
+func (m *ToggleService) ListDependents(ctx context.Context, accountID uid.UID, key string) ([]togglr.Toggle, error) {
+	m.ListDependentsCalled++
+	if m.ListDependentsFn != nil {
+		return m.ListDependentsFn(ctx, accountID, key)
 	}
 
 	return make([]togglr.Toggle, 0), m.Error
@@ -78,3 +125,39 @@ func (m *ToggleService) DeleteToggle(ctx context.Context, id uid.UID) error {
 
 	return m.Error
 }
+
+func (m *ToggleService) ArchiveToggle(ctx context.Context, id uid.UID) error {
+	m.ArchiveToggleCalled++
+	if m.ArchiveToggleFn != nil {
+		return m.ArchiveToggleFn(ctx, id)
+	}
+
+	return m.Error
+}
+
+func (m *ToggleService) RestoreToggle(ctx context.Context, id uid.UID) error {
+	m.RestoreToggleCalled++
+	if m.RestoreToggleFn != nil {
+		return m.RestoreToggleFn(ctx, id)
+	}
+
+	return m.Error
+}
+
+func (m *ToggleService) ListToggleRevisions(ctx context.Context, toggleID uid.UID) ([]togglr.ToggleRevision, error) {
+	m.ListToggleRevisionsCalled++
+	if m.ListToggleRevisionsFn != nil {
+		return m.ListToggleRevisionsFn(ctx, toggleID)
+	}
+
+	return make([]togglr.ToggleRevision, 0), m.Error
+}
+
+func (m *ToggleService) RollbackToggle(ctx context.Context, toggleID uid.UID, revision int) error {
+	m.RollbackToggleCalled++
+	if m.RollbackToggleFn != nil {
+		return m.RollbackToggleFn(ctx, toggleID, revision)
+	}
+
+	return m.Error
+}
@@ -0,0 +1,40 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/uid"
+)
+
+type UsageService struct {
+	RecordUsageFn     func(ctx context.Context, usage ...togglr.ToggleUsage) error
+	RecordUsageCalled int
+
+	ListUsageFn     func(ctx context.Context, accountID uid.UID) ([]togglr.ToggleUsage, error)
+	ListUsageCalled int
+
+	Error error
+}
+
+func NewUsageService(err error) *UsageService {
+	return &UsageService{Error: err}
+}
+
+func (m *UsageService) RecordUsage(ctx context.Context, usage ...togglr.ToggleUsage) error {
+	m.RecordUsageCalled++
+	if m.RecordUsageFn != nil {
+		return m.RecordUsageFn(ctx, usage...)
+	}
+
+	return m.Error
+}
+
+func (m *UsageService) ListUsage(ctx context.Context, accountID uid.UID) ([]togglr.ToggleUsage, error) {
+	m.ListUsageCalled++
+	if m.ListUsageFn != nil {
+		return m.ListUsageFn(ctx, accountID)
+	}
+
+	return make([]togglr.ToggleUsage, 0), m.Error
+}
@@ -19,6 +19,14 @@ type UserService struct {
 
 	ListUsersFn     func(ctx context.Context, req togglr.ListUsersReq) ([]togglr.User, error)
 	ListUsersCalled int
+	// Paged sorts and pages the results of ListUsersFn in memory like postgres would
+	Paged bool
+
+	FetchUserByEmailFn     func(ctx context.Context, email string, identity togglr.IdentityType) (togglr.User, error)
+	FetchUserByEmailCalled int
+
+	ListUserAccountsFn     func(ctx context.Context, userID uid.UID) ([]uid.UID, error)
+	ListUserAccountsCalled int
 
 	DeleteUserFn     func(ctx context.Context, id uid.UID) error
 	DeleteUserCalled int
@@ -64,12 +72,39 @@ func (m *UserService) FetchUser(ctx context.Context, id uid.UID) (togglr.User, e
 func (m *UserService) ListUsers(ctx context.Context, req togglr.ListUsersReq) ([]togglr.User, error) {
 	m.ListUsersCalled++
 	if m.ListUsersFn != nil {
-		return m.ListUsersFn(ctx, req)
+		users, err := m.ListUsersFn(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		if !m.Paged {
+			return users, nil
+		}
+
+		return pageUsers(users, req.Page)
 	}
 
 	return make([]togglr.User, 0), m.Error
 }
 
+func (m *UserService) FetchUserByEmail(ctx context.Context, email string, identity togglr.IdentityType) (togglr.User, error) {
+	m.FetchUserByEmailCalled++
+	if m.FetchUserByEmailFn != nil {
+		return m.FetchUserByEmailFn(ctx, email, identity)
+	}
+
+	return togglr.User{}, m.Error
+}
+
+func (m *UserService) ListUserAccounts(ctx context.Context, userID uid.UID) ([]uid.UID, error) {
+	m.ListUserAccountsCalled++
+	if m.ListUserAccountsFn != nil {
+		return m.ListUserAccountsFn(ctx, userID)
+	}
+
+	return []uid.UID{}, m.Error
+}
+
 func (m *UserService) DeleteUser(ctx context.Context, id uid.UID) error {
 	m.DeleteUserCalled++
 	if m.DeleteUserFn != nil {
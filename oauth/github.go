@@ -0,0 +1,47 @@
+package oauth
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/togglr-io/togglr"
+)
+
+type githubUser struct {
+	Login string `json:"login"`
+	Name  string `json:"name"`
+}
+
+type githubEmail struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+// githubIdentity finds the name and primary email of a GitHub user. The email on the user itself is the public
+// one, which can be empty or unverified, so the primary email is looked up separately
+func (p Provider) githubIdentity(ctx context.Context, token string) (togglr.ExternalIdentity, error) {
+	var user githubUser
+	if err := p.get(ctx, p.UserURL, token, &user); err != nil {
+		return togglr.ExternalIdentity{}, err
+	}
+
+	var emails []githubEmail
+	if err := p.get(ctx, p.EmailsURL, token, &emails); err != nil {
+		return togglr.ExternalIdentity{}, err
+	}
+
+	identity := togglr.ExternalIdentity{Name: user.Name}
+	if identity.Name == "" {
+		identity.Name = user.Login
+	}
+
+	for _, email := range emails {
+		if email.Primary && email.Verified {
+			identity.Email = email.Email
+			return identity, nil
+		}
+	}
+
+	return togglr.ExternalIdentity{}, fmt.Errorf("%w: github user %s has no verified primary email", togglr.ErrInvalidCredentials, user.Login)
+}
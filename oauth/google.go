@@ -0,0 +1,28 @@
+package oauth
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/togglr-io/togglr"
+)
+
+type googleUser struct {
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+}
+
+// googleIdentity finds the name and email of a Google user from the OpenID Connect userinfo endpoint
+func (p Provider) googleIdentity(ctx context.Context, token string) (togglr.ExternalIdentity, error) {
+	var user googleUser
+	if err := p.get(ctx, p.UserURL, token, &user); err != nil {
+		return togglr.ExternalIdentity{}, err
+	}
+
+	if user.Email == "" || !user.EmailVerified {
+		return togglr.ExternalIdentity{}, fmt.Errorf("%w: google user has no verified email", togglr.ErrInvalidCredentials)
+	}
+
+	return togglr.ExternalIdentity{Email: user.Email, Name: user.Name}, nil
+}
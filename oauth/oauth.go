@@ -0,0 +1,222 @@
+// Package oauth logs Users in with GitHub and Google through the OAuth2 authorization code flow. Every endpoint of a
+// Provider can be configured, so that logging in can be tested against a local fake of either
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/env"
+)
+
+// how long requests to a provider can take before giving up
+const requestTimeout = 10 * time.Second
+
+// The endpoints of GitHub and Google used when they aren't configured
+const (
+	GithubAuthURL   = "https://github.com/login/oauth/authorize"
+	GithubTokenURL  = "https://github.com/login/oauth/access_token"
+	GithubUserURL   = "https://api.github.com/user"
+	GithubEmailsURL = "https://api.github.com/user/emails"
+
+	GoogleAuthURL  = "https://accounts.google.com/o/oauth2/v2/auth"
+	GoogleTokenURL = "https://oauth2.googleapis.com/token"
+	GoogleUserURL  = "https://openidconnect.googleapis.com/v1/userinfo"
+)
+
+// A Provider implements the togglr.IdentityProvider interface for GitHub or Google, depending on its Type.
+// EmailsURL is only used by GitHub, which doesn't include private emails in the User it returns
+type Provider struct {
+	Type         togglr.IdentityType
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the callback Users are sent back to after logging in, and has to be registered with the provider
+	RedirectURL string
+	Scopes      []string
+
+	AuthURL   string
+	TokenURL  string
+	UserURL   string
+	EmailsURL string
+
+	Client *http.Client
+}
+
+// NewGithub creates a Provider that logs in with GitHub
+func NewGithub(clientID, clientSecret, redirectURL string) Provider {
+	return Provider{
+		Type:         togglr.IdentityTypeGithub,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       []string{"read:user", "user:email"},
+		AuthURL:      GithubAuthURL,
+		TokenURL:     GithubTokenURL,
+		UserURL:      GithubUserURL,
+		EmailsURL:    GithubEmailsURL,
+	}
+}
+
+// NewGoogle creates a Provider that logs in with Google
+func NewGoogle(clientID, clientSecret, redirectURL string) Provider {
+	return Provider{
+		Type:         togglr.IdentityTypeGoogle,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       []string{"openid", "email", "profile"},
+		AuthURL:      GoogleAuthURL,
+		TokenURL:     GoogleTokenURL,
+		UserURL:      GoogleUserURL,
+	}
+}
+
+// FromEnv creates a Provider for the IdentityType using environment variables starting with the prefix, e.g.
+// TOGGLE_GITHUB_CLIENT_ID. The returned bool is false if no client ID is configured. Endpoints default to the real
+// provider's and can be overridden with the _AUTH_URL, _TOKEN_URL, _USER_URL and _EMAILS_URL variables
+func FromEnv(prefix string, identity togglr.IdentityType) (Provider, bool) {
+	key := fmt.Sprintf("%s_%s_", prefix, strings.ToUpper(string(identity)))
+	clientID := env.GetString(key+"CLIENT_ID", "")
+	clientSecret := env.GetString(key+"CLIENT_SECRET", "")
+	redirectURL := env.GetString(key+"REDIRECT_URL", "")
+
+	var p Provider
+	switch identity {
+	case togglr.IdentityTypeGithub:
+		p = NewGithub(clientID, clientSecret, redirectURL)
+	case togglr.IdentityTypeGoogle:
+		p = NewGoogle(clientID, clientSecret, redirectURL)
+	default:
+		return p, false
+	}
+
+	p.AuthURL = env.GetString(key+"AUTH_URL", p.AuthURL)
+	p.TokenURL = env.GetString(key+"TOKEN_URL", p.TokenURL)
+	p.UserURL = env.GetString(key+"USER_URL", p.UserURL)
+	p.EmailsURL = env.GetString(key+"EMAILS_URL", p.EmailsURL)
+
+	return p, clientID != ""
+}
+
+func (p Provider) client() *http.Client {
+	if p.Client != nil {
+		return p.Client
+	}
+
+	return &http.Client{Timeout: requestTimeout}
+}
+
+// Identity returns the IdentityType of Users that log in with the Provider
+func (p Provider) Identity() togglr.IdentityType {
+	return p.Type
+}
+
+// AuthCodeURL returns the URL Users are sent to to log in. The state is sent back to the RedirectURL along with
+// the code, and has to be checked to match the one the User was sent with
+func (p Provider) AuthCodeURL(state string) string {
+	query := url.Values{
+		"response_type": {"code"},
+		"client_id":     {p.ClientID},
+		"redirect_uri":  {p.RedirectURL},
+		"scope":         {strings.Join(p.Scopes, " ")},
+		"state":         {state},
+	}
+
+	separator := "?"
+	if strings.Contains(p.AuthURL, "?") {
+		separator = "&"
+	}
+
+	return p.AuthURL + separator + query.Encode()
+}
+
+// Authenticate exchanges a code for an access token and uses it to find out who the User is
+func (p Provider) Authenticate(ctx context.Context, code string) (togglr.ExternalIdentity, error) {
+	token, err := p.exchange(ctx, code)
+	if err != nil {
+		return togglr.ExternalIdentity{}, err
+	}
+
+	switch p.Type {
+	case togglr.IdentityTypeGithub:
+		return p.githubIdentity(ctx, token)
+	case togglr.IdentityTypeGoogle:
+		return p.googleIdentity(ctx, token)
+	}
+
+	return togglr.ExternalIdentity{}, fmt.Errorf("unsupported identity type %q", p.Type)
+}
+
+// a tokenResponse is returned when exchanging a code. GitHub reports rejected codes in the error field of a 200
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// exchange trades a code for an access token
+func (p Provider) exchange(ctx context.Context, code string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.RedirectURL},
+		"client_id":     {p.ClientID},
+		"client_secret": {p.ClientSecret},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	res, err := p.client().Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to exchange code: %w", err)
+	}
+	defer res.Body.Close()
+
+	var token tokenResponse
+	if err := json.NewDecoder(res.Body).Decode(&token); err != nil && res.StatusCode < 400 {
+		return "", fmt.Errorf("failed to decode token: %w", err)
+	}
+
+	if token.Error != "" || res.StatusCode >= 400 || token.AccessToken == "" {
+		return "", fmt.Errorf("%w: code was rejected with status %d %s %s", togglr.ErrInvalidCredentials, res.StatusCode, token.Error, token.ErrorDescription)
+	}
+
+	return token.AccessToken, nil
+}
+
+// get fetches a resource from the provider with an access token, decoding the JSON response into v
+func (p Provider) get(ctx context.Context, endpoint, token string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+
+	res, err := p.client().Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", endpoint, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusUnauthorized {
+		return fmt.Errorf("%w: access token was rejected", togglr.ErrInvalidCredentials)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch %s: unexpected status %d", endpoint, res.StatusCode)
+	}
+
+	return json.NewDecoder(res.Body).Decode(v)
+}
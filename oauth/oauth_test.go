@@ -0,0 +1,152 @@
+package oauth_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/oauth"
+)
+
+// fakeProvider serves the token and user endpoints of GitHub and Google. The code "good" is exchanged for the token
+// "token", and verified controls whether the user's email is verified
+func fakeProvider(t *testing.T, verified bool) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatalf("failed to parse token request: %s", err)
+		}
+
+		if r.PostForm.Get("client_secret") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		// github rejects codes with a 200
+		if r.PostForm.Get("code") != "good" {
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "bad_verification_code"})
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]string{"access_token": "token", "token_type": "bearer"})
+	})
+
+	authorized := func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			next(w, r)
+		}
+	}
+
+	mux.HandleFunc("/user", authorized(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"login": "octocat", "name": "", "email": nil})
+	}))
+	mux.HandleFunc("/user/emails", authorized(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode([]map[string]interface{}{
+			{"email": "octocat@users.noreply.github.com", "primary": false, "verified": true},
+			{"email": "octocat@example.com", "primary": true, "verified": verified},
+		})
+	}))
+	mux.HandleFunc("/userinfo", authorized(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"email": "jane@example.com", "email_verified": verified, "name": "Jane"})
+	}))
+
+	return httptest.NewServer(mux)
+}
+
+func Test_ProviderAuthenticate(t *testing.T) {
+	cases := []struct {
+		name             string
+		identity         togglr.IdentityType
+		code             string
+		verified         bool
+		expectedIdentity togglr.ExternalIdentity
+		expectedErr      error
+	}{
+		{
+			name:             "github",
+			identity:         togglr.IdentityTypeGithub,
+			code:             "good",
+			verified:         true,
+			expectedIdentity: togglr.ExternalIdentity{Email: "octocat@example.com", Name: "octocat"},
+		},
+		{
+			name:        "github rejected code",
+			identity:    togglr.IdentityTypeGithub,
+			code:        "bad",
+			verified:    true,
+			expectedErr: togglr.ErrInvalidCredentials,
+		},
+		{
+			name:        "github unverified email",
+			identity:    togglr.IdentityTypeGithub,
+			code:        "good",
+			expectedErr: togglr.ErrInvalidCredentials,
+		},
+		{
+			name:             "google",
+			identity:         togglr.IdentityTypeGoogle,
+			code:             "good",
+			verified:         true,
+			expectedIdentity: togglr.ExternalIdentity{Email: "jane@example.com", Name: "Jane"},
+		},
+		{
+			name:        "google unverified email",
+			identity:    togglr.IdentityTypeGoogle,
+			code:        "good",
+			expectedErr: togglr.ErrInvalidCredentials,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := fakeProvider(t, c.verified)
+			defer s.Close()
+
+			p := oauth.NewGithub("client", "secret", "http://localhost/callback")
+			p.UserURL = s.URL + "/user"
+			p.EmailsURL = s.URL + "/user/emails"
+			if c.identity == togglr.IdentityTypeGoogle {
+				p = oauth.NewGoogle("client", "secret", "http://localhost/callback")
+				p.UserURL = s.URL + "/userinfo"
+			}
+			p.TokenURL = s.URL + "/token"
+
+			identity, err := p.Authenticate(context.TODO(), c.code)
+			if !errors.Is(err, c.expectedErr) {
+				t.Fatalf("expected error %v, got %v", c.expectedErr, err)
+			}
+
+			if identity != c.expectedIdentity {
+				t.Fatalf("expected identity %+v, got %+v", c.expectedIdentity, identity)
+			}
+		})
+	}
+}
+
+func Test_ProviderAuthCodeURL(t *testing.T) {
+	p := oauth.NewGoogle("client", "secret", "http://localhost/callback")
+
+	parsed, err := url.Parse(p.AuthCodeURL("abc"))
+	if err != nil {
+		t.Fatalf("failed to parse auth code url: %s", err)
+	}
+
+	query := parsed.Query()
+	if !strings.HasPrefix(parsed.String(), oauth.GoogleAuthURL+"?") || query.Get("state") != "abc" || query.Get("client_id") != "client" {
+		t.Fatalf("expected auth code url to carry the state and client ID, got %s", parsed)
+	}
+
+	if query.Get("redirect_uri") != "http://localhost/callback" || query.Get("scope") != "openid email profile" {
+		t.Fatalf("expected auth code url to carry the redirect and scopes, got %s", parsed)
+	}
+}
@@ -0,0 +1,25 @@
+module github.com/togglr-io/togglr/openfeature
+
+go 1.24.0
+
+require github.com/togglr-io/togglr v0.0.0
+
+require (
+	github.com/go-chi/chi v1.5.4 // indirect
+	github.com/go-chi/cors v1.2.0 // indirect
+	github.com/gorilla/websocket v1.5.0 // indirect
+	golang.org/x/crypto v0.1.0 // indirect
+)
+
+require (
+	github.com/go-logr/logr v1.4.3 // indirect
+	github.com/google/uuid v1.3.0 // indirect
+	github.com/open-feature/go-sdk v1.16.0
+	go.uber.org/atomic v1.9.0 // indirect
+	go.uber.org/mock v0.6.0 // indirect
+	go.uber.org/multierr v1.7.0 // indirect
+	go.uber.org/zap v1.19.0
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+replace github.com/togglr-io/togglr => ../
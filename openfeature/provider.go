@@ -0,0 +1,289 @@
+// Package openfeature is an OpenFeature provider for togglr. Toggles are evaluated locally by a client.Client, with
+// the same semantics as togglr's Resolver. It's a separate module so that the rest of togglr doesn't have to require
+// the Go version the OpenFeature SDK does
+package openfeature
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	of "github.com/open-feature/go-sdk/openfeature"
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/client"
+	"github.com/togglr-io/togglr/rules"
+)
+
+// the name the provider reports in its Metadata and events
+const providerName = "togglr"
+
+// Reasons reported for toggles turned off by a failed prerequisite and for contexts served the Control of an
+// Experiment without being exposed to it, which OpenFeature has no standard Reasons for
+const (
+	PrerequisiteFailedReason = of.Reason("PREREQUISITE_FAILED")
+	HoldoutReason            = of.Reason("HOLDOUT")
+	ExcludedReason           = of.Reason("EXCLUDED")
+)
+
+// reasons maps the Reasons toggles resolve with to their OpenFeature equivalent
+var reasons = map[togglr.Reason]of.Reason{
+	togglr.ReasonStatic:             of.StaticReason,
+	togglr.ReasonTargetingMatch:     of.TargetingMatchReason,
+	togglr.ReasonPrerequisiteFailed: PrerequisiteFailedReason,
+	togglr.ReasonSplit:              of.SplitReason,
+	togglr.ReasonHoldout:            HoldoutReason,
+	togglr.ReasonExcluded:           ExcludedReason,
+}
+
+// how long Init waits for the first download of definitions
+const initTimeout = 5 * time.Second
+
+// the number of events that can be waiting to be read by the SDK
+const eventBufferSize = 16
+
+// A Provider implements of.FeatureProvider, of.StateHandler and of.EventHandler on top of a client.Client. The
+// Provider runs the Client itself between Init and Shutdown, so Run shouldn't be called on it separately.
+//
+// Boolean flags resolve to whether a toggle is on. Every other type is decoded from the toggle's Payload when it's
+// on, and falls back to the default when it's off or has no Payload. The variant of a flag is the key of the Variant
+// the context was assigned, or "on" or "off" for toggles without Variants, and the reason is the toggle's Reason.
+// The evaluation context is used as the Metadata rules are evaluated against
+type Provider struct {
+	client *client.Client
+	events chan of.Event
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+}
+
+// NewProvider creates a new Provider using the given Client
+func NewProvider(c *client.Client) *Provider {
+	return &Provider{
+		client: c,
+		events: make(chan of.Event, eventBufferSize),
+	}
+}
+
+// Metadata implements the of.FeatureProvider interface
+func (p *Provider) Metadata() of.Metadata {
+	return of.Metadata{Name: providerName}
+}
+
+// Hooks implements the of.FeatureProvider interface
+func (p *Provider) Hooks() []of.Hook {
+	return []of.Hook{}
+}
+
+// EventChannel implements the of.EventHandler interface. Events are emitted when definitions change, when
+// downloading them starts failing and when it recovers
+func (p *Provider) EventChannel() <-chan of.Event {
+	return p.events
+}
+
+// Init implements the of.StateHandler interface. It waits for the first download of definitions and starts keeping
+// them up to date. Init only fails if there are no definitions at all, including bootstrapped or persisted ones, but
+// the Client keeps trying in the background and a ready event is emitted once definitions arrive
+func (p *Provider) Init(evalCtx of.EvaluationContext) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cancel != nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+
+	refreshCtx, cancelRefresh := context.WithTimeout(ctx, initTimeout)
+	err := p.client.Refresh(refreshCtx)
+	cancelRefresh()
+
+	changes, unsubscribe := p.client.Subscribe()
+	status := p.client.Status()
+	go p.watch(ctx, changes, unsubscribe, status)
+	go p.client.Run(ctx)
+
+	if status.Source == client.SourceNone {
+		return fmt.Errorf("no toggle definitions are available: %w", err)
+	}
+
+	return nil
+}
+
+// Shutdown implements the of.StateHandler interface
+func (p *Provider) Shutdown() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cancel != nil {
+		p.cancel()
+		p.cancel = nil
+	}
+}
+
+// watch turns changes to the Client's Status into events until the context is cancelled
+func (p *Provider) watch(ctx context.Context, changes <-chan struct{}, unsubscribe func(), last client.Status) {
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-changes:
+		}
+
+		status := p.client.Status()
+		var event of.Event
+		switch {
+		case status.Version != last.Version || status.Source != last.Source:
+			event.EventType = of.ProviderConfigChange
+			if last.Source == client.SourceNone {
+				event.EventType = of.ProviderReady
+			}
+			event.Message = fmt.Sprintf("loaded version %d from %s", status.Version, status.Source)
+		case status.LastError != nil && last.LastError == nil:
+			// the last definitions loaded keep being served, so they're only stale unless there aren't any
+			event.EventType = of.ProviderStale
+			if status.Source == client.SourceNone {
+				event.EventType = of.ProviderError
+			}
+			event.Message = status.LastError.Error()
+		case status.LastError == nil && last.LastError != nil && status.Source != client.SourceNone:
+			event.EventType = of.ProviderReady
+			event.Message = "definitions are being refreshed again"
+		default:
+			last = status
+			continue
+		}
+		last = status
+
+		event.ProviderName = providerName
+		select {
+		case p.events <- event:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// evaluate resolves a flag and describes how it was resolved. The returned bool is false if the flag couldn't be
+// resolved, in which case the detail carries the error
+func (p *Provider) evaluate(flag string, flatCtx of.FlattenedContext) (client.Result, of.ProviderResolutionDetail, bool) {
+	status := p.client.Status()
+	if status.Source == client.SourceNone {
+		return client.Result{}, failed(of.NewProviderNotReadyResolutionError("no toggle definitions are available")), false
+	}
+
+	result := p.client.Evaluate(flag, rules.MetaFromRaw(map[string]interface{}(flatCtx)))
+	if !result.Found {
+		return result, failed(of.NewFlagNotFoundResolutionError(fmt.Sprintf("toggle %q does not exist", flag))), false
+	}
+
+	detail := of.ProviderResolutionDetail{
+		Reason:  of.UnknownReason,
+		Variant: result.Variant,
+		FlagMetadata: of.FlagMetadata{
+			"source":  string(status.Source),
+			"version": status.Version,
+		},
+	}
+
+	if reason, ok := reasons[result.Reason]; ok {
+		detail.Reason = reason
+	}
+
+	// toggles without Variants of their own are either on or off
+	if detail.Variant == "" {
+		detail.Variant = togglr.VariantOff
+		if result.Value {
+			detail.Variant = togglr.VariantOn
+		}
+	}
+
+	return result, detail, true
+}
+
+func failed(err of.ResolutionError) of.ProviderResolutionDetail {
+	return of.ProviderResolutionDetail{
+		ResolutionError: err,
+		Reason:          of.ErrorReason,
+	}
+}
+
+// decode resolves a flag and unmarshals its Payload into v. The returned bool is false if the default should be
+// used instead, in which case the detail explains why
+func (p *Provider) decode(flag string, flatCtx of.FlattenedContext, v interface{}) (of.ProviderResolutionDetail, bool) {
+	result, detail, ok := p.evaluate(flag, flatCtx)
+	if !ok {
+		return detail, false
+	}
+
+	if !result.Value || len(result.Payload) == 0 {
+		detail.Reason = of.DefaultReason
+		return detail, false
+	}
+
+	if err := json.Unmarshal(result.Payload, v); err != nil {
+		detail.Reason = of.ErrorReason
+		detail.ResolutionError = of.NewTypeMismatchResolutionError(fmt.Sprintf("toggle %q has a payload of the wrong type", flag))
+		return detail, false
+	}
+
+	return detail, true
+}
+
+// BooleanEvaluation implements the of.FeatureProvider interface
+func (p *Provider) BooleanEvaluation(ctx context.Context, flag string, defaultValue bool, flatCtx of.FlattenedContext) of.BoolResolutionDetail {
+	result, detail, ok := p.evaluate(flag, flatCtx)
+	if !ok {
+		return of.BoolResolutionDetail{Value: defaultValue, ProviderResolutionDetail: detail}
+	}
+
+	return of.BoolResolutionDetail{Value: result.Value, ProviderResolutionDetail: detail}
+}
+
+// StringEvaluation implements the of.FeatureProvider interface
+func (p *Provider) StringEvaluation(ctx context.Context, flag string, defaultValue string, flatCtx of.FlattenedContext) of.StringResolutionDetail {
+	var value string
+	detail, ok := p.decode(flag, flatCtx, &value)
+	if !ok {
+		value = defaultValue
+	}
+
+	return of.StringResolutionDetail{Value: value, ProviderResolutionDetail: detail}
+}
+
+// FloatEvaluation implements the of.FeatureProvider interface
+func (p *Provider) FloatEvaluation(ctx context.Context, flag string, defaultValue float64, flatCtx of.FlattenedContext) of.FloatResolutionDetail {
+	var value float64
+	detail, ok := p.decode(flag, flatCtx, &value)
+	if !ok {
+		value = defaultValue
+	}
+
+	return of.FloatResolutionDetail{Value: value, ProviderResolutionDetail: detail}
+}
+
+// IntEvaluation implements the of.FeatureProvider interface
+func (p *Provider) IntEvaluation(ctx context.Context, flag string, defaultValue int64, flatCtx of.FlattenedContext) of.IntResolutionDetail {
+	var value int64
+	detail, ok := p.decode(flag, flatCtx, &value)
+	if !ok {
+		value = defaultValue
+	}
+
+	return of.IntResolutionDetail{Value: value, ProviderResolutionDetail: detail}
+}
+
+// ObjectEvaluation implements the of.FeatureProvider interface
+func (p *Provider) ObjectEvaluation(ctx context.Context, flag string, defaultValue interface{}, flatCtx of.FlattenedContext) of.InterfaceResolutionDetail {
+	var value interface{}
+	detail, ok := p.decode(flag, flatCtx, &value)
+	if !ok {
+		value = defaultValue
+	}
+
+	return of.InterfaceResolutionDetail{Value: value, ProviderResolutionDetail: detail}
+}
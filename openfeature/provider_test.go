@@ -0,0 +1,198 @@
+package openfeature_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	of "github.com/open-feature/go-sdk/openfeature"
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/client"
+	"github.com/togglr-io/togglr/http"
+	"github.com/togglr-io/togglr/mock"
+	"github.com/togglr-io/togglr/openfeature"
+	"github.com/togglr-io/togglr/rules"
+	"github.com/togglr-io/togglr/uid"
+	"go.uber.org/zap"
+)
+
+// a fakeServer serves definitions from a list of toggles that can be changed while it's running
+type fakeServer struct {
+	mu      sync.Mutex
+	version int64
+	toggles []togglr.Toggle
+}
+
+func (f *fakeServer) set(toggles ...togglr.Toggle) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.version++
+	f.toggles = toggles
+}
+
+func (f *fakeServer) routes() *httptest.Server {
+	ts := mock.NewToggleService(nil)
+	ts.ListTogglesFn = func(ctx context.Context, req togglr.ListTogglesReq) ([]togglr.Toggle, error) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		return f.toggles, nil
+	}
+
+	cvs := mock.NewConfigVersionService(nil)
+	cvs.FetchConfigVersionFn = func(ctx context.Context, accountID uid.UID) (int64, error) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		return f.version, nil
+	}
+
+	return httptest.NewServer(http.BuildRoutes(http.Config{
+		Logger: zap.NewNop(),
+		Services: http.Services{
+			ToggleService:        ts,
+			ConfigVersionService: cvs,
+		},
+	}))
+}
+
+func adminToggle(key string, payload string) togglr.Toggle {
+	return togglr.Toggle{
+		ID:      uid.New(),
+		Key:     key,
+		Payload: togglr.Payload(payload),
+		Rules: rules.Rules{
+			{
+				Op: rules.BinOpAnd,
+				Expr: rules.Expression{
+					Type:   rules.ExprTypeBinary,
+					Binary: rules.NewBinary(rules.NewIdent("userType"), rules.NewString("admin"), rules.BinOpEq),
+				},
+			},
+		},
+	}
+}
+
+func Test_ProviderEvaluation(t *testing.T) {
+	// SETUP
+	ctx := context.TODO()
+	fake := &fakeServer{}
+	fake.set(
+		adminToggle("bool", ""),
+		adminToggle("string", `"hello"`),
+		adminToggle("int", "42"),
+		adminToggle("float", "1.5"),
+		adminToggle("object", `{"a":1}`),
+		togglr.Toggle{ID: uid.New(), Key: "static"},
+		togglr.Toggle{ID: uid.New(), Key: "split", Variants: togglr.Variants{{Key: "green", Weight: 1, Value: true}}},
+	)
+	s := fake.routes()
+	defer s.Close()
+
+	provider := openfeature.NewProvider(client.New(client.Config{BaseURL: s.URL, AccountID: uid.New()}))
+	if err := provider.Init(of.EvaluationContext{}); err != nil {
+		t.Fatalf("failed to init provider: %s", err)
+	}
+	defer provider.Shutdown()
+
+	admin := of.FlattenedContext{"userType": "admin"}
+	user := of.FlattenedContext{"userType": "user"}
+
+	// RUN
+	boolean := provider.BooleanEvaluation(ctx, "bool", false, admin)
+	if !boolean.Value || boolean.Variant != togglr.VariantOn || boolean.Reason != of.TargetingMatchReason {
+		t.Fatalf("unexpected bool resolution: %+v", boolean)
+	}
+
+	boolean = provider.BooleanEvaluation(ctx, "bool", true, user)
+	if boolean.Value || boolean.Variant != togglr.VariantOff {
+		t.Fatalf("unexpected bool resolution: %+v", boolean)
+	}
+
+	if static := provider.BooleanEvaluation(ctx, "static", false, user); !static.Value || static.Reason != of.StaticReason {
+		t.Fatalf("unexpected static resolution: %+v", static)
+	}
+
+	split := provider.BooleanEvaluation(ctx, "split", false, of.FlattenedContext{"targetingKey": "user-1"})
+	if !split.Value || split.Variant != "green" || split.Reason != of.SplitReason {
+		t.Fatalf("unexpected split resolution: %+v", split)
+	}
+
+	if str := provider.StringEvaluation(ctx, "string", "default", admin); str.Value != "hello" || str.Error() != nil {
+		t.Fatalf("unexpected string resolution: %+v", str)
+	}
+
+	if str := provider.StringEvaluation(ctx, "string", "default", user); str.Value != "default" || str.Reason != of.DefaultReason {
+		t.Fatalf("unexpected string resolution: %+v", str)
+	}
+
+	if i := provider.IntEvaluation(ctx, "int", 0, admin); i.Value != 42 {
+		t.Fatalf("unexpected int resolution: %+v", i)
+	}
+
+	if f := provider.FloatEvaluation(ctx, "float", 0, admin); f.Value != 1.5 {
+		t.Fatalf("unexpected float resolution: %+v", f)
+	}
+
+	if obj := provider.ObjectEvaluation(ctx, "object", nil, admin); obj.Value.(map[string]interface{})["a"] != float64(1) {
+		t.Fatalf("unexpected object resolution: %+v", obj)
+	}
+
+	mismatch := provider.IntEvaluation(ctx, "string", 7, admin)
+	if mismatch.Value != 7 || mismatch.ResolutionDetail().ErrorCode != of.TypeMismatchCode {
+		t.Fatalf("unexpected mismatched resolution: %+v", mismatch)
+	}
+
+	missing := provider.BooleanEvaluation(ctx, "missing", true, admin)
+	if !missing.Value || missing.ResolutionDetail().ErrorCode != of.FlagNotFoundCode {
+		t.Fatalf("unexpected missing resolution: %+v", missing)
+	}
+}
+
+func Test_ProviderEvents(t *testing.T) {
+	// SETUP
+	fake := &fakeServer{}
+	fake.set(adminToggle("bool", ""))
+	s := fake.routes()
+	defer s.Close()
+
+	provider := openfeature.NewProvider(client.New(client.Config{BaseURL: s.URL, AccountID: uid.New(), PollInterval: 20 * time.Millisecond}))
+	if err := provider.Init(of.EvaluationContext{}); err != nil {
+		t.Fatalf("failed to init provider: %s", err)
+	}
+	defer provider.Shutdown()
+
+	// RUN
+	fake.set(adminToggle("bool", ""), adminToggle("string", ""))
+	expectEvent(t, provider, of.ProviderConfigChange)
+
+	s.Close()
+	expectEvent(t, provider, of.ProviderStale)
+}
+
+func expectEvent(t *testing.T, provider *openfeature.Provider, expected of.EventType) {
+	select {
+	case event := <-provider.EventChannel():
+		if event.EventType != expected {
+			t.Fatalf("expected a %s event, got %s", expected, event.EventType)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for a %s event", expected)
+	}
+}
+
+func Test_ProviderNotReady(t *testing.T) {
+	s := httptest.NewServer(nil)
+	s.Close()
+
+	provider := openfeature.NewProvider(client.New(client.Config{BaseURL: s.URL, AccountID: uid.New()}))
+	if err := provider.Init(of.EvaluationContext{}); err == nil {
+		t.Fatalf("expected init to fail without any definitions")
+	}
+	defer provider.Shutdown()
+
+	res := provider.BooleanEvaluation(context.TODO(), "bool", true, of.FlattenedContext{})
+	if !res.Value || res.ResolutionDetail().ErrorCode != of.ProviderNotReadyCode {
+		t.Fatalf("unexpected resolution: %+v", res)
+	}
+}
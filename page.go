@@ -0,0 +1,145 @@
+package togglr
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/togglr-io/togglr/uid"
+)
+
+// A SortOrder determines the direction that list results are sorted in
+type SortOrder string
+
+// Enumeration of possible SortOrders
+const (
+	SortOrderAsc  = SortOrder("asc")
+	SortOrderDesc = SortOrder("desc")
+)
+
+// Fields that list results can be sorted by. Not every field is available for every type
+const (
+	SortByCreatedAt = "createdAt"
+	SortByUpdatedAt = "updatedAt"
+	SortByKey       = "key"
+	SortByName      = "name"
+	SortByEmail     = "email"
+)
+
+// cursorTimeFormat is a fixed width timestamp format so that cursor values for timestamps sort correctly as strings
+const cursorTimeFormat = "2006-01-02T15:04:05.000000000Z"
+
+// A Page defines the sorting and pagination parameters for a list request. Results are always sorted by the SortBy
+// field and then by ID so that the order is stable. A zero Limit returns every result after the Cursor
+type Page struct {
+	Cursor string    `json:"cursor"`
+	Limit  uint      `json:"limit"`
+	SortBy string    `json:"sortBy"`
+	Order  SortOrder `json:"order"`
+}
+
+// SortField returns the field that results should be sorted by, defaulting to creation time
+func (p Page) SortField() string {
+	if p.SortBy == "" {
+		return SortByCreatedAt
+	}
+
+	return p.SortBy
+}
+
+// Descending returns whether or not results should be sorted in descending order
+func (p Page) Descending() bool {
+	return p.Order == SortOrderDesc
+}
+
+// Validate checks that the Page can be applied to a list of results
+func (p Page) Validate() error {
+	if p.Order != "" && p.Order != SortOrderAsc && p.Order != SortOrderDesc {
+		return fmt.Errorf("%w: unknown sort order %q", ErrInvalidPage, p.Order)
+	}
+
+	if p.Cursor != "" {
+		if _, err := ParseCursor(p.Cursor); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// A Cursor is an opaque marker for the position of the last item in a page of results. It captures the value of the
+// sorted field along with the ID of the item, which breaks ties between items with the same value
+type Cursor struct {
+	Value string  `json:"v"`
+	ID    uid.UID `json:"id"`
+}
+
+// Encode returns the string representation of a Cursor that's handed out to clients
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// ParseCursor decodes a Cursor from its string representation
+func ParseCursor(encoded string) (Cursor, error) {
+	var cursor Cursor
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return cursor, fmt.Errorf("%w: malformed cursor", ErrInvalidPage)
+	}
+
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID.IsNull() {
+		return cursor, fmt.Errorf("%w: malformed cursor", ErrInvalidPage)
+	}
+
+	return cursor, nil
+}
+
+func timeCursor(t time.Time, id uid.UID) Cursor {
+	return Cursor{Value: t.UTC().Format(cursorTimeFormat), ID: id}
+}
+
+// CursorFor returns the Cursor pointing at a Toggle when sorting by the given field
+func (t Toggle) CursorFor(sortBy string) (Cursor, error) {
+	switch sortBy {
+	case "", SortByCreatedAt:
+		return timeCursor(t.CreatedAt, t.ID), nil
+	case SortByUpdatedAt:
+		return timeCursor(t.UpdatedAt, t.ID), nil
+	case SortByKey:
+		return Cursor{Value: t.Key, ID: t.ID}, nil
+	}
+
+	return Cursor{}, fmt.Errorf("%w: toggles cannot be sorted by %q", ErrInvalidPage, sortBy)
+}
+
+// CursorFor returns the Cursor pointing at an Account when sorting by the given field
+func (a Account) CursorFor(sortBy string) (Cursor, error) {
+	switch sortBy {
+	case "", SortByCreatedAt:
+		return timeCursor(a.CreatedAt, a.ID), nil
+	case SortByUpdatedAt:
+		return timeCursor(a.UpdatedAt, a.ID), nil
+	case SortByName:
+		return Cursor{Value: a.Name, ID: a.ID}, nil
+	}
+
+	return Cursor{}, fmt.Errorf("%w: accounts cannot be sorted by %q", ErrInvalidPage, sortBy)
+}
+
+// CursorFor returns the Cursor pointing at a User when sorting by the given field
+func (u User) CursorFor(sortBy string) (Cursor, error) {
+	switch sortBy {
+	case "", SortByCreatedAt:
+		return timeCursor(u.CreatedAt, u.ID), nil
+	case SortByUpdatedAt:
+		return timeCursor(u.UpdatedAt, u.ID), nil
+	case SortByName:
+		return Cursor{Value: u.Name, ID: u.ID}, nil
+	case SortByEmail:
+		return Cursor{Value: u.Email, ID: u.ID}, nil
+	}
+
+	return Cursor{}, fmt.Errorf("%w: users cannot be sorted by %q", ErrInvalidPage, sortBy)
+}
@@ -10,6 +10,12 @@ import (
 	"github.com/togglr-io/togglr/uid"
 )
 
+var accountSortColumns = map[string]sortColumn{
+	togglr.SortByCreatedAt: {"created_at", true},
+	togglr.SortByUpdatedAt: {"updated_at", true},
+	togglr.SortByName:      {"name", false},
+}
+
 // CreateAccount creates a new Account in postgres
 func (c Client) CreateAccount(ctx context.Context, account togglr.Account) (uid.UID, error) {
 	// if no ID is provided, generate one
@@ -31,8 +37,7 @@ func (c Client) UpdateAccount(ctx context.Context, req togglr.UpdateAccountReq)
 		return fmt.Errorf("failed to create transaction: %w", err)
 	}
 
-	query := tx.Update("accounts").Set(updateReqToRecord(req)).Where(goqu.Ex{"id": req.ID})
-	if _, err := query.Executor().ExecContext(ctx); err != nil {
+	if err := updateVersioned(ctx, tx, "accounts", req.ID, req.Version, updateReqToRecord(req)); err != nil {
 		return c.handleTxErr(tx, err)
 	}
 
@@ -57,7 +62,10 @@ func (c Client) FetchAccount(ctx context.Context, id uid.UID) (togglr.Account, e
 func (c Client) ListAccounts(ctx context.Context, req togglr.ListAccountsReq) ([]togglr.Account, error) {
 	// default to instantiated value so that we return an empty slice instead of null when there's no results
 	accounts := []togglr.Account{}
-	query := c.db.From("accounts")
+	query, err := applyPage(c.db.From("accounts"), req.Page, "", accountSortColumns)
+	if err != nil {
+		return nil, err
+	}
 
 	if err := query.ScanStructsContext(ctx, &accounts); err != nil {
 		return nil, err
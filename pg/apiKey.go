@@ -0,0 +1,81 @@
+package pg
+
+import (
+	"context"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/uid"
+)
+
+// CreateAPIKey creates a new APIKey in postgres
+func (c Client) CreateAPIKey(ctx context.Context, key togglr.APIKey) (uid.UID, error) {
+	// if no ID is provided, generate one
+	if key.ID.IsNull() {
+		key.ID = uid.New()
+	}
+
+	query := c.db.Insert("api_keys").Rows(key)
+	if _, err := query.Executor().ExecContext(ctx); err != nil {
+		return key.ID, err
+	}
+
+	return key.ID, nil
+}
+
+// FetchAPIKey queries a single APIKey from postgres
+func (c Client) FetchAPIKey(ctx context.Context, id uid.UID) (togglr.APIKey, error) {
+	return c.fetchAPIKey(ctx, goqu.Ex{"id": id})
+}
+
+// FetchAPIKeyByHash queries a single APIKey from postgres by the hash of its secret
+func (c Client) FetchAPIKeyByHash(ctx context.Context, hash string) (togglr.APIKey, error) {
+	return c.fetchAPIKey(ctx, goqu.Ex{"hash": hash})
+}
+
+func (c Client) fetchAPIKey(ctx context.Context, where goqu.Ex) (togglr.APIKey, error) {
+	var key togglr.APIKey
+	found, err := c.db.From("api_keys").Where(where).ScanStructContext(ctx, &key)
+	if err != nil {
+		return key, err
+	}
+
+	if !found {
+		return key, togglr.ErrNotFound
+	}
+
+	return key, nil
+}
+
+// ListAPIKeys queries the APIKeys of an account from postgres, revoked keys included, newest first
+func (c Client) ListAPIKeys(ctx context.Context, accountID uid.UID) ([]togglr.APIKey, error) {
+	// default to instantiated value so that we return an empty slice instead of null when there's no results
+	keys := []togglr.APIKey{}
+	query := c.db.From("api_keys").Where(goqu.Ex{"account_id": accountID}).Order(goqu.I("created_at").Desc())
+	if err := query.ScanStructsContext(ctx, &keys); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey marks an APIKey as revoked in postgres. Revoking a key that's already revoked keeps the original
+// revocation time
+func (c Client) RevokeAPIKey(ctx context.Context, id uid.UID) error {
+	query := c.db.Update("api_keys").Set(goqu.Record{"revoked_at": goqu.L("COALESCE(revoked_at, NOW())")}).Where(goqu.Ex{"id": id})
+	res, err := query.Executor().ExecContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return togglr.ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/uid"
+)
+
+// CreateChangeRequest creates a new ChangeRequest in postgres
+func (c Client) CreateChangeRequest(ctx context.Context, cr togglr.ChangeRequest) (uid.UID, error) {
+	// if no ID is provided, generate one
+	if cr.ID.IsNull() {
+		cr.ID = uid.New()
+	}
+
+	query := c.db.Insert("change_requests").Rows(cr)
+	if _, err := query.Executor().ExecContext(ctx); err != nil {
+		return cr.ID, err
+	}
+
+	return cr.ID, nil
+}
+
+// UpdateChangeRequest updates an existing ChangeRequest in postgres. If the request has a FromStatus and the
+// ChangeRequest has already moved on, nothing is updated and ErrInvalidTransition is returned
+func (c Client) UpdateChangeRequest(ctx context.Context, req togglr.UpdateChangeRequestReq) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+
+	where := goqu.Ex{"id": req.ID}
+	if req.FromStatus != "" {
+		where["status"] = req.FromStatus
+	}
+
+	query := tx.Update("change_requests").Set(updateReqToRecord(req)).Where(where)
+	res, err := query.Executor().ExecContext(ctx)
+	if err != nil {
+		return c.handleTxErr(tx, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return c.handleTxErr(tx, err)
+	}
+
+	if affected == 0 && req.FromStatus != "" {
+		return c.handleTxErr(tx, togglr.ErrInvalidTransition)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit: %w", err)
+	}
+
+	return nil
+}
+
+// FetchChangeRequest queries a single ChangeRequest from postgres
+func (c Client) FetchChangeRequest(ctx context.Context, id uid.UID) (togglr.ChangeRequest, error) {
+	var cr togglr.ChangeRequest
+	query := c.db.From("change_requests").Where(goqu.Ex{"id": id})
+	found, err := query.ScanStructContext(ctx, &cr)
+	if err != nil {
+		return cr, err
+	}
+
+	if !found {
+		return cr, togglr.ErrNotFound
+	}
+
+	return cr, nil
+}
+
+// ListChangeRequests queries a slice of ChangeRequests from postgres, newest first
+func (c Client) ListChangeRequests(ctx context.Context, req togglr.ListChangeRequestsReq) ([]togglr.ChangeRequest, error) {
+	// default to instantiated value so that we return an empty slice instead of null when there's no results
+	crs := []togglr.ChangeRequest{}
+	query := c.db.From("change_requests").Order(goqu.I("created_at").Desc())
+	if !req.AccountID.IsNull() {
+		query = query.Where(goqu.Ex{"account_id": req.AccountID})
+	}
+
+	if !req.ToggleID.IsNull() {
+		query = query.Where(goqu.Ex{"toggle_id": req.ToggleID})
+	}
+
+	if req.Status != "" {
+		query = query.Where(goqu.Ex{"status": req.Status})
+	}
+
+	if err := query.ScanStructsContext(ctx, &crs); err != nil {
+		return nil, err
+	}
+
+	return crs, nil
+}
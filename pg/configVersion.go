@@ -0,0 +1,20 @@
+package pg
+
+import (
+	"context"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/togglr-io/togglr/uid"
+)
+
+// FetchConfigVersion queries the configuration version of an account from postgres. Accounts whose toggles have
+// never changed are at version 0
+func (c Client) FetchConfigVersion(ctx context.Context, accountID uid.UID) (int64, error) {
+	var version int64
+	query := c.db.From("config_versions").Select("version").Where(goqu.Ex{"account_id": accountID})
+	if _, err := query.ScanValContext(ctx, &version); err != nil {
+		return 0, err
+	}
+
+	return version, nil
+}
@@ -0,0 +1,50 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/togglr-io/togglr"
+)
+
+// RecordEvaluationCounts adds evaluation counts to the ones already in postgres
+func (c Client) RecordEvaluationCounts(ctx context.Context, counts ...togglr.EvaluationCount) error {
+	if len(counts) == 0 {
+		return nil
+	}
+
+	query := c.db.Insert("evaluation_counts").Rows(counts).OnConflict(goqu.DoUpdate("toggle_id, bucket, variant, reason", goqu.Record{
+		"count": goqu.L("evaluation_counts.count + EXCLUDED.count"),
+	}))
+	if _, err := query.Executor().ExecContext(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ListEvaluationCounts queries the evaluation counts of a Toggle, adding buckets together to match the requested
+// interval. Counts are ordered by bucket
+func (c Client) ListEvaluationCounts(ctx context.Context, req togglr.ListEvaluationCountsReq) ([]togglr.EvaluationCount, error) {
+	if req.Interval != togglr.IntervalHour && req.Interval != togglr.IntervalDay {
+		return nil, fmt.Errorf("unsupported interval %q", req.Interval)
+	}
+
+	bucket := goqu.L("date_trunc(?, bucket)", string(req.Interval))
+	counts := []togglr.EvaluationCount{}
+	query := c.db.From("evaluation_counts").
+		Select("toggle_id", "account_id", bucket.As("bucket"), "variant", "reason", goqu.SUM("count").As("count")).
+		Where(
+			goqu.Ex{"toggle_id": req.ToggleID},
+			goqu.C("bucket").Gte(req.From),
+			goqu.C("bucket").Lt(req.To),
+		).
+		GroupBy("toggle_id", "account_id", bucket, "variant", "reason").
+		Order(bucket.Asc(), goqu.C("variant").Asc(), goqu.C("reason").Asc())
+	if err := query.ScanStructsContext(ctx, &counts); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
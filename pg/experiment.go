@@ -0,0 +1,181 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/uid"
+)
+
+// CreateExperiment creates a new Experiment in postgres
+func (c Client) CreateExperiment(ctx context.Context, exp togglr.Experiment) (uid.UID, error) {
+	// if no ID is provided, generate one
+	if exp.ID.IsNull() {
+		exp.ID = uid.New()
+	}
+
+	query := c.db.Insert("experiments").Rows(exp)
+	if _, err := query.Executor().ExecContext(ctx); err != nil {
+		return exp.ID, err
+	}
+
+	return exp.ID, nil
+}
+
+// UpdateExperiment updates an existing Experiment in postgres, along with its Toggle when the request has an update
+// for it. Running Experiments can't share slots in a Layer, so starting one in slots that were claimed in the
+// meantime fails with ErrLayerFull
+func (c Client) UpdateExperiment(ctx context.Context, req togglr.UpdateExperimentReq) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+
+	where := goqu.Ex{"id": req.ID}
+	if req.FromStatus != "" {
+		where["status"] = req.FromStatus
+	}
+
+	query := tx.Update("experiments").Set(updateReqToRecord(req)).Where(where)
+	res, err := query.Executor().ExecContext(ctx)
+	if isExclusionViolation(err) {
+		return c.handleTxErr(tx, togglr.ErrLayerFull)
+	}
+
+	if err != nil {
+		return c.handleTxErr(tx, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return c.handleTxErr(tx, err)
+	}
+
+	if affected == 0 && req.FromStatus != "" {
+		return c.handleTxErr(tx, togglr.ErrInvalidTransition)
+	}
+
+	if req.Toggle != nil {
+		if err := updateVersioned(ctx, tx, "toggles", req.Toggle.ID, req.Toggle.Version, updateReqToRecord(*req.Toggle)); err != nil {
+			return c.handleTxErr(tx, err)
+		}
+
+		if err := c.recordRevision(ctx, tx, req.Toggle.ID); err != nil {
+			return c.handleTxErr(tx, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit: %w", err)
+	}
+
+	return nil
+}
+
+// FetchExperiment queries a single Experiment from postgres
+func (c Client) FetchExperiment(ctx context.Context, id uid.UID) (togglr.Experiment, error) {
+	var exp togglr.Experiment
+	query := c.db.From("experiments").Where(goqu.Ex{"id": id})
+	found, err := query.ScanStructContext(ctx, &exp)
+	if err != nil {
+		return exp, err
+	}
+
+	if !found {
+		return exp, togglr.ErrNotFound
+	}
+
+	return exp, nil
+}
+
+// ListExperiments queries a slice of Experiments from postgres, newest first
+func (c Client) ListExperiments(ctx context.Context, req togglr.ListExperimentsReq) ([]togglr.Experiment, error) {
+	// default to instantiated value so that we return an empty slice instead of null when there's no results
+	exps := []togglr.Experiment{}
+	query := c.db.From("experiments").Order(goqu.I("created_at").Desc())
+	if !req.AccountID.IsNull() {
+		query = query.Where(goqu.Ex{"account_id": req.AccountID})
+	}
+
+	if !req.ToggleID.IsNull() {
+		query = query.Where(goqu.Ex{"toggle_id": req.ToggleID})
+	}
+
+	if req.Layer != "" {
+		query = query.Where(goqu.Ex{"layer": req.Layer})
+	}
+
+	if req.Status != "" {
+		query = query.Where(goqu.Ex{"status": req.Status})
+	}
+
+	if err := query.ScanStructsContext(ctx, &exps); err != nil {
+		return nil, err
+	}
+
+	return exps, nil
+}
+
+// RecordExposures inserts exposures into postgres, keeping the earliest exposure of contexts that were already
+// exposed
+func (c Client) RecordExposures(ctx context.Context, exposures ...togglr.Exposure) error {
+	if len(exposures) == 0 {
+		return nil
+	}
+
+	query := c.db.Insert("experiment_exposures").Rows(exposures).OnConflict(goqu.DoUpdate("experiment_id, context_key", goqu.Record{
+		"exposed_at": goqu.L("LEAST(experiment_exposures.exposed_at, EXCLUDED.exposed_at)"),
+	}))
+	if _, err := query.Executor().ExecContext(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RecordMetricEvents inserts metric events into postgres
+func (c Client) RecordMetricEvents(ctx context.Context, events ...togglr.MetricEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	if _, err := c.db.Insert("metric_events").Rows(events).Executor().ExecContext(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// tallyQuery joins every exposure of an Experiment with the metric events reported for its context between the
+// exposure and the end of the Experiment
+const tallyQuery = `
+SELECT e.variant AS variant,
+	COUNT(*) AS exposures,
+	COUNT(*) FILTER (WHERE m.events > 0) AS conversions,
+	COALESCE(SUM(m.total), 0) AS value
+FROM experiment_exposures e
+LEFT JOIN LATERAL (
+	SELECT COUNT(*) AS events, SUM(value) AS total
+	FROM metric_events
+	WHERE account_id = $1 AND metric = $2 AND context_key = e.context_key AND occurred_at >= e.exposed_at AND occurred_at < $3
+) m ON TRUE
+WHERE e.experiment_id = $4
+GROUP BY e.variant`
+
+// TallyExperiment counts the exposures and conversions of each Variant of an Experiment in postgres
+func (c Client) TallyExperiment(ctx context.Context, exp togglr.Experiment) ([]togglr.VariantTally, error) {
+	end := time.Now()
+	if exp.StoppedAt != nil {
+		end = *exp.StoppedAt
+	}
+
+	tallies := []togglr.VariantTally{}
+	if err := c.db.ScanStructsContext(ctx, &tallies, tallyQuery, exp.AccountID, exp.Metric, end, exp.ID); err != nil {
+		return nil, err
+	}
+
+	return tallies, nil
+}
@@ -0,0 +1,70 @@
+package pg
+
+import (
+	"context"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/uid"
+)
+
+// CreateHoldout creates a new Holdout in postgres
+func (c Client) CreateHoldout(ctx context.Context, holdout togglr.Holdout) (uid.UID, error) {
+	// if no ID is provided, generate one
+	if holdout.ID.IsNull() {
+		holdout.ID = uid.New()
+	}
+
+	query := c.db.Insert("holdouts").Rows(holdout)
+	if _, err := query.Executor().ExecContext(ctx); err != nil {
+		return holdout.ID, err
+	}
+
+	return holdout.ID, nil
+}
+
+// FetchHoldout queries a single Holdout from postgres
+func (c Client) FetchHoldout(ctx context.Context, id uid.UID) (togglr.Holdout, error) {
+	var holdout togglr.Holdout
+	found, err := c.db.From("holdouts").Where(goqu.Ex{"id": id}).ScanStructContext(ctx, &holdout)
+	if err != nil {
+		return holdout, err
+	}
+
+	if !found {
+		return holdout, togglr.ErrNotFound
+	}
+
+	return holdout, nil
+}
+
+// ListHoldouts queries the Holdouts of an account from postgres, oldest first
+func (c Client) ListHoldouts(ctx context.Context, accountID uid.UID) ([]togglr.Holdout, error) {
+	// default to instantiated value so that we return an empty slice instead of null when there's no results
+	holdouts := []togglr.Holdout{}
+	query := c.db.From("holdouts").Where(goqu.Ex{"account_id": accountID}).Order(goqu.I("created_at").Asc())
+	if err := query.ScanStructsContext(ctx, &holdouts); err != nil {
+		return nil, err
+	}
+
+	return holdouts, nil
+}
+
+// DeleteHoldout deletes a Holdout from postgres
+func (c Client) DeleteHoldout(ctx context.Context, id uid.UID) error {
+	res, err := c.db.Delete("holdouts").Where(goqu.Ex{"id": id}).Executor().ExecContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return togglr.ErrNotFound
+	}
+
+	return nil
+}
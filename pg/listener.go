@@ -0,0 +1,149 @@
+package pg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/togglr-io/togglr"
+	"go.uber.org/zap"
+)
+
+// changeChannel is the channel that notify_change_trigger publishes change notifications to
+const changeChannel = "togglr_changes"
+
+// how often the listener connection is checked for liveness between notifications
+const listenerPingInterval = 90 * time.Second
+
+// the number of events buffered for each subscriber before they start being dropped
+const subscriberBuffer = 256
+
+type subscriber struct {
+	events chan togglr.ChangeEvent
+	missed bool
+}
+
+// A Listener receives change notifications from postgres and fans them out to subscribers as togglr.ChangeEvents.
+// The connection is re-established automatically whenever it's lost. Notifications sent while disconnected are
+// gone for good, so subscribers receive a resync event after every reconnect
+type Listener struct {
+	listener *pq.Listener
+	log      *zap.Logger
+
+	mu          sync.Mutex
+	subscribers []*subscriber
+}
+
+// NewListener returns a new Listener for the database described by the given Config. No notifications are received
+// until the Listener is started with Run
+func NewListener(cfg Config, logger *zap.Logger) *Listener {
+	l := &Listener{log: logger}
+	l.listener = pq.NewListener(cfg.DSN(), 100*time.Millisecond, 10*time.Second, l.handleEvent)
+	return l
+}
+
+func (l *Listener) handleEvent(event pq.ListenerEventType, err error) {
+	switch event {
+	case pq.ListenerEventDisconnected:
+		l.log.Warn("lost connection for change notifications", zap.Error(err))
+	case pq.ListenerEventReconnected:
+		l.log.Info("reconnected for change notifications")
+	case pq.ListenerEventConnectionAttemptFailed:
+		l.log.Error("failed to connect for change notifications", zap.Error(err))
+	}
+}
+
+// Subscribe returns a channel that receives every ChangeEvent published after the call. Subscribers that fall too
+// far behind have events dropped and receive a single resync event in their place once they catch up. The channel
+// is closed when the Listener stops running
+func (l *Listener) Subscribe() <-chan togglr.ChangeEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	sub := &subscriber{events: make(chan togglr.ChangeEvent, subscriberBuffer)}
+	l.subscribers = append(l.subscribers, sub)
+	return sub.events
+}
+
+func (l *Listener) publish(event togglr.ChangeEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, sub := range l.subscribers {
+		ev := event
+		if sub.missed {
+			// a resync covers the current event along with everything that was dropped before it
+			ev = togglr.ChangeEvent{Resync: true}
+		}
+
+		select {
+		case sub.events <- ev:
+			sub.missed = false
+		default:
+			sub.missed = true
+		}
+	}
+}
+
+func (l *Listener) closeSubscribers() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, sub := range l.subscribers {
+		close(sub.events)
+	}
+	l.subscribers = nil
+}
+
+// Run listens for change notifications and publishes them to subscribers until the context is cancelled
+func (l *Listener) Run(ctx context.Context) error {
+	defer l.log.Sync()
+	defer l.closeSubscribers()
+
+	// closing the listener also unblocks a pending Listen
+	go func() {
+		<-ctx.Done()
+		l.listener.Close()
+	}()
+
+	// Listen blocks until a connection is established, so it's done here rather than when the Listener is created
+	if err := l.listener.Listen(changeChannel); err != nil {
+		return fmt.Errorf("failed to listen for changes: %w", err)
+	}
+
+	ticker := time.NewTicker(listenerPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case notification, ok := <-l.listener.Notify:
+			if !ok {
+				return nil
+			}
+
+			// pq sends a nil notification after reconnecting
+			if notification == nil {
+				l.publish(togglr.ChangeEvent{Resync: true})
+				continue
+			}
+
+			var event togglr.ChangeEvent
+			if err := json.Unmarshal([]byte(notification.Extra), &event); err != nil {
+				l.log.Error("failed to unmarshal change notification", zap.Error(err))
+				continue
+			}
+
+			l.publish(event)
+		case <-ticker.C:
+			// pinging makes sure a dead connection is noticed even when there are no notifications
+			if err := l.listener.Ping(); err != nil {
+				l.log.Warn("failed to ping change notification connection", zap.Error(err))
+			}
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package pg
+
+import (
+	"context"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/uid"
+)
+
+// SetPasswordHash creates or replaces the password hash of a User in postgres
+func (c Client) SetPasswordHash(ctx context.Context, userID uid.UID, hash string) error {
+	query := c.db.Insert("user_passwords").
+		Rows(goqu.Record{"user_id": userID, "hash": hash}).
+		OnConflict(goqu.DoUpdate("user_id", goqu.Record{
+			"hash":       goqu.L("EXCLUDED.hash"),
+			"updated_at": goqu.L("NOW()"),
+		}))
+
+	if _, err := query.Executor().ExecContext(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// FetchPasswordHash queries the password hash of a User from postgres
+func (c Client) FetchPasswordHash(ctx context.Context, userID uid.UID) (string, error) {
+	var hash string
+	found, err := c.db.From("user_passwords").Select("hash").Where(goqu.Ex{"user_id": userID}).ScanValContext(ctx, &hash)
+	if err != nil {
+		return "", err
+	}
+
+	if !found {
+		return "", togglr.ErrNotFound
+	}
+
+	return hash, nil
+}
@@ -1,19 +1,27 @@
 package pg
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"reflect"
 	"strings"
 
 	"github.com/doug-martin/goqu/v9"
+	"github.com/lib/pq"
+	"github.com/togglr-io/togglr"
 	"github.com/togglr-io/togglr/env"
-
-	// importing postgres driver implementation for database/sql and goqu
-	_ "github.com/lib/pq"
+	"github.com/togglr-io/togglr/uid"
 	"go.uber.org/zap"
 )
 
+// postgres error codes for violated unique and exclusion constraints
+const (
+	uniqueViolation    = "23505"
+	exclusionViolation = "23P01"
+)
+
 // A Config captures information required to make a postgres connection
 type Config struct {
 	Host     string
@@ -92,6 +100,96 @@ func updateReqToRecord(st interface{}) goqu.Record {
 	return rec
 }
 
+// updateVersioned updates a single row as part of a transaction and bumps its version. When a non-zero version is
+// given, the update only applies if the row is still at that version so that concurrent modifications aren't
+// silently overwritten
+func updateVersioned(ctx context.Context, tx *goqu.TxDatabase, table string, id uid.UID, version int, rec goqu.Record) error {
+	rec["version"] = goqu.L("version + 1")
+	where := goqu.Ex{"id": id}
+	if version != 0 {
+		where["version"] = version
+	}
+
+	res, err := tx.Update(table).Set(rec).Where(where).Executor().ExecContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected > 0 {
+		return nil
+	}
+
+	// nothing was updated, so the row is either missing or has moved on to a newer version
+	count, err := tx.From(table).Where(goqu.Ex{"id": id}).CountContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return togglr.ErrNotFound
+	}
+
+	return togglr.ErrConflict
+}
+
+// A sortColumn maps a sortable field onto the column backing it
+type sortColumn struct {
+	name      string
+	timestamp bool
+}
+
+// applyPage adds the sorting, cursor and limit of a Page to a query. Results are ordered by the sort column and then
+// by ID so that cursors always point at a unique position
+func applyPage(query *goqu.SelectDataset, page togglr.Page, prefix string, columns map[string]sortColumn) (*goqu.SelectDataset, error) {
+	if err := page.Validate(); err != nil {
+		return nil, err
+	}
+
+	col, ok := columns[page.SortField()]
+	if !ok {
+		return nil, fmt.Errorf("%w: cannot sort by %q", togglr.ErrInvalidPage, page.SortField())
+	}
+
+	sortCol := goqu.I(prefix + col.name)
+	idCol := goqu.I(prefix + "id")
+
+	if page.Cursor != "" {
+		cursor, err := togglr.ParseCursor(page.Cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		cmp := ">"
+		if page.Descending() {
+			cmp = "<"
+		}
+
+		placeholder := "?"
+		if col.timestamp {
+			placeholder = "?::timestamp"
+		}
+
+		query = query.Where(goqu.L(fmt.Sprintf("(?, ?) %s (%s, ?)", cmp, placeholder), sortCol, idCol, cursor.Value, cursor.ID))
+	}
+
+	if page.Descending() {
+		query = query.Order(sortCol.Desc(), idCol.Desc())
+	} else {
+		query = query.Order(sortCol.Asc(), idCol.Asc())
+	}
+
+	if page.Limit > 0 {
+		query = query.Limit(page.Limit)
+	}
+
+	return query, nil
+}
+
 func (c Client) handleTxErr(tx *goqu.TxDatabase, err error) error {
 	if rbErr := tx.Rollback(); rbErr != nil {
 		c.log.Error("rollback failure", zap.Error(rbErr))
@@ -100,3 +198,15 @@ func (c Client) handleTxErr(tx *goqu.TxDatabase, err error) error {
 
 	return fmt.Errorf("failed with rollback: %w", err)
 }
+
+// isUniqueViolation returns true if the error was caused by a violated unique constraint
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation
+}
+
+// isExclusionViolation returns true if the error was caused by a violated exclusion constraint
+func isExclusionViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == exclusionViolation
+}
@@ -0,0 +1,69 @@
+package pg
+
+import (
+	"context"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/uid"
+)
+
+// CreateSession creates a new Session in postgres
+func (c Client) CreateSession(ctx context.Context, session togglr.Session) (uid.UID, error) {
+	// if no ID is provided, generate one
+	if session.ID.IsNull() {
+		session.ID = uid.New()
+	}
+
+	query := c.db.Insert("sessions").Rows(session)
+	if _, err := query.Executor().ExecContext(ctx); err != nil {
+		return session.ID, err
+	}
+
+	return session.ID, nil
+}
+
+// FetchSession queries a single Session from postgres
+func (c Client) FetchSession(ctx context.Context, id uid.UID) (togglr.Session, error) {
+	var session togglr.Session
+	found, err := c.db.From("sessions").Where(goqu.Ex{"id": id}).ScanStructContext(ctx, &session)
+	if err != nil {
+		return session, err
+	}
+
+	if !found {
+		return session, togglr.ErrNotFound
+	}
+
+	return session, nil
+}
+
+// DeleteSession deletes a Session from postgres, along with any of the User's Sessions that have expired
+func (c Client) DeleteSession(ctx context.Context, id uid.UID) error {
+	userIDs := c.db.From("sessions").Select("user_id").Where(goqu.Ex{"id": id})
+	expired := c.db.Delete("sessions").Where(goqu.Ex{"user_id": userIDs, "expires_at": goqu.Op{"lt": time.Now().UTC()}})
+	if _, err := expired.Executor().ExecContext(ctx); err != nil {
+		return err
+	}
+
+	if _, err := c.db.Delete("sessions").Where(goqu.Ex{"id": id}).Executor().ExecContext(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteUserSessions deletes every Session of a User from postgres except the one with the keep ID
+func (c Client) DeleteUserSessions(ctx context.Context, userID uid.UID, keep uid.UID) error {
+	query := c.db.Delete("sessions").Where(goqu.Ex{"user_id": userID})
+	if !keep.IsNull() {
+		query = query.Where(goqu.C("id").Neq(keep))
+	}
+
+	if _, err := query.Executor().ExecContext(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -2,18 +2,44 @@ package pg
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
 	"github.com/togglr-io/togglr"
 	"github.com/togglr-io/togglr/uid"
 )
 
+// likeEscaper escapes the wildcard characters of a LIKE pattern so that user input is matched literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+var toggleSortColumns = map[string]sortColumn{
+	togglr.SortByCreatedAt: {"created_at", true},
+	togglr.SortByUpdatedAt: {"updated_at", true},
+	togglr.SortByKey:       {"key", false},
+}
+
 // CreateToggle creates a new Toggle in postgres. If the toggle doen't already have an ID, one will be
 // generated
 func (c Client) CreateToggle(ctx context.Context, toggle togglr.Toggle) (uid.UID, error) {
-	if _, err := c.db.Insert("toggles").Rows(toggle).Executor().ExecContext(ctx); err != nil {
-		return toggle.ID, err
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return toggle.ID, fmt.Errorf("failed to create transaction: %w", err)
+	}
+
+	if _, err := tx.Insert("toggles").Rows(toggle).Executor().ExecContext(ctx); err != nil {
+		return toggle.ID, c.handleTxErr(tx, err)
+	}
+
+	if err := c.recordRevision(ctx, tx, toggle.ID); err != nil {
+		return toggle.ID, c.handleTxErr(tx, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return toggle.ID, fmt.Errorf("failed to commit: %w", err)
 	}
 
 	return toggle.ID, nil
@@ -27,13 +53,12 @@ func (c Client) UpdateToggle(ctx context.Context, req togglr.UpdateToggleReq) er
 	}
 
 	// TODO (etate): This is a super naive update. Should probably be a bit more perscriptive.
-	query := tx.Update("toggles").Set(updateReqToRecord(req)).Where(goqu.Ex{"id": req.ID})
-	if _, err := query.Executor().Exec(); err != nil {
-		if rbErr := tx.Rollback(); err != nil {
-			return fmt.Errorf("rollback failed with err: %s %w", rbErr, err)
-		}
+	if err := updateVersioned(ctx, tx, "toggles", req.ID, req.Version, updateReqToRecord(req)); err != nil {
+		return c.handleTxErr(tx, err)
+	}
 
-		return fmt.Errorf("failed with rollback: %w", err)
+	if err := c.recordRevision(ctx, tx, req.ID); err != nil {
+		return c.handleTxErr(tx, err)
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -54,23 +79,100 @@ func (c Client) FetchToggle(ctx context.Context, id uid.UID) (togglr.Toggle, err
 	return tog, nil
 }
 
+// FetchToggleByKey queries a single Toggle from postgres by its account and key
+func (c Client) FetchToggleByKey(ctx context.Context, accountID uid.UID, key string) (togglr.Toggle, error) {
+	var tog togglr.Toggle
+	// archived toggles can share a key with the active one, which takes precedence
+	query := c.db.From("toggles").
+		Where(goqu.Ex{"account_id": accountID, "key": key}).
+		Order(goqu.I("archived_at").Desc().NullsFirst())
+	found, err := query.ScanStructContext(ctx, &tog)
+	if err != nil {
+		return tog, err
+	}
+
+	if !found {
+		return tog, togglr.ErrNotFound
+	}
+
+	return tog, nil
+}
+
 // ListToggles queries a slice of Toggles from postgres
 func (c Client) ListToggles(ctx context.Context, req togglr.ListTogglesReq) ([]togglr.Toggle, error) {
 	// default to instantiated value so that we return an empty slice instead of null when there's no results
 	toggles := []togglr.Toggle{}
 	query := c.db.From("toggles")
 	if !req.AccountID.IsNull() {
-		query.Where(goqu.Ex{"account_id": req.AccountID})
+		query = query.Where(goqu.Ex{"account_id": req.AccountID})
+	}
+
+	if req.Tag != "" {
+		tag, err := json.Marshal([]string{req.Tag})
+		if err != nil {
+			return nil, err
+		}
+
+		query = query.Where(goqu.L("tags @> ?::jsonb", string(tag)))
+	}
+
+	if req.Owner != "" {
+		query = query.Where(goqu.Ex{"owner": req.Owner})
 	}
 
-	if err := query.ScanStructs(&toggles); err != nil {
+	if req.Kind != "" {
+		query = query.Where(goqu.Ex{"kind": req.Kind})
+	}
+
+	if req.Active != nil {
+		query = query.Where(goqu.Ex{"active": *req.Active})
+	}
+
+	if req.Archived {
+		query = query.Where(goqu.C("archived_at").IsNotNull())
+	} else {
+		query = query.Where(goqu.C("archived_at").IsNull())
+	}
+
+	if req.Search != "" {
+		pattern := "%" + likeEscaper.Replace(req.Search) + "%"
+		query = query.Where(goqu.Or(
+			goqu.I("key").ILike(pattern),
+			goqu.I("description").ILike(pattern),
+		))
+	}
+
+	query, err := applyPage(query, req.Page, "", toggleSortColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := query.ScanStructsContext(ctx, &toggles); err != nil {
+		return nil, err
+	}
+
+	return toggles, nil
+}
+
+// ListDependents queries every Toggle in an account, archived or not, that lists the given key as a prerequisite
+func (c Client) ListDependents(ctx context.Context, accountID uid.UID, key string) ([]togglr.Toggle, error) {
+	toggles := []togglr.Toggle{}
+	prerequisite, err := json.Marshal([]string{key})
+	if err != nil {
+		return nil, err
+	}
+
+	query := c.db.From("toggles").
+		Where(goqu.Ex{"account_id": accountID}).
+		Where(goqu.L("prerequisites @> ?::jsonb", string(prerequisite)))
+	if err := query.ScanStructsContext(ctx, &toggles); err != nil {
 		return nil, err
 	}
 
 	return toggles, nil
 }
 
-// DeleteToggle deletes a Toggle from postgres
+// DeleteToggle deletes a Toggle from postgres. Its revisions are removed along with it
 func (c Client) DeleteToggle(ctx context.Context, id uid.UID) error {
 	del := c.db.Delete("toggles").Where(goqu.Ex{"id": id}).Executor()
 	if _, err := del.Exec(); err != nil {
@@ -79,3 +181,129 @@ func (c Client) DeleteToggle(ctx context.Context, id uid.UID) error {
 
 	return nil
 }
+
+// ArchiveToggle marks a Toggle as archived in postgres. Archiving a Toggle that's already archived keeps the
+// original archive time
+func (c Client) ArchiveToggle(ctx context.Context, id uid.UID) error {
+	return c.setArchivedAt(ctx, id, goqu.L("COALESCE(archived_at, NOW())"))
+}
+
+// RestoreToggle clears the archived state of a Toggle in postgres. ErrKeyTaken is returned if another Toggle was
+// given the same key while it was archived
+func (c Client) RestoreToggle(ctx context.Context, id uid.UID) error {
+	err := c.setArchivedAt(ctx, id, nil)
+	if isUniqueViolation(err) {
+		return togglr.ErrKeyTaken
+	}
+
+	return err
+}
+
+func (c Client) setArchivedAt(ctx context.Context, id uid.UID, archivedAt interface{}) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+
+	if err := updateVersioned(ctx, tx, "toggles", id, 0, goqu.Record{"archived_at": archivedAt}); err != nil {
+		return c.handleTxErr(tx, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit: %w", err)
+	}
+
+	return nil
+}
+
+// recordRevision snapshots the current state of a Toggle into the toggle_revisions table as part of the given
+// transaction. Revision numbers start at 1 and increase by one for every change made to a Toggle. The Toggle's row is
+// locked until the transaction ends so that concurrent changes can't both claim the next revision number
+func (c Client) recordRevision(ctx context.Context, tx *goqu.TxDatabase, toggleID uid.UID) error {
+	var tog togglr.Toggle
+	found, err := tx.From("toggles").Where(goqu.Ex{"id": toggleID}).ForUpdate(exp.Wait).ScanStructContext(ctx, &tog)
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		return fmt.Errorf("toggle %s does not exist", toggleID)
+	}
+
+	var latest sql.NullInt64
+	if _, err := tx.From("toggle_revisions").
+		Select(goqu.MAX("revision")).
+		Where(goqu.Ex{"toggle_id": toggleID}).
+		ScanValContext(ctx, &latest); err != nil {
+		return err
+	}
+
+	revision := togglr.ToggleRevision{
+		ID:          uid.New(),
+		ToggleID:    toggleID,
+		Revision:    int(latest.Int64) + 1,
+		Description: tog.Description,
+		Active:      tog.Active,
+		Rules:       tog.Rules,
+	}
+
+	if _, err := tx.Insert("toggle_revisions").Rows(revision).Executor().ExecContext(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ListToggleRevisions queries all of the revisions recorded for a Toggle, newest first
+func (c Client) ListToggleRevisions(ctx context.Context, toggleID uid.UID) ([]togglr.ToggleRevision, error) {
+	revisions := []togglr.ToggleRevision{}
+	query := c.db.From("toggle_revisions").
+		Where(goqu.Ex{"toggle_id": toggleID}).
+		Order(goqu.I("revision").Desc())
+
+	if err := query.ScanStructsContext(ctx, &revisions); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// RollbackToggle restores a Toggle's description, active state and rules from a previous revision and records
+// the result as a new revision
+func (c Client) RollbackToggle(ctx context.Context, toggleID uid.UID, revision int) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+
+	var rev togglr.ToggleRevision
+	found, err := tx.From("toggle_revisions").
+		Where(goqu.Ex{"toggle_id": toggleID, "revision": revision}).
+		ScanStructContext(ctx, &rev)
+	if err != nil {
+		return c.handleTxErr(tx, err)
+	}
+
+	if !found {
+		return c.handleTxErr(tx, fmt.Errorf("revision %d does not exist for toggle %s: %w", revision, toggleID, togglr.ErrNotFound))
+	}
+
+	rec := goqu.Record{
+		"description": rev.Description,
+		"active":      rev.Active,
+		"rules":       rev.Rules,
+	}
+	if err := updateVersioned(ctx, tx, "toggles", toggleID, 0, rec); err != nil {
+		return c.handleTxErr(tx, err)
+	}
+
+	if err := c.recordRevision(ctx, tx, toggleID); err != nil {
+		return c.handleTxErr(tx, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package pg
+
+import (
+	"context"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/uid"
+)
+
+// RecordUsage merges aggregated ToggleUsage into postgres, keeping the most recent timestamps
+func (c Client) RecordUsage(ctx context.Context, usage ...togglr.ToggleUsage) error {
+	if len(usage) == 0 {
+		return nil
+	}
+
+	query := c.db.Insert("toggle_usage").Rows(usage).OnConflict(goqu.DoUpdate("toggle_id", goqu.Record{
+		"last_evaluated_at": goqu.L("GREATEST(toggle_usage.last_evaluated_at, EXCLUDED.last_evaluated_at)"),
+		"last_true_at":      goqu.L("GREATEST(toggle_usage.last_true_at, EXCLUDED.last_true_at)"),
+		"last_false_at":     goqu.L("GREATEST(toggle_usage.last_false_at, EXCLUDED.last_false_at)"),
+	}))
+	if _, err := query.Executor().ExecContext(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ListUsage queries the usage of every Toggle in an account that has been evaluated at least once
+func (c Client) ListUsage(ctx context.Context, accountID uid.UID) ([]togglr.ToggleUsage, error) {
+	usage := []togglr.ToggleUsage{}
+	query := c.db.From("toggle_usage").Where(goqu.Ex{"account_id": accountID})
+	if err := query.ScanStructsContext(ctx, &usage); err != nil {
+		return nil, err
+	}
+
+	return usage, nil
+}
@@ -10,6 +10,13 @@ import (
 	"github.com/togglr-io/togglr/uid"
 )
 
+var userSortColumns = map[string]sortColumn{
+	togglr.SortByCreatedAt: {"created_at", true},
+	togglr.SortByUpdatedAt: {"updated_at", true},
+	togglr.SortByName:      {"name", false},
+	togglr.SortByEmail:     {"email", false},
+}
+
 // CreateUser creates a new User in postgres
 func (c Client) CreateUser(ctx context.Context, user togglr.User) (uid.UID, error) {
 	// if no ID is provided, generate one
@@ -19,6 +26,10 @@ func (c Client) CreateUser(ctx context.Context, user togglr.User) (uid.UID, erro
 
 	query := c.db.Insert("users").Rows(user)
 	if _, err := query.Executor().ExecContext(ctx); err != nil {
+		if isUniqueViolation(err) {
+			return user.ID, togglr.ErrUserExists
+		}
+
 		return user.ID, err
 	}
 
@@ -31,13 +42,8 @@ func (c Client) UpdateUser(ctx context.Context, req togglr.UpdateUserReq) error
 		return fmt.Errorf("failed to create transaction: %w", err)
 	}
 
-	query := tx.Update("users").Set(updateReqToRecord(req)).Where(goqu.Ex{"id": req.ID})
-	if _, err := query.Executor().ExecContext(ctx); err != nil {
-		if rbErr := tx.Rollback(); err != nil {
-			return fmt.Errorf("rollback failed with err: %s %w", rbErr, err)
-		}
-
-		return fmt.Errorf("failed with rollback: %w", err)
+	if err := updateVersioned(ctx, tx, "users", req.ID, req.Version, updateReqToRecord(req)); err != nil {
+		return c.handleTxErr(tx, err)
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -77,13 +83,45 @@ func (c Client) ListUsers(ctx context.Context, req togglr.ListUsersReq) ([]toggl
 			),
 		)
 
-	if err := query.Where(goqu.Ex{"au.account_id": req.AccountID}).ScanStructsContext(ctx, &users); err != nil {
+	query, err := applyPage(query.Where(goqu.Ex{"au.account_id": req.AccountID}), req.Page, "u.", userSortColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := query.ScanStructsContext(ctx, &users); err != nil {
 		return nil, err
 	}
 
 	return users, nil
 }
 
+// FetchUserByEmail queries the User with the given email and IdentityType from postgres
+func (c Client) FetchUserByEmail(ctx context.Context, email string, identity togglr.IdentityType) (togglr.User, error) {
+	var user togglr.User
+	query := c.db.From("users").Where(goqu.Ex{"email": email, "identity_type": identity})
+	found, err := query.ScanStructContext(ctx, &user)
+	if err != nil {
+		return user, err
+	}
+
+	if !found {
+		return user, togglr.ErrNotFound
+	}
+
+	return user, nil
+}
+
+// ListUserAccounts queries the IDs of the accounts a User belongs to from postgres
+func (c Client) ListUserAccounts(ctx context.Context, userID uid.UID) ([]uid.UID, error) {
+	accounts := []uid.UID{}
+	query := c.db.From("account_users").Select("account_id").Where(goqu.Ex{"user_id": userID})
+	if err := query.ScanValsContext(ctx, &accounts); err != nil {
+		return nil, err
+	}
+
+	return accounts, nil
+}
+
 // DeleteUser deletes a User from postgres
 func (c Client) DeleteUser(ctx context.Context, id uid.UID) error {
 	del := c.db.Delete("users").Where(goqu.Ex{"id": id}).Executor()
@@ -2,31 +2,441 @@ package togglr
 
 import (
 	"context"
+	"errors"
+	"runtime"
+	"time"
 
 	"github.com/togglr-io/togglr/rules"
 	"github.com/togglr-io/togglr/uid"
 )
 
 type DefaultResolver struct {
-	ts ToggleService
+	ts       ToggleService
+	recorder EvaluationRecorder
 }
 
-func NewResolver(ts ToggleService) DefaultResolver {
+// NewResolver returns a new DefaultResolver. Every evaluation is reported to the given EvaluationRecorder, which
+// can be nil if evaluations don't need to be recorded
+func NewResolver(ts ToggleService, recorder EvaluationRecorder) DefaultResolver {
 	return DefaultResolver{
-		ts: ts,
+		ts:       ts,
+		recorder: recorder,
 	}
 }
 
-func (r DefaultResolver) Resolve(ctx context.Context, accountID uid.UID, md rules.Metadata) (ResolvedToggles, error) {
-	resolved := make(ResolvedToggles)
-	toggles, err := r.ts.ListToggles(ctx, ListTogglesReq{AccountID: accountID})
+// Resolve evaluates the toggles of an account. When keys are given only those toggles are evaluated, along with
+// their prerequisites, and keys that don't match a resolvable toggle are left out of the result
+func (r DefaultResolver) Resolve(ctx context.Context, accountID uid.UID, md rules.Metadata, keys ...string) (ResolvedToggles, error) {
+	if len(keys) == 0 {
+		toggles, err := r.ts.ListToggles(ctx, ListTogglesReq{AccountID: accountID})
+		if err != nil {
+			return nil, err
+		}
+
+		e := newEvaluator(md, staticLookup(toggles))
+		for _, toggle := range toggles {
+			if _, err := e.evaluate(toggle.Key); err != nil {
+				return nil, err
+			}
+		}
+
+		r.record(accountID, e)
+		return e.resolved, nil
+	}
+
+	e := newEvaluator(md, r.fetchLookup(ctx, accountID))
+	resolved := make(ResolvedToggles, len(keys))
+	for _, key := range keys {
+		if _, err := e.evaluate(key); err != nil {
+			return nil, err
+		}
+
+		if _, ok := e.toggles[key]; ok {
+			resolved[key] = e.resolved[key]
+		}
+	}
+
+	r.record(accountID, e)
+	return resolved, nil
+}
+
+// ResolveOne evaluates a single toggle, along with its prerequisites. ErrNotFound is returned if the key doesn't
+// match a resolvable toggle
+func (r DefaultResolver) ResolveOne(ctx context.Context, accountID uid.UID, key string, md rules.Metadata) (bool, error) {
+	e := newEvaluator(md, r.fetchLookup(ctx, accountID))
+	value, err := e.evaluate(key)
+	if err != nil {
+		return false, err
+	}
+
+	if _, ok := e.toggles[key]; !ok {
+		return false, ErrNotFound
+	}
+
+	r.record(accountID, e)
+	return value, nil
+}
+
+// the number of goroutines evaluating a batch in parallel
+var batchWorkers = runtime.GOMAXPROCS(0)
+
+type batchResult struct {
+	resolved ResolvedToggles
+	err      error
+}
+
+type batchJob struct {
+	md  rules.Metadata
+	out chan batchResult
+}
+
+// ResolveBatch evaluates toggles for every set of Metadata in a batch, loading the account's toggles only once.
+// Evaluation happens in parallel, but results are passed to fn in the same order as the Metadata, so callers can
+// stream them out as they arrive. The batch stops at the first error, including any returned by fn
+func (r DefaultResolver) ResolveBatch(ctx context.Context, req ResolveBatchReq, fn func(int, ResolvedToggles) error) error {
+	toggles, err := r.loadToggles(ctx, req.AccountID, req.Keys)
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	keys := req.Keys
+	if len(keys) == 0 {
+		keys = make([]string, 0, len(toggles))
+		for _, toggle := range toggles {
+			keys = append(keys, toggle.Key)
+		}
+	}
+
+	// the lookup is only ever read from, so it's safe to share between workers
+	lookup := staticLookup(toggles)
+	resolve := func(md rules.Metadata) (ResolvedToggles, error) {
+		e := newEvaluator(md, lookup)
+		resolved := make(ResolvedToggles, len(keys))
+		for _, key := range keys {
+			value, err := e.evaluate(key)
+			if err != nil {
+				return nil, err
+			}
+
+			if _, ok := e.toggles[key]; ok {
+				resolved[key] = value
+			}
+		}
+
+		r.record(req.AccountID, e)
+		return resolved, nil
 	}
 
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan batchJob)
+	for i := 0; i < batchWorkers; i++ {
+		go func() {
+			for job := range jobs {
+				resolved, err := resolve(job.md)
+				job.out <- batchResult{resolved: resolved, err: err}
+			}
+		}()
+	}
+
+	// pending holds the output of every job in the order they were queued. Its buffer bounds how far workers can
+	// get ahead of fn
+	pending := make(chan chan batchResult, batchWorkers)
+	go func() {
+		defer close(jobs)
+		defer close(pending)
+
+		for _, md := range req.Metadata {
+			out := make(chan batchResult, 1)
+			select {
+			case pending <- out:
+			case <-ctx.Done():
+				return
+			}
+
+			select {
+			case jobs <- batchJob{md: md, out: out}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	i := 0
+	for out := range pending {
+		select {
+		case result := <-out:
+			if result.err != nil {
+				return result.err
+			}
+
+			if err := fn(i, result.resolved); err != nil {
+				return err
+			}
+			i++
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if i < len(req.Metadata) {
+		return ctx.Err()
+	}
+
+	return nil
+}
+
+// loadToggles loads the toggles needed to resolve the given keys, including their prerequisites. Every resolvable
+// toggle in the account is loaded when no keys are given
+func (r DefaultResolver) loadToggles(ctx context.Context, accountID uid.UID, keys []string) ([]Toggle, error) {
+	if len(keys) == 0 {
+		return r.ts.ListToggles(ctx, ListTogglesReq{AccountID: accountID})
+	}
+
+	lookup := r.fetchLookup(ctx, accountID)
+	seen := make(map[string]bool, len(keys))
+	toggles := []Toggle{}
+	queue := append([]string{}, keys...)
+	for len(queue) > 0 {
+		key := queue[0]
+		queue = queue[1:]
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		toggle, ok, err := lookup(key)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			toggles = append(toggles, toggle)
+			queue = append(queue, toggle.Prerequisites...)
+		}
+	}
+
+	return toggles, nil
+}
+
+// record reports every toggle evaluated by an evaluator, prerequisites included
+func (r DefaultResolver) record(accountID uid.UID, e *evaluator) {
+	if r.recorder == nil {
+		return
+	}
+
+	now := time.Now()
+	contextKey := ContextKey(e.md)
+	for key, toggle := range e.toggles {
+		eval := Evaluation{
+			AccountID:  accountID,
+			ToggleID:   toggle.ID,
+			Key:        key,
+			Value:      e.resolved[key],
+			Reason:     e.reasons[key],
+			ContextKey: contextKey,
+			Time:       now,
+		}
+
+		if variant, ok := e.variants[key]; ok {
+			eval.VariantKey = variant.Key
+		}
+
+		if e.exposed[key] {
+			eval.ExperimentID = toggle.ExperimentID
+		}
+
+		r.recorder.RecordEvaluation(eval)
+	}
+}
+
+// fetchLookup finds toggles one key at a time, treating archived toggles as missing
+func (r DefaultResolver) fetchLookup(ctx context.Context, accountID uid.UID) toggleLookup {
+	return func(key string) (Toggle, bool, error) {
+		toggle, err := r.ts.FetchToggleByKey(ctx, accountID, key)
+		if errors.Is(err, ErrNotFound) {
+			return toggle, false, nil
+		}
+
+		if err != nil {
+			return toggle, false, err
+		}
+
+		return toggle, !toggle.Archived(), nil
+	}
+}
+
+// A Snapshot evaluates a fixed set of toggles without going back to a ToggleService, which lets clients evaluate
+// toggles locally. It's never modified after being created, so it's safe for concurrent use
+type Snapshot struct {
+	toggles []Toggle
+	lookup  toggleLookup
+}
+
+// NewSnapshot creates a Snapshot of the given toggles, which should all belong to the same account and already
+// exclude archived toggles
+func NewSnapshot(toggles []Toggle) Snapshot {
+	return Snapshot{
+		toggles: toggles,
+		lookup:  staticLookup(toggles),
+	}
+}
+
+// Lookup finds the Toggle with the given key. The returned bool is false if there isn't one
+func (s Snapshot) Lookup(key string) (Toggle, bool) {
+	toggle, ok, _ := s.lookup(key)
+	return toggle, ok
+}
+
+// Resolve evaluates the toggles in the Snapshot, limited to the given keys if there are any. Keys that don't match
+// a toggle are left out of the result
+func (s Snapshot) Resolve(md rules.Metadata, keys ...string) ResolvedToggles {
+	if len(keys) == 0 {
+		keys = make([]string, 0, len(s.toggles))
+		for _, toggle := range s.toggles {
+			keys = append(keys, toggle.Key)
+		}
+	}
+
+	// a static lookup never fails, so neither can evaluating
+	e := newEvaluator(md, s.lookup)
+	resolved := make(ResolvedToggles, len(keys))
+	for _, key := range keys {
+		value, _ := e.evaluate(key)
+		if _, ok := e.toggles[key]; ok {
+			resolved[key] = value
+		}
+	}
+
+	return resolved
+}
+
+// ResolveOne evaluates a single toggle, along with its prerequisites. Toggles that aren't in the Snapshot resolve to
+// false
+func (s Snapshot) ResolveOne(key string, md rules.Metadata) bool {
+	return s.Explain(key, md).Value
+}
+
+// An Explanation describes how a single toggle resolved
+type Explanation struct {
+	Value  bool
+	Reason Reason
+	// Variant is only set for toggles with Variants that were split between them
+	Variant *Variant
+}
+
+// Explain evaluates a single toggle like ResolveOne, also describing why it resolved the way it did. The Reason is
+// empty for toggles that aren't in the Snapshot
+func (s Snapshot) Explain(key string, md rules.Metadata) Explanation {
+	e := newEvaluator(md, s.lookup)
+	value, _ := e.evaluate(key)
+	explanation := Explanation{Value: value, Reason: e.reasons[key]}
+	if variant, ok := e.variants[key]; ok {
+		explanation.Variant = &variant
+	}
+
+	return explanation
+}
+
+// A toggleLookup finds the resolvable Toggle with the given key. The returned bool is false if there isn't one
+type toggleLookup func(key string) (Toggle, bool, error)
+
+// staticLookup finds toggles in a list that's already been loaded
+func staticLookup(toggles []Toggle) toggleLookup {
+	byKey := make(map[string]Toggle, len(toggles))
 	for _, toggle := range toggles {
-		resolved[toggle.Key] = rules.EvaluateRules(md, toggle.Rules...)
+		byKey[toggle.Key] = toggle
 	}
 
-	return resolved, nil
+	return func(key string) (Toggle, bool, error) {
+		toggle, ok := byKey[key]
+		return toggle, ok, nil
+	}
+}
+
+// An evaluator resolves toggles against some metadata, looking up each Toggle at most once. A Toggle only resolves
+// to true when all of its prerequisites do as well. Prerequisites that are missing, archived or part of a cycle
+// resolve to false
+type evaluator struct {
+	md     rules.Metadata
+	lookup toggleLookup
+
+	// every Toggle that was found, the value it resolved to and why, the Variant assigned if it has Variants and
+	// whether that exposed the metadata to the Toggle's Experiment
+	toggles  map[string]Toggle
+	resolved ResolvedToggles
+	reasons  map[string]Reason
+	variants map[string]Variant
+	exposed  map[string]bool
+	missing  map[string]bool
+	visiting map[string]bool
+}
+
+func newEvaluator(md rules.Metadata, lookup toggleLookup) *evaluator {
+	return &evaluator{
+		md:       md,
+		lookup:   lookup,
+		toggles:  make(map[string]Toggle),
+		resolved: make(ResolvedToggles),
+		reasons:  make(map[string]Reason),
+		variants: make(map[string]Variant),
+		exposed:  make(map[string]bool),
+		missing:  make(map[string]bool),
+		visiting: make(map[string]bool),
+	}
+}
+
+func (e *evaluator) evaluate(key string) (bool, error) {
+	if value, ok := e.resolved[key]; ok {
+		return value, nil
+	}
+
+	if e.missing[key] || e.visiting[key] {
+		return false, nil
+	}
+
+	toggle, ok, err := e.lookup(key)
+	if err != nil {
+		return false, err
+	}
+
+	if !ok {
+		e.missing[key] = true
+		return false, nil
+	}
+
+	e.visiting[key] = true
+	defer delete(e.visiting, key)
+
+	e.toggles[key] = toggle
+	for _, prereq := range toggle.Prerequisites {
+		value, err := e.evaluate(prereq)
+		if err != nil {
+			return false, err
+		}
+
+		if !value {
+			e.resolved[key] = false
+			e.reasons[key] = ReasonPrerequisiteFailed
+			return false, nil
+		}
+	}
+
+	e.reasons[key] = ReasonStatic
+	if len(toggle.Rules) > 0 || len(toggle.Prerequisites) > 0 {
+		e.reasons[key] = ReasonTargetingMatch
+	}
+
+	value := rules.EvaluateRules(e.md, toggle.Rules...)
+	if value && len(toggle.Variants) > 0 {
+		variant, reason, exposed := toggle.assign(ContextKey(e.md))
+		e.variants[key] = variant
+		e.reasons[key] = reason
+		e.exposed[key] = exposed
+		value = variant.Value
+	}
+
+	e.resolved[key] = value
+	return value, nil
 }
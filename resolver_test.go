@@ -2,7 +2,10 @@ package togglr_test
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/togglr-io/togglr"
 	"github.com/togglr-io/togglr/mock"
@@ -76,7 +79,7 @@ func Test_DefaultResolver(t *testing.T) {
 	ctx := context.TODO()
 	ts := mock.NewToggleService(nil)
 	ts.ListTogglesFn = listToggles
-	resolver := togglr.NewResolver(ts)
+	resolver := togglr.NewResolver(ts, nil)
 	metadata := rules.Metadata{
 		"userType": rules.NewString("admin"),
 		"hasFlag":  rules.NewBool(true),
@@ -106,3 +109,357 @@ func Test_DefaultResolver(t *testing.T) {
 		t.Fatalf("expected 'user-feature' flag to be false")
 	}
 }
+
+func Test_DefaultResolverPrerequisites(t *testing.T) {
+	// SETUP
+	ctx := context.TODO()
+	ts := mock.NewToggleService(nil)
+	ts.ListTogglesFn = func(ctx context.Context, req togglr.ListTogglesReq) ([]togglr.Toggle, error) {
+		toggles, err := listToggles(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		return append(toggles,
+			togglr.Toggle{ID: uid.New(), Key: "admin-dependent", Prerequisites: togglr.Keys{"admin-feature"}},
+			togglr.Toggle{ID: uid.New(), Key: "user-dependent", Prerequisites: togglr.Keys{"user-feature"}},
+			togglr.Toggle{ID: uid.New(), Key: "missing-dependent", Prerequisites: togglr.Keys{"archived-feature"}},
+			togglr.Toggle{ID: uid.New(), Key: "cycle-a", Prerequisites: togglr.Keys{"cycle-b"}},
+			togglr.Toggle{ID: uid.New(), Key: "cycle-b", Prerequisites: togglr.Keys{"cycle-a"}},
+		), nil
+	}
+	resolver := togglr.NewResolver(ts, nil)
+	metadata := rules.Metadata{
+		"userType": rules.NewString("admin"),
+		"hasFlag":  rules.NewBool(true),
+	}
+
+	// RUN
+	resolved, err := resolver.Resolve(ctx, uid.New(), metadata)
+	if err != nil {
+		t.Fatalf("failed to resolve toggles: %s", err)
+	}
+
+	expected := map[string]bool{
+		"admin-dependent":   true,
+		"user-dependent":    false,
+		"missing-dependent": false,
+		"cycle-a":           false,
+		"cycle-b":           false,
+	}
+
+	for key, value := range expected {
+		actual, ok := resolved[key]
+		if !ok {
+			t.Fatalf("expected '%s' flag to be present", key)
+		}
+
+		if actual != value {
+			t.Fatalf("expected '%s' flag to be %t", key, value)
+		}
+	}
+}
+
+// fetchToggleByKey serves the toggles from listToggles one at a time, along with a prerequisite and an archived toggle
+func fetchToggleByKey(ctx context.Context, accountID uid.UID, key string) (togglr.Toggle, error) {
+	toggles, err := listToggles(ctx, togglr.ListTogglesReq{AccountID: accountID})
+	if err != nil {
+		return togglr.Toggle{}, err
+	}
+
+	archivedAt := time.Now()
+	toggles = append(toggles,
+		togglr.Toggle{ID: uid.New(), Key: "admin-dependent", Prerequisites: togglr.Keys{"admin-feature"}},
+		togglr.Toggle{ID: uid.New(), Key: "archived-feature", ArchivedAt: &archivedAt},
+	)
+
+	for _, toggle := range toggles {
+		if toggle.Key == key {
+			return toggle, nil
+		}
+	}
+
+	return togglr.Toggle{}, togglr.ErrNotFound
+}
+
+func Test_DefaultResolverKeys(t *testing.T) {
+	// SETUP
+	ctx := context.TODO()
+	ts := mock.NewToggleService(nil)
+	ts.FetchToggleByKeyFn = fetchToggleByKey
+	resolver := togglr.NewResolver(ts, nil)
+	metadata := rules.Metadata{
+		"userType": rules.NewString("admin"),
+		"hasFlag":  rules.NewBool(true),
+	}
+
+	// RUN
+	resolved, err := resolver.Resolve(ctx, uid.New(), metadata, "admin-dependent", "archived-feature", "unknown")
+	if err != nil {
+		t.Fatalf("failed to resolve toggles: %s", err)
+	}
+
+	if len(resolved) != 1 || !resolved["admin-dependent"] {
+		t.Fatalf("expected only 'admin-dependent' to resolve to true, got %v", resolved)
+	}
+
+	if ts.ListTogglesCalled != 0 {
+		t.Fatalf("expected a filtered resolve not to list toggles")
+	}
+
+	value, err := resolver.ResolveOne(ctx, uid.New(), "user-feature", metadata)
+	if err != nil {
+		t.Fatalf("failed to resolve toggle: %s", err)
+	}
+
+	if value {
+		t.Fatalf("expected 'user-feature' flag to be false")
+	}
+
+	for _, key := range []string{"archived-feature", "unknown"} {
+		if _, err := resolver.ResolveOne(ctx, uid.New(), key, metadata); !errors.Is(err, togglr.ErrNotFound) {
+			t.Fatalf("expected '%s' not to be found, got %v", key, err)
+		}
+	}
+}
+
+func Test_DefaultResolverBatch(t *testing.T) {
+	// SETUP
+	ctx := context.TODO()
+	ts := mock.NewToggleService(nil)
+	ts.ListTogglesFn = listToggles
+	resolver := togglr.NewResolver(ts, nil)
+	req := togglr.ResolveBatchReq{AccountID: uid.New()}
+	for i := 0; i < 100; i++ {
+		userType := "user"
+		if i%2 == 0 {
+			userType = "admin"
+		}
+
+		req.Metadata = append(req.Metadata, rules.Metadata{
+			"userType": rules.NewString(userType),
+			"hasFlag":  rules.NewBool(true),
+		})
+	}
+
+	// RUN
+	next := 0
+	err := resolver.ResolveBatch(ctx, req, func(i int, resolved togglr.ResolvedToggles) error {
+		if i != next {
+			t.Fatalf("expected result %d, got %d", next, i)
+		}
+		next++
+
+		admin := i%2 == 0
+		if resolved["admin-feature"] != admin || resolved["user-feature"] == admin {
+			t.Fatalf("unexpected result %d: %v", i, resolved)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to resolve batch: %s", err)
+	}
+
+	if next != len(req.Metadata) {
+		t.Fatalf("expected %d results, got %d", len(req.Metadata), next)
+	}
+
+	if ts.ListTogglesCalled != 1 {
+		t.Fatalf("expected toggles to be listed once, but they were listed %d times", ts.ListTogglesCalled)
+	}
+
+	stop := errors.New("stop")
+	err = resolver.ResolveBatch(ctx, req, func(i int, resolved togglr.ResolvedToggles) error {
+		return stop
+	})
+	if !errors.Is(err, stop) {
+		t.Fatalf("expected the batch to stop with the callback's error, got %v", err)
+	}
+
+	req.Keys = []string{"admin-dependent"}
+	ts.FetchToggleByKeyFn = fetchToggleByKey
+	err = resolver.ResolveBatch(ctx, req, func(i int, resolved togglr.ResolvedToggles) error {
+		if len(resolved) != 1 || resolved["admin-dependent"] != (i%2 == 0) {
+			t.Fatalf("unexpected result %d: %v", i, resolved)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to resolve batch: %s", err)
+	}
+
+	if ts.FetchToggleByKeyCalled != 2 {
+		t.Fatalf("expected a toggle and its prerequisite to be fetched once, got %d fetches", ts.FetchToggleByKeyCalled)
+	}
+}
+
+// evaluationsByKey is an EvaluationRecorder that keeps the last evaluation of each toggle
+type evaluationsByKey map[string]togglr.Evaluation
+
+func (e evaluationsByKey) RecordEvaluation(eval togglr.Evaluation) {
+	e[eval.Key] = eval
+}
+
+func Test_DefaultResolverReasons(t *testing.T) {
+	// SETUP
+	ctx := context.TODO()
+	ts := mock.NewToggleService(nil)
+	ts.ListTogglesFn = func(ctx context.Context, req togglr.ListTogglesReq) ([]togglr.Toggle, error) {
+		toggles, err := listToggles(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		return append(toggles,
+			togglr.Toggle{ID: uid.New(), Key: "static-feature"},
+			togglr.Toggle{ID: uid.New(), Key: "user-dependent", Prerequisites: togglr.Keys{"user-feature"}},
+		), nil
+	}
+	evals := make(evaluationsByKey)
+	resolver := togglr.NewResolver(ts, evals)
+	metadata := rules.Metadata{
+		togglr.ContextKeyName: rules.NewString("user-1"),
+		"userType":            rules.NewString("admin"),
+		"hasFlag":             rules.NewBool(true),
+	}
+
+	// RUN
+	if _, err := resolver.Resolve(ctx, uid.New(), metadata); err != nil {
+		t.Fatalf("failed to resolve toggles: %s", err)
+	}
+
+	expected := map[string]togglr.Reason{
+		"admin-feature":  togglr.ReasonTargetingMatch,
+		"user-feature":   togglr.ReasonTargetingMatch,
+		"static-feature": togglr.ReasonStatic,
+		"user-dependent": togglr.ReasonPrerequisiteFailed,
+	}
+
+	for key, reason := range expected {
+		eval, ok := evals[key]
+		if !ok {
+			t.Fatalf("expected '%s' flag to be recorded", key)
+		}
+
+		if eval.Reason != reason {
+			t.Fatalf("expected '%s' flag to be recorded with reason %s, got %s", key, reason, eval.Reason)
+		}
+
+		if eval.ContextKey != "user-1" {
+			t.Fatalf("expected '%s' flag to be recorded for the context key, got '%s'", key, eval.ContextKey)
+		}
+	}
+
+	if evals["admin-feature"].Variant() != togglr.VariantOn || evals["user-dependent"].Variant() != togglr.VariantOff {
+		t.Fatalf("expected evaluations to report the variant they resolved to")
+	}
+}
+
+func Test_DefaultResolverSplit(t *testing.T) {
+	// SETUP
+	ctx := context.TODO()
+	experimentID := uid.New()
+	ts := mock.NewToggleService(nil)
+	ts.ListTogglesFn = func(ctx context.Context, req togglr.ListTogglesReq) ([]togglr.Toggle, error) {
+		return []togglr.Toggle{{
+			ID:           uid.New(),
+			Key:          "checkout-button",
+			ExperimentID: experimentID,
+			Variants: togglr.Variants{
+				{Key: "control", Weight: 1},
+				{Key: "green", Weight: 1, Value: true},
+			},
+		}}, nil
+	}
+	evals := make(evaluationsByKey)
+	resolver := togglr.NewResolver(ts, evals)
+	assigned := make(map[string]int)
+
+	// RUN
+	for i := 0; i < 100; i++ {
+		md := rules.Metadata{togglr.ContextKeyName: rules.NewString(fmt.Sprintf("user-%d", i))}
+		resolved, err := resolver.Resolve(ctx, uid.New(), md)
+		if err != nil {
+			t.Fatalf("failed to resolve toggles: %s", err)
+		}
+
+		eval := evals["checkout-button"]
+		if eval.Reason != togglr.ReasonSplit || !eval.ExperimentID.Equals(experimentID) {
+			t.Fatalf("expected split evaluation attributed to the experiment, got %+v", eval)
+		}
+
+		if resolved["checkout-button"] != (eval.VariantKey == "green") {
+			t.Fatalf("expected toggle to resolve to the value of variant %s", eval.VariantKey)
+		}
+		assigned[eval.VariantKey]++
+	}
+
+	if assigned["control"] == 0 || assigned["green"] == 0 {
+		t.Fatalf("expected both variants to be assigned, got %v", assigned)
+	}
+}
+
+func Test_DefaultResolverLayers(t *testing.T) {
+	// SETUP
+	ctx := context.TODO()
+	accountID := uid.New()
+	holdout := togglr.Holdout{ID: uid.New(), Percent: 10}
+	variants := togglr.Variants{
+		{Key: "control", Weight: 1},
+		{Key: "treatment", Weight: 1, Value: true},
+	}
+	ts := mock.NewToggleService(nil)
+	ts.ListTogglesFn = func(ctx context.Context, req togglr.ListTogglesReq) ([]togglr.Toggle, error) {
+		return []togglr.Toggle{
+			{
+				ID:           uid.New(),
+				AccountID:    accountID,
+				Key:          "checkout-button",
+				Variants:     variants,
+				ExperimentID: uid.New(),
+				Allocation:   &togglr.Allocation{Layer: "checkout", SlotStart: 0, SlotEnd: 50, Control: "control", Holdouts: []togglr.Holdout{holdout}},
+			},
+			{
+				ID:           uid.New(),
+				AccountID:    accountID,
+				Key:          "checkout-copy",
+				Variants:     variants,
+				ExperimentID: uid.New(),
+				Allocation:   &togglr.Allocation{Layer: "checkout", SlotStart: 50, SlotEnd: 100, Control: "control", Holdouts: []togglr.Holdout{holdout}},
+			},
+		}, nil
+	}
+	evals := make(evaluationsByKey)
+	resolver := togglr.NewResolver(ts, evals)
+	reasons := make(map[togglr.Reason]int)
+
+	// RUN
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("user-%d", i)
+		md := rules.Metadata{togglr.ContextKeyName: rules.NewString(key)}
+		if _, err := resolver.Resolve(ctx, accountID, md); err != nil {
+			t.Fatalf("failed to resolve toggles: %s", err)
+		}
+
+		button, other := evals["checkout-button"], evals["checkout-copy"]
+		if !button.ExperimentID.IsNull() && !other.ExperimentID.IsNull() {
+			t.Fatalf("expected '%s' to be exposed to at most one experiment in the layer", key)
+		}
+
+		if holdout.Holds(key) && (button.Reason != togglr.ReasonHoldout || button.VariantKey != "control" || !button.ExperimentID.IsNull()) {
+			t.Fatalf("expected held out '%s' to be served the control without being exposed, got %+v", key, button)
+		}
+
+		if button.Reason == togglr.ReasonExcluded && (button.VariantKey != "control" || other.Reason != togglr.ReasonSplit) {
+			t.Fatalf("expected '%s' to be in the other experiment of the layer when excluded from one", key)
+		}
+
+		reasons[button.Reason]++
+	}
+
+	if reasons[togglr.ReasonHoldout] == 0 || reasons[togglr.ReasonExcluded] == 0 || reasons[togglr.ReasonSplit] == 0 {
+		t.Fatalf("expected contexts to be held out, excluded and split, got %v", reasons)
+	}
+}
@@ -1,5 +1,7 @@
 package rules
 
+import "encoding/json"
+
 // A Bool expression represents a boolean literal during rule evaluation
 type Bool struct {
 	Value bool `json:"value"`
@@ -33,3 +35,9 @@ func (b Bool) IsTrue() bool {
 func (b Bool) Evaluate(md Metadata) Comparable {
 	return b
 }
+
+// MarshalJSON implements the json.Marshaler interface. The type is included so that the Bool can be unmarshalled
+// as an Expression
+func (b Bool) MarshalJSON() ([]byte, error) {
+	return json.Marshal(literalTarget{Type: ExprTypeBool, Value: b.Value})
+}
@@ -1,5 +1,7 @@
 package rules
 
+import "encoding/json"
+
 // A Float expression represents a float literal during rule evaluation
 type Float struct {
 	Value float32 `json:"value"`
@@ -40,3 +42,9 @@ func (f Float) IsTrue() bool {
 func (f Float) Evaluate(md Metadata) Comparable {
 	return f
 }
+
+// MarshalJSON implements the json.Marshaler interface. The type is included so that the Float can be unmarshalled
+// as an Expression
+func (f Float) MarshalJSON() ([]byte, error) {
+	return json.Marshal(literalTarget{Type: ExprTypeFloat, Value: f.Value})
+}
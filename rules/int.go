@@ -1,5 +1,7 @@
 package rules
 
+import "encoding/json"
+
 // An Int expression represents an int literal during rule evaluation
 type Int struct {
 	Value int `json:"value"`
@@ -38,3 +40,9 @@ func (i Int) IsTrue() bool {
 func (i Int) Evaluate(md Metadata) Comparable {
 	return i
 }
+
+// MarshalJSON implements the json.Marshaler interface. The type is included so that the Int can be unmarshalled
+// as an Expression
+func (i Int) MarshalJSON() ([]byte, error) {
+	return json.Marshal(literalTarget{Type: ExprTypeInt, Value: i.Value})
+}
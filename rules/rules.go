@@ -150,6 +150,12 @@ func (e *Expression) UnmarshalJSON(data []byte) error {
 	return fmt.Errorf("failed to unmarshal invalid Expression type %s", e.Type)
 }
 
+// the marshal target for literals that don't carry their own type
+type literalTarget struct {
+	Type  ExprType    `json:"type"`
+	Value interface{} `json:"value"`
+}
+
 // A Rule evaluates against Metadata to determine a value for a particular Toggle.
 type Rule struct {
 	Op   BinOp      `json:"op"`
@@ -152,6 +152,18 @@ func Test_MarshalExpression(t *testing.T) {
 			},
 			expected: `{"type":"binary","left":{"type":"ident","value":"hello"},"right":{"type":"string","value":"hello"},"op":"=="}`,
 		},
+		{
+			name: "marshal binary with literals",
+			expression: rules.Expression{
+				Type: rules.ExprTypeBinary,
+				Binary: rules.NewBinary(
+					rules.NewBinary(rules.NewInt(1), rules.NewFloat(1.5), rules.BinOpLt),
+					rules.NewBool(true),
+					rules.BinOpAnd,
+				),
+			},
+			expected: `{"type":"binary","left":{"type":"binary","left":{"type":"int","value":1},"right":{"type":"float","value":1.5},"op":"\u003c"},"right":{"type":"bool","value":true},"op":"\u0026\u0026"}`,
+		},
 	}
 
 	for _, c := range cases {
@@ -0,0 +1,173 @@
+package togglr
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/togglr-io/togglr/uid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// the shortest password accepted, and the longest, since bcrypt ignores everything after 72 bytes
+const (
+	minPasswordLength = 8
+	maxPasswordLength = 72
+)
+
+// the number of random bytes in the CSRFToken of a Session
+const csrfTokenSize = 32
+
+// compared against when logging in with an unknown email, so that it takes as long as a wrong password and can't
+// be used to find out which emails have Users
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("togglr-dummy-password"), bcrypt.DefaultCost)
+
+// HashPassword hashes a password with bcrypt. ErrInvalidPassword is returned if the password is too short or too
+// long
+func HashPassword(password string) (string, error) {
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		return "", ErrInvalidPassword
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	return string(hash), nil
+}
+
+// Login finds the User with the basic IdentityType that the email and password belong to. ErrInvalidCredentials is
+// returned if there isn't one, without saying whether it was the email or the password that was wrong
+func Login(ctx context.Context, us UserService, ps PasswordService, email, password string) (User, error) {
+	user, err := us.FetchUserByEmail(ctx, email, IdentityTypeBasic)
+	if errors.Is(err, ErrNotFound) {
+		_ = bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return User{}, ErrInvalidCredentials
+	}
+
+	if err != nil {
+		return User{}, err
+	}
+
+	if err := VerifyPassword(ctx, ps, user.ID, password); err != nil {
+		return User{}, err
+	}
+
+	return user, nil
+}
+
+// VerifyPassword checks the password of a User. ErrInvalidCredentials is returned if it's wrong or the User doesn't
+// have a password
+func VerifyPassword(ctx context.Context, ps PasswordService, userID uid.UID, password string) error {
+	hash, err := ps.FetchPasswordHash(ctx, userID)
+	if errors.Is(err, ErrNotFound) {
+		_ = bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return ErrInvalidCredentials
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+		return ErrInvalidCredentials
+	}
+
+	return nil
+}
+
+// LoginExternal finds the User with the given IdentityType and the email of the ExternalIdentity. Users that don't
+// exist yet are only created the first time they log in when their email is on one of the given signup domains,
+// otherwise ErrUnknownUser is returned and they have to be created by an admin first. Users with the same email but
+// another IdentityType are separate Users. When the first logins of a User race, the one that loses finds the User
+// the other created
+func LoginExternal(ctx context.Context, us UserService, identity IdentityType, ext ExternalIdentity, signupDomains []string) (User, error) {
+	user, err := us.FetchUserByEmail(ctx, ext.Email, identity)
+	if err == nil {
+		return user, nil
+	}
+
+	if !errors.Is(err, ErrNotFound) {
+		return user, err
+	}
+
+	if !onDomain(ext.Email, signupDomains) {
+		return user, ErrUnknownUser
+	}
+
+	user = User{
+		Email:    ext.Email,
+		Name:     ext.Name,
+		Identity: identity,
+	}
+	// providers don't require a name, but Users do
+	if user.Name == "" {
+		user.Name = ext.Email
+	}
+
+	user.ID, err = us.CreateUser(ctx, user)
+	if errors.Is(err, ErrUserExists) {
+		return us.FetchUserByEmail(ctx, ext.Email, identity)
+	}
+
+	if err != nil {
+		return user, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	return user, nil
+}
+
+// onDomain returns true if the email is on one of the domains
+func onDomain(email string, domains []string) bool {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+
+	for _, domain := range domains {
+		if strings.EqualFold(email[at+1:], domain) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NewSession creates a Session for a User that expires after ttl, with its ID and CSRFToken filled in
+func NewSession(userID uid.UID, ttl time.Duration) (Session, error) {
+	random := make([]byte, csrfTokenSize)
+	if _, err := rand.Read(random); err != nil {
+		return Session{}, fmt.Errorf("failed to generate csrf token: %w", err)
+	}
+
+	return Session{
+		ID:        uid.New(),
+		UserID:    userID,
+		CSRFToken: base64.RawURLEncoding.EncodeToString(random),
+		ExpiresAt: time.Now().UTC().Add(ttl),
+	}, nil
+}
+
+// AuthenticateSession finds the Session with the given ID. ErrInvalidSession is returned if there isn't one or it
+// has expired
+func AuthenticateSession(ctx context.Context, ss SessionService, id uid.UID) (Session, error) {
+	session, err := ss.FetchSession(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return session, ErrInvalidSession
+	}
+
+	if err != nil {
+		return session, err
+	}
+
+	if session.Expired() {
+		return session, ErrInvalidSession
+	}
+
+	return session, nil
+}
@@ -0,0 +1,170 @@
+package togglr_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/mock"
+	"github.com/togglr-io/togglr/uid"
+)
+
+func Test_Login(t *testing.T) {
+	// SETUP
+	ctx := context.TODO()
+	user := togglr.User{ID: uid.New(), Email: "jane@example.com", Identity: togglr.IdentityTypeBasic}
+	hash, err := togglr.HashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("failed to hash password: %s", err)
+	}
+
+	us := mock.NewUserService(nil)
+	us.FetchUserByEmailFn = func(ctx context.Context, email string, identity togglr.IdentityType) (togglr.User, error) {
+		if email != user.Email || identity != togglr.IdentityTypeBasic {
+			return togglr.User{}, togglr.ErrNotFound
+		}
+		return user, nil
+	}
+	ps := mock.NewPasswordService(nil)
+	ps.FetchPasswordHashFn = func(ctx context.Context, userID uid.UID) (string, error) {
+		return hash, nil
+	}
+
+	// RUN
+	loggedIn, err := togglr.Login(ctx, us, ps, user.Email, "correct horse")
+	if err != nil {
+		t.Fatalf("failed to log in: %s", err)
+	}
+
+	if !loggedIn.ID.Equals(user.ID) {
+		t.Fatalf("expected to log in as %s, got %s", user.ID, loggedIn.ID)
+	}
+
+	if _, err := togglr.Login(ctx, us, ps, user.Email, "battery staple"); !errors.Is(err, togglr.ErrInvalidCredentials) {
+		t.Fatalf("expected wrong password to be rejected, got %v", err)
+	}
+
+	if _, err := togglr.Login(ctx, us, ps, "john@example.com", "correct horse"); !errors.Is(err, togglr.ErrInvalidCredentials) {
+		t.Fatalf("expected unknown email to be rejected, got %v", err)
+	}
+
+	ps.FetchPasswordHashFn = func(ctx context.Context, userID uid.UID) (string, error) {
+		return "", togglr.ErrNotFound
+	}
+	if _, err := togglr.Login(ctx, us, ps, user.Email, "correct horse"); !errors.Is(err, togglr.ErrInvalidCredentials) {
+		t.Fatalf("expected user without a password to be rejected, got %v", err)
+	}
+
+	if _, err := togglr.HashPassword("short"); !errors.Is(err, togglr.ErrInvalidPassword) {
+		t.Fatalf("expected short password to be invalid, got %v", err)
+	}
+}
+
+func Test_AuthenticateSession(t *testing.T) {
+	// SETUP
+	ctx := context.TODO()
+	session, err := togglr.NewSession(uid.New(), time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create session: %s", err)
+	}
+
+	ss := mock.NewSessionService(nil)
+	ss.FetchSessionFn = func(ctx context.Context, id uid.UID) (togglr.Session, error) {
+		if !id.Equals(session.ID) {
+			return togglr.Session{}, togglr.ErrNotFound
+		}
+		return session, nil
+	}
+
+	// RUN
+	if _, err := togglr.AuthenticateSession(ctx, ss, session.ID); err != nil {
+		t.Fatalf("failed to authenticate session: %s", err)
+	}
+
+	if _, err := togglr.AuthenticateSession(ctx, ss, uid.New()); !errors.Is(err, togglr.ErrInvalidSession) {
+		t.Fatalf("expected unknown session to be invalid, got %v", err)
+	}
+
+	session.ExpiresAt = time.Now().Add(-time.Minute)
+	if _, err := togglr.AuthenticateSession(ctx, ss, session.ID); !errors.Is(err, togglr.ErrInvalidSession) {
+		t.Fatalf("expected expired session to be invalid, got %v", err)
+	}
+}
+
+func Test_LoginExternal(t *testing.T) {
+	// SETUP
+	ctx := context.TODO()
+	var created []togglr.User
+	us := mock.NewUserService(nil)
+	us.FetchUserByEmailFn = func(ctx context.Context, email string, identity togglr.IdentityType) (togglr.User, error) {
+		for _, user := range created {
+			if user.Email == email && user.Identity == identity {
+				return user, nil
+			}
+		}
+		return togglr.User{}, togglr.ErrNotFound
+	}
+	us.CreateUserFn = func(ctx context.Context, user togglr.User) (uid.UID, error) {
+		user.ID = uid.New()
+		created = append(created, user)
+		return user.ID, nil
+	}
+
+	ext := togglr.ExternalIdentity{Email: "jane@example.com"}
+	domains := []string{"Example.com"}
+
+	// RUN
+	if _, err := togglr.LoginExternal(ctx, us, togglr.IdentityTypeGithub, ext, nil); !errors.Is(err, togglr.ErrUnknownUser) {
+		t.Fatalf("expected signing up without any signup domains to fail, got %v", err)
+	}
+
+	if _, err := togglr.LoginExternal(ctx, us, togglr.IdentityTypeGithub, togglr.ExternalIdentity{Email: "jane@example.com.evil"}, domains); !errors.Is(err, togglr.ErrUnknownUser) {
+		t.Fatalf("expected signing up from another domain to fail, got %v", err)
+	}
+
+	first, err := togglr.LoginExternal(ctx, us, togglr.IdentityTypeGithub, ext, domains)
+	if err != nil {
+		t.Fatalf("failed to log in: %s", err)
+	}
+
+	if first.ID.IsNull() || first.Name != ext.Email {
+		t.Fatalf("expected a new user named after their email, got %+v", first)
+	}
+
+	again, err := togglr.LoginExternal(ctx, us, togglr.IdentityTypeGithub, ext, domains)
+	if err != nil {
+		t.Fatalf("failed to log in again: %s", err)
+	}
+
+	if !again.ID.Equals(first.ID) {
+		t.Fatalf("expected logging in again to find the same user")
+	}
+
+	google, err := togglr.LoginExternal(ctx, us, togglr.IdentityTypeGoogle, ext, domains)
+	if err != nil {
+		t.Fatalf("failed to log in with google: %s", err)
+	}
+
+	if google.ID.Equals(first.ID) || len(created) != 2 {
+		t.Fatalf("expected the same email with another identity type to be a separate user")
+	}
+
+	existing, err := togglr.LoginExternal(ctx, us, togglr.IdentityTypeGithub, ext, nil)
+	if err != nil || !existing.ID.Equals(first.ID) {
+		t.Fatalf("expected existing users to log in without signup domains, got %v", err)
+	}
+
+	// another login creates the user between the lookup and the insert
+	raced := togglr.User{ID: uid.New(), Email: "john@example.com", Identity: togglr.IdentityTypeGithub}
+	us.CreateUserFn = func(ctx context.Context, user togglr.User) (uid.UID, error) {
+		created = append(created, raced)
+		return uid.UID{}, togglr.ErrUserExists
+	}
+
+	concurrent, err := togglr.LoginExternal(ctx, us, togglr.IdentityTypeGithub, togglr.ExternalIdentity{Email: raced.Email}, domains)
+	if err != nil || !concurrent.ID.Equals(raced.ID) {
+		t.Fatalf("expected a concurrent first login to find the user it raced with, got %v", err)
+	}
+}
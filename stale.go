@@ -0,0 +1,77 @@
+package togglr
+
+import (
+	"context"
+	"time"
+
+	"github.com/togglr-io/togglr/uid"
+)
+
+// A StaleReason describes why a Toggle is considered stale
+type StaleReason string
+
+// Enumeration of possible StaleReasons
+const (
+	StaleReasonNeverEvaluated = StaleReason("never-evaluated")
+	StaleReasonNotEvaluated   = StaleReason("not-evaluated")
+	StaleReasonAlwaysTrue     = StaleReason("always-true")
+	StaleReasonAlwaysFalse    = StaleReason("always-false")
+)
+
+// A StaleToggle is a Toggle that's a candidate for being cleaned up, along with the usage that led to it being
+// flagged
+type StaleToggle struct {
+	Toggle Toggle       `json:"toggle"`
+	Usage  *ToggleUsage `json:"usage"`
+	Reason StaleReason  `json:"reason"`
+}
+
+// FindStaleToggles reports the Toggles in an account that haven't been evaluated at all, haven't been evaluated
+// within the given age, or have only served a single value for at least that long. Toggles younger than the age are
+// never considered stale
+func FindStaleToggles(ctx context.Context, ts ToggleService, us UsageService, accountID uid.UID, age time.Duration) ([]StaleToggle, error) {
+	toggles, err := ts.ListToggles(ctx, ListTogglesReq{AccountID: accountID})
+	if err != nil {
+		return nil, err
+	}
+
+	usages, err := us.ListUsage(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	usageByToggle := make(map[uid.UID]ToggleUsage, len(usages))
+	for _, usage := range usages {
+		usageByToggle[usage.ToggleID] = usage
+	}
+
+	cutoff := time.Now().Add(-age)
+	stale := []StaleToggle{}
+	for _, toggle := range toggles {
+		if toggle.CreatedAt.After(cutoff) {
+			continue
+		}
+
+		usage, ok := usageByToggle[toggle.ID]
+		if !ok {
+			stale = append(stale, StaleToggle{Toggle: toggle, Reason: StaleReasonNeverEvaluated})
+			continue
+		}
+
+		var reason StaleReason
+		switch {
+		case usage.LastEvaluatedAt.Before(cutoff):
+			reason = StaleReasonNotEvaluated
+		case usage.LastFalseAt == nil || usage.LastFalseAt.Before(cutoff):
+			reason = StaleReasonAlwaysTrue
+		case usage.LastTrueAt == nil || usage.LastTrueAt.Before(cutoff):
+			reason = StaleReasonAlwaysFalse
+		default:
+			continue
+		}
+
+		stale = append(stale, StaleToggle{Toggle: toggle, Usage: &usage, Reason: reason})
+	}
+
+	return stale, nil
+}
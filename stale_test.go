@@ -0,0 +1,126 @@
+package togglr_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/mock"
+	"github.com/togglr-io/togglr/uid"
+	"go.uber.org/zap"
+)
+
+func Test_FindStaleToggles(t *testing.T) {
+	// SETUP
+	now := time.Now()
+	old := now.Add(-60 * 24 * time.Hour)
+	recent := now.Add(-time.Hour)
+	ids := map[string]uid.UID{
+		"unused":       uid.New(),
+		"forgotten":    uid.New(),
+		"always-on":    uid.New(),
+		"always-off":   uid.New(),
+		"healthy":      uid.New(),
+		"brand-new":    uid.New(),
+		"unused-never": uid.New(),
+	}
+
+	ts := mock.NewToggleService(nil)
+	ts.ListTogglesFn = func(ctx context.Context, req togglr.ListTogglesReq) ([]togglr.Toggle, error) {
+		toggles := []togglr.Toggle{}
+		for key, id := range ids {
+			created := old
+			if key == "brand-new" {
+				created = recent
+			}
+			toggles = append(toggles, togglr.Toggle{ID: id, Key: key, CreatedAt: created})
+		}
+		return toggles, nil
+	}
+
+	us := mock.NewUsageService(nil)
+	us.ListUsageFn = func(ctx context.Context, accountID uid.UID) ([]togglr.ToggleUsage, error) {
+		return []togglr.ToggleUsage{
+			{ToggleID: ids["forgotten"], LastEvaluatedAt: old, LastTrueAt: &old, LastFalseAt: &old},
+			{ToggleID: ids["always-on"], LastEvaluatedAt: recent, LastTrueAt: &recent, LastFalseAt: &old},
+			{ToggleID: ids["always-off"], LastEvaluatedAt: recent, LastFalseAt: &recent},
+			{ToggleID: ids["healthy"], LastEvaluatedAt: recent, LastTrueAt: &recent, LastFalseAt: &recent},
+		}, nil
+	}
+
+	expected := map[string]togglr.StaleReason{
+		"unused":       togglr.StaleReasonNeverEvaluated,
+		"unused-never": togglr.StaleReasonNeverEvaluated,
+		"forgotten":    togglr.StaleReasonNotEvaluated,
+		"always-on":    togglr.StaleReasonAlwaysTrue,
+		"always-off":   togglr.StaleReasonAlwaysFalse,
+	}
+
+	// RUN
+	stale, err := togglr.FindStaleToggles(context.TODO(), ts, us, uid.New(), 30*24*time.Hour)
+	if err != nil {
+		t.Fatalf("failed to find stale toggles: %s", err)
+	}
+
+	if len(stale) != len(expected) {
+		t.Fatalf("expected %d stale toggles, got %d", len(expected), len(stale))
+	}
+
+	for _, s := range stale {
+		reason, ok := expected[s.Toggle.Key]
+		if !ok {
+			t.Fatalf("did not expect '%s' to be stale", s.Toggle.Key)
+		}
+
+		if s.Reason != reason {
+			t.Fatalf("expected '%s' to be stale because %s, not %s", s.Toggle.Key, reason, s.Reason)
+		}
+	}
+}
+
+func Test_UsageRecorder(t *testing.T) {
+	// SETUP
+	toggleID := uid.New()
+	us := mock.NewUsageService(nil)
+	recorded := make(chan []togglr.ToggleUsage, 1)
+	us.RecordUsageFn = func(ctx context.Context, usage ...togglr.ToggleUsage) error {
+		recorded <- usage
+		return nil
+	}
+
+	recorder := togglr.NewUsageRecorder(us, time.Hour, zap.NewNop())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		recorder.Run(ctx)
+		close(done)
+	}()
+
+	first := time.Now().Add(-time.Minute)
+	last := time.Now()
+
+	// RUN
+	recorder.RecordEvaluation(togglr.Evaluation{ToggleID: toggleID, Value: true, Time: first})
+	recorder.RecordEvaluation(togglr.Evaluation{ToggleID: toggleID, Value: false, Time: last})
+	recorder.RecordEvaluation(togglr.Evaluation{ToggleID: toggleID, Value: true, Time: first})
+	cancel()
+	<-done
+
+	usage := <-recorded
+	if len(usage) != 1 {
+		t.Fatalf("expected evaluations to be aggregated into 1 usage, got %d", len(usage))
+	}
+
+	if !usage[0].LastEvaluatedAt.Equal(last) {
+		t.Fatalf("expected last evaluation at %s, got %s", last, usage[0].LastEvaluatedAt)
+	}
+
+	if usage[0].LastTrueAt == nil || !usage[0].LastTrueAt.Equal(first) {
+		t.Fatalf("expected last true evaluation at %s, got %v", first, usage[0].LastTrueAt)
+	}
+
+	if usage[0].LastFalseAt == nil || !usage[0].LastFalseAt.Equal(last) {
+		t.Fatalf("expected last false evaluation at %s, got %v", last, usage[0].LastFalseAt)
+	}
+}
@@ -0,0 +1,76 @@
+// Package stats implements the statistics used to analyze experiments. Conversions are modelled as binomial
+// proportions, compared with a two-proportion z-test and, for a Bayesian view, the probability that one rate beats
+// another under uniform Beta priors
+package stats
+
+import "math"
+
+// Z95 is the z-score of a two-sided 95% confidence level
+const Z95 = 1.959963984540054
+
+// Rate returns the proportion of trials that were successes, or 0 when there weren't any trials
+func Rate(successes, trials int64) float64 {
+	if trials <= 0 {
+		return 0
+	}
+
+	return float64(successes) / float64(trials)
+}
+
+// WilsonInterval returns the Wilson score interval of a proportion for the given z-score. Unlike the normal
+// approximation it stays within [0, 1] and behaves with few trials or rates close to 0 or 1
+func WilsonInterval(successes, trials int64, z float64) (float64, float64) {
+	if trials <= 0 {
+		return 0, 1
+	}
+
+	n := float64(trials)
+	p := Rate(successes, trials)
+	z2 := z * z
+	center := (p + z2/(2*n)) / (1 + z2/n)
+	margin := z / (1 + z2/n) * math.Sqrt(p*(1-p)/n+z2/(4*n*n))
+
+	return math.Max(0, center-margin), math.Min(1, center+margin)
+}
+
+// TwoProportionPValue returns the two-sided p-value of a pooled two-proportion z-test of whether a and b convert at
+// different rates. It's 1 when there isn't enough data to tell them apart
+func TwoProportionPValue(successesA, trialsA, successesB, trialsB int64) float64 {
+	if trialsA <= 0 || trialsB <= 0 {
+		return 1
+	}
+
+	nA, nB := float64(trialsA), float64(trialsB)
+	pooled := float64(successesA+successesB) / (nA + nB)
+	se := math.Sqrt(pooled * (1 - pooled) * (1/nA + 1/nB))
+	if se == 0 {
+		return 1
+	}
+
+	z := (Rate(successesB, trialsB) - Rate(successesA, trialsA)) / se
+	return 2 * (1 - NormalCDF(math.Abs(z)))
+}
+
+// ProbabilityToBeat returns the posterior probability that b converts at a higher rate than a, starting from a
+// uniform prior on both rates. The Beta posteriors are approximated as normal distributions, which is accurate once
+// each has more than a handful of trials
+func ProbabilityToBeat(successesA, trialsA, successesB, trialsB int64) float64 {
+	meanA, varA := betaMoments(successesA, trialsA)
+	meanB, varB := betaMoments(successesB, trialsB)
+
+	return NormalCDF((meanB - meanA) / math.Sqrt(varA+varB))
+}
+
+// betaMoments returns the mean and variance of the Beta(1+successes, 1+failures) posterior
+func betaMoments(successes, trials int64) (float64, float64) {
+	alpha := float64(1 + successes)
+	beta := float64(1 + trials - successes)
+	sum := alpha + beta
+
+	return alpha / sum, alpha * beta / (sum * sum * (sum + 1))
+}
+
+// NormalCDF returns the cumulative distribution function of the standard normal distribution at x
+func NormalCDF(x float64) float64 {
+	return 0.5 * math.Erfc(-x/math.Sqrt2)
+}
@@ -0,0 +1,79 @@
+package stats_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/togglr-io/togglr/stats"
+)
+
+func near(a, b, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance
+}
+
+func Test_WilsonInterval(t *testing.T) {
+	cases := []struct {
+		name         string
+		successes    int64
+		trials       int64
+		expectedLow  float64
+		expectedHigh float64
+	}{
+		{"half", 50, 100, 0.4038, 0.5962},
+		{"none", 0, 10, 0, 0.2775},
+		{"all", 10, 10, 0.7225, 1},
+		{"no trials", 0, 0, 0, 1},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			low, high := stats.WilsonInterval(c.successes, c.trials, stats.Z95)
+			if !near(low, c.expectedLow, 1e-4) || !near(high, c.expectedHigh, 1e-4) {
+				t.Fatalf("expected [%.4f, %.4f], got [%.4f, %.4f]", c.expectedLow, c.expectedHigh, low, high)
+			}
+		})
+	}
+}
+
+func Test_TwoProportionPValue(t *testing.T) {
+	cases := []struct {
+		name     string
+		a        [2]int64
+		b        [2]int64
+		expected float64
+	}{
+		{"significant", [2]int64{100, 1000}, [2]int64{150, 1000}, 0.0007},
+		{"not significant", [2]int64{100, 1000}, [2]int64{110, 1000}, 0.4657},
+		{"identical", [2]int64{100, 1000}, [2]int64{100, 1000}, 1},
+		{"no trials", [2]int64{0, 0}, [2]int64{10, 100}, 1},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p := stats.TwoProportionPValue(c.a[0], c.a[1], c.b[0], c.b[1])
+			if !near(p, c.expected, 1e-4) {
+				t.Fatalf("expected a p-value of %.4f, got %.4f", c.expected, p)
+			}
+		})
+	}
+}
+
+func Test_ProbabilityToBeat(t *testing.T) {
+	// SETUP
+	better := stats.ProbabilityToBeat(100, 1000, 150, 1000)
+	worse := stats.ProbabilityToBeat(150, 1000, 100, 1000)
+	same := stats.ProbabilityToBeat(100, 1000, 100, 1000)
+
+	// RUN
+	if better < 0.99 {
+		t.Fatalf("expected a clearly better variant to almost certainly beat the other, got %.4f", better)
+	}
+
+	if !near(better+worse, 1, 1e-9) {
+		t.Fatalf("expected the probabilities of beating each other to add up to 1, got %.4f", better+worse)
+	}
+
+	if !near(same, 0.5, 1e-9) {
+		t.Fatalf("expected identical variants to have even odds, got %.4f", same)
+	}
+}
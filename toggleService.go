@@ -2,6 +2,8 @@ package togglr
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/togglr-io/togglr/rules"
 	"github.com/togglr-io/togglr/uid"
@@ -48,12 +50,20 @@ func extractKeys(expr rules.Expr) []string {
 	return keys
 }
 
-func (s DefaultToggleService) pushKeys(ctx context.Context, accountID uid.UID, rules rules.Rules) {
+// pushKeysTimeout bounds how long pushing metadata keys may take once the request that triggered it has finished
+const pushKeysTimeout = 10 * time.Second
+
+// pushKeys records the metadata keys referenced by a set of Rules. It's meant to run in the background, so it uses its
+// own context rather than one tied to the lifetime of the request
+func (s DefaultToggleService) pushKeys(accountID uid.UID, rules rules.Rules) {
 	defer s.log.Sync()
 	if rules == nil || len(rules) == 0 {
 		return
 	}
 
+	ctx, cancel := context.WithTimeout(context.Background(), pushKeysTimeout)
+	defer cancel()
+
 	// collect keys from Rules
 	keys := []string{}
 	for _, rule := range rules {
@@ -65,28 +75,201 @@ func (s DefaultToggleService) pushKeys(ctx context.Context, accountID uid.UID, r
 	}
 }
 
+// validateVariants checks that every Variant can be told apart and that something can be assigned
+func validateVariants(variants Variants) error {
+	seen := make(map[string]bool, len(variants))
+	total := 0
+	for _, variant := range variants {
+		if variant.Key == "" || seen[variant.Key] {
+			return fmt.Errorf("%w: keys must be present and unique", ErrInvalidVariants)
+		}
+		seen[variant.Key] = true
+
+		if variant.Weight < 0 {
+			return fmt.Errorf("%w: weights can't be negative", ErrInvalidVariants)
+		}
+		total += variant.Weight
+	}
+
+	if len(variants) > 0 && total == 0 {
+		return fmt.Errorf("%w: at least one weight must be positive", ErrInvalidVariants)
+	}
+
+	return nil
+}
+
 func (s DefaultToggleService) CreateToggle(ctx context.Context, toggle Toggle) (uid.UID, error) {
-	// push keys asynchronously so we don't keep the caller waiting
-	go s.pushKeys(ctx, toggle.AccountID, toggle.Rules)
+	if err := validateVariants(toggle.Variants); err != nil {
+		return uid.UID{}, err
+	}
 
 	toggle.ID = uid.New()
-	return s.ts.CreateToggle(ctx, toggle)
+	toggle.ExperimentID = uid.UID{}
+	if toggle.Kind == "" {
+		toggle.Kind = ToggleKindRelease
+	}
+
+	if !toggle.Kind.Valid() {
+		return uid.UID{}, fmt.Errorf("%w: %s", ErrInvalidKind, toggle.Kind)
+	}
+
+	id, err := s.ts.CreateToggle(ctx, toggle)
+	if err != nil {
+		return uid.UID{}, err
+	}
+
+	// push keys asynchronously so we don't keep the caller waiting
+	go s.pushKeys(toggle.AccountID, toggle.Rules)
+
+	return id, nil
+}
+
+// checkApproval refuses direct edits to a Toggle that requires approval. Edits made while applying an approved
+// ChangeRequest are allowed through
+func (s DefaultToggleService) checkApproval(ctx context.Context, id uid.UID) error {
+	if isApprovedChange(ctx) {
+		return nil
+	}
+
+	toggle, err := s.ts.FetchToggle(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if toggle.RequiresApproval {
+		return ErrApprovalRequired
+	}
+
+	return nil
 }
 
+// UpdateToggle updates a Toggle. The Variants of a Toggle can't change while an Experiment is running on it
 func (s DefaultToggleService) UpdateToggle(ctx context.Context, req UpdateToggleReq) error {
-	go s.pushKeys(ctx, req.AccountID, req.Rules)
+	if err := s.checkApproval(ctx, req.ID); err != nil {
+		return err
+	}
+
+	if req.Kind != nil && !req.Kind.Valid() {
+		return fmt.Errorf("%w: %s", ErrInvalidKind, *req.Kind)
+	}
+
+	if req.Variants != nil {
+		if err := validateVariants(req.Variants); err != nil {
+			return err
+		}
+
+		toggle, err := s.ts.FetchToggle(ctx, req.ID)
+		if err != nil {
+			return err
+		}
+
+		if !toggle.ExperimentID.IsNull() {
+			return ErrExperimentRunning
+		}
+	}
+
+	if err := s.ts.UpdateToggle(ctx, req); err != nil {
+		return err
+	}
+
+	go s.pushKeys(req.AccountID, req.Rules)
 
-	return s.ts.UpdateToggle(ctx, req)
+	return nil
 }
 
 func (s DefaultToggleService) FetchToggle(ctx context.Context, id uid.UID) (Toggle, error) {
 	return s.ts.FetchToggle(ctx, id)
 }
 
+func (s DefaultToggleService) FetchToggleByKey(ctx context.Context, accountID uid.UID, key string) (Toggle, error) {
+	return s.ts.FetchToggleByKey(ctx, accountID, key)
+}
+
 func (s DefaultToggleService) ListToggles(ctx context.Context, req ListTogglesReq) ([]Toggle, error) {
 	return s.ts.ListToggles(ctx, req)
 }
 
+func (s DefaultToggleService) ListDependents(ctx context.Context, accountID uid.UID, key string) ([]Toggle, error) {
+	return s.ts.ListDependents(ctx, accountID, key)
+}
+
+// checkDependents returns ErrPrerequisite if any other toggle in the same account lists the given Toggle as a
+// prerequisite. Archived toggles are only considered when includeArchived is set
+func (s DefaultToggleService) checkDependents(ctx context.Context, toggle Toggle, includeArchived bool) error {
+	dependents, err := s.ts.ListDependents(ctx, toggle.AccountID, toggle.Key)
+	if err != nil {
+		return err
+	}
+
+	for _, dependent := range dependents {
+		if dependent.ID.Equals(toggle.ID) || (dependent.Archived() && !includeArchived) {
+			continue
+		}
+
+		return fmt.Errorf("%w: required by %s", ErrPrerequisite, dependent.Key)
+	}
+
+	return nil
+}
+
+// DeleteToggle permanently purges a Toggle. Only archived toggles that no other toggle depends on, archived or
+// not, can be purged
 func (s DefaultToggleService) DeleteToggle(ctx context.Context, id uid.UID) error {
+	if err := s.checkApproval(ctx, id); err != nil {
+		return err
+	}
+
+	toggle, err := s.ts.FetchToggle(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if !toggle.Archived() {
+		return ErrNotArchived
+	}
+
+	if err := s.checkDependents(ctx, toggle, true); err != nil {
+		return err
+	}
+
 	return s.ts.DeleteToggle(ctx, id)
 }
+
+// ArchiveToggle hides a Toggle from resolves and default listings. Toggles that are still prerequisites of active
+// toggles can't be archived
+func (s DefaultToggleService) ArchiveToggle(ctx context.Context, id uid.UID) error {
+	if err := s.checkApproval(ctx, id); err != nil {
+		return err
+	}
+
+	toggle, err := s.ts.FetchToggle(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkDependents(ctx, toggle, false); err != nil {
+		return err
+	}
+
+	return s.ts.ArchiveToggle(ctx, id)
+}
+
+func (s DefaultToggleService) RestoreToggle(ctx context.Context, id uid.UID) error {
+	if err := s.checkApproval(ctx, id); err != nil {
+		return err
+	}
+
+	return s.ts.RestoreToggle(ctx, id)
+}
+
+func (s DefaultToggleService) ListToggleRevisions(ctx context.Context, toggleID uid.UID) ([]ToggleRevision, error) {
+	return s.ts.ListToggleRevisions(ctx, toggleID)
+}
+
+func (s DefaultToggleService) RollbackToggle(ctx context.Context, toggleID uid.UID, revision int) error {
+	if err := s.checkApproval(ctx, toggleID); err != nil {
+		return err
+	}
+
+	return s.ts.RollbackToggle(ctx, toggleID, revision)
+}
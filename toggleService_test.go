@@ -2,8 +2,10 @@ package togglr_test
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/togglr-io/togglr"
 	"github.com/togglr-io/togglr/mock"
@@ -95,3 +97,111 @@ func Test_DefaultToggleService(t *testing.T) {
 		t.Fatalf("expected ToggleService.UpdateTogggle to be called 1 time, not %d", mockTS.UpdateToggleCalled)
 	}
 }
+
+func Test_DefaultToggleServiceKind(t *testing.T) {
+	// SETUP
+	ctx := context.TODO()
+	mockTS := mock.NewToggleService(nil)
+	ms := mock.NewMetadataService(nil)
+	ts := togglr.NewToggleService(mockTS, ms, zap.NewNop())
+	chore := togglr.ToggleKind("chore")
+	rules := rules.Rules{{Expr: rules.Expression{Type: rules.ExprTypeIdent, Ident: rules.NewIdent("team")}}}
+
+	// RUN
+	if _, err := ts.CreateToggle(ctx, togglr.Toggle{Key: "cleanup", Kind: chore, Rules: rules}); !errors.Is(err, togglr.ErrInvalidKind) {
+		t.Fatalf("expected creating a toggle with an unknown kind to fail, got %v", err)
+	}
+
+	if err := ts.UpdateToggle(ctx, togglr.UpdateToggleReq{ID: uid.New(), Kind: &chore, Rules: rules}); !errors.Is(err, togglr.ErrInvalidKind) {
+		t.Fatalf("expected updating a toggle to an unknown kind to fail, got %v", err)
+	}
+
+	if mockTS.CreateToggleCalled != 0 || mockTS.UpdateToggleCalled != 0 {
+		t.Fatalf("expected toggles with unknown kinds not to be saved")
+	}
+
+	if ms.PushKeysCalled != 0 {
+		t.Fatalf("expected no metadata keys to be pushed for rejected toggles, pushed %d times", ms.PushKeysCalled)
+	}
+}
+
+func Test_DefaultToggleServiceArchive(t *testing.T) {
+	// SETUP
+	ctx := context.TODO()
+	accountID := uid.New()
+	archivedAt := time.Now()
+	base := togglr.Toggle{ID: uid.New(), AccountID: accountID, Key: "base-feature"}
+	dependent := togglr.Toggle{
+		ID:            uid.New(),
+		AccountID:     accountID,
+		Key:           "dependent-feature",
+		Prerequisites: togglr.Keys{"base-feature"},
+	}
+	toggles := []togglr.Toggle{base, dependent}
+
+	mockTS := mock.NewToggleService(nil)
+	mockTS.FetchToggleFn = func(ctx context.Context, id uid.UID) (togglr.Toggle, error) {
+		for _, toggle := range toggles {
+			if toggle.ID.Equals(id) {
+				return toggle, nil
+			}
+		}
+
+		return togglr.Toggle{}, togglr.ErrNotFound
+	}
+	mockTS.ListDependentsFn = func(ctx context.Context, accountID uid.UID, key string) ([]togglr.Toggle, error) {
+		dependents := []togglr.Toggle{}
+		for _, toggle := range toggles {
+			for _, prerequisite := range toggle.Prerequisites {
+				if prerequisite == key {
+					dependents = append(dependents, toggle)
+				}
+			}
+		}
+
+		return dependents, nil
+	}
+	ts := togglr.NewToggleService(mockTS, mock.NewMetadataService(nil), zap.NewNop())
+
+	// RUN
+	if err := ts.ArchiveToggle(ctx, base.ID); !errors.Is(err, togglr.ErrPrerequisite) {
+		t.Fatalf("expected archiving a prerequisite to fail with ErrPrerequisite, got %v", err)
+	}
+
+	if err := ts.DeleteToggle(ctx, dependent.ID); !errors.Is(err, togglr.ErrNotArchived) {
+		t.Fatalf("expected purging an active toggle to fail with ErrNotArchived, got %v", err)
+	}
+
+	// archive the dependent so that it no longer blocks archiving its prerequisite
+	if err := ts.ArchiveToggle(ctx, dependent.ID); err != nil {
+		t.Fatalf("failed to archive toggle: %s", err)
+	}
+	toggles[1].ArchivedAt = &archivedAt
+
+	if err := ts.ArchiveToggle(ctx, base.ID); err != nil {
+		t.Fatalf("failed to archive toggle: %s", err)
+	}
+	toggles[0].ArchivedAt = &archivedAt
+
+	// archived dependents still prevent their prerequisites from being purged
+	if err := ts.DeleteToggle(ctx, base.ID); !errors.Is(err, togglr.ErrPrerequisite) {
+		t.Fatalf("expected purging a prerequisite to fail with ErrPrerequisite, got %v", err)
+	}
+
+	if err := ts.DeleteToggle(ctx, dependent.ID); err != nil {
+		t.Fatalf("failed to purge toggle: %s", err)
+	}
+
+	if mockTS.ArchiveToggleCalled != 2 {
+		t.Fatalf("expected ToggleService.ArchiveToggle to be called 2 times, not %d", mockTS.ArchiveToggleCalled)
+	}
+
+	if mockTS.DeleteToggleCalled != 1 {
+		t.Fatalf("expected ToggleService.DeleteToggle to be called 1 time, not %d", mockTS.DeleteToggleCalled)
+	}
+
+	// dependents are looked up directly rather than by paging through every toggle in the account
+	if mockTS.ListTogglesCalled != 0 {
+		t.Fatalf("expected ToggleService.ListToggles not to be called, called %d times", mockTS.ListTogglesCalled)
+	}
+}
@@ -2,6 +2,9 @@ package togglr
 
 import (
 	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"time"
 
 	"github.com/togglr-io/togglr/rules"
@@ -18,6 +21,27 @@ const (
 	IdentityTypeGoogle = IdentityType("google")
 )
 
+// A ToggleKind describes what a Toggle is used for, which helps with organizing large numbers of toggles
+type ToggleKind string
+
+// Enumeration of possible ToggleKinds
+const (
+	ToggleKindRelease    = ToggleKind("release")
+	ToggleKindExperiment = ToggleKind("experiment")
+	ToggleKindOps        = ToggleKind("ops")
+	ToggleKindPermission = ToggleKind("permission")
+)
+
+// Valid returns true for the known ToggleKinds
+func (k ToggleKind) Valid() bool {
+	switch k {
+	case ToggleKindRelease, ToggleKindExperiment, ToggleKindOps, ToggleKindPermission:
+		return true
+	}
+
+	return false
+}
+
 // An ID is a wrapper struct for working with JSON payloads containing
 // an ID. This is used to differentiate between creates and updates
 // as well as a return type for certain operations
@@ -29,16 +53,20 @@ type ID struct {
 type Account struct {
 	ID        uid.UID   `json:"id" db:"id"`
 	Name      string    `json:"name" db:"name"`
+	Version   int       `json:"version" db:"version" goqu:"skipinsert,skipupdate"`
 	CreatedAt time.Time `json:"createdAt" db:"created_at" goqu:"skipinsert,skipupdate"`
 	UpdatedAt time.Time `json:"updatedAt" db:"updated_at" goqu:"skipinsert,skipupdate"`
 }
 
 type UpdateAccountReq struct {
-	ID   uid.UID `json:"id" db:"-"`
-	Name *string `json:"name,omitempty" db:"name,omitempty"`
+	ID      uid.UID `json:"id" db:"-"`
+	Version int     `json:"version" db:"-"`
+	Name    *string `json:"name,omitempty" db:"name,omitempty"`
 }
 
+// ListAccountsReq defines the sorting and pagination parameters used when generating a list of accounts
 type ListAccountsReq struct {
+	Page
 }
 
 // UpdateAccountUsersReq contains a list of User UIDs to be added to the account and a list
@@ -57,33 +85,251 @@ type AccountService interface {
 	UpdateAccountUsers(ctx context.Context, accountID uid.UID, req UpdateAccountUsersReq) error
 }
 
-// A Toggle represents a key and the set of rules that determine the value that should be returned for it
+// A Toggle represents a key and the set of rules that determine the value that should be returned for it. A Toggle
+// that RequiresApproval can only be modified by applying an approved ChangeRequest. A Toggle only resolves to true
+// when all of its Prerequisites, given as the keys of other toggles in the same account, also resolve to true. An
+// archived Toggle is hidden from resolves and default listings but keeps its history until it's purged, and its key
+// can be reused. A Toggle can also carry a Payload, an arbitrary JSON value that clients are served while the Toggle resolves to true. A
+// Toggle with Variants is multivariate: metadata its rules match is split between the Variants by weight, and the
+// Variant assigned decides the value and Payload served. ExperimentID and Allocation are set while an Experiment is
+// running on the Toggle and can only be changed by starting or stopping one
 type Toggle struct {
-	ID          uid.UID     `json:"id" db:"id"`
-	AccountID   uid.UID     `json:"accountId" db:"account_id"`
-	Key         string      `json:"key" db:"key"`
-	Description string      `json:"description" db:"description"`
-	Active      bool        `json:"active" db:"active"`
-	Rules       rules.Rules `json:"rules" db:"rules"`
-	CreatedAt   time.Time   `json:"createdAt" db:"created_at" goqu:"skipinsert,skipupdate"`
-	UpdatedAt   time.Time   `json:"updatedAt" db:"updated_at" goqu:"skipinsert,skipupdate"`
+	ID               uid.UID     `json:"id" db:"id"`
+	AccountID        uid.UID     `json:"accountId" db:"account_id"`
+	Key              string      `json:"key" db:"key"`
+	Description      string      `json:"description" db:"description"`
+	Active           bool        `json:"active" db:"active"`
+	Rules            rules.Rules `json:"rules" db:"rules"`
+	RequiresApproval bool        `json:"requiresApproval" db:"requires_approval"`
+	Kind             ToggleKind  `json:"kind" db:"kind"`
+	Owner            string      `json:"owner" db:"owner"`
+	Tags             Tags        `json:"tags" db:"tags"`
+	Prerequisites    Keys        `json:"prerequisites" db:"prerequisites"`
+	Payload          Payload     `json:"payload,omitempty" db:"payload"`
+	Variants         Variants    `json:"variants,omitempty" db:"variants"`
+	ExperimentID     uid.UID     `json:"experimentId" db:"experiment_id"`
+	Allocation       *Allocation `json:"allocation,omitempty" db:"allocation"`
+	Version          int         `json:"version" db:"version" goqu:"skipinsert,skipupdate"`
+	ArchivedAt       *time.Time  `json:"archivedAt" db:"archived_at" goqu:"skipinsert,skipupdate"`
+	CreatedAt        time.Time   `json:"createdAt" db:"created_at" goqu:"skipinsert,skipupdate"`
+	UpdatedAt        time.Time   `json:"updatedAt" db:"updated_at" goqu:"skipinsert,skipupdate"`
+}
+
+// Archived returns true if the Toggle has been archived
+func (t Toggle) Archived() bool {
+	return t.ArchivedAt != nil
 }
 
 // An UpdateToggleReq contains all of the fields that are possible to update on a Toggle. The main difference from
 // the Toggle struct is that some of the fields are pointers to differentiate from a field being omitted and an
-// actual update containing the zero value
+// actual update containing the zero value. Version is the version of the Toggle the update was based on, an update
+// made against a stale version fails with ErrConflict. A zero Version skips the check
 type UpdateToggleReq struct {
 	ID          uid.UID     `json:"id" db:"-"`
 	AccountID   uid.UID     `json:"accountId" db:"-"`
+	Version     int         `json:"version" db:"-"`
 	Key         *string     `json:"key,omitempty" db:"key,omitempty"`
 	Description *string     `json:"description,omitempty" db:"description,omitempty"`
 	Active      *bool       `json:"active" db:"active,omitempty"`
 	Rules       rules.Rules `json:"rules" db:"rules,omitempty"`
+
+	RequiresApproval *bool       `json:"requiresApproval,omitempty" db:"requires_approval,omitempty"`
+	Kind             *ToggleKind `json:"kind,omitempty" db:"kind,omitempty"`
+	Owner            *string     `json:"owner,omitempty" db:"owner,omitempty"`
+	Tags             Tags        `json:"tags,omitempty" db:"tags,omitempty"`
+	Prerequisites    Keys        `json:"prerequisites,omitempty" db:"prerequisites,omitempty"`
+	Payload          Payload     `json:"payload,omitempty" db:"payload,omitempty"`
+	Variants         Variants    `json:"variants,omitempty" db:"variants,omitempty"`
+	ExperimentID     *uid.UID    `json:"-" db:"experiment_id,omitempty"`
+	Allocation       *Allocation `json:"-" db:"allocation,omitempty"`
+}
+
+// Value implements the driver.Valuer interface so that proposed updates can be stored alongside a ChangeRequest
+func (r UpdateToggleReq) Value() (driver.Value, error) {
+	return json.Marshal(r)
+}
+
+// Scan implements the sql.Scanner interface
+func (r *UpdateToggleReq) Scan(src interface{}) error {
+	var source []byte
+	switch val := src.(type) {
+	case string:
+		source = []byte(val)
+	case []byte:
+		source = val
+	default:
+		return errors.New("incompatible type for UpdateToggleReq")
+	}
+
+	return json.Unmarshal(source, r)
 }
 
-// ListTogglesReq defines the search parameters that will be used when generating a list of toggles
+// ListTogglesReq defines the search parameters that will be used when generating a list of toggles. Empty fields
+// aren't used for filtering. Search matches against the key and description of each Toggle. Archived toggles are
+// only listed, to the exclusion of all others, when Archived is set
 type ListTogglesReq struct {
-	AccountID uid.UID `json:"accountId" db:"account_id"`
+	AccountID uid.UID    `json:"accountId" db:"account_id"`
+	Tag       string     `json:"tag"`
+	Owner     string     `json:"owner"`
+	Kind      ToggleKind `json:"kind"`
+	Active    *bool      `json:"active"`
+	Search    string     `json:"search"`
+	Archived  bool       `json:"archived"`
+	Page
+}
+
+// Tags is an alias to a string slice that we can implement some interfaces on
+type Tags []string
+
+// Value implements the driver.Valuer interface
+func (t Tags) Value() (driver.Value, error) {
+	// store missing tags as an empty list so that they can always be searched
+	if t == nil {
+		return []byte("[]"), nil
+	}
+
+	return json.Marshal(t)
+}
+
+// Scan implements the sql.Scanner interface
+func (t *Tags) Scan(src interface{}) error {
+	var source []byte
+	switch val := src.(type) {
+	case string:
+		source = []byte(val)
+	case []byte:
+		source = val
+	case nil:
+		*t = Tags{}
+		return nil
+	default:
+		return errors.New("incompatible type for Tags")
+	}
+
+	return json.Unmarshal(source, t)
+}
+
+// A Payload is an arbitrary JSON value. An empty Payload is stored as NULL
+type Payload json.RawMessage
+
+// MarshalJSON implements the json.Marshaler interface
+func (p Payload) MarshalJSON() ([]byte, error) {
+	if len(p) == 0 {
+		return []byte("null"), nil
+	}
+
+	return p, nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface
+func (p *Payload) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*p = nil
+		return nil
+	}
+
+	*p = append((*p)[:0], data...)
+	return nil
+}
+
+// Value implements the driver.Valuer interface
+func (p Payload) Value() (driver.Value, error) {
+	if len(p) == 0 {
+		return nil, nil
+	}
+
+	return []byte(p), nil
+}
+
+// Scan implements the sql.Scanner interface
+func (p *Payload) Scan(src interface{}) error {
+	switch val := src.(type) {
+	case string:
+		*p = Payload(val)
+	case []byte:
+		*p = append(Payload{}, val...)
+	case nil:
+		*p = nil
+	default:
+		return errors.New("incompatible type for Payload")
+	}
+
+	return nil
+}
+
+// A Variant is one of the values a multivariate Toggle can serve. Metadata is assigned to a Variant with a
+// probability proportional to its Weight. A Variant without a Payload serves the Toggle's Payload
+type Variant struct {
+	Key     string  `json:"key"`
+	Weight  int     `json:"weight"`
+	Value   bool    `json:"value"`
+	Payload Payload `json:"payload,omitempty"`
+}
+
+// Variants is a list of Variants that we can implement some interfaces on
+type Variants []Variant
+
+// Value implements the driver.Valuer interface
+func (v Variants) Value() (driver.Value, error) {
+	if v == nil {
+		return []byte("[]"), nil
+	}
+
+	return json.Marshal(v)
+}
+
+// Scan implements the sql.Scanner interface
+func (v *Variants) Scan(src interface{}) error {
+	var source []byte
+	switch val := src.(type) {
+	case string:
+		source = []byte(val)
+	case []byte:
+		source = val
+	case nil:
+		*v = nil
+		return nil
+	default:
+		return errors.New("incompatible type for Variants")
+	}
+
+	return json.Unmarshal(source, v)
+}
+
+// Find returns the Variant with the given key. The returned bool is false if there isn't one
+func (v Variants) Find(key string) (Variant, bool) {
+	for _, variant := range v {
+		if variant.Key == key {
+			return variant, true
+		}
+	}
+
+	return Variant{}, false
+}
+
+// Keys is a list of toggle keys that we can implement some interfaces on. It's stored the same way as Tags
+type Keys []string
+
+// Value implements the driver.Valuer interface
+func (k Keys) Value() (driver.Value, error) {
+	return Tags(k).Value()
+}
+
+// Scan implements the sql.Scanner interface
+func (k *Keys) Scan(src interface{}) error {
+	return (*Tags)(k).Scan(src)
+}
+
+// A ToggleRevision is an immutable, numbered snapshot of the configurable parts of a Toggle. A new revision is
+// recorded every time a Toggle is created, updated or rolled back
+type ToggleRevision struct {
+	ID          uid.UID     `json:"id" db:"id"`
+	ToggleID    uid.UID     `json:"toggleId" db:"toggle_id"`
+	Revision    int         `json:"revision" db:"revision"`
+	Description string      `json:"description" db:"description"`
+	Active      bool        `json:"active" db:"active"`
+	Rules       rules.Rules `json:"rules" db:"rules"`
+	CreatedAt   time.Time   `json:"createdAt" db:"created_at" goqu:"skipinsert,skipupdate"`
 }
 
 // A ToggleService performs basic CRUD operations on toggles
@@ -91,29 +337,392 @@ type ToggleService interface {
 	CreateToggle(ctx context.Context, toggle Toggle) (uid.UID, error)
 	UpdateToggle(ctx context.Context, req UpdateToggleReq) error
 	FetchToggle(ctx context.Context, id uid.UID) (Toggle, error)
+	// FetchToggleByKey returns ErrNotFound if the account doesn't have a Toggle with the given key. Archived
+	// toggles are returned like any other
+	FetchToggleByKey(ctx context.Context, accountID uid.UID, key string) (Toggle, error)
 	ListToggles(ctx context.Context, req ListTogglesReq) ([]Toggle, error)
+	// ListDependents returns every Toggle in an account, archived or not, that lists the given key as one of its
+	// Prerequisites. The results aren't paged
+	ListDependents(ctx context.Context, accountID uid.UID, key string) ([]Toggle, error)
+	// DeleteToggle permanently removes a Toggle along with its history. Prefer ArchiveToggle unless the Toggle
+	// is certain to never be needed again
 	DeleteToggle(ctx context.Context, id uid.UID) error
+	ArchiveToggle(ctx context.Context, id uid.UID) error
+	RestoreToggle(ctx context.Context, id uid.UID) error
+	ListToggleRevisions(ctx context.Context, toggleID uid.UID) ([]ToggleRevision, error)
+	// RollbackToggle restores a Toggle to the state captured in a previous revision. The rollback itself
+	// is recorded as a new revision so that it can be undone in the same way
+	RollbackToggle(ctx context.Context, toggleID uid.UID, revision int) error
+}
+
+// A ConfigVersionService reports the configuration version of an account. The version is bumped every time any of
+// the account's toggles change, so anything derived from them is still current as long as the version is the same
+type ConfigVersionService interface {
+	FetchConfigVersion(ctx context.Context, accountID uid.UID) (int64, error)
+}
+
+// Definitions are the resolvable toggles of an account at some config version. They're everything a client needs to
+// evaluate toggles locally
+type Definitions struct {
+	Version int64    `json:"version"`
+	Toggles []Toggle `json:"toggles"`
+}
+
+// A ChangeOp is the kind of write that produced a ChangeEvent
+type ChangeOp string
+
+// Enumeration of possible ChangeOps
+const (
+	ChangeOpInsert = ChangeOp("INSERT")
+	ChangeOpUpdate = ChangeOp("UPDATE")
+	ChangeOpDelete = ChangeOp("DELETE")
+)
+
+// A ChangeEvent reports that a row belonging to an account was written, no matter which server made the write.
+// Resync events don't refer to any particular row, they're published when changes may have been missed and
+// anything derived from stored state should be rebuilt
+type ChangeEvent struct {
+	Table     string   `json:"table"`
+	Op        ChangeOp `json:"op"`
+	ID        uid.UID  `json:"id"`
+	AccountID uid.UID  `json:"accountId"`
+	Resync    bool     `json:"resync"`
+}
+
+// A ChangeRequestStatus captures where a ChangeRequest is in the approval workflow
+type ChangeRequestStatus string
+
+// Enumeration of possible ChangeRequestStatuses
+const (
+	ChangeRequestStatusPending  = ChangeRequestStatus("pending")
+	ChangeRequestStatusApproved = ChangeRequestStatus("approved")
+	ChangeRequestStatusRejected = ChangeRequestStatus("rejected")
+	ChangeRequestStatusApplied  = ChangeRequestStatus("applied")
+)
+
+// A ChangeRequest proposes an update to a Toggle that requires approval. A ChangeRequest starts out pending, is
+// approved or rejected by a reviewer and, once approved, can be applied to the Toggle
+type ChangeRequest struct {
+	ID         uid.UID             `json:"id" db:"id"`
+	AccountID  uid.UID             `json:"accountId" db:"account_id"`
+	ToggleID   uid.UID             `json:"toggleId" db:"toggle_id"`
+	AuthorID   uid.UID             `json:"authorId" db:"author_id"`
+	ReviewerID uid.UID             `json:"reviewerId" db:"reviewer_id"`
+	Status     ChangeRequestStatus `json:"status" db:"status"`
+	Comment    string              `json:"comment" db:"comment"`
+	Change     UpdateToggleReq     `json:"change" db:"change"`
+	CreatedAt  time.Time           `json:"createdAt" db:"created_at" goqu:"skipinsert,skipupdate"`
+	UpdatedAt  time.Time           `json:"updatedAt" db:"updated_at" goqu:"skipinsert,skipupdate"`
+}
+
+// An UpdateChangeRequestReq moves a ChangeRequest through the approval workflow. The ReviewerID is taken from
+// whoever made the request rather than the body. When FromStatus is set the update only applies while the
+// ChangeRequest still has that status, so that concurrent reviews can't both succeed
+type UpdateChangeRequestReq struct {
+	ID         uid.UID              `json:"id" db:"-"`
+	ReviewerID uid.UID              `json:"-" db:"reviewer_id,omitempty"`
+	Status     *ChangeRequestStatus `json:"status,omitempty" db:"status,omitempty"`
+	Comment    *string              `json:"comment,omitempty" db:"comment,omitempty"`
+	FromStatus ChangeRequestStatus  `json:"-" db:"-"`
+}
+
+// ListChangeRequestsReq defines the search parameters that will be used when generating a list of change requests
+type ListChangeRequestsReq struct {
+	AccountID uid.UID             `json:"accountId" db:"account_id"`
+	ToggleID  uid.UID             `json:"toggleId" db:"toggle_id"`
+	Status    ChangeRequestStatus `json:"status" db:"status"`
+}
+
+// A ChangeRequestService performs basic CRUD operations on ChangeRequests
+type ChangeRequestService interface {
+	CreateChangeRequest(ctx context.Context, cr ChangeRequest) (uid.UID, error)
+	UpdateChangeRequest(ctx context.Context, req UpdateChangeRequestReq) error
+	FetchChangeRequest(ctx context.Context, id uid.UID) (ChangeRequest, error)
+	ListChangeRequests(ctx context.Context, req ListChangeRequestsReq) ([]ChangeRequest, error)
+}
+
+// ToggleUsage summarizes when a Toggle was last evaluated and when it last served each value
+type ToggleUsage struct {
+	ToggleID        uid.UID    `json:"toggleId" db:"toggle_id"`
+	AccountID       uid.UID    `json:"accountId" db:"account_id"`
+	LastEvaluatedAt time.Time  `json:"lastEvaluatedAt" db:"last_evaluated_at"`
+	LastTrueAt      *time.Time `json:"lastTrueAt" db:"last_true_at"`
+	LastFalseAt     *time.Time `json:"lastFalseAt" db:"last_false_at"`
+}
+
+// A UsageService records and reports on how Toggles are being evaluated
+type UsageService interface {
+	RecordUsage(ctx context.Context, usage ...ToggleUsage) error
+	ListUsage(ctx context.Context, accountID uid.UID) ([]ToggleUsage, error)
+}
+
+// An EvaluationCount is the number of times a Toggle was evaluated to a variant for a reason within a time bucket
+type EvaluationCount struct {
+	ToggleID  uid.UID   `json:"toggleId" db:"toggle_id"`
+	AccountID uid.UID   `json:"accountId" db:"account_id"`
+	Bucket    time.Time `json:"bucket" db:"bucket"`
+	Variant   string    `json:"variant" db:"variant"`
+	Reason    Reason    `json:"reason" db:"reason"`
+	Count     int64     `json:"count" db:"count"`
+}
+
+// An Interval is the size of the buckets evaluation counts are reported in
+type Interval string
+
+// Enumeration of possible Intervals
+const (
+	IntervalHour = Interval("hour")
+	IntervalDay  = Interval("day")
+)
+
+// ListEvaluationCountsReq defines the search parameters used when reporting evaluation counts for a Toggle. Buckets
+// starting at or after From and before To are included
+type ListEvaluationCountsReq struct {
+	ToggleID uid.UID
+	From     time.Time
+	To       time.Time
+	Interval Interval
+}
+
+// An EventService records evaluation counts and reports on them over time
+type EventService interface {
+	RecordEvaluationCounts(ctx context.Context, counts ...EvaluationCount) error
+	ListEvaluationCounts(ctx context.Context, req ListEvaluationCountsReq) ([]EvaluationCount, error)
+}
+
+// An ExperimentStatus captures where an Experiment is in its lifecycle
+type ExperimentStatus string
+
+// Enumeration of possible ExperimentStatuses
+const (
+	ExperimentStatusDraft   = ExperimentStatus("draft")
+	ExperimentStatusRunning = ExperimentStatus("running")
+	ExperimentStatusStopped = ExperimentStatus("stopped")
+)
+
+// An Experiment measures how the Variants of a multivariate Toggle affect a metric. An Experiment starts out as a
+// draft, is started once and then stopped, and a Toggle can only have one running Experiment at a time. Every
+// context the Toggle is resolved for while the Experiment is running is exposed to the Variant it was assigned, and
+// it converts when a MetricEvent for the Metric is reported for it afterwards. Variants are the keys of the Toggle's
+// Variants, captured when the Experiment starts, and every other Variant is compared to the Control.
+//
+// Experiments in the same Layer of an account are mutually exclusive: the Layer's traffic is split into LayerSlots
+// slots, and a running Experiment owns the Traffic percent of them between SlotStart and SlotEnd. Contexts outside
+// of its slots, and contexts in one of the account's Holdouts, are served the Control without being exposed. An
+// Experiment without a Layer only shares its slots with itself
+type Experiment struct {
+	ID          uid.UID          `json:"id" db:"id"`
+	AccountID   uid.UID          `json:"accountId" db:"account_id"`
+	ToggleID    uid.UID          `json:"toggleId" db:"toggle_id"`
+	Name        string           `json:"name" db:"name"`
+	Description string           `json:"description" db:"description"`
+	Metric      string           `json:"metric" db:"metric"`
+	Control     string           `json:"control" db:"control"`
+	Variants    Keys             `json:"variants" db:"variants"`
+	Layer       string           `json:"layer" db:"layer"`
+	Traffic     int              `json:"traffic" db:"traffic"`
+	SlotStart   int              `json:"slotStart" db:"slot_start"`
+	SlotEnd     int              `json:"slotEnd" db:"slot_end"`
+	Status      ExperimentStatus `json:"status" db:"status"`
+	StartedAt   *time.Time       `json:"startedAt" db:"started_at"`
+	StoppedAt   *time.Time       `json:"stoppedAt" db:"stopped_at"`
+	CreatedAt   time.Time        `json:"createdAt" db:"created_at" goqu:"skipinsert,skipupdate"`
+	UpdatedAt   time.Time        `json:"updatedAt" db:"updated_at" goqu:"skipinsert,skipupdate"`
+}
+
+// An UpdateExperimentReq moves an Experiment through its lifecycle
+type UpdateExperimentReq struct {
+	ID        uid.UID           `json:"id" db:"-"`
+	Status    *ExperimentStatus `json:"status,omitempty" db:"status,omitempty"`
+	Variants  Keys              `json:"-" db:"variants,omitempty"`
+	SlotStart *int              `json:"-" db:"slot_start,omitempty"`
+	SlotEnd   *int              `json:"-" db:"slot_end,omitempty"`
+	StartedAt *time.Time        `json:"-" db:"started_at,omitempty"`
+	StoppedAt *time.Time        `json:"-" db:"stopped_at,omitempty"`
+	// FromStatus makes the update conditional on the Experiment still being in the given status, so that concurrent
+	// transitions can't both succeed
+	FromStatus ExperimentStatus `json:"-" db:"-"`
+	// Toggle is an update to the Experiment's Toggle that's made together with the Experiment's, so that a Toggle
+	// is only ever pointed at a running Experiment
+	Toggle *UpdateToggleReq `json:"-" db:"-"`
+}
+
+// ListExperimentsReq defines the search parameters that will be used when generating a list of experiments
+type ListExperimentsReq struct {
+	AccountID uid.UID          `json:"accountId" db:"account_id"`
+	ToggleID  uid.UID          `json:"toggleId" db:"toggle_id"`
+	Layer     string           `json:"layer" db:"layer"`
+	Status    ExperimentStatus `json:"status" db:"status"`
+}
+
+// An Exposure records the first time a context was served a Variant of a Toggle with a running Experiment
+type Exposure struct {
+	ExperimentID uid.UID   `json:"experimentId" db:"experiment_id"`
+	ContextKey   string    `json:"contextKey" db:"context_key"`
+	Variant      string    `json:"variant" db:"variant"`
+	ExposedAt    time.Time `json:"exposedAt" db:"exposed_at"`
+}
+
+// A MetricEvent reports that something measurable happened for a context, e.g. a purchase. Value defaults to 1 and
+// can carry an amount, like the value of the purchase
+type MetricEvent struct {
+	AccountID  uid.UID   `json:"-" db:"account_id"`
+	Metric     string    `json:"metric" db:"metric"`
+	ContextKey string    `json:"contextKey" db:"context_key"`
+	Value      float64   `json:"value" db:"value"`
+	OccurredAt time.Time `json:"timestamp" db:"occurred_at"`
+}
+
+// A VariantTally counts the contexts exposed to a Variant of an Experiment, how many of them converted and the total
+// Value of the MetricEvents reported for them after they were exposed
+type VariantTally struct {
+	Variant     string  `json:"variant" db:"variant"`
+	Exposures   int64   `json:"exposures" db:"exposures"`
+	Conversions int64   `json:"conversions" db:"conversions"`
+	Value       float64 `json:"value" db:"value"`
+}
+
+// An ExperimentService performs basic CRUD operations on Experiments, and records and tallies what they measure
+type ExperimentService interface {
+	CreateExperiment(ctx context.Context, exp Experiment) (uid.UID, error)
+	UpdateExperiment(ctx context.Context, req UpdateExperimentReq) error
+	FetchExperiment(ctx context.Context, id uid.UID) (Experiment, error)
+	ListExperiments(ctx context.Context, req ListExperimentsReq) ([]Experiment, error)
+	RecordExposures(ctx context.Context, exposures ...Exposure) error
+	RecordMetricEvents(ctx context.Context, events ...MetricEvent) error
+	// TallyExperiment counts MetricEvents up until the Experiment was stopped, or until now if it's still running
+	TallyExperiment(ctx context.Context, exp Experiment) ([]VariantTally, error)
+}
+
+// LayerSlots is the number of slots the traffic of a Layer is split into, so a percent of traffic is a slot
+const LayerSlots = 100
+
+// An Allocation is the part of a running Experiment a Toggle needs to decide which contexts are exposed to it. It's
+// copied from the Experiment and the account's Holdouts when the Experiment starts, so Holdouts created later only
+// apply to Experiments started after them
+type Allocation struct {
+	Layer     string    `json:"layer,omitempty"`
+	SlotStart int       `json:"slotStart"`
+	SlotEnd   int       `json:"slotEnd"`
+	Control   string    `json:"control"`
+	Holdouts  []Holdout `json:"holdouts,omitempty"`
+}
+
+// Value implements the driver.Valuer interface. An empty Allocation is stored as NULL
+func (a Allocation) Value() (driver.Value, error) {
+	if a.Control == "" {
+		return nil, nil
+	}
+
+	return json.Marshal(a)
+}
+
+// Scan implements the sql.Scanner interface
+func (a *Allocation) Scan(src interface{}) error {
+	var source []byte
+	switch val := src.(type) {
+	case string:
+		source = []byte(val)
+	case []byte:
+		source = val
+	case nil:
+		*a = Allocation{}
+		return nil
+	default:
+		return errors.New("incompatible type for Allocation")
+	}
+
+	return json.Unmarshal(source, a)
+}
+
+// A Holdout is a group of contexts in an account, Percent of them in total, that are kept out of every Experiment
+// and always served the Control. It's used to measure the combined effect of all Experiments
+type Holdout struct {
+	ID          uid.UID   `json:"id" db:"id"`
+	AccountID   uid.UID   `json:"accountId" db:"account_id"`
+	Name        string    `json:"name" db:"name"`
+	Description string    `json:"description" db:"description"`
+	Percent     int       `json:"percent" db:"percent"`
+	CreatedAt   time.Time `json:"createdAt" db:"created_at" goqu:"skipinsert,skipupdate"`
+}
+
+// A HoldoutService performs basic CRUD operations on Holdouts
+type HoldoutService interface {
+	CreateHoldout(ctx context.Context, holdout Holdout) (uid.UID, error)
+	FetchHoldout(ctx context.Context, id uid.UID) (Holdout, error)
+	ListHoldouts(ctx context.Context, accountID uid.UID) ([]Holdout, error)
+	DeleteHoldout(ctx context.Context, id uid.UID) error
+}
+
+// An APIKeyKind determines what an APIKey can be used for
+type APIKeyKind string
+
+// Enumeration of possible APIKeyKinds
+const (
+	// APIKeyKindClient keys can only resolve toggles. They're meant to be embedded in browsers and mobile apps,
+	// where they can't be kept secret
+	APIKeyKindClient = APIKeyKind("client")
+	// APIKeyKindServer keys can also download definitions and report events, for SDKs evaluating toggles locally
+	APIKeyKindServer = APIKeyKind("server")
+	// APIKeyKindManagement keys can do everything in their account, including managing toggles and other keys
+	APIKeyKindManagement = APIKeyKind("management")
+)
+
+// An APIKey authenticates requests made on behalf of an account. Only a hash of the secret is stored, so the secret
+// itself is only ever known when the APIKey is created. Prefix is the start of the secret, which is enough to tell
+// keys apart without revealing them. Environment is a free form label, like "production", that tells keys for
+// different deployments apart
+type APIKey struct {
+	ID          uid.UID    `json:"id" db:"id"`
+	AccountID   uid.UID    `json:"accountId" db:"account_id"`
+	Name        string     `json:"name" db:"name"`
+	Environment string     `json:"environment" db:"environment"`
+	Kind        APIKeyKind `json:"kind" db:"kind"`
+	Prefix      string     `json:"prefix" db:"prefix"`
+	Hash        string     `json:"-" db:"hash"`
+	RevokedAt   *time.Time `json:"revokedAt" db:"revoked_at"`
+	CreatedAt   time.Time  `json:"createdAt" db:"created_at" goqu:"skipinsert,skipupdate"`
+}
+
+// Revoked returns true if the APIKey has been revoked
+func (k APIKey) Revoked() bool {
+	return k.RevokedAt != nil
+}
+
+// An APIKeyService performs basic CRUD operations on APIKeys. APIKeys are never deleted, only revoked
+type APIKeyService interface {
+	CreateAPIKey(ctx context.Context, key APIKey) (uid.UID, error)
+	FetchAPIKey(ctx context.Context, id uid.UID) (APIKey, error)
+	FetchAPIKeyByHash(ctx context.Context, hash string) (APIKey, error)
+	ListAPIKeys(ctx context.Context, accountID uid.UID) ([]APIKey, error)
+	RevokeAPIKey(ctx context.Context, id uid.UID) error
 }
 
 // A User represents a single User interacting with Togglr. Users can belong to multiple
-// accounts and a User will be attached to every request to make decisions around authZ
+// accounts and a User will be attached to every request to make decisions around authZ. Users can only access the
+// accounts they belong to, except for admins, who can access every account and manage accounts and Users
 type User struct {
 	ID        uid.UID      `json:"id" db:"id"`
 	Name      string       `json:"name" db:"name"`
 	Email     string       `json:"email" db:"email"`
 	Identity  IdentityType `json:"identity" db:"identity_type"`
+	Admin     bool         `json:"admin" db:"admin"`
+	Version   int          `json:"version" db:"version" goqu:"skipinsert,skipupdate"`
 	CreatedAt time.Time    `json:"createdAt" db:"created_at" goqu:"skipinsert,skipupdate"`
 	UpdatedAt time.Time    `json:"updatedAt" db:"updated_at" goqu:"skipinsert,skipupdate"`
 }
 
+// ListUsersReq defines the search parameters that will be used when generating a list of users
 type ListUsersReq struct {
 	AccountID uid.UID `json:"accountId" db:"account_id"`
+	Page
 }
 
 // UpdateUserReq
 type UpdateUserReq struct {
-	ID   uid.UID `json:"id" db:"-"`
-	Name *string `json:"name,omitempty" db:"name"`
+	ID      uid.UID `json:"id" db:"-"`
+	Version int     `json:"version" db:"-"`
+	Name    *string `json:"name,omitempty" db:"name"`
 }
 
 // A UserService performs basic CRUD operations on Users
@@ -122,9 +731,61 @@ type UserService interface {
 	UpdateUser(ctx context.Context, req UpdateUserReq) error
 	FetchUser(ctx context.Context, id uid.UID) (User, error)
 	ListUsers(ctx context.Context, req ListUsersReq) ([]User, error)
+	// FetchUserByEmail returns ErrNotFound when no User has the email with the given IdentityType
+	FetchUserByEmail(ctx context.Context, email string, identity IdentityType) (User, error)
+	// ListUserAccounts returns the IDs of every account the User belongs to
+	ListUserAccounts(ctx context.Context, userID uid.UID) ([]uid.UID, error)
 	DeleteUser(ctx context.Context, id uid.UID) error
 }
 
+// A PasswordService stores the password hashes of Users with the basic IdentityType. FetchPasswordHash returns
+// ErrNotFound when the User has never set a password
+type PasswordService interface {
+	SetPasswordHash(ctx context.Context, userID uid.UID, hash string) error
+	FetchPasswordHash(ctx context.Context, userID uid.UID) (string, error)
+}
+
+// A Session is created when a User logs in and is referenced by a signed cookie until it expires or the User logs
+// out. Requests made with the cookie that change anything have to repeat the CSRFToken in a header, which other
+// sites can't read
+type Session struct {
+	ID        uid.UID   `json:"id" db:"id"`
+	UserID    uid.UID   `json:"userId" db:"user_id"`
+	CSRFToken string    `json:"csrfToken" db:"csrf_token"`
+	ExpiresAt time.Time `json:"expiresAt" db:"expires_at"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at" goqu:"skipinsert"`
+}
+
+// Expired returns true if the Session can no longer be used
+func (s Session) Expired() bool {
+	return !time.Now().Before(s.ExpiresAt)
+}
+
+// A SessionService stores Sessions. Sessions are never updated, logging in again creates a new one
+type SessionService interface {
+	CreateSession(ctx context.Context, session Session) (uid.UID, error)
+	FetchSession(ctx context.Context, id uid.UID) (Session, error)
+	DeleteSession(ctx context.Context, id uid.UID) error
+	// DeleteUserSessions logs a User out everywhere by deleting all of their Sessions except the one with the keep
+	// ID, which can be null to delete every one of them
+	DeleteUserSessions(ctx context.Context, userID uid.UID, keep uid.UID) error
+}
+
+// An ExternalIdentity is who an IdentityProvider says a User is
+type ExternalIdentity struct {
+	Email string
+	Name  string
+}
+
+// An IdentityProvider logs Users in with an external IdentityType through OAuth2. Users are sent to AuthCodeURL to
+// log in, and Authenticate exchanges the code they come back with for their ExternalIdentity. Authenticate returns
+// ErrInvalidCredentials when the code is rejected or the provider doesn't know a verified email for the User
+type IdentityProvider interface {
+	Identity() IdentityType
+	AuthCodeURL(state string) string
+	Authenticate(ctx context.Context, code string) (ExternalIdentity, error)
+}
+
 // A MetadataKey represents a key that an account has used before. It's primary purpose is
 // populating option lists
 type MetadataKey struct {
@@ -148,7 +809,17 @@ type ResolvedToggles map[string]bool
 // A Resolver returns a map of resolved toggles for a given account
 // using the given Metadata
 type Resolver interface {
-	Resolve(ctx context.Context, accountID uid.UID, metdata rules.Metadata) (ResolvedToggles, error)
+	Resolve(ctx context.Context, accountID uid.UID, metdata rules.Metadata, keys ...string) (ResolvedToggles, error)
+	ResolveOne(ctx context.Context, accountID uid.UID, key string, metadata rules.Metadata) (bool, error)
+	ResolveBatch(ctx context.Context, req ResolveBatchReq, fn func(int, ResolvedToggles) error) error
+}
+
+// A ResolveBatchReq resolves toggles for many sets of Metadata at once. Keys limits the toggles that are resolved,
+// just like the keys given to Resolve
+type ResolveBatchReq struct {
+	AccountID uid.UID
+	Metadata  []rules.Metadata
+	Keys      []string
 }
 
 // A Signer signs and validates the signature of some data. Validating
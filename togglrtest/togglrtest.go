@@ -0,0 +1,366 @@
+// Package togglrtest provides a fake togglr for testing code that checks toggles. A Fake is an in-process server
+// built on the real HTTP routes and Resolver, serving toggles whose values are set by the test. Every evaluation is
+// recorded so tests can assert which toggles were checked, including evaluation events sent by clients
+package togglrtest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/http"
+	"github.com/togglr-io/togglr/rules"
+	"github.com/togglr-io/togglr/uid"
+	"go.uber.org/zap"
+)
+
+// An override sets the value of a toggle for metadata that contains every key and value in match
+type override struct {
+	match map[string]interface{}
+	value bool
+}
+
+// a toggle is everything set on a single key
+type toggle struct {
+	id        uid.UID
+	value     bool
+	payload   togglr.Payload
+	overrides []override
+}
+
+// A Fake serves toggles set by a test through the resolve and definitions endpoints, as well as directly through
+// Resolver. The other endpoints aren't backed by anything. Toggles that haven't been set don't exist
+type Fake struct {
+	tb        testing.TB
+	accountID uid.UID
+	server    *httptest.Server
+	hub       *http.Hub
+	resolver  togglr.Resolver
+
+	mu          sync.Mutex
+	version     int64
+	toggles     map[string]*toggle
+	evaluations []togglr.Evaluation
+}
+
+// New starts a new Fake that's closed when the test finishes
+func New(tb testing.TB) *Fake {
+	f := &Fake{
+		tb:        tb,
+		accountID: uid.New(),
+		hub:       http.NewHub(),
+		toggles:   make(map[string]*toggle),
+	}
+
+	f.resolver = togglr.NewResolver(toggleService{f: f}, f)
+	f.server = httptest.NewServer(http.BuildRoutes(http.Config{
+		Logger: zap.NewNop(),
+		Hub:    f.hub,
+		Services: http.Services{
+			ToggleService:        toggleService{f: f},
+			ConfigVersionService: f,
+			Resolver:             f.resolver,
+			EvaluationRecorder:   f,
+		},
+	}))
+	tb.Cleanup(f.Close)
+
+	return f
+}
+
+// Close shuts down the server
+func (f *Fake) Close() {
+	f.server.Close()
+}
+
+// URL returns the base URL of the server, e.g. for client.Config
+func (f *Fake) URL() string {
+	return f.server.URL
+}
+
+// AccountID returns the account every toggle belongs to
+func (f *Fake) AccountID() uid.UID {
+	return f.accountID
+}
+
+// Resolver returns a Resolver evaluating the Fake's toggles in-process
+func (f *Fake) Resolver() togglr.Resolver {
+	return f.resolver
+}
+
+// change applies a modification to a toggle, creating it if it doesn't exist, and notifies open streams
+func (f *Fake) change(key string, fn func(t *toggle)) {
+	f.mu.Lock()
+	t, ok := f.toggles[key]
+	if !ok {
+		t = &toggle{id: uid.New()}
+		f.toggles[key] = t
+	}
+	fn(t)
+	f.version++
+	f.mu.Unlock()
+
+	f.hub.Notify(f.accountID)
+}
+
+// Set sets the value of a toggle for any metadata that doesn't match one of its overrides
+func (f *Fake) Set(key string, value bool) {
+	f.change(key, func(t *toggle) {
+		t.value = value
+	})
+}
+
+// SetFor overrides the value of a toggle for metadata that contains every key and value in match. When several
+// overrides match, the one set last wins
+func (f *Fake) SetFor(key string, match map[string]interface{}, value bool) {
+	if len(rules.MetaFromRaw(match)) != len(match) {
+		f.tb.Fatalf("override for %s can only match strings, ints, float32s and bools", key)
+	}
+
+	f.change(key, func(t *toggle) {
+		t.overrides = append([]override{{match: match, value: value}}, t.overrides...)
+	})
+}
+
+// SetPayload sets the Payload a toggle serves while it's on. The payload is marshalled to JSON
+func (f *Fake) SetPayload(key string, payload interface{}) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		f.tb.Fatalf("failed to marshal payload for %s: %s", key, err)
+	}
+
+	f.change(key, func(t *toggle) {
+		t.payload = data
+	})
+}
+
+// Remove deletes a toggle, so that it no longer exists
+func (f *Fake) Remove(key string) {
+	f.mu.Lock()
+	delete(f.toggles, key)
+	f.version++
+	f.mu.Unlock()
+
+	f.hub.Notify(f.accountID)
+}
+
+// RecordEvaluation implements the togglr.EvaluationRecorder interface
+func (f *Fake) RecordEvaluation(eval togglr.Evaluation) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.evaluations = append(f.evaluations, eval)
+}
+
+// Evaluations returns every evaluation made so far, prerequisites included. Toggles evaluated locally by a client are
+// only included once the client has sent its events, and without their prerequisites
+func (f *Fake) Evaluations() []togglr.Evaluation {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]togglr.Evaluation{}, f.evaluations...)
+}
+
+// Evaluated returns how many times a toggle has been evaluated
+func (f *Fake) Evaluated(key string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	count := 0
+	for _, eval := range f.evaluations {
+		if eval.Key == key {
+			count++
+		}
+	}
+
+	return count
+}
+
+// AssertEvaluated fails the test if a toggle hasn't been evaluated
+func (f *Fake) AssertEvaluated(key string) {
+	f.tb.Helper()
+	if f.Evaluated(key) == 0 {
+		f.tb.Errorf("expected toggle %s to be evaluated", key)
+	}
+}
+
+// AssertNotEvaluated fails the test if a toggle has been evaluated
+func (f *Fake) AssertNotEvaluated(key string) {
+	f.tb.Helper()
+	if count := f.Evaluated(key); count > 0 {
+		f.tb.Errorf("expected toggle %s not to be evaluated, but it was evaluated %d times", key, count)
+	}
+}
+
+// ResetEvaluations forgets every evaluation made so far
+func (f *Fake) ResetEvaluations() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.evaluations = nil
+}
+
+// FetchConfigVersion implements the togglr.ConfigVersionService interface. The version is bumped by every change
+func (f *Fake) FetchConfigVersion(ctx context.Context, accountID uid.UID) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.version, nil
+}
+
+// list returns every toggle as a togglr.Toggle
+func (f *Fake) list() []togglr.Toggle {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	toggles := make([]togglr.Toggle, 0, len(f.toggles))
+	for key, t := range f.toggles {
+		toggles = append(toggles, t.toggle(f.accountID, key))
+	}
+
+	return toggles
+}
+
+// fetch returns a single toggle as a togglr.Toggle
+func (f *Fake) fetch(key string) (togglr.Toggle, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t, ok := f.toggles[key]
+	if !ok {
+		return togglr.Toggle{}, false
+	}
+
+	return t.toggle(f.accountID, key), true
+}
+
+// toggle builds a togglr.Toggle whose rules produce the values that have been set, so that it evaluates the same
+// through the Resolver and through clients evaluating definitions locally
+func (t *toggle) toggle(accountID uid.UID, key string) togglr.Toggle {
+	return togglr.Toggle{
+		ID:        t.id,
+		AccountID: accountID,
+		Key:       key,
+		Active:    true,
+		Payload:   t.payload,
+		Rules: rules.Rules{
+			{Op: rules.BinOpAnd, Expr: rules.ExpressionFromExpr(overrideExpr(t.overrides, t.value))},
+		},
+	}
+}
+
+// overrideExpr builds an expression that evaluates to the value of the first matching override, or def if none of
+// them match. Rules have no negation, so an override not matching is expressed as any of its values differing
+func overrideExpr(overrides []override, def bool) rules.Expr {
+	if len(overrides) == 0 {
+		return rules.NewBool(def)
+	}
+
+	var matches, differs rules.Expr = rules.NewBool(true), rules.NewBool(false)
+	for key, value := range rules.MetaFromRaw(overrides[0].match) {
+		// every Comparable is also an Expr that evaluates to itself
+		expr := value.(rules.Expr)
+		matches = rules.NewBinary(matches, rules.NewBinary(rules.NewIdent(key), expr, rules.BinOpEq), rules.BinOpAnd)
+		differs = rules.NewBinary(differs, rules.NewBinary(rules.NewIdent(key), expr, rules.BinOpNotEq), rules.BinOpOr)
+	}
+
+	return rules.NewBinary(
+		rules.NewBinary(matches, rules.NewBool(overrides[0].value), rules.BinOpAnd),
+		rules.NewBinary(differs, overrideExpr(overrides[1:], def), rules.BinOpAnd),
+		rules.BinOpOr,
+	)
+}
+
+// errReadOnly is returned when something other than the Fake tries to change its toggles
+var errReadOnly = errors.New("togglrtest: toggles can only be changed through the Fake")
+
+// toggleService serves a Fake's toggles to the Resolver and the definitions endpoint. Toggles can only be changed
+// through the Fake, so every write fails with errReadOnly
+type toggleService struct {
+	f *Fake
+}
+
+// CreateToggle implements the togglr.ToggleService interface
+func (s toggleService) CreateToggle(ctx context.Context, toggle togglr.Toggle) (uid.UID, error) {
+	return uid.UID{}, errReadOnly
+}
+
+// UpdateToggle implements the togglr.ToggleService interface
+func (s toggleService) UpdateToggle(ctx context.Context, req togglr.UpdateToggleReq) error {
+	return errReadOnly
+}
+
+// FetchToggle implements the togglr.ToggleService interface
+func (s toggleService) FetchToggle(ctx context.Context, id uid.UID) (togglr.Toggle, error) {
+	for _, toggle := range s.f.list() {
+		if toggle.ID.Equals(id) {
+			return toggle, nil
+		}
+	}
+
+	return togglr.Toggle{}, togglr.ErrNotFound
+}
+
+// ListToggles implements the togglr.ToggleService interface
+func (s toggleService) ListToggles(ctx context.Context, req togglr.ListTogglesReq) ([]togglr.Toggle, error) {
+	// toggles are never archived
+	if req.Archived {
+		return []togglr.Toggle{}, nil
+	}
+
+	return s.f.list(), nil
+}
+
+// FetchToggleByKey implements the togglr.ToggleService interface
+func (s toggleService) FetchToggleByKey(ctx context.Context, accountID uid.UID, key string) (togglr.Toggle, error) {
+	toggle, ok := s.f.fetch(key)
+	if !ok {
+		return toggle, togglr.ErrNotFound
+	}
+
+	return toggle, nil
+}
+
+// ListDependents implements the togglr.ToggleService interface
+func (s toggleService) ListDependents(ctx context.Context, accountID uid.UID, key string) ([]togglr.Toggle, error) {
+	dependents := []togglr.Toggle{}
+	for _, toggle := range s.f.list() {
+		for _, prerequisite := range toggle.Prerequisites {
+			if prerequisite == key {
+				dependents = append(dependents, toggle)
+				break
+			}
+		}
+	}
+
+	return dependents, nil
+}
+
+// DeleteToggle implements the togglr.ToggleService interface
+func (s toggleService) DeleteToggle(ctx context.Context, id uid.UID) error {
+	return errReadOnly
+}
+
+// ArchiveToggle implements the togglr.ToggleService interface
+func (s toggleService) ArchiveToggle(ctx context.Context, id uid.UID) error {
+	return errReadOnly
+}
+
+// RestoreToggle implements the togglr.ToggleService interface
+func (s toggleService) RestoreToggle(ctx context.Context, id uid.UID) error {
+	return errReadOnly
+}
+
+// ListToggleRevisions implements the togglr.ToggleService interface. The Fake doesn't keep any history
+func (s toggleService) ListToggleRevisions(ctx context.Context, toggleID uid.UID) ([]togglr.ToggleRevision, error) {
+	if _, err := s.FetchToggle(ctx, toggleID); err != nil {
+		return nil, err
+	}
+
+	return []togglr.ToggleRevision{}, nil
+}
+
+// RollbackToggle implements the togglr.ToggleService interface
+func (s toggleService) RollbackToggle(ctx context.Context, toggleID uid.UID, revision int) error {
+	return errReadOnly
+}
@@ -0,0 +1,137 @@
+package togglrtest_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/client"
+	"github.com/togglr-io/togglr/rules"
+	"github.com/togglr-io/togglr/togglrtest"
+)
+
+func Test_FakeResolver(t *testing.T) {
+	// SETUP
+	ctx := context.TODO()
+	fake := togglrtest.New(t)
+	fake.Set("feature", false)
+	fake.SetFor("feature", map[string]interface{}{"userType": "admin"}, true)
+	fake.SetFor("feature", map[string]interface{}{"userType": "admin", "banned": true}, false)
+	fake.Set("unused", true)
+	resolver := fake.Resolver()
+
+	cases := []struct {
+		md       rules.Metadata
+		expected bool
+	}{
+		{rules.Metadata{}, false},
+		{rules.Metadata{"userType": rules.NewString("user")}, false},
+		{rules.Metadata{"userType": rules.NewString("admin")}, true},
+		{rules.Metadata{"userType": rules.NewString("admin"), "banned": rules.NewBool(true)}, false},
+		{rules.Metadata{"userType": rules.NewString("admin"), "banned": rules.NewBool(false)}, true},
+	}
+
+	// RUN
+	for _, c := range cases {
+		value, err := resolver.ResolveOne(ctx, fake.AccountID(), "feature", c.md)
+		if err != nil {
+			t.Fatalf("failed to resolve toggle: %s", err)
+		}
+
+		if value != c.expected {
+			t.Fatalf("expected %v to resolve to %t", c.md, c.expected)
+		}
+	}
+
+	if fake.Evaluated("feature") != len(cases) {
+		t.Fatalf("expected feature to be evaluated %d times, got %d", len(cases), fake.Evaluated("feature"))
+	}
+	fake.AssertNotEvaluated("unused")
+
+	if _, err := resolver.ResolveOne(ctx, fake.AccountID(), "missing", rules.Metadata{}); err != togglr.ErrNotFound {
+		t.Fatalf("expected toggles that were never set not to exist, got %v", err)
+	}
+}
+
+func Test_FakeServer(t *testing.T) {
+	// SETUP
+	fake := togglrtest.New(t)
+	fake.SetFor("feature", map[string]interface{}{"userType": "admin"}, true)
+	fake.SetPayload("feature", "hello")
+
+	// RUN
+	url := fmt.Sprintf("%s/resolve/%s", fake.URL(), fake.AccountID())
+	res, err := http.Post(url, "application/json", strings.NewReader(`{"userType":"admin"}`))
+	if err != nil {
+		t.Fatalf("failed to send request: %s", err)
+	}
+	defer res.Body.Close()
+
+	var resolved togglr.ResolvedToggles
+	if err := json.NewDecoder(res.Body).Decode(&resolved); err != nil {
+		t.Fatalf("failed to decode response: %s", err)
+	}
+
+	if !resolved["feature"] {
+		t.Fatalf("expected feature to resolve to true over HTTP")
+	}
+	fake.AssertEvaluated("feature")
+
+	// clients evaluate the same definitions locally
+	c := client.New(client.Config{BaseURL: fake.URL(), AccountID: fake.AccountID()})
+	if err := c.Refresh(context.TODO()); err != nil {
+		t.Fatalf("failed to refresh client: %s", err)
+	}
+
+	if c.String("feature", rules.Metadata{"userType": rules.NewString("admin")}, "") != "hello" {
+		t.Fatalf("expected the client to evaluate the payload")
+	}
+
+	if c.Bool("feature", rules.Metadata{"userType": rules.NewString("user")}, true) {
+		t.Fatalf("expected the client to evaluate the override")
+	}
+}
+
+func Test_FakeClientEvents(t *testing.T) {
+	// SETUP
+	fake := togglrtest.New(t)
+	fake.Set("feature", true)
+	c := client.New(client.Config{BaseURL: fake.URL(), AccountID: fake.AccountID(), SendEvents: true})
+	if err := c.Refresh(context.TODO()); err != nil {
+		t.Fatalf("failed to refresh client: %s", err)
+	}
+
+	// RUN
+	c.Bool("feature", rules.Metadata{togglr.ContextKeyName: rules.NewString("user-1")}, false)
+	c.Bool("missing", rules.Metadata{}, false)
+	fake.AssertNotEvaluated("feature")
+
+	// stopping the client sends whatever events are left
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		c.Run(ctx)
+		close(done)
+	}()
+	cancel()
+	<-done
+
+	deadline := time.Now().Add(5 * time.Second)
+	for fake.Evaluated("feature") == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	evals := fake.Evaluations()
+	if len(evals) != 1 {
+		t.Fatalf("expected only the toggle that exists to be recorded, got %d evaluations", len(evals))
+	}
+
+	if !evals[0].Value || evals[0].Reason != togglr.ReasonTargetingMatch || evals[0].ContextKey != "user-1" {
+		t.Fatalf("expected the client's evaluation to be recorded, got %+v", evals[0])
+	}
+}
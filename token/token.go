@@ -0,0 +1,71 @@
+// Package token issues and verifies signed tokens carrying resolved toggles. Tokens can be verified offline by any
+// service holding one of the signing keys, without calling back to togglr
+package token
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/hmac"
+	"github.com/togglr-io/togglr/uid"
+)
+
+// tokens are signed for their own purpose so that nothing else signed with the same keys can be passed off as one
+const purpose = "token"
+
+var (
+	// ErrMalformed is returned when a token can't be decoded
+	ErrMalformed = errors.New("malformed token")
+	// ErrExpired is returned when a token is past its expiry
+	ErrExpired = errors.New("token expired")
+)
+
+// Claims are the payload of a token
+type Claims struct {
+	AccountID uid.UID                `json:"accountId"`
+	Toggles   togglr.ResolvedToggles `json:"toggles"`
+	IssuedAt  time.Time              `json:"issuedAt"`
+	ExpiresAt time.Time              `json:"expiresAt"`
+}
+
+// Issue signs some Claims, returning them as a URL-safe string
+func Issue(signer togglr.Signer, claims Claims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signed, err := hmac.WithPurpose(signer, purpose).Sign(payload)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(signed), nil
+}
+
+// Verify checks the signature and expiry of a token, returning its Claims if it's valid at the given time
+func Verify(signer togglr.Signer, token string, now time.Time) (Claims, error) {
+	signed, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return Claims{}, ErrMalformed
+	}
+
+	payload, err := hmac.WithPurpose(signer, purpose).Validate(signed)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return Claims{}, ErrMalformed
+	}
+
+	if !now.Before(claims.ExpiresAt) {
+		return Claims{}, ErrExpired
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,48 @@
+package token_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/togglr-io/togglr"
+	"github.com/togglr-io/togglr/hmac"
+	"github.com/togglr-io/togglr/token"
+	"github.com/togglr-io/togglr/uid"
+)
+
+func Test_Token(t *testing.T) {
+	signer := hmac.NewSigner("testing")
+	now := time.Now()
+	claims := token.Claims{
+		AccountID: uid.New(),
+		Toggles:   togglr.ResolvedToggles{"feature": true},
+		IssuedAt:  now,
+		ExpiresAt: now.Add(time.Minute),
+	}
+
+	signed, err := token.Issue(signer, claims)
+	if err != nil {
+		t.Fatalf("failed to issue token: %s", err)
+	}
+
+	verified, err := token.Verify(signer, signed, now)
+	if err != nil {
+		t.Fatalf("failed to verify token: %s", err)
+	}
+
+	if !verified.AccountID.Equals(claims.AccountID) || !verified.Toggles["feature"] {
+		t.Fatalf("expected the issued claims back, got %+v", verified)
+	}
+
+	if _, err := token.Verify(signer, signed, now.Add(time.Minute)); err != token.ErrExpired {
+		t.Fatalf("expected an expired token, got %v", err)
+	}
+
+	if _, err := token.Verify(hmac.NewSigner("other"), signed, now); err != hmac.ErrInvalidSignature {
+		t.Fatalf("expected an invalid signature, got %v", err)
+	}
+
+	if _, err := token.Verify(signer, "not a token!", now); err != token.ErrMalformed {
+		t.Fatalf("expected a malformed token, got %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package togglr
+
+import (
+	"context"
+	"time"
+
+	"github.com/togglr-io/togglr/uid"
+	"go.uber.org/zap"
+)
+
+// the number of evaluations that can be waiting to be aggregated before new ones are dropped
+const usageBufferSize = 10000
+
+// An Evaluation captures the outcome of evaluating a single Toggle
+type Evaluation struct {
+	AccountID uid.UID
+	ToggleID  uid.UID
+	Key       string
+	Value     bool
+	Reason    Reason
+	// ContextKey identifies who the Toggle was evaluated for, taken from the ContextKeyName metadata. It's empty
+	// when the metadata doesn't have one
+	ContextKey string
+	// VariantKey is the key of the Variant assigned when the Toggle has Variants, and ExperimentID is the
+	// Experiment that was running on it at the time, if any
+	VariantKey   string
+	ExperimentID uid.UID
+	Time         time.Time
+}
+
+// Variant returns the variant the Toggle resolved to, which is either its assigned Variant or on or off
+func (e Evaluation) Variant() string {
+	if e.VariantKey != "" {
+		return e.VariantKey
+	}
+
+	if e.Value {
+		return VariantOn
+	}
+
+	return VariantOff
+}
+
+// An EvaluationRecorder is notified of every Toggle evaluated by a Resolver. Recording happens in the request path,
+// so implementations must never block
+type EvaluationRecorder interface {
+	RecordEvaluation(eval Evaluation)
+}
+
+// A UsageRecorder is an EvaluationRecorder that aggregates evaluations in memory and periodically flushes them to a
+// UsageService as ToggleUsage
+type UsageRecorder struct {
+	batchRecorder
+	us      UsageService
+	pending map[uid.UID]ToggleUsage
+}
+
+// NewUsageRecorder returns a new UsageRecorder that flushes to the given UsageService every interval. Run must be
+// called for anything to be flushed
+func NewUsageRecorder(us UsageService, interval time.Duration, logger *zap.Logger) *UsageRecorder {
+	r := &UsageRecorder{
+		us:      us,
+		pending: make(map[uid.UID]ToggleUsage),
+	}
+	r.batchRecorder = newBatchRecorder(usageBufferSize, interval, r.merge, r.flushUsage, logger)
+
+	return r
+}
+
+func (r *UsageRecorder) merge(eval Evaluation) {
+	r.pending[eval.ToggleID] = mergeEvaluation(r.pending[eval.ToggleID], eval)
+}
+
+func (r *UsageRecorder) flushUsage(ctx context.Context) {
+	if len(r.pending) == 0 {
+		return
+	}
+
+	usage := make([]ToggleUsage, 0, len(r.pending))
+	for _, u := range r.pending {
+		usage = append(usage, u)
+	}
+	r.pending = make(map[uid.UID]ToggleUsage)
+
+	if err := r.us.RecordUsage(ctx, usage...); err != nil {
+		r.log.Error("failed to record toggle usage", zap.Error(err), zap.Int("toggles", len(usage)))
+	}
+}
+
+// mergeEvaluation folds an Evaluation into the aggregated usage for its Toggle
+func mergeEvaluation(usage ToggleUsage, eval Evaluation) ToggleUsage {
+	usage.ToggleID = eval.ToggleID
+	usage.AccountID = eval.AccountID
+	if eval.Time.After(usage.LastEvaluatedAt) {
+		usage.LastEvaluatedAt = eval.Time
+	}
+
+	at := eval.Time
+	if eval.Value {
+		if usage.LastTrueAt == nil || at.After(*usage.LastTrueAt) {
+			usage.LastTrueAt = &at
+		}
+	} else {
+		if usage.LastFalseAt == nil || at.After(*usage.LastFalseAt) {
+			usage.LastFalseAt = &at
+		}
+	}
+
+	return usage
+}